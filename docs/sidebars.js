@@ -46,11 +46,13 @@ const sidebars = {
           },
           items: [
             "configuration/providers/adfs",
+            "configuration/providers/apple",
             "configuration/providers/azure",
             "configuration/providers/bitbucket",
             "configuration/providers/cidaas",
             "configuration/providers/cisco_duo",
             "configuration/providers/digitalocean",
+            "configuration/providers/discord",
             "configuration/providers/facebook",
             "configuration/providers/gitea",
             "configuration/providers/github",
@@ -61,6 +63,7 @@ const sidebars = {
             "configuration/providers/linkedin",
             "configuration/providers/login_gov",
             "configuration/providers/ms_entra_id",
+            "configuration/providers/mock",
             "configuration/providers/nextcloud",
             "configuration/providers/openid_connect",
             "configuration/providers/sourcehut"
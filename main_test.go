@@ -161,17 +161,24 @@ redirect_url="http://localhost:4180/oauth2/callback"
 
 		opts.Providers = options.Providers{
 			options.Provider{
-				ID:                       "google=oauth2-proxy",
-				Type:                     "google",
-				ClientSecret:             "b2F1dGgyLXByb3h5LWNsaWVudC1zZWNyZXQK",
-				ClientID:                 "oauth2-proxy",
-				UseSystemTrustStore:      ptr.To(false),
-				SkipClaimsFromProfileURL: ptr.To(false),
+				ID:                                 "google=oauth2-proxy",
+				Type:                               "google",
+				ClientSecret:                       "b2F1dGgyLXByb3h5LWNsaWVudC1zZWNyZXQK",
+				ClientID:                           "oauth2-proxy",
+				UseSystemTrustStore:                ptr.To(false),
+				SkipClaimsFromProfileURL:           ptr.To(false),
+				DeviceAuthorizationGrant:           ptr.To(false),
+				RequirePushedAuthorizationRequests: ptr.To(false),
+				TokenIntrospection:                 ptr.To(false),
+				IntrospectionCacheTTL:              ptr.To(options.DefaultIntrospectionCacheTTL),
+				RevalidateInterval:                 ptr.To(options.DefaultRevalidateInterval),
 				GoogleConfig: options.GoogleOptions{
 					AdminEmail:                       "admin@example.com",
 					TargetPrincipal:                  "principal",
 					UseOrganizationID:                ptr.To(false),
 					UseApplicationDefaultCredentials: ptr.To(false),
+					TransitiveGroups:                 ptr.To(false),
+					GroupMembershipCacheTTL:          ptr.To(options.DefaultGoogleGroupMembershipCacheTTL),
 				},
 				AzureConfig: options.AzureOptions{
 					Tenant: "common",
@@ -186,9 +193,12 @@ redirect_url="http://localhost:4180/oauth2/callback"
 					InsecureAllowUnverifiedEmail:   ptr.To(false),
 					InsecureSkipIssuerVerification: ptr.To(false),
 					SkipDiscovery:                  ptr.To(false),
+					RPInitiatedLogout:              ptr.To(false),
 				},
 				MicrosoftEntraIDConfig: options.MicrosoftEntraIDOptions{
 					FederatedTokenAuth: ptr.To(false),
+					GroupNameCacheTTL:  ptr.To(options.DefaultMicrosoftEntraIDGroupNameCacheTTL),
+					AppRolesAsGroups:   ptr.To(false),
 				},
 				ADFSConfig: options.ADFSOptions{
 					SkipScope: ptr.To(false),
@@ -13,6 +13,7 @@ import (
 	"os"
 	"os/signal"
 	"regexp"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -25,20 +26,25 @@ import (
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/apikey"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/basic"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/proxyhttp"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/version"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/middleware"
+	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/upstream"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+	"golang.org/x/oauth2"
 )
 
 const (
@@ -46,14 +52,29 @@ const (
 	schemeHTTPS     = "https"
 	applicationJSON = "application/json"
 
-	robotsPath        = "/robots.txt"
-	signInPath        = "/sign_in"
-	signOutPath       = "/sign_out"
-	oauthStartPath    = "/start"
-	oauthCallbackPath = "/callback"
-	authOnlyPath      = "/auth"
-	userInfoPath      = "/userinfo"
-	staticPathPrefix  = "/static/"
+	robotsPath            = "/robots.txt"
+	signInPath            = "/sign_in"
+	signOutPath           = "/sign_out"
+	signOutCallbackPath   = "/sign_out/callback"
+	oauthStartPath        = "/start"
+	oauthCallbackPath     = "/callback"
+	samlMetadataPath      = "/saml/metadata"
+	samlACSPath           = "/saml/acs"
+	backchannelLogoutPath = "/backchannel-logout"
+	deviceStartPath       = "/device/start"
+	devicePollPath        = "/device/poll"
+	authOnlyPath          = "/auth"
+	userInfoPath          = "/userinfo"
+	staticPathPrefix      = "/static/"
+	mockPathPrefix        = "/mock/"
+
+	// backendLogoutTimeout bounds the server side call to the backend logout URL
+	backendLogoutTimeout = 10 * time.Second
+
+	// deviceResponseSession and deviceResponseTokens select whether the device
+	// poll endpoint returns a session cookie or the provider tokens
+	deviceResponseSession = "session"
+	deviceResponseTokens  = "tokens"
 )
 
 var (
@@ -91,6 +112,10 @@ type OAuthProxy struct {
 	relativeRedirectURL  bool
 	whitelistDomains     []string
 	provider             providers.Provider
+	providerID           string
+	providerIDs          []string
+	providersByID        map[string]providers.Provider
+	loginDomains         map[string][]string
 	sessionStore         sessionsapi.SessionStore
 	ProxyPrefix          string
 	basicAuthValidator   basic.Validator
@@ -108,7 +133,7 @@ type OAuthProxy struct {
 	preAuthChain      alice.Chain
 	pageWriter        pagewriter.Writer
 	server            proxyhttp.Server
-	upstreamProxy     http.Handler
+	upstreamProxy     upstream.Proxy
 	serveMux          *mux.Router
 	redirectValidator redirect.Validator
 	appDirector       redirect.AppDirector
@@ -123,37 +148,64 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		return nil, fmt.Errorf("error initialising session store: %v", err)
 	}
 
-	var basicAuthValidator basic.Validator
+	var basicAuthValidators []basic.Validator
 	if opts.HtpasswdFile != "" {
 		logger.Printf("using htpasswd file: %s", opts.HtpasswdFile)
-		var err error
-		basicAuthValidator, err = basic.NewHTPasswdValidator(opts.HtpasswdFile)
+		htpasswdValidator, err := basic.NewHTPasswdValidator(opts.HtpasswdFile)
 		if err != nil {
 			return nil, fmt.Errorf("could not validate htpasswd: %v", err)
 		}
+		basicAuthValidators = append(basicAuthValidators, htpasswdValidator)
+	}
+	if opts.LDAP.URL != "" {
+		logger.Printf("using LDAP server: %s", opts.LDAP.URL)
+		ldapValidator, err := basic.NewLDAPValidator(opts.LDAP)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialise LDAP: %v", err)
+		}
+		basicAuthValidators = append(basicAuthValidators, ldapValidator)
+	}
+	var basicAuthValidator basic.Validator
+	if len(basicAuthValidators) > 0 {
+		basicAuthValidator = basic.NewMultiValidator(basicAuthValidators...)
+	}
+
+	var apiKeyStore *apikey.Store
+	if opts.APIKeys.Enabled() {
+		apiKeyStore, err = apikey.NewStore(opts.APIKeys)
+		if err != nil {
+			return nil, fmt.Errorf("could not load API keys: %v", err)
+		}
 	}
 
-	provider, err := providers.NewProvider(opts.Providers[0])
+	providersByID, err := buildProviders(opts.Providers)
 	if err != nil {
 		return nil, fmt.Errorf("error initialising provider: %v", err)
 	}
+	// The first configured provider is the default provider
+	provider := providersByID[opts.Providers[0].ID]
+
+	providerIDs, loginDomains := buildLoginDomains(opts.Providers)
 
 	pageWriter, err := pagewriter.NewWriter(pagewriter.Opts{
-		TemplatesPath:    opts.Templates.Path,
-		CustomLogo:       opts.Templates.CustomLogo,
-		ProxyPrefix:      opts.ProxyPrefix,
-		Footer:           opts.Templates.Footer,
-		Version:          version.VERSION,
-		Debug:            opts.Templates.Debug,
-		ProviderName:     buildProviderName(provider, opts.Providers[0].Name),
-		SignInMessage:    buildSignInMessage(opts),
-		DisplayLoginForm: basicAuthValidator != nil && opts.Templates.DisplayLoginForm,
+		TemplatesPath:         opts.Templates.Path,
+		CustomLogo:            opts.Templates.CustomLogo,
+		ProxyPrefix:           opts.ProxyPrefix,
+		Footer:                opts.Templates.Footer,
+		Version:               version.VERSION,
+		Debug:                 opts.Templates.Debug,
+		ProviderName:          buildProviderName(provider, opts.Providers[0].Name),
+		Providers:             buildSignInProviders(opts.Providers, providersByID),
+		DisplayEmailDiscovery: len(loginDomains) > 0,
+		SkipProviderButton:    opts.SkipProviderButton,
+		SignInMessage:         buildSignInMessage(opts),
+		DisplayLoginForm:      basicAuthValidator != nil && opts.Templates.DisplayLoginForm,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error initialising page writer: %v", err)
 	}
 
-	upstreamProxy, err := upstream.NewProxy(opts.UpstreamServers, opts.GetSignatureData(), pageWriter)
+	upstreamProxy, err := upstream.NewProxy(opts.UpstreamServers, opts.GetSignatureData(), pageWriter, newUpstreamTokenExchanger(providersByID, provider))
 	if err != nil {
 		return nil, fmt.Errorf("error initialising upstream proxy: %v", err)
 	}
@@ -173,7 +225,9 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		redirectURL.Path = fmt.Sprintf("%s/callback", opts.ProxyPrefix)
 	}
 
-	logger.Printf("OAuthProxy configured for %s Client ID: %s", provider.Data().ProviderName, opts.Providers[0].ClientID)
+	for _, providerConfig := range opts.Providers {
+		logger.Printf("OAuthProxy configured for %s Client ID: %s", providersByID[providerConfig.ID].Data().ProviderName, providerConfig.ClientID)
+	}
 	refresh := "disabled"
 	if opts.Cookie.Refresh != time.Duration(0) {
 		refresh = fmt.Sprintf("after %s", opts.Cookie.Refresh)
@@ -204,7 +258,7 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 	if err != nil {
 		return nil, fmt.Errorf("could not build pre-auth chain: %v", err)
 	}
-	sessionChain := buildSessionChain(opts, provider, sessionStore, basicAuthValidator)
+	sessionChain := buildSessionChain(opts, providersByID, sessionStore, basicAuthValidator, apiKeyStore)
 	headersChain, err := buildHeadersChain(opts)
 	if err != nil {
 		return nil, fmt.Errorf("could not build headers chain: %v", err)
@@ -224,6 +278,10 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 
 		ProxyPrefix:          opts.ProxyPrefix,
 		provider:             provider,
+		providerID:           opts.Providers[0].ID,
+		providerIDs:          providerIDs,
+		providersByID:        providersByID,
+		loginDomains:         loginDomains,
 		sessionStore:         sessionStore,
 		redirectURL:          redirectURL,
 		relativeRedirectURL:  opts.RelativeRedirectURL,
@@ -341,6 +399,12 @@ func (p *OAuthProxy) buildProxySubrouter(s *mux.Router) {
 	s.Path(signInPath).HandlerFunc(p.SignIn)
 	s.Path(oauthStartPath).HandlerFunc(p.OAuthStart)
 	s.Path(oauthCallbackPath).HandlerFunc(p.OAuthCallback)
+	s.Path(samlMetadataPath).HandlerFunc(p.SAMLMetadata)
+	s.Path(samlACSPath).HandlerFunc(p.SAMLAssertionConsumer)
+	s.Path(backchannelLogoutPath).HandlerFunc(p.BackchannelLogout)
+	s.Path(deviceStartPath).HandlerFunc(p.DeviceStart)
+	s.Path(devicePollPath).HandlerFunc(p.DevicePoll)
+	s.PathPrefix(mockPathPrefix).HandlerFunc(p.MockProvider)
 
 	// Static file paths
 	s.PathPrefix(staticPathPrefix).Handler(http.StripPrefix(p.ProxyPrefix, http.FileServer(http.FS(staticFiles))))
@@ -348,6 +412,7 @@ func (p *OAuthProxy) buildProxySubrouter(s *mux.Router) {
 	// The userinfo and logout endpoints needs to load sessions before handling the request
 	s.Path(userInfoPath).Handler(p.sessionChain.ThenFunc(p.UserInfo))
 	s.Path(signOutPath).Handler(p.sessionChain.ThenFunc(p.SignOut))
+	s.Path(signOutCallbackPath).HandlerFunc(p.SignOutCallback)
 }
 
 // buildPreAuthChain constructs a chain that should process every request before
@@ -393,21 +458,92 @@ func buildPreAuthChain(opts *options.Options, sessionStore sessionsapi.SessionSt
 	return chain, nil
 }
 
-func buildSessionChain(opts *options.Options, provider providers.Provider, sessionStore sessionsapi.SessionStore, validator basic.Validator) alice.Chain {
+// newUpstreamTokenExchanger exchanges the access token of a session at the
+// provider that created it, or at the default provider for sessions without
+// a provider ID such as sessions loaded from bearer tokens.
+func newUpstreamTokenExchanger(providersByID map[string]providers.Provider, defaultProvider providers.Provider) upstream.TokenExchanger {
+	return func(ctx context.Context, session *sessionsapi.SessionState, exchange options.TokenExchange) (*oauth2.Token, error) {
+		provider := defaultProvider
+		if session.ProviderID != "" {
+			var ok bool
+			provider, ok = providersByID[session.ProviderID]
+			if !ok {
+				return nil, fmt.Errorf("%w: session was created by unknown provider %q", upstream.ErrTokenExchangeDenied, session.ProviderID)
+			}
+		}
+
+		token, err := provider.Data().ExchangeToken(ctx, session.AccessToken, exchange.Audience, exchange.Resource, exchange.Scopes)
+		var tokenErr *providers.TokenError
+		if errors.As(err, &tokenErr) {
+			switch tokenErr.Code {
+			case "access_denied", "invalid_grant", "invalid_scope", "invalid_target", "unauthorized_client":
+				// The provider refuses to issue a token for this user or upstream
+				return nil, fmt.Errorf("%w: %v", upstream.ErrTokenExchangeDenied, err)
+			}
+		}
+		return token, err
+	}
+}
+
+// buildProviders initialises every configured provider, keyed by provider ID.
+func buildProviders(providerConfigs options.Providers) (map[string]providers.Provider, error) {
+	providersByID := make(map[string]providers.Provider, len(providerConfigs))
+	for _, providerConfig := range providerConfigs {
+		provider, err := providers.NewProvider(providerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("provider %q: %v", providerConfig.ID, err)
+		}
+		providersByID[providerConfig.ID] = provider
+	}
+	return providersByID, nil
+}
+
+func buildSessionChain(opts *options.Options, providersByID map[string]providers.Provider, sessionStore sessionsapi.SessionStore, validator basic.Validator, apiKeyStore *apikey.Store) alice.Chain {
 	chain := alice.New()
 
+	// Sessions without a provider ID were created by the default provider
+	sessionProvider := func(s *sessionsapi.SessionState) (providers.Provider, bool) {
+		id := s.ProviderID
+		if id == "" {
+			id = opts.Providers[0].ID
+		}
+		provider, ok := providersByID[id]
+		return provider, ok
+	}
+
+	// API keys are loaded first, as without the bearer token login fallback
+	// the JWT session loader denies the ApiKey scheme of the Authorization header
+	if apiKeyStore != nil {
+		chain = chain.Append(middleware.NewAPIKeySessionLoader(apiKeyStore, opts.APIKeys.Header))
+	}
+
 	if opts.SkipJwtBearerTokens {
 		verifiers := opts.GetJWTBearerVerifiers()
 
-		sessionLoaders := make([]middlewareapi.TokenToSessionFunc, 0, len(verifiers)+1)
-		sessionLoaders = append(sessionLoaders, provider.CreateSessionFromToken)
+		sessionLoaders := make([]middlewareapi.TokenToSessionFunc, 0, len(verifiers)+len(opts.Providers))
+		for _, providerConfig := range opts.Providers {
+			sessionLoaders = append(sessionLoaders, createProviderSessionFromToken(providersByID[providerConfig.ID]))
+		}
 
 		for _, verifier := range opts.GetJWTBearerVerifiers() {
 			sessionLoaders = append(sessionLoaders,
 				middlewareapi.CreateTokenToSessionFunc(verifier.Verify))
 		}
 
-		chain = chain.Append(middleware.NewJwtSessionLoader(sessionLoaders, opts.BearerTokenLoginFallback))
+		opaqueTokenLoaders := []middlewareapi.TokenToSessionFunc{}
+		for _, providerConfig := range opts.Providers {
+			if ptr.Deref(providerConfig.TokenIntrospection, options.DefaultTokenIntrospection) {
+				opaqueTokenLoaders = append(opaqueTokenLoaders, createProviderSessionFromIntrospection(providersByID[providerConfig.ID]))
+			}
+		}
+
+		// Session stores that can record used identifiers detect replayed DPoP proofs
+		replayTracker, _ := sessionStore.(sessionsapi.ReplayTracker)
+		chain = chain.Append(middleware.NewJwtSessionLoader(sessionLoaders, opaqueTokenLoaders, opts.BearerTokenLoginFallback, replayTracker))
+	}
+
+	if opts.ClientCertificateAuth != nil {
+		chain = chain.Append(middleware.NewClientCertificateSessionLoader(*opts.ClientCertificateAuth))
 	}
 
 	if validator != nil {
@@ -415,17 +551,101 @@ func buildSessionChain(opts *options.Options, provider providers.Provider, sessi
 	}
 
 	chain = chain.Append(middleware.NewStoredSessionLoader(&middleware.StoredSessionLoaderOptions{
-		SessionStore:    sessionStore,
-		RefreshPeriod:   opts.Cookie.Refresh,
-		RefreshSession:  provider.RefreshSession,
-		ValidateSession: provider.ValidateSession,
+		SessionStore:  sessionStore,
+		RefreshPeriod: opts.Cookie.Refresh,
+		RefreshSession: func(ctx context.Context, s *sessionsapi.SessionState) (bool, error) {
+			provider, ok := sessionProvider(s)
+			if !ok {
+				return false, fmt.Errorf("unknown provider %q", s.ProviderID)
+			}
+			// Refresh the provider's values and map them again, whether or
+			// not the provider replaced them
+			provider.Data().RevertClaimExpressions(s)
+			refreshed, err := provider.RefreshSession(ctx, s)
+			if mapErr := provider.Data().ApplyClaimExpressions(s); err == nil {
+				err = mapErr
+			}
+			return refreshed, err
+		},
+		ValidateSession: func(ctx context.Context, s *sessionsapi.SessionState) bool {
+			provider, ok := sessionProvider(s)
+			return ok && provider.ValidateSession(ctx, s)
+		},
+		RevalidateInterval: func(s *sessionsapi.SessionState) time.Duration {
+			provider, ok := sessionProvider(s)
+			if !ok {
+				return 0
+			}
+			return provider.Data().RevalidateInterval
+		},
+		// Only errors reaching the provider keep the session, sessions that
+		// can't be mapped or authorized any more are cleared.
+		RevalidateSession: func(ctx context.Context, s *sessionsapi.SessionState) (bool, error) {
+			provider, ok := sessionProvider(s)
+			if !ok {
+				logger.Errorf("Unable to revalidate session of unknown provider %q", s.ProviderID)
+				return false, nil
+			}
+			provider.Data().RevertClaimExpressions(s)
+			authorized, err := provider.RevalidateSession(ctx, s)
+			if mapErr := provider.Data().ApplyClaimExpressions(s); mapErr != nil {
+				logger.Errorf("Unable to map revalidated session: %v", mapErr)
+				return false, nil
+			}
+			if err != nil || !authorized {
+				return false, err
+			}
+			authorized, err = provider.Authorize(ctx, s)
+			if err != nil {
+				logger.Errorf("Unable to authorize revalidated session: %v", err)
+				return false, nil
+			}
+			return authorized, nil
+		},
 	}))
 
 	return chain
 }
 
+// createProviderSessionFromToken wraps the provider's CreateSessionFromToken
+// so that sessions loaded from bearer tokens record the provider that verified them.
+func createProviderSessionFromToken(provider providers.Provider) middlewareapi.TokenToSessionFunc {
+	return func(ctx context.Context, token string) (*sessionsapi.SessionState, error) {
+		session, err := provider.CreateSessionFromToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if err := provider.Data().ApplyClaimExpressions(session); err != nil {
+			return nil, err
+		}
+		session.ProviderID = provider.Data().ProviderID
+		return session, nil
+	}
+}
+
+// createProviderSessionFromIntrospection loads sessions for opaque bearer
+// tokens from the token introspection endpoint of the provider
+func createProviderSessionFromIntrospection(provider providers.Provider) middlewareapi.TokenToSessionFunc {
+	return func(ctx context.Context, token string) (*sessionsapi.SessionState, error) {
+		session, err := provider.Data().IntrospectToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if err := provider.Data().ApplyClaimExpressions(session); err != nil {
+			return nil, err
+		}
+		session.ProviderID = provider.Data().ProviderID
+		return session, nil
+	}
+}
+
 func buildHeadersChain(opts *options.Options) (alice.Chain, error) {
-	requestInjector, err := middleware.NewRequestHeaderInjector(opts.InjectRequestHeaders)
+	requestHeaders := opts.InjectRequestHeaders
+	if opts.ClientCertificateAuth != nil {
+		requestHeaders = append(clientCertificateHeaders(opts.ClientCertificateAuth), requestHeaders...)
+	}
+
+	requestInjector, err := middleware.NewRequestHeaderInjector(requestHeaders)
 	if err != nil {
 		return alice.Chain{}, fmt.Errorf("error constructing request header injector: %v", err)
 	}
@@ -438,6 +658,28 @@ func buildHeadersChain(opts *options.Options) (alice.Chain, error) {
 	return alice.New(requestInjector, responseInjector), nil
 }
 
+// clientCertificateHeaders returns the headers the certificates of clients,
+// and their fingerprints, are passed to upstreams in
+func clientCertificateHeaders(opts *options.ClientCertificateAuthOptions) []options.Header {
+	headers := []options.Header{}
+	if opts.CertificateHeader != "" {
+		headers = append(headers, claimHeader(opts.CertificateHeader, middleware.ClientCertificateClaim))
+	}
+	if opts.FingerprintHeader != "" {
+		headers = append(headers, claimHeader(opts.FingerprintHeader, middleware.ClientCertificateFingerprintClaim))
+	}
+	return headers
+}
+
+func claimHeader(name, claim string) options.Header {
+	return options.Header{
+		Name: name,
+		Values: []options.HeaderValue{
+			{ClaimSource: &options.ClaimSource{Claim: claim}},
+		},
+	}
+}
+
 func buildSignInMessage(opts *options.Options) string {
 	var msg string
 	if len(opts.Templates.Banner) >= 1 {
@@ -463,6 +705,33 @@ func buildProviderName(p providers.Provider, override string) string {
 	return p.Data().ProviderName
 }
 
+// buildSignInProviders builds the sign in page buttons for every configured provider
+func buildSignInProviders(providerConfigs options.Providers, providersByID map[string]providers.Provider) []pagewriter.SignInProvider {
+	signInProviders := make([]pagewriter.SignInProvider, 0, len(providerConfigs))
+	for _, providerConfig := range providerConfigs {
+		signInProviders = append(signInProviders, pagewriter.SignInProvider{
+			ID:   providerConfig.ID,
+			Name: buildProviderName(providersByID[providerConfig.ID], providerConfig.Name),
+			Logo: providerConfig.Logo,
+		})
+	}
+	return signInProviders
+}
+
+// buildLoginDomains returns the configured provider IDs in order and the
+// lowercased login domains of every provider that has any
+func buildLoginDomains(providerConfigs options.Providers) ([]string, map[string][]string) {
+	providerIDs := make([]string, 0, len(providerConfigs))
+	loginDomains := make(map[string][]string)
+	for _, providerConfig := range providerConfigs {
+		providerIDs = append(providerIDs, providerConfig.ID)
+		for _, domain := range providerConfig.LoginDomains {
+			loginDomains[providerConfig.ID] = append(loginDomains[providerConfig.ID], strings.ToLower(domain))
+		}
+	}
+	return providerIDs, loginDomains
+}
+
 // buildRoutesAllowlist builds an []allowedRoute  list from either the legacy
 // SkipAuthRegex option (paths only support) or newer SkipAuthRoutes option
 // (method=path support)
@@ -654,23 +923,24 @@ func (p *OAuthProxy) SignInPage(rw http.ResponseWriter, req *http.Request, code
 	p.pageWriter.WriteSignInPage(rw, req, redirectURL, code)
 }
 
-// ManualSignIn handles basic auth logins to the proxy
-func (p *OAuthProxy) ManualSignIn(req *http.Request) (string, bool, int) {
+// ManualSignIn handles basic auth logins to the proxy, returning the user and
+// the groups of the user
+func (p *OAuthProxy) ManualSignIn(req *http.Request) (string, []string, bool, int) {
 	if req.Method != "POST" || p.basicAuthValidator == nil {
-		return "", false, http.StatusOK
+		return "", nil, false, http.StatusOK
 	}
 	user := req.FormValue("username")
 	passwd := req.FormValue("password")
 	if user == "" {
-		return "", false, http.StatusBadRequest
+		return "", nil, false, http.StatusBadRequest
 	}
 	// check auth
-	if p.basicAuthValidator.Validate(user, passwd) {
-		logger.PrintAuthf(user, req, logger.AuthSuccess, "Authenticated via HtpasswdFile")
-		return user, true, http.StatusOK
+	if groups, ok := basic.ValidateGroups(p.basicAuthValidator, user, passwd, p.basicAuthGroups); ok {
+		logger.PrintAuthf(user, req, logger.AuthSuccess, "Authenticated via sign in form")
+		return user, groups, true, http.StatusOK
 	}
-	logger.PrintAuthf(user, req, logger.AuthFailure, "Invalid authentication via HtpasswdFile")
-	return "", false, http.StatusUnauthorized
+	logger.PrintAuthf(user, req, logger.AuthFailure, "Invalid authentication via sign in form")
+	return "", nil, false, http.StatusUnauthorized
 }
 
 // SignIn serves a page prompting users to sign in
@@ -682,9 +952,9 @@ func (p *OAuthProxy) SignIn(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	user, ok, statusCode := p.ManualSignIn(req)
+	user, groups, ok, statusCode := p.ManualSignIn(req)
 	if ok {
-		session := &sessionsapi.SessionState{User: user, Groups: p.basicAuthGroups}
+		session := &sessionsapi.SessionState{User: user, Groups: groups}
 		err = p.SaveSession(rw, req, session)
 		if err != nil {
 			logger.Printf("Error saving session: %v", err)
@@ -693,7 +963,7 @@ func (p *OAuthProxy) SignIn(rw http.ResponseWriter, req *http.Request) {
 		}
 		http.Redirect(rw, req, redirect, http.StatusFound)
 	} else {
-		if p.SkipProviderButton {
+		if p.skipSignInPage() {
 			p.OAuthStart(rw, req)
 		} else {
 			// TODO - should we pass on /oauth2/sign_in query params to /oauth2/start?
@@ -740,7 +1010,8 @@ func (p *OAuthProxy) UserInfo(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// SignOut sends a response to clear the authentication cookie
+// SignOut sends a response to clear the authentication cookie. With
+// RP-initiated logout enabled the user is logged out of the provider too.
 func (p *OAuthProxy) SignOut(rw http.ResponseWriter, req *http.Request) {
 	redirect, err := p.appDirector.GetRedirect(req)
 	if err != nil {
@@ -748,9 +1019,20 @@ func (p *OAuthProxy) SignOut(rw http.ResponseWriter, req *http.Request) {
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
-	// Call backend logout before clearing the session so we still have the session
+
+	// Load the session before clearing it so we still have the session
 	// (and id_token) available to invoke the provider's logout endpoint
-	p.backendLogout(rw, req)
+	var provider providers.Provider
+	session, err := p.getAuthenticatedSession(rw, req)
+	if err != nil {
+		logger.Errorf("error getting authenticated session during sign out: %v", err)
+	} else if session != nil {
+		provider, _ = p.getProvider(session.ProviderID)
+	}
+
+	if provider != nil {
+		p.backendLogout(req, provider, session)
+	}
 
 	err = p.ClearSessionCookie(rw, req)
 	if err != nil {
@@ -759,56 +1041,133 @@ func (p *OAuthProxy) SignOut(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if provider != nil && provider.Data().EndSessionURL != nil {
+		p.redirectToEndSession(rw, req, provider, session, redirect)
+		return
+	}
+
 	http.Redirect(rw, req, redirect, http.StatusFound)
 }
 
-func (p *OAuthProxy) backendLogout(rw http.ResponseWriter, req *http.Request) {
-	session, err := p.getAuthenticatedSession(rw, req)
+// redirectToEndSession performs an OIDC RP-initiated logout. The user is sent
+// to the provider's end_session_endpoint, which returns them to the sign out
+// callback with the state that is checked against the CSRF cookie.
+func (p *OAuthProxy) redirectToEndSession(rw http.ResponseWriter, req *http.Request, provider providers.Provider, session *sessionsapi.SessionState, redirect string) {
+	csrf, err := cookies.NewCSRF(p.CookieOptions, "")
 	if err != nil {
-		logger.Errorf("error getting authenticated session during backend logout: %v", err)
+		logger.Errorf("Error creating CSRF nonce: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+	csrf.SetProviderID(provider.Data().ProviderID)
 
-	if session == nil {
+	state := encodeState(csrf.HashOAuthState(), redirect, p.encodeState)
+	logoutURL := provider.GetLogoutURL(p.getProxyURL(req, signOutCallbackPath), state, session.IDToken)
+	if logoutURL == "" {
+		http.Redirect(rw, req, redirect, http.StatusFound)
+		return
+	}
+
+	cookies.ClearExtraCsrfCookies(p.CookieOptions, rw, req)
+	if _, err := csrf.SetCookie(rw, req); err != nil {
+		logger.Errorf("Error setting CSRF cookie: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	http.Redirect(rw, req, logoutURL, http.StatusFound)
+}
+
+// SignOutCallback finishes an RP-initiated logout. It validates the state
+// returned by the provider before redirecting the user to the original
+// sign out redirect.
+func (p *OAuthProxy) SignOutCallback(rw http.ResponseWriter, req *http.Request) {
+	nonce, redirect, err := decodeState(req.URL.Query().Get("state"), p.encodeState)
+	if err != nil {
+		logger.Errorf("Error while parsing sign out state: %v", err)
+		p.ErrorPage(rw, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	csrf, err := cookies.LoadCSRFCookie(req, cookies.GenerateCookieName(p.CookieOptions, nonce), p.CookieOptions)
+	if err != nil {
+		logger.Println(req, logger.AuthFailure, "Invalid sign out: unable to obtain CSRF cookie: %s (state=%s)", err, nonce)
+		p.ErrorPage(rw, req, http.StatusForbidden, err.Error(), "Sign Out Failed: Unable to find a valid CSRF token.")
+		return
+	}
+	csrf.ClearCookie(rw, req)
+
+	if !csrf.CheckOAuthState(nonce) {
+		logger.Println(req, logger.AuthFailure, "Invalid sign out: CSRF token mismatch, potential attack")
+		p.ErrorPage(rw, req, http.StatusForbidden, "CSRF token mismatch, potential attack", "Sign Out Failed: Unable to find a valid CSRF token.")
 		return
 	}
 
-	providerData := p.provider.Data()
+	if !p.redirectValidator.IsValidRedirect(redirect) {
+		redirect = "/"
+	}
+	http.Redirect(rw, req, redirect, http.StatusFound)
+}
+
+// backendLogout calls the provider's backend logout URL, if configured, with
+// the id_token of the session
+func (p *OAuthProxy) backendLogout(req *http.Request, provider providers.Provider, session *sessionsapi.SessionState) {
+	providerData := provider.Data()
 	if providerData.BackendLogoutURL == "" {
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(providerData.ClientContext(req.Context()), backendLogoutTimeout)
+	defer cancel()
+
 	backendLogoutURL := strings.ReplaceAll(providerData.BackendLogoutURL, "{id_token}", session.IDToken)
-	// security exception because URL is dynamic ({id_token} replacement) but
-	// base is not end-user provided but comes from configuration somewhat secure
-	resp, err := http.Get(backendLogoutURL) // #nosec G107
-	if err != nil {
-		logger.Errorf("error while calling backend logout: %v", err)
+	result := requests.New(backendLogoutURL).WithContext(ctx).Do()
+	if result.Error() != nil {
+		logger.Errorf("error while calling backend logout: %v", result.Error())
 		return
 	}
 
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		logger.Errorf("error while calling backend logout url, returned error code %v", resp.StatusCode)
+	if result.StatusCode() != http.StatusOK {
+		logger.Errorf("error while calling backend logout url, returned error code %v", result.StatusCode())
 	}
 }
 
 // OAuthStart starts the OAuth2 authentication flow
 func (p *OAuthProxy) OAuthStart(rw http.ResponseWriter, req *http.Request) {
+	providerID := req.URL.Query().Get("provider")
+	email := req.URL.Query().Get("email")
+	if providerID == "" && email != "" {
+		// Home realm discovery: route the user by the domain of their email
+		matches := p.providersForEmail(email)
+		if len(matches) != 1 {
+			logger.Printf("Email discovery found %d providers for %q, asking the user to pick one", len(matches), email)
+			p.SignInPage(rw, req, http.StatusOK)
+			return
+		}
+		providerID = matches[0]
+	}
+
+	provider, ok := p.getProvider(providerID)
+	if !ok {
+		logger.Errorf("Unknown provider requested: %q", providerID)
+		p.ErrorPage(rw, req, http.StatusBadRequest, fmt.Sprintf("unknown provider %q", providerID))
+		return
+	}
+
 	// start the flow permitting login URL query parameters to be overridden from the request URL
-	p.doOAuthStart(rw, req, req.URL.Query())
+	p.doOAuthStart(rw, req, provider, req.URL.Query())
 }
 
-func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, overrides url.Values) {
-	extraParams := p.provider.Data().LoginURLParams(overrides)
+func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, provider providers.Provider, overrides url.Values) {
+	extraParams := provider.Data().LoginURLParams(overrides)
 	prepareNoCache(rw)
 
 	var (
 		err                                              error
 		codeChallenge, codeVerifier, codeChallengeMethod string
 	)
-	if p.provider.Data().CodeChallengeMethod != "" {
-		codeChallengeMethod = p.provider.Data().CodeChallengeMethod
+	if provider.Data().CodeChallengeMethod != "" {
+		codeChallengeMethod = provider.Data().CodeChallengeMethod
 		codeVerifier, err = encryption.GenerateCodeVerifierString(96)
 		if err != nil {
 			logger.Errorf("Unable to build random ASCII string for code verifier: %v", err)
@@ -816,7 +1175,7 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 			return
 		}
 
-		codeChallenge, err = encryption.GenerateCodeChallenge(p.provider.Data().CodeChallengeMethod, codeVerifier)
+		codeChallenge, err = encryption.GenerateCodeChallenge(provider.Data().CodeChallengeMethod, codeVerifier)
 		if err != nil {
 			logger.Errorf("Error creating code challenge: %v", err)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -833,6 +1192,7 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+	csrf.SetProviderID(provider.Data().ProviderID)
 
 	appRedirect, err := p.appDirector.GetRedirect(req)
 	if err != nil {
@@ -841,22 +1201,346 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 		return
 	}
 
-	callbackRedirect := p.getOAuthRedirectURI(req)
-	loginURL := p.provider.GetLoginURL(
-		callbackRedirect,
-		encodeState(csrf.HashOAuthState(), appRedirect, p.encodeState),
-		csrf.HashOIDCNonce(),
-		extraParams,
+	callbackRedirect := p.getRedirectURI(req, provider)
+	state := encodeState(csrf.HashOAuthState(), appRedirect, p.encodeState)
+
+	var (
+		loginURL  string
+		loginForm []byte
 	)
+	if sp, ok := provider.(providers.SAMLServiceProvider); ok {
+		loginURL, loginForm, err = sp.MakeAuthnRequest(callbackRedirect, state, extraParams)
+		if err != nil {
+			logger.Errorf("Error creating SAML authentication request: %v", err)
+			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else {
+		loginURL = provider.GetLoginURL(callbackRedirect, state, csrf.HashOIDCNonce(), extraParams)
+		if provider.Data().PushedAuthorizationRequestURL != nil {
+			pushedURL, err := provider.Data().PushAuthorizationRequest(req.Context(), loginURL)
+			switch {
+			case err == nil:
+				loginURL = pushedURL
+			case provider.Data().RequirePushedAuthorizationRequests:
+				logger.Errorf("Error pushing authorization request: %v", err)
+				p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+				return
+			default:
+				logger.Errorf("Error pushing authorization request, falling back to the login URL: %v", err)
+			}
+		}
+	}
+
 	cookies.ClearExtraCsrfCookies(p.CookieOptions, rw, req)
 	if _, err := csrf.SetCookie(rw, req); err != nil {
 		logger.Errorf("Error setting CSRF cookie: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+
+	if loginForm != nil {
+		// The HTTP-POST binding sends the request from a self-submitting form
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write(loginForm)
+		return
+	}
 	http.Redirect(rw, req, loginURL, http.StatusFound)
 }
 
+// SAMLMetadata serves the SAML service provider metadata of the provider
+// selected by the `provider` query parameter, or of the default provider.
+func (p *OAuthProxy) SAMLMetadata(rw http.ResponseWriter, req *http.Request) {
+	providerID := req.URL.Query().Get("provider")
+	provider, ok := p.getProvider(providerID)
+	sp, isSAML := provider.(providers.SAMLServiceProvider)
+	if !ok || !isSAML {
+		p.ErrorPage(rw, req, http.StatusNotFound, fmt.Sprintf("provider %q is not a SAML provider", providerID))
+		return
+	}
+
+	metadata, err := sp.Metadata(p.getSAMLACSURL(req))
+	if err != nil {
+		logger.Errorf("Error creating SAML metadata: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/samlmetadata+xml")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(metadata)
+}
+
+// MockProvider serves the authorize, token and JWKS endpoints of the mock
+// provider selected by the `provider` query parameter (or the default
+// provider)
+func (p *OAuthProxy) MockProvider(rw http.ResponseWriter, req *http.Request) {
+	providerID := req.URL.Query().Get("provider")
+	provider, ok := p.getProvider(providerID)
+	mp, isMock := provider.(providers.MockIdentityProvider)
+	if !ok || !isMock {
+		p.ErrorPage(rw, req, http.StatusNotFound, fmt.Sprintf("provider %q is not a mock provider", providerID))
+		return
+	}
+
+	http.StripPrefix(p.ProxyPrefix+strings.TrimSuffix(mockPathPrefix, "/"), mp).ServeHTTP(rw, req)
+}
+
+// SAMLAssertionConsumer is the SAML assertion consumer service. It finishes
+// the login like the OAuth2 callback, with the posted SAMLResponse as the code
+// and the RelayState as the state.
+func (p *OAuthProxy) SAMLAssertionConsumer(rw http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		logger.Errorf("Error while parsing SAML response: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+	req.Form.Set("code", req.PostForm.Get("SAMLResponse"))
+	req.Form.Set("state", req.PostForm.Get("RelayState"))
+
+	p.OAuthCallback(rw, req)
+}
+
+// BackchannelLogout is the OIDC back-channel logout endpoint. It verifies the
+// logout token posted by the provider selected by the `provider` query
+// parameter (or the default provider) and revokes the matching sessions.
+func (p *OAuthProxy) BackchannelLogout(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		writeOAuth2Error(rw, http.StatusMethodNotAllowed, "invalid_request", "back-channel logout requires a POST request")
+		return
+	}
+
+	revoker, ok := p.sessionStore.(sessionsapi.SessionRevoker)
+	if !ok {
+		writeOAuth2Error(rw, http.StatusNotImplemented, "unsupported_session_store", "back-channel logout requires a persistent session store")
+		return
+	}
+
+	providerID := req.URL.Query().Get("provider")
+	provider, ok := p.getProvider(providerID)
+	if !ok || provider.Data().Verifier == nil {
+		writeOAuth2Error(rw, http.StatusBadRequest, "invalid_request", fmt.Sprintf("provider %q does not support back-channel logout", providerID))
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		writeOAuth2Error(rw, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	rawLogoutToken := req.PostForm.Get("logout_token")
+	if rawLogoutToken == "" {
+		writeOAuth2Error(rw, http.StatusBadRequest, "invalid_request", "missing logout_token")
+		return
+	}
+
+	logoutToken, err := internaloidc.VerifyLogoutToken(req.Context(), provider.Data().Verifier, rawLogoutToken)
+	if err != nil {
+		logger.Errorf("Error verifying back-channel logout token: %v", err)
+		writeOAuth2Error(rw, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	err = revoker.RevokeSessions(req.Context(), provider.Data().ProviderID, logoutToken.SessionID, logoutToken.Subject)
+	if err != nil {
+		logger.Errorf("Error revoking sessions on back-channel logout: %v", err)
+		writeOAuth2Error(rw, http.StatusInternalServerError, "server_error", "unable to revoke sessions")
+		return
+	}
+
+	logger.Printf("Revoked sessions on back-channel logout from provider %q (sid=%q sub=%q)", provider.Data().ProviderID, logoutToken.SessionID, logoutToken.Subject)
+	rw.WriteHeader(http.StatusOK)
+}
+
+// writeOAuth2Error writes an OAuth2 style JSON error response as expected by
+// providers sending back-channel logout requests and device flow clients
+func writeOAuth2Error(rw http.ResponseWriter, code int, errorCode, description string) {
+	rw.Header().Set("Content-Type", applicationJSON)
+	rw.WriteHeader(code)
+	err := json.NewEncoder(rw).Encode(map[string]string{
+		"error":             errorCode,
+		"error_description": description,
+	})
+	if err != nil {
+		logger.Errorf("Error encoding OAuth2 error: %v", err)
+	}
+}
+
+// DeviceStart starts the device authorization grant (RFC 8628) with the
+// provider selected by the `provider` parameter (or the default provider) and
+// returns the user code and verification URI for the user.
+func (p *OAuthProxy) DeviceStart(rw http.ResponseWriter, req *http.Request) {
+	provider, ok := p.getDeviceProvider(rw, req)
+	if !ok {
+		return
+	}
+
+	authorization, err := provider.StartDeviceAuthorization(req.Context())
+	if err != nil {
+		logger.Errorf("Error starting device authorization with provider %q: %v", provider.Data().ProviderID, err)
+		var tokenErr *providers.TokenError
+		if errors.As(err, &tokenErr) {
+			writeOAuth2Error(rw, http.StatusBadRequest, tokenErr.Code, tokenErr.Description)
+			return
+		}
+		writeOAuth2Error(rw, http.StatusBadGateway, "server_error", "unable to start device authorization")
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, authorization)
+}
+
+// DevicePoll polls the provider with the device code of a device authorization
+// grant. Until the user completes the login it returns the provider's error,
+// such as authorization_pending. Then the session is created and authorized
+// the same way as for browser logins and returned as a session cookie or, with
+// `response=tokens`, as the provider tokens.
+func (p *OAuthProxy) DevicePoll(rw http.ResponseWriter, req *http.Request) {
+	provider, ok := p.getDeviceProvider(rw, req)
+	if !ok {
+		return
+	}
+
+	responseType := req.PostForm.Get("response")
+	if responseType != "" && responseType != deviceResponseSession && responseType != deviceResponseTokens {
+		writeOAuth2Error(rw, http.StatusBadRequest, "invalid_request", fmt.Sprintf("invalid response %q: must be one of %s or %s", responseType, deviceResponseSession, deviceResponseTokens))
+		return
+	}
+
+	session, err := provider.RedeemDeviceCode(req.Context(), req.PostForm.Get("device_code"))
+	if err != nil {
+		var tokenErr *providers.TokenError
+		if errors.As(err, &tokenErr) {
+			writeOAuth2Error(rw, http.StatusBadRequest, tokenErr.Code, tokenErr.Description)
+			return
+		}
+		logger.Errorf("Error redeeming device code with provider %q: %v", provider.Data().ProviderID, err)
+		writeOAuth2Error(rw, http.StatusBadGateway, "server_error", "unable to redeem device code")
+		return
+	}
+	session.ProviderID = provider.Data().ProviderID
+	if session.CreatedAt == nil {
+		session.CreatedAtNow()
+	}
+	if session.ExpiresOn == nil {
+		session.ExpiresIn(p.CookieOptions.Expire)
+	}
+
+	if err := p.enrichSessionState(req.Context(), provider, session); err != nil {
+		logger.Errorf("Error creating session during device authorization: %v", err)
+		writeOAuth2Error(rw, http.StatusInternalServerError, "server_error", "unable to create session")
+		return
+	}
+
+	if !provider.ValidateSession(req.Context(), session) {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session validation failed: %s", session)
+		writeOAuth2Error(rw, http.StatusForbidden, "access_denied", "session validation failed")
+		return
+	}
+
+	authorized, err := provider.Authorize(req.Context(), session)
+	if err != nil {
+		logger.Errorf("Error with authorization: %v", err)
+	}
+	if !p.Validator(session.Email) || !authorized {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authentication via device authorization: unauthorized")
+		writeOAuth2Error(rw, http.StatusForbidden, "access_denied", "unauthorized")
+		return
+	}
+	logger.PrintAuthf(session.Email, req, logger.AuthSuccess, "Authenticated via device authorization: %s", session)
+
+	if responseType == deviceResponseTokens {
+		writeJSON(rw, http.StatusOK, deviceTokensResponse{
+			AccessToken: session.AccessToken,
+			IDToken:     session.IDToken,
+			TokenType:   "Bearer",
+			ExpiresIn:   int64(time.Until(*session.ExpiresOn).Seconds()),
+		})
+		return
+	}
+
+	// The session cookie is returned in the body rather than set on this
+	// response so that a cross-site request can't log a browser in.
+	recorder := &headerRecorder{header: http.Header{}}
+	if err := p.SaveSession(recorder, req, session); err != nil {
+		logger.Errorf("Error saving session state during device authorization: %v", err)
+		writeOAuth2Error(rw, http.StatusInternalServerError, "server_error", "unable to save session")
+		return
+	}
+	cookies := []string{}
+	for _, c := range (&http.Response{Header: recorder.header}).Cookies() {
+		cookies = append(cookies, (&http.Cookie{Name: c.Name, Value: c.Value}).String())
+	}
+	writeJSON(rw, http.StatusOK, deviceSessionResponse{
+		Cookie:    strings.Join(cookies, "; "),
+		ExpiresIn: int64(time.Until(*session.ExpiresOn).Seconds()),
+	})
+}
+
+// getDeviceProvider returns the provider for a device authorization request
+// and writes the error response when the request can't be handled
+func (p *OAuthProxy) getDeviceProvider(rw http.ResponseWriter, req *http.Request) (providers.Provider, bool) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		writeOAuth2Error(rw, http.StatusMethodNotAllowed, "invalid_request", "device authorization requires a POST request")
+		return nil, false
+	}
+	if err := req.ParseForm(); err != nil {
+		writeOAuth2Error(rw, http.StatusBadRequest, "invalid_request", err.Error())
+		return nil, false
+	}
+
+	providerID := req.Form.Get("provider")
+	provider, ok := p.getProvider(providerID)
+	if !ok || provider.Data().DeviceAuthorizationURL == nil || provider.Data().DeviceAuthorizationURL.String() == "" {
+		writeOAuth2Error(rw, http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("provider %q does not support device authorization", providerID))
+		return nil, false
+	}
+	return provider, true
+}
+
+// deviceSessionResponse is returned by the device poll endpoint with the
+// session cookie to send on subsequent requests
+type deviceSessionResponse struct {
+	Cookie    string `json:"cookie"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// deviceTokensResponse is returned by the device poll endpoint with the
+// provider tokens to send as bearer tokens on subsequent requests
+type deviceTokensResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token,omitempty"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// headerRecorder is a response writer that only records the headers, used
+// to capture the session cookie set by the session store
+type headerRecorder struct {
+	header http.Header
+}
+
+func (r *headerRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *headerRecorder) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (r *headerRecorder) WriteHeader(int) {}
+
+// writeJSON writes the value as a JSON response with the status code
+func writeJSON(rw http.ResponseWriter, code int, v interface{}) {
+	rw.Header().Set("Content-Type", applicationJSON)
+	rw.WriteHeader(code)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		logger.Errorf("Error encoding JSON response: %v", err)
+	}
+}
+
 // OAuthCallback is the OAuth2 authentication flow callback that finishes the
 // OAuth2 authentication flow
 func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
@@ -898,14 +1582,22 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	session, err := p.redeemCode(req, csrf.GetCodeVerifier())
+	provider, ok := p.getProvider(csrf.GetProviderID())
+	if !ok {
+		logger.Println(req, logger.AuthFailure, "Invalid authentication via OAuth2: unknown provider %q (state=%s)", csrf.GetProviderID(), nonce)
+		p.ErrorPage(rw, req, http.StatusForbidden, fmt.Sprintf("unknown provider %q", csrf.GetProviderID()), "Login Failed: The identity provider used to sign in is no longer configured. Please try again.")
+		return
+	}
+
+	session, err := p.redeemCode(req, provider, csrf.GetCodeVerifier())
 	if err != nil {
 		logger.Errorf("Error redeeming code during OAuth2 callback: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+	session.ProviderID = provider.Data().ProviderID
 
-	err = p.enrichSessionState(req.Context(), session)
+	err = p.enrichSessionState(req.Context(), provider, session)
 	if err != nil {
 		logger.Errorf("Error creating session during OAuth2 callback: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -921,7 +1613,7 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	csrf.SetSessionNonce(session)
-	if !p.provider.ValidateSession(req.Context(), session) {
+	if !provider.ValidateSession(req.Context(), session) {
 		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session validation failed: %s", session)
 		p.ErrorPage(rw, req, http.StatusForbidden, "Session validation failed")
 		return
@@ -932,7 +1624,7 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	// set cookie, or deny
-	authorized, err := p.provider.Authorize(req.Context(), session)
+	authorized, err := provider.Authorize(req.Context(), session)
 	if err != nil {
 		logger.Errorf("Error with authorization: %v", err)
 	}
@@ -951,18 +1643,24 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (p *OAuthProxy) redeemCode(req *http.Request, codeVerifier string) (*sessionsapi.SessionState, error) {
+func (p *OAuthProxy) redeemCode(req *http.Request, provider providers.Provider, codeVerifier string) (*sessionsapi.SessionState, error) {
 	code := req.Form.Get("code")
 	if code == "" {
 		return nil, providers.ErrMissingCode
 	}
 
-	redirectURI := p.getOAuthRedirectURI(req)
-	s, err := p.provider.Redeem(req.Context(), redirectURI, code, codeVerifier)
+	redirectURI := p.getRedirectURI(req, provider)
+	s, err := provider.Redeem(req.Context(), redirectURI, code, codeVerifier)
 	if err != nil {
 		return nil, err
 	}
 
+	if cp, ok := provider.(providers.CallbackSessionEnricher); ok {
+		if err := cp.EnrichSessionFromCallback(s, req.Form); err != nil {
+			return nil, err
+		}
+	}
+
 	// Force setting these in case the Provider didn't
 	if s.CreatedAt == nil {
 		s.CreatedAtNow()
@@ -974,18 +1672,25 @@ func (p *OAuthProxy) redeemCode(req *http.Request, codeVerifier string) (*sessio
 	return s, nil
 }
 
-func (p *OAuthProxy) enrichSessionState(ctx context.Context, s *sessionsapi.SessionState) error {
+func (p *OAuthProxy) enrichSessionState(ctx context.Context, provider providers.Provider, s *sessionsapi.SessionState) error {
 	var err error
 	if s.Email == "" {
 		// TODO(@NickMeves): Remove once all provider are updated to implement EnrichSession
 		// nolint:staticcheck
-		s.Email, err = p.provider.GetEmailAddress(ctx, s)
+		s.Email, err = provider.GetEmailAddress(ctx, s)
 		if err != nil && !errors.Is(err, providers.ErrNotImplemented) {
 			return err
 		}
 	}
 
-	return p.provider.EnrichSession(ctx, s)
+	if err := provider.EnrichSession(ctx, s); err != nil {
+		return err
+	}
+	if provider.Data().RevalidateInterval > 0 {
+		// The re-validation interval starts at login, refreshes don't reset it
+		s.ValidatedAtNow()
+	}
+	return provider.Data().ApplyClaimExpressions(s)
 }
 
 // AuthOnly checks whether the user is currently logged in (both authentication
@@ -1024,6 +1729,16 @@ func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		// Check against the authorization rules of the upstream
+		if !p.authorizeUpstream(req, session) {
+			if p.forceJSONErrors {
+				p.errorJSON(rw, http.StatusForbidden)
+			} else {
+				p.ErrorPage(rw, req, http.StatusForbidden, "The session failed the authorization checks of the upstream")
+			}
+			return
+		}
+
 		// we are authenticated
 		p.addHeadersForProxying(rw, session)
 		p.headersChain.Then(p.upstreamProxy).ServeHTTP(rw, req)
@@ -1037,11 +1752,11 @@ func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 		}
 
 		logger.Printf("No valid authentication in request. Initiating login.")
-		if p.SkipProviderButton {
+		if p.skipSignInPage() {
 			// start OAuth flow, but only with the default login URL params - do not
 			// consider this request's query params as potential overrides, since
 			// the user did not explicitly start the login flow
-			p.doOAuthStart(rw, req, nil)
+			p.doOAuthStart(rw, req, p.provider, nil)
 		} else {
 			p.SignInPage(rw, req, http.StatusForbidden)
 		}
@@ -1060,6 +1775,26 @@ func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// authorizeUpstream checks the session against the authorization rules of the
+// upstream the request is proxied to, and records the upstream in the request
+// scope. Requests allowed to bypass authentication are not checked.
+func (p *OAuthProxy) authorizeUpstream(req *http.Request, session *sessionsapi.SessionState) bool {
+	if session == nil || p.IsAllowedRequest(req) {
+		return true
+	}
+
+	upstreamID, allowed := p.upstreamProxy.Authorize(req, session)
+	if upstreamID == "" {
+		return true
+	}
+	middlewareapi.GetRequestScope(req).Upstream = upstreamID
+
+	if !allowed {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authorization for upstream %q: the session failed its authorization rules", upstreamID)
+	}
+	return allowed
+}
+
 // See https://developers.google.com/web/fundamentals/performance/optimizing-content-efficiency/http-caching?hl=en
 var noCacheHeaders = map[string]string{
 	"Expires":         time.Unix(0, 0).Format(time.RFC1123),
@@ -1082,6 +1817,46 @@ func prepareNoCacheMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// skipSignInPage reports whether users can be sent straight to the provider.
+// With multiple providers the sign in page is still needed to choose one.
+func (p *OAuthProxy) skipSignInPage() bool {
+	return p.SkipProviderButton && len(p.providersByID) <= 1
+}
+
+// providersForEmail returns the IDs of the providers whose login domains
+// match the domain of the email address. Providers claiming every domain
+// with `*` are only returned when no provider claims the domain explicitly.
+func (p *OAuthProxy) providersForEmail(email string) []string {
+	email = strings.ToLower(email)
+
+	var matches, wildcards []string
+	for _, id := range p.providerIDs {
+		domains := p.loginDomains[id]
+		if slices.Contains(domains, "*") {
+			wildcards = append(wildcards, id)
+		}
+		if isEmailValidWithDomains(email, domains) {
+			matches = append(matches, id)
+		}
+	}
+
+	if len(matches) == 0 {
+		return wildcards
+	}
+	return matches
+}
+
+// getProvider returns the provider with the given ID. An empty ID selects the
+// default provider, which also owns sessions created before provider IDs were
+// recorded on sessions.
+func (p *OAuthProxy) getProvider(id string) (providers.Provider, bool) {
+	if id == "" || id == p.providerID {
+		return p.provider, true
+	}
+	provider, ok := p.providersByID[id]
+	return provider, ok
+}
+
 // getOAuthRedirectURI returns the redirectURL that the upstream OAuth Provider will
 // redirect clients to once authenticated.
 // This is usually the OAuthProxy callback URL.
@@ -1092,21 +1867,52 @@ func (p *OAuthProxy) getOAuthRedirectURI(req *http.Request) string {
 	}
 
 	// Otherwise figure out the scheme + host from the request
-	rd := *p.redirectURL
-	rd.Host = requestutil.GetRequestHost(req)
-	rd.Scheme = requestutil.GetRequestProto(req)
+	return p.withRequestHost(req, *p.redirectURL).String()
+}
+
+// getSAMLACSURL returns the absolute URL of the SAML assertion consumer
+// service, on the same host as the OAuth2 redirect URI.
+func (p *OAuthProxy) getSAMLACSURL(req *http.Request) string {
+	return p.getProxyURL(req, samlACSPath)
+}
+
+// getProxyURL returns the absolute URL of an endpoint under the proxy prefix,
+// using the host of the redirect URL or else of the request
+func (p *OAuthProxy) getProxyURL(req *http.Request, path string) string {
+	u := *p.redirectURL
+	u.Path = p.ProxyPrefix + path
+	u.RawQuery = ""
+	if u.Host != "" {
+		return u.String()
+	}
+	return p.withRequestHost(req, u).String()
+}
+
+// getRedirectURI returns the URI the provider sends its response to, which
+// is the SAML assertion consumer service for SAML providers.
+func (p *OAuthProxy) getRedirectURI(req *http.Request, provider providers.Provider) string {
+	if _, ok := provider.(providers.SAMLServiceProvider); ok {
+		return p.getSAMLACSURL(req)
+	}
+	return p.getOAuthRedirectURI(req)
+}
+
+// withRequestHost sets the scheme and host of the URL from the request
+func (p *OAuthProxy) withRequestHost(req *http.Request, u url.URL) *url.URL {
+	u.Host = requestutil.GetRequestHost(req)
+	u.Scheme = requestutil.GetRequestProto(req)
 
 	// If there's no scheme in the request, we should still include one
-	if rd.Scheme == "" {
-		rd.Scheme = schemeHTTP
+	if u.Scheme == "" {
+		u.Scheme = schemeHTTP
 	}
 
 	// If CookieSecure is true, return `https` no matter what
 	// Not all reverse proxies set X-Forwarded-Proto
 	if p.CookieOptions.Secure {
-		rd.Scheme = schemeHTTPS
+		u.Scheme = schemeHTTPS
 	}
-	return rd.String()
+	return &u
 }
 
 // getAuthenticatedSession checks whether a user is authenticated and returns a session object and nil error if so
@@ -1126,8 +1932,17 @@ func (p *OAuthProxy) getAuthenticatedSession(rw http.ResponseWriter, req *http.R
 		return nil, ErrNeedsLogin
 	}
 
+	provider, ok := p.getProvider(session.ProviderID)
+	if !ok {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid session: unknown provider %q: removing session %s", session.ProviderID, session)
+		if err := p.ClearSessionCookie(rw, req); err != nil {
+			logger.Errorf("Error clearing session cookie: %v", err)
+		}
+		return nil, ErrNeedsLogin
+	}
+
 	invalidEmail := session.Email != "" && !p.Validator(session.Email)
-	authorized, err := p.provider.Authorize(req.Context(), session)
+	authorized, err := provider.Authorize(req.Context(), session)
 	if err != nil {
 		logger.Errorf("Error with authorization: %v", err)
 	}
@@ -1,31 +1,53 @@
 package main
 
 import (
+	"bytes"
+	"compress/flate"
 	"context"
 	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/crewjam/saml"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/hmacauth"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 	sessionscookie "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/cookie"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	sessionstests "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/upstream"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/providers/samltest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -115,7 +137,7 @@ func Test_redeemCode(t *testing.T) {
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	_, err = proxy.redeemCode(req, "")
+	_, err = proxy.redeemCode(req, proxy.provider, "")
 	assert.Equal(t, providers.ErrMissingCode, err)
 }
 
@@ -167,7 +189,7 @@ func Test_enrichSession(t *testing.T) {
 			}
 			proxy.provider = NewTestProvider(&url.URL{Host: "www.example.com"}, providerEmail)
 
-			err = proxy.enrichSessionState(context.Background(), tc.session)
+			err = proxy.enrichSessionState(context.Background(), proxy.provider, tc.session)
 			assert.NoError(t, err)
 			assert.Equal(t, tc.expectedUser, tc.session.User)
 			assert.Equal(t, tc.expectedEmail, tc.session.Email)
@@ -659,6 +681,52 @@ func TestManualSignInStoresUserGroupsInTheSession(t *testing.T) {
 	assert.Equal(t, userGroups, s.Groups)
 }
 
+type GroupsSignInValidator struct {
+	AlwaysSuccessfulValidator
+}
+
+func (GroupsSignInValidator) ValidateGroups(_, _ string) ([]string, bool) {
+	return []string{"directorygroup"}, true
+}
+
+func TestManualSignInStoresDirectoryGroupsInTheSession(t *testing.T) {
+	opts := baseTestOptions()
+	opts.HtpasswdUserGroups = []string{"somegroup"}
+	err := validation.Validate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := NewOAuthProxy(opts, func(email string) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.basicAuthValidator = GroupsSignInValidator{}
+
+	rw := httptest.NewRecorder()
+	formData := url.Values{}
+	formData.Set("username", "someuser")
+	formData.Set("password", "somepass")
+	signInReq, _ := http.NewRequest(http.MethodPost, "/oauth2/sign_in", strings.NewReader(formData.Encode()))
+	signInReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	proxy.ServeHTTP(rw, signInReq)
+
+	assert.Equal(t, http.StatusFound, rw.Code)
+
+	req, _ := http.NewRequest(http.MethodGet, "/something", nil)
+	for _, c := range rw.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	s, err := proxy.sessionStore.Load(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"directorygroup"}, s.Groups)
+}
+
 type ManualSignInValidator struct{}
 
 func (ManualSignInValidator) Validate(user, password string) bool {
@@ -2080,6 +2148,394 @@ func TestSignOutCallsBackendLogoutURL(t *testing.T) {
 		"backend logout URL should have been called with id_token from session")
 }
 
+func TestSignOutCallsBackendLogoutURLWithClientCertificate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "oauth2-proxy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	var peerCertificates []*x509.Certificate
+	backendLogoutServer := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		peerCertificates = req.TLS.PeerCertificates
+		rw.WriteHeader(http.StatusOK)
+	}))
+	backendLogoutServer.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	backendLogoutServer.StartTLS()
+	defer backendLogoutServer.Close()
+
+	// Trust the test server the same way CA files configure the default transport
+	defaultTransport := requests.DefaultTransport
+	requests.DefaultTransport = backendLogoutServer.Client().Transport
+	defer func() { requests.DefaultTransport = defaultTransport }()
+
+	opts := baseTestOptions()
+	opts.Providers[0].Type = options.OIDCProvider
+	opts.Providers[0].LoginURL = "https://issuer.example.com/authorize"
+	opts.Providers[0].RedeemURL = "https://issuer.example.com/token"
+	opts.Providers[0].OIDCConfig.IssuerURL = "https://issuer.example.com"
+	opts.Providers[0].OIDCConfig.JwksURL = "https://issuer.example.com/jwks"
+	opts.Providers[0].OIDCConfig.SkipDiscovery = ptr.To(true)
+	opts.Providers[0].BackendLogoutURL = backendLogoutServer.URL + "/logout?id_token_hint={id_token}"
+	opts.Providers[0].ClientCertificate = &options.ClientCertificate{
+		Cert: &options.SecretSource{Value: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})},
+		Key:  &options.SecretSource{Value: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})},
+	}
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, proxy.sessionStore.Save(rw, req, &sessions.SessionState{
+		Email:   "user@example.com",
+		IDToken: "test-id-token-12345",
+	}))
+
+	signOutReq := httptest.NewRequest(http.MethodGet, "/oauth2/sign_out", nil)
+	signOutReq.Header.Set("Cookie", rw.Header().Values("Set-Cookie")[0])
+	proxy.ServeHTTP(httptest.NewRecorder(), signOutReq)
+
+	require.Len(t, peerCertificates, 1, "backend logout should present the provider's client certificate")
+	assert.Equal(t, der, peerCertificates[0].Raw)
+}
+
+func TestSignOutRPInitiatedLogout(t *testing.T) {
+	const testIDToken = "test-id-token-12345"
+
+	opts := baseTestOptions()
+	opts.Providers[0].OIDCConfig.RPInitiatedLogout = ptr.To(true)
+	opts.Providers[0].OIDCConfig.EndSessionURL = "https://idp.example.com/logout?ui_locales=en"
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, proxy.sessionStore.Save(rw, req, &sessions.SessionState{
+		Email:   "user@example.com",
+		IDToken: testIDToken,
+	}))
+	sessionCookie := rw.Header().Values("Set-Cookie")[0]
+
+	signOutReq := httptest.NewRequest(http.MethodGet, "/oauth2/sign_out?rd=%2Fapp", nil)
+	signOutReq.Header.Set("Cookie", sessionCookie)
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, signOutReq)
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	logoutURL, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", logoutURL.Host)
+	assert.Equal(t, "/logout", logoutURL.Path)
+	params := logoutURL.Query()
+	assert.Equal(t, "en", params.Get("ui_locales"))
+	assert.Equal(t, testIDToken, params.Get("id_token_hint"))
+	assert.Equal(t, opts.Providers[0].ClientID, params.Get("client_id"))
+	assert.Equal(t, "https://example.com/oauth2/sign_out/callback", params.Get("post_logout_redirect_uri"))
+	state := params.Get("state")
+	require.NotEmpty(t, state)
+
+	var csrfCookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if strings.HasSuffix(c.Name, "_csrf") {
+			csrfCookie = c
+		}
+	}
+	require.NotNil(t, csrfCookie)
+
+	t.Run("redirects to rd after validating the state", func(t *testing.T) {
+		callbackReq := httptest.NewRequest(http.MethodGet, "/oauth2/sign_out/callback?state="+url.QueryEscape(state), nil)
+		callbackReq.AddCookie(csrfCookie)
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, callbackReq)
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "/app", rec.Header().Get("Location"))
+	})
+
+	t.Run("rejects a callback without the CSRF cookie", func(t *testing.T) {
+		callbackReq := httptest.NewRequest(http.MethodGet, "/oauth2/sign_out/callback?state="+url.QueryEscape(state), nil)
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, callbackReq)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("rejects a callback with a mismatched state", func(t *testing.T) {
+		callbackReq := httptest.NewRequest(http.MethodGet, "/oauth2/sign_out/callback?state="+url.QueryEscape(encodeState("invalid", "/app", proxy.encodeState)), nil)
+		callbackReq.AddCookie(csrfCookie)
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, callbackReq)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func multipleProvidersTestOptions() *options.Options {
+	opts := baseTestOptions()
+	opts.Providers = append(opts.Providers, options.Provider{
+		ID:           "github",
+		Type:         options.GitHubProvider,
+		ClientID:     "github-client-id",
+		ClientSecret: "github-client-secret",
+	})
+	return opts
+}
+
+func TestOAuthStartWithMultipleProviders(t *testing.T) {
+	opts := multipleProvidersTestOptions()
+	err := validation.Validate(opts)
+	require.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	t.Run("defaults to the first provider", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/start", nil)
+		proxy.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusFound, rw.Code)
+		assert.Contains(t, rw.Header().Get("Location"), "accounts.google.com")
+		assert.Contains(t, rw.Header().Get("Location"), "client_id="+clientID)
+	})
+
+	t.Run("selects the requested provider", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/start?provider=github", nil)
+		proxy.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusFound, rw.Code)
+		assert.Contains(t, rw.Header().Get("Location"), "github.com/login/oauth/authorize")
+		assert.Contains(t, rw.Header().Get("Location"), "client_id=github-client-id")
+
+		callbackReq := httptest.NewRequest(http.MethodGet, "/oauth2/callback", nil)
+		for _, c := range rw.Result().Cookies() {
+			callbackReq.AddCookie(c)
+		}
+		csrf, err := cookies.LoadCSRFCookie(callbackReq, cookies.GenerateCookieName(proxy.CookieOptions, ""), proxy.CookieOptions)
+		require.NoError(t, err)
+		assert.Equal(t, "github", csrf.GetProviderID())
+	})
+
+	t.Run("rejects an unknown provider", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/start?provider=unknown", nil)
+		proxy.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+}
+
+func TestOAuthStartWithPushedAuthorizationRequests(t *testing.T) {
+	var pushed url.Values
+	par := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseForm())
+		pushed = req.PostForm
+		rw.Header().Set("Content-Type", "application/json")
+		if req.URL.Path == "/unavailable" {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{"request_uri":"urn:ietf:params:oauth:request_uri:abc","expires_in":60}`))
+	}))
+	defer par.Close()
+
+	newProxy := func(t *testing.T, path string, required bool) *OAuthProxy {
+		opts := baseTestOptions()
+		opts.Providers[0].CodeChallengeMethod = providers.CodeChallengeMethodS256
+		opts.Providers[0].PushedAuthorizationRequestURL = par.URL + path
+		opts.Providers[0].RequirePushedAuthorizationRequests = ptr.To(required)
+		require.NoError(t, validation.Validate(opts))
+
+		proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+		require.NoError(t, err)
+		return proxy
+	}
+
+	t.Run("redirects with the request URI", func(t *testing.T) {
+		proxy := newProxy(t, "/par", false)
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/start", nil))
+
+		require.Equal(t, http.StatusFound, rw.Code)
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "accounts.google.com", location.Host)
+		assert.Equal(t, url.Values{
+			"client_id":   []string{clientID},
+			"request_uri": []string{"urn:ietf:params:oauth:request_uri:abc"},
+		}, location.Query())
+
+		assert.Equal(t, clientID, pushed.Get("client_id"))
+		assert.Equal(t, clientSecret, pushed.Get("client_secret"))
+		assert.Equal(t, "S256", pushed.Get("code_challenge_method"))
+		assert.NotEmpty(t, pushed.Get("code_challenge"))
+		assert.NotEmpty(t, pushed.Get("state"))
+		assert.NotEmpty(t, pushed.Get("redirect_uri"))
+	})
+
+	t.Run("falls back to the login URL", func(t *testing.T) {
+		proxy := newProxy(t, "/unavailable", false)
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/start", nil))
+
+		require.Equal(t, http.StatusFound, rw.Code)
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Empty(t, location.Query().Get("request_uri"))
+		assert.NotEmpty(t, location.Query().Get("code_challenge"))
+	})
+
+	t.Run("fails when pushed authorization requests are required", func(t *testing.T) {
+		proxy := newProxy(t, "/unavailable", true)
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/start", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rw.Code)
+		assert.Empty(t, rw.Header().Get("Location"))
+	})
+}
+
+func TestSignInPageWithMultipleProviders(t *testing.T) {
+	opts := multipleProvidersTestOptions()
+	opts.Providers[1].Name = "GitHub Contractors"
+	err := validation.Validate(opts)
+	require.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/sign_in", nil)
+	proxy.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	body := rw.Body.String()
+	assert.Contains(t, body, `<input type="hidden" name="provider" value="providerID">`)
+	assert.Contains(t, body, `<input type="hidden" name="provider" value="github">`)
+	assert.Contains(t, body, "Sign in with Google")
+	assert.Contains(t, body, "Sign in with GitHub Contractors")
+	assert.NotContains(t, body, `name="email"`)
+}
+
+func TestOAuthStartWithEmailDiscovery(t *testing.T) {
+	opts := multipleProvidersTestOptions()
+	opts.Providers[0].LoginDomains = []string{"corp.com"}
+	opts.Providers[1].LoginDomains = []string{"*"}
+	opts.SkipProviderButton = true
+	err := validation.Validate(opts)
+	require.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	t.Run("sign in page only asks for the email", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/sign_in", nil)
+		proxy.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `name="email"`)
+		assert.NotContains(t, rw.Body.String(), "Sign in with")
+	})
+
+	t.Run("unauthenticated requests are sent to the sign in page", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		proxy.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusForbidden, rw.Code)
+		assert.Contains(t, rw.Body.String(), `name="email"`)
+	})
+
+	testCases := map[string]struct {
+		email        string
+		expectedHost string
+	}{
+		"explicit domain": {
+			email:        "staff@Corp.com",
+			expectedHost: "accounts.google.com",
+		},
+		"wildcard domain": {
+			email:        "contractor@example.com",
+			expectedHost: "github.com",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/oauth2/start?email="+url.QueryEscape(tc.email), nil)
+			proxy.ServeHTTP(rw, req)
+
+			assert.Equal(t, http.StatusFound, rw.Code)
+			location, err := url.Parse(rw.Header().Get("Location"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedHost, location.Host)
+		})
+	}
+}
+
+func TestOAuthCallbackWithMultipleProviders(t *testing.T) {
+	providerServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(`{"access_token": "github_token"}`))
+	}))
+	defer providerServer.Close()
+
+	opts := multipleProvidersTestOptions()
+	err := validation.Validate(opts)
+	require.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	providerURL, _ := url.Parse(providerServer.URL)
+	githubProvider := NewTestProvider(providerURL, "contractor@example.com")
+	githubProvider.ProviderID = "github"
+	githubProvider.ValidToken = true
+	proxy.providersByID["github"] = githubProvider
+
+	csrf, err := cookies.NewCSRF(proxy.CookieOptions, "")
+	require.NoError(t, err)
+	csrf.SetProviderID("github")
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf(
+		"/oauth2/callback?code=callback_code&state=%s",
+		encodeState(csrf.HashOAuthState(), "/", false),
+	), nil)
+	csrfCookie, err := csrf.SetCookie(httptest.NewRecorder(), req)
+	require.NoError(t, err)
+	req.AddCookie(csrfCookie)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusFound, rw.Code)
+
+	sessionReq := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == proxy.CookieOptions.Name {
+			sessionReq.AddCookie(c)
+		}
+	}
+	session, err := proxy.sessionStore.Load(sessionReq)
+	require.NoError(t, err)
+	assert.Equal(t, "github", session.ProviderID)
+	assert.Equal(t, "contractor@example.com", session.Email)
+	assert.Equal(t, "github_token", session.AccessToken)
+}
+
 func baseTestOptions() *options.Options {
 	opts := options.NewOptions()
 	opts.Cookie.Secret = rawCookieSecret
@@ -3497,71 +3953,386 @@ func TestAuthOnlyAllowedEmails(t *testing.T) {
 	}
 }
 
-func TestGetOAuthRedirectURI(t *testing.T) {
-	tests := []struct {
-		name      string
-		setupOpts func(*options.Options) *options.Options
-		req       *http.Request
-		want      string
+func TestAuthOnlyEndpointWithAPIKeys(t *testing.T) {
+	testCases := []struct {
+		name               string
+		header             string
+		value              string
+		querystring        string
+		skipJwtBearer      bool
+		expectedStatusCode int
+		expectedUser       string
+		expectedGroups     string
 	}{
 		{
-			name: "redirect with https schema",
-			setupOpts: func(baseOpts *options.Options) *options.Options {
-				return baseOpts
-			},
-			req: &http.Request{
-				Host: "example",
-				URL: &url.URL{
-					Scheme: schemeHTTPS,
-				},
-			},
-			want: "https://example/oauth2/callback",
+			name:               "KeyInHeader",
+			header:             "X-API-Key",
+			value:              "batch-key",
+			expectedStatusCode: http.StatusAccepted,
+			expectedUser:       "batch",
+			expectedGroups:     "jobs",
 		},
 		{
-			name: "redirect with http schema",
-			setupOpts: func(baseOpts *options.Options) *options.Options {
-				baseOpts.Cookie.Secure = false
-				return baseOpts
-			},
-			req: &http.Request{
-				Host: "example",
-				URL: &url.URL{
-					Scheme: schemeHTTP,
-				},
-			},
-			want: "http://example/oauth2/callback",
+			name:               "KeyInAuthorizationHeader",
+			header:             "Authorization",
+			value:              "ApiKey webhook-key",
+			expectedStatusCode: http.StatusAccepted,
+			expectedUser:       "webhook@example.com",
+			expectedGroups:     "jobs,hooks",
 		},
 		{
-			name: "relative redirect url",
-			setupOpts: func(baseOpts *options.Options) *options.Options {
-				baseOpts.RelativeRedirectURL = true
-				return baseOpts
-			},
-			req:  &http.Request{},
-			want: "/oauth2/callback",
+			name:               "KeyInAuthorizationHeaderWithJWTBearerTokens",
+			header:             "Authorization",
+			value:              "ApiKey webhook-key",
+			skipJwtBearer:      true,
+			expectedStatusCode: http.StatusAccepted,
+			expectedUser:       "webhook@example.com",
+			expectedGroups:     "jobs,hooks",
 		},
 		{
-			name: "proxy prefix",
-			setupOpts: func(baseOpts *options.Options) *options.Options {
-				baseOpts.ProxyPrefix = "/prefix"
-				return baseOpts
-			},
-			req: &http.Request{
-				Host: "example",
-				URL: &url.URL{
-					Scheme: schemeHTTP,
-				},
-			},
-			want: "https://example/prefix/callback",
+			name:               "KeyNotInQuerystringGroup",
+			header:             "X-API-Key",
+			value:              "batch-key",
+			querystring:        "?allowed_groups=hooks",
+			expectedStatusCode: http.StatusForbidden,
 		},
 		{
-			name: "proxy prefix with relative redirect",
-			setupOpts: func(baseOpts *options.Options) *options.Options {
-				baseOpts.ProxyPrefix = "/prefix"
-				baseOpts.RelativeRedirectURL = true
-				return baseOpts
-			},
-			req: &http.Request{
+			name:               "KeyNotInAllowedGroup",
+			header:             "X-API-Key",
+			value:              "other-key",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "ExpiredKey",
+			header:             "X-API-Key",
+			value:              "expired-key",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "UnknownKey",
+			header:             "X-API-Key",
+			value:              "unknown-key",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			webhookKeyHash := sha256.Sum256([]byte("webhook-key"))
+
+			opts := baseTestOptions()
+			opts.Providers[0].AllowedGroups = []string{"jobs"}
+			opts.APIKeys = options.APIKeyOptions{
+				Keys: []options.APIKey{
+					{
+						Key:    &options.SecretSource{Value: []byte("batch-key")},
+						User:   "batch",
+						Groups: []string{"jobs"},
+					},
+					{
+						KeyHash: hex.EncodeToString(webhookKeyHash[:]),
+						Email:   "webhook@example.com",
+						Groups:  []string{"jobs", "hooks"},
+					},
+					{
+						Key:    &options.SecretSource{Value: []byte("other-key")},
+						User:   "other",
+						Groups: []string{"others"},
+					},
+					{
+						Key:       &options.SecretSource{Value: []byte("expired-key")},
+						User:      "expired",
+						Groups:    []string{"jobs"},
+						ExpiresAt: time.Now().Add(-time.Hour).Format(time.RFC3339),
+					},
+				},
+			}
+			opts.APIKeys.EnsureDefaults()
+			// Invalid bearer tokens are denied without the login fallback
+			opts.SkipJwtBearerTokens = tc.skipJwtBearer
+			opts.BearerTokenLoginFallback = false
+			opts.InjectResponseHeaders = []options.Header{
+				{
+					Name: "X-Auth-Request-User",
+					Values: []options.HeaderValue{
+						{ClaimSource: &options.ClaimSource{Claim: "user"}},
+					},
+				},
+				{
+					Name: "X-Auth-Request-Groups",
+					Values: []options.HeaderValue{
+						{ClaimSource: &options.ClaimSource{Claim: "groups"}},
+					},
+				},
+			}
+			err := validation.Validate(opts)
+			assert.NoError(t, err)
+
+			proxy, err := NewOAuthProxy(opts, func(email string) bool {
+				return true
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, opts.ProxyPrefix+authOnlyPath+tc.querystring, nil)
+			req.Header.Set(tc.header, tc.value)
+			proxy.ServeHTTP(rw, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rw.Code)
+			if tc.expectedUser != "" {
+				assert.Equal(t, tc.expectedUser, rw.Header().Get("X-Auth-Request-User"))
+				assert.Equal(t, tc.expectedGroups, rw.Header().Get("X-Auth-Request-Groups"))
+			}
+		})
+	}
+}
+
+func TestClientCertificateAuthentication(t *testing.T) {
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := fmt.Fprintf(w, "%s %s", r.Header.Get("X-Forwarded-User"), r.Header.Get("X-Client-Certificate-Fingerprint"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	t.Cleanup(upstreamServer.Close)
+
+	deviceCert := &x509.Certificate{
+		Raw:     []byte("device"),
+		Subject: pkix.Name{CommonName: "device-1", OrganizationalUnit: []string{"devices"}},
+	}
+	deviceFingerprint := sha256.Sum256(deviceCert.Raw)
+	otherCert := &x509.Certificate{
+		Raw:     []byte("other"),
+		Subject: pkix.Name{CommonName: "other-1", OrganizationalUnit: []string{"others"}},
+	}
+
+	testCases := []struct {
+		name               string
+		tls                *tls.ConnectionState
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "VerifiedCertificate",
+			tls:                &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{deviceCert}}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "device-1 " + hex.EncodeToString(deviceFingerprint[:]),
+		},
+		{
+			name:               "CertificateNotInAllowedGroup",
+			tls:                &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{otherCert}}},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "UnverifiedCertificate",
+			tls:                &tls.ConnectionState{PeerCertificates: []*x509.Certificate{deviceCert}},
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := baseTestOptions()
+			opts.UpstreamServers = options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   upstreamServer.URL,
+						Path: "/",
+						URI:  upstreamServer.URL,
+					},
+				},
+			}
+			opts.Providers[0].AllowedGroups = []string{"devices"}
+			opts.Server.TLS = &options.TLS{
+				ClientCA: &options.SecretSource{FromFile: "/etc/ssl/client-ca.pem"},
+			}
+			opts.ClientCertificateAuth = &options.ClientCertificateAuthOptions{
+				FingerprintHeader: "X-Client-Certificate-Fingerprint",
+			}
+			opts.ClientCertificateAuth.EnsureDefaults()
+			opts.InjectRequestHeaders = []options.Header{
+				{
+					Name: "X-Forwarded-User",
+					Values: []options.HeaderValue{
+						{ClaimSource: &options.ClaimSource{Claim: "user"}},
+					},
+				},
+			}
+			err := validation.Validate(opts)
+			assert.NoError(t, err)
+
+			proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.TLS = tc.tls
+			// Fingerprints sent by clients must not reach the upstream
+			req.Header.Set("X-Client-Certificate-Fingerprint", "spoofed")
+			proxy.ServeHTTP(rw, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rw.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpstreamAuthorization(t *testing.T) {
+	opts := baseTestOptions()
+	opts.UpstreamServers = options.UpstreamConfig{
+		Upstreams: []options.Upstream{
+			{
+				ID:     "grafana",
+				Path:   "/grafana/",
+				Static: ptr.To(true),
+				Authorization: []options.AuthorizationRule{
+					{AllowedGroups: []string{"users"}},
+				},
+			},
+			{
+				ID:     "admin",
+				Path:   "/admin/",
+				Static: ptr.To(true),
+				Authorization: []options.AuthorizationRule{
+					{AllowedGroups: []string{"admins"}},
+				},
+			},
+		},
+	}
+	opts.SkipAuthRoutes = []string{"^/admin/health$"}
+	opts.APIKeys = options.APIKeyOptions{
+		Keys: []options.APIKey{
+			{
+				Key:    &options.SecretSource{Value: []byte("user-key")},
+				User:   "user",
+				Groups: []string{"users"},
+			},
+		},
+	}
+	opts.APIKeys.EnsureDefaults()
+	opts.UpstreamServers.EnsureDefaults()
+	err := validation.Validate(opts)
+	assert.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+	}{
+		{
+			name:               "AllowedUpstream",
+			path:               "/grafana/",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "ForbiddenUpstream",
+			path:               "/admin/",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "SkippedAuthRoute",
+			path:               "/admin/health",
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("X-API-Key", "user-key")
+			proxy.ServeHTTP(rw, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rw.Code)
+		})
+	}
+
+	t.Run("RecordsUpstreamInScope", func(t *testing.T) {
+		scope := &middlewareapi.RequestScope{}
+		req := middlewareapi.AddRequestScope(httptest.NewRequest(http.MethodGet, "/admin/users", nil), scope)
+
+		allowed := proxy.authorizeUpstream(req, &sessions.SessionState{User: "admin", Groups: []string{"admins"}})
+		assert.True(t, allowed)
+		assert.Equal(t, "admin", scope.Upstream)
+	})
+}
+
+func TestGetOAuthRedirectURI(t *testing.T) {
+	tests := []struct {
+		name      string
+		setupOpts func(*options.Options) *options.Options
+		req       *http.Request
+		want      string
+	}{
+		{
+			name: "redirect with https schema",
+			setupOpts: func(baseOpts *options.Options) *options.Options {
+				return baseOpts
+			},
+			req: &http.Request{
+				Host: "example",
+				URL: &url.URL{
+					Scheme: schemeHTTPS,
+				},
+			},
+			want: "https://example/oauth2/callback",
+		},
+		{
+			name: "redirect with http schema",
+			setupOpts: func(baseOpts *options.Options) *options.Options {
+				baseOpts.Cookie.Secure = false
+				return baseOpts
+			},
+			req: &http.Request{
+				Host: "example",
+				URL: &url.URL{
+					Scheme: schemeHTTP,
+				},
+			},
+			want: "http://example/oauth2/callback",
+		},
+		{
+			name: "relative redirect url",
+			setupOpts: func(baseOpts *options.Options) *options.Options {
+				baseOpts.RelativeRedirectURL = true
+				return baseOpts
+			},
+			req:  &http.Request{},
+			want: "/oauth2/callback",
+		},
+		{
+			name: "proxy prefix",
+			setupOpts: func(baseOpts *options.Options) *options.Options {
+				baseOpts.ProxyPrefix = "/prefix"
+				return baseOpts
+			},
+			req: &http.Request{
+				Host: "example",
+				URL: &url.URL{
+					Scheme: schemeHTTP,
+				},
+			},
+			want: "https://example/prefix/callback",
+		},
+		{
+			name: "proxy prefix with relative redirect",
+			setupOpts: func(baseOpts *options.Options) *options.Options {
+				baseOpts.ProxyPrefix = "/prefix"
+				baseOpts.RelativeRedirectURL = true
+				return baseOpts
+			},
+			req: &http.Request{
 				Host: "example",
 				URL: &url.URL{
 					Scheme: schemeHTTP,
@@ -3583,3 +4354,574 @@ func TestGetOAuthRedirectURI(t *testing.T) {
 		})
 	}
 }
+
+func TestSAMLLogin(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	idpMetadata, err := xml.Marshal(idp.Metadata())
+	require.NoError(t, err)
+	idpMetadataFile := filepath.Join(t.TempDir(), "idp-metadata.xml")
+	require.NoError(t, os.WriteFile(idpMetadataFile, idpMetadata, 0600))
+
+	opts := baseTestOptions()
+	opts.Providers = append(opts.Providers, options.Provider{
+		ID:       "saml",
+		Type:     options.SAMLProvider,
+		ClientID: "https://example.com/oauth2/saml/metadata",
+		SAMLConfig: options.SAMLOptions{
+			IDPMetadataFile: idpMetadataFile,
+		},
+	})
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	// The identity provider is configured with the service provider metadata
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/saml/metadata?provider=saml", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/samlmetadata+xml", rw.Header().Get("Content-Type"))
+	spMetadata := &saml.EntityDescriptor{}
+	require.NoError(t, xml.Unmarshal(rw.Body.Bytes(), spMetadata))
+	assert.Equal(t, "https://example.com/oauth2/saml/metadata", spMetadata.EntityID)
+	assert.Equal(t, "https://example.com/oauth2/saml/acs", spMetadata.SPSSODescriptors[0].AssertionConsumerServices[0].Location)
+
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/saml/metadata", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+
+	// Starting the login redirects to the identity provider
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/start?provider=saml&rd=/app", nil))
+	require.Equal(t, http.StatusFound, rw.Code)
+	loginURL, err := url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", loginURL.Host)
+	csrfCookies := rw.Result().Cookies()
+	require.Len(t, csrfCookies, 1)
+
+	compressed, err := base64.StdEncoding.DecodeString(loginURL.Query().Get("SAMLRequest"))
+	require.NoError(t, err)
+	rawRequest, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	require.NoError(t, err)
+	authnRequest := saml.AuthnRequest{}
+	require.NoError(t, xml.Unmarshal(rawRequest, &authnRequest))
+	assert.Equal(t, "https://example.com/oauth2/saml/acs", authnRequest.AssertionConsumerServiceURL)
+
+	// The identity provider posts its response to the assertion consumer service
+	idpRequest := &saml.IdpAuthnRequest{
+		IDP:                     idp,
+		HTTPRequest:             httptest.NewRequest(http.MethodPost, authnRequest.AssertionConsumerServiceURL, nil),
+		Request:                 authnRequest,
+		ServiceProviderMetadata: spMetadata,
+		SPSSODescriptor:         &spMetadata.SPSSODescriptors[0],
+		ACSEndpoint:             &spMetadata.SPSSODescriptors[0].AssertionConsumerServices[0],
+		RelayState:              loginURL.Query().Get("RelayState"),
+		Now:                     saml.TimeNow(),
+	}
+	require.NoError(t, saml.DefaultAssertionMaker{}.MakeAssertion(idpRequest, &saml.Session{
+		NameID:       "jdoe@example.com",
+		NameIDFormat: string(saml.EmailAddressNameIDFormat),
+	}))
+	form, err := idpRequest.PostBinding()
+	require.NoError(t, err)
+
+	acsForm := url.Values{"SAMLResponse": {form.SAMLResponse}, "RelayState": {form.RelayState}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/saml/acs", strings.NewReader(acsForm.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(csrfCookies[0])
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusFound, rw.Code, rw.Body.String())
+	assert.Equal(t, "/app", rw.Header().Get("Location"))
+
+	sessionReq := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == proxy.CookieOptions.Name {
+			sessionReq.AddCookie(c)
+		}
+	}
+	session, err := proxy.sessionStore.Load(sessionReq)
+	require.NoError(t, err)
+	assert.Equal(t, "saml", session.ProviderID)
+	assert.Equal(t, "jdoe@example.com", session.Email)
+	assert.Equal(t, "jdoe@example.com", session.User)
+}
+
+func TestMockProviderLogin(t *testing.T) {
+	opts := baseTestOptions()
+	mockProvider := options.Provider{
+		ID:           "mock",
+		Type:         options.MockProvider,
+		ClientID:     "mock-client",
+		ClientSecret: "mock-secret",
+		MockConfig: options.MockOptions{
+			InsecureDevelopmentMode: true,
+			Users: []options.MockUser{
+				{Email: "jane@example.com", Groups: []string{"admins"}},
+			},
+		},
+	}
+	mockProvider.EnsureDefaults()
+	opts.Providers = append(opts.Providers, mockProvider)
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	// Starting the login redirects to the authorize endpoint served by the proxy
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/start?provider=mock&rd=/app", nil))
+	require.Equal(t, http.StatusFound, rw.Code)
+	loginURL, err := url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth2/mock/authorize", loginURL.Path)
+	csrfCookies := rw.Result().Cookies()
+	require.Len(t, csrfCookies, 1)
+
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, loginURL.RequestURI(), nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `value="jane@example.com"`)
+
+	// Picking a user redirects back to the callback with a code
+	req := httptest.NewRequest(http.MethodPost, loginURL.RequestURI(), strings.NewReader("email=jane%40example.com"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusFound, rw.Code, rw.Body.String())
+	callbackURL, err := url.Parse(rw.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth2/callback", callbackURL.Path)
+
+	req = httptest.NewRequest(http.MethodGet, callbackURL.RequestURI(), nil)
+	req.AddCookie(csrfCookies[0])
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusFound, rw.Code, rw.Body.String())
+	assert.Equal(t, "/app", rw.Header().Get("Location"))
+
+	sessionReq := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == proxy.CookieOptions.Name {
+			sessionReq.AddCookie(c)
+		}
+	}
+	session, err := proxy.sessionStore.Load(sessionReq)
+	require.NoError(t, err)
+	assert.Equal(t, "mock", session.ProviderID)
+	assert.Equal(t, "jane@example.com", session.Email)
+	assert.Equal(t, []string{"admins"}, session.Groups)
+
+	// The endpoints are only served for mock providers
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/mock/jwks", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestBackchannelLogout(t *testing.T) {
+	opts := baseTestOptions()
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+	proxy.sessionStore = persistence.NewManager(sessionstests.NewMockStore(), &opts.Cookie)
+
+	providerData := proxy.provider.Data()
+	providerData.Verifier = internaloidc.NewVerifier(
+		oidc.NewVerifier("https://issuer.example.com", NoOpKeySet{}, &oidc.Config{
+			ClientID:        "client",
+			SkipExpiryCheck: true,
+		}),
+		internaloidc.IDTokenVerificationOptions{
+			AudienceClaims: []string{"aud"},
+			ClientID:       "client",
+		},
+	)
+
+	makeLogoutToken := func(claims map[string]interface{}) string {
+		claims["iss"] = "https://issuer.example.com"
+		claims["aud"] = "client"
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err)
+		return "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl"
+	}
+	backchannelLogoutEvents := map[string]interface{}{
+		internaloidc.BackchannelLogoutEvent: map[string]interface{}{},
+	}
+
+	saveSession := func(sid string) []*http.Cookie {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		require.NoError(t, proxy.SaveSession(rw, req, &sessions.SessionState{
+			Email:      "john.doe@example.com",
+			User:       "john.doe",
+			SID:        sid,
+			Subject:    "248289761001",
+			ProviderID: providerData.ProviderID,
+		}))
+		return rw.Result().Cookies()
+	}
+
+	loadSession := func(cookies []*http.Cookie) (*sessions.SessionState, error) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		return proxy.LoadCookiedSession(req)
+	}
+
+	backchannelLogout := func(method, logoutToken string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		if logoutToken != "" {
+			form.Set("logout_token", logoutToken)
+		}
+		req := httptest.NewRequest(method, "/oauth2/backchannel-logout", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, req)
+		return rw
+	}
+
+	t.Run("revokes the session matching the sid", func(t *testing.T) {
+		revoked := saveSession("sid-1")
+		kept := saveSession("sid-2")
+
+		rw := backchannelLogout(http.MethodPost, makeLogoutToken(map[string]interface{}{
+			"sub":    "248289761001",
+			"sid":    "sid-1",
+			"events": backchannelLogoutEvents,
+		}))
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "no-cache, no-store, must-revalidate, max-age=0", rw.Header().Get("Cache-Control"))
+
+		_, err := loadSession(revoked)
+		assert.Error(t, err)
+		session, err := loadSession(kept)
+		require.NoError(t, err)
+		assert.Equal(t, "sid-2", session.SID)
+	})
+
+	t.Run("revokes all sessions matching the sub", func(t *testing.T) {
+		first := saveSession("sid-1")
+		second := saveSession("")
+
+		rw := backchannelLogout(http.MethodPost, makeLogoutToken(map[string]interface{}{
+			"sub":    "248289761001",
+			"events": backchannelLogoutEvents,
+		}))
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		_, err := loadSession(first)
+		assert.Error(t, err)
+		_, err = loadSession(second)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects a token without the back-channel logout event", func(t *testing.T) {
+		cookies := saveSession("sid-1")
+
+		rw := backchannelLogout(http.MethodPost, makeLogoutToken(map[string]interface{}{
+			"sub": "248289761001",
+			"sid": "sid-1",
+		}))
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.JSONEq(t, `{"error":"invalid_request","error_description":"logout token does not contain the back-channel logout event"}`, rw.Body.String())
+
+		_, err := loadSession(cookies)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects a request without a logout token", func(t *testing.T) {
+		rw := backchannelLogout(http.MethodPost, "")
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("rejects a GET request", func(t *testing.T) {
+		rw := backchannelLogout(http.MethodGet, "")
+		assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+	})
+}
+
+func TestBackchannelLogoutWithCookieSessionStore(t *testing.T) {
+	opts := baseTestOptions()
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/oauth2/backchannel-logout", nil))
+	assert.Equal(t, http.StatusNotImplemented, rw.Code)
+}
+
+func TestDeviceAuthorization(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseForm())
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/device":
+			_, _ = rw.Write([]byte(`{"device_code":"device-code","user_code":"ABCD-EFGH","verification_uri":"https://idp.example.com/device","expires_in":600,"interval":5}`))
+		case "/token":
+			switch req.PostForm.Get("device_code") {
+			case "pending":
+				rw.WriteHeader(http.StatusBadRequest)
+				_, _ = rw.Write([]byte(`{"error":"authorization_pending"}`))
+			case "outsider":
+				_, _ = rw.Write([]byte(`{"access_token":"outsider-token","token_type":"Bearer","expires_in":3600}`))
+			case "revoked":
+				_, _ = rw.Write([]byte(`{"access_token":"revoked-token","token_type":"Bearer","expires_in":3600}`))
+			default:
+				_, _ = rw.Write([]byte(`{"access_token":"access-token","token_type":"Bearer","expires_in":3600}`))
+			}
+		case "/validate":
+			if req.Header.Get("Authorization") == "Bearer revoked-token" {
+				rw.WriteHeader(http.StatusUnauthorized)
+			}
+		case "/profile":
+			if req.Header.Get("Authorization") == "Bearer outsider-token" {
+				_, _ = rw.Write([]byte(`{"sub":"456","email":"jane@outsider.com"}`))
+				return
+			}
+			_, _ = rw.Write([]byte(`{"sub":"123","email":"john@example.com"}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer idp.Close()
+
+	opts := baseTestOptions()
+	opts.Providers[0].Type = options.GenericOAuth2Provider
+	opts.Providers[0].LoginURL = idp.URL + "/authorize"
+	opts.Providers[0].RedeemURL = idp.URL + "/token"
+	opts.Providers[0].ProfileURL = idp.URL + "/profile"
+	opts.Providers[0].ValidateURL = idp.URL + "/validate"
+	opts.Providers[0].DeviceAuthorizationGrant = ptr.To(true)
+	opts.Providers[0].DeviceAuthorizationURL = idp.URL + "/device"
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(email string) bool { return strings.HasSuffix(email, "@example.com") })
+	require.NoError(t, err)
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) map[string]interface{} {
+		body := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body
+	}
+
+	t.Run("start returns the user code", func(t *testing.T) {
+		rec := post("/oauth2/device/start", url.Values{})
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := decode(rec)
+		assert.Equal(t, "device-code", body["device_code"])
+		assert.Equal(t, "ABCD-EFGH", body["user_code"])
+		assert.Equal(t, "https://idp.example.com/device", body["verification_uri"])
+	})
+
+	t.Run("start requires a POST request", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/oauth2/device/start", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("start with an unknown provider", func(t *testing.T) {
+		rec := post("/oauth2/device/start", url.Values{"provider": {"unknown"}})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "unsupported_grant_type", decode(rec)["error"])
+	})
+
+	t.Run("poll while the authorization is pending", func(t *testing.T) {
+		rec := post("/oauth2/device/poll", url.Values{"device_code": {"pending"}})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "authorization_pending", decode(rec)["error"])
+	})
+
+	t.Run("poll returns a session cookie", func(t *testing.T) {
+		rec := post("/oauth2/device/poll", url.Values{"device_code": {"device-code"}})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Values("Set-Cookie"))
+		cookie, ok := decode(rec)["cookie"].(string)
+		require.True(t, ok)
+		require.NotEmpty(t, cookie)
+
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/userinfo", nil)
+		req.Header.Set("Cookie", cookie)
+		userInfo := httptest.NewRecorder()
+		proxy.ServeHTTP(userInfo, req)
+		require.Equal(t, http.StatusOK, userInfo.Code)
+		assert.Equal(t, "john@example.com", decode(userInfo)["email"])
+	})
+
+	t.Run("poll returns the provider tokens", func(t *testing.T) {
+		rec := post("/oauth2/device/poll", url.Values{"device_code": {"device-code"}, "response": {"tokens"}})
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := decode(rec)
+		assert.Equal(t, "access-token", body["access_token"])
+		assert.Equal(t, "Bearer", body["token_type"])
+	})
+
+	t.Run("poll with an invalid response", func(t *testing.T) {
+		rec := post("/oauth2/device/poll", url.Values{"device_code": {"device-code"}, "response": {"code"}})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("poll denies users rejected by the email validator", func(t *testing.T) {
+		rec := post("/oauth2/device/poll", url.Values{"device_code": {"outsider"}})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "access_denied", decode(rec)["error"])
+	})
+
+	t.Run("poll denies sessions that fail validation", func(t *testing.T) {
+		rec := post("/oauth2/device/poll", url.Values{"device_code": {"revoked"}})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		body := decode(rec)
+		assert.Equal(t, "access_denied", body["error"])
+		assert.Equal(t, "session validation failed", body["error_description"])
+	})
+}
+
+func TestDeviceAuthorizationNotEnabled(t *testing.T) {
+	opts := baseTestOptions()
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/device/start", nil)
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUpstreamTokenExchanger(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseForm())
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.PostForm.Get("audience") {
+		case "forbidden":
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"error":"invalid_target"}`))
+		case "misconfigured":
+			rw.WriteHeader(http.StatusUnauthorized)
+			_, _ = rw.Write([]byte(`{"error":"invalid_client"}`))
+		default:
+			_, _ = rw.Write([]byte(`{"access_token":"` + req.URL.Path + `","token_type":"Bearer","expires_in":300}`))
+		}
+	}))
+	defer idp.Close()
+
+	newProvider := func(path string) providers.Provider {
+		redeemURL, err := url.Parse(idp.URL + path)
+		require.NoError(t, err)
+		provider := NewTestProvider(redeemURL, "john@example.com")
+		provider.RedeemURL = redeemURL
+		return provider
+	}
+	defaultProvider := newProvider("/default")
+	exchanger := newUpstreamTokenExchanger(map[string]providers.Provider{
+		"default": defaultProvider,
+		"other":   newProvider("/other"),
+	}, defaultProvider)
+
+	testCases := []struct {
+		name          string
+		providerID    string
+		audience      string
+		expectedToken string
+		expectDenied  bool
+		expectedError string
+	}{
+		{name: "with the session provider", providerID: "other", audience: "backend", expectedToken: "/other"},
+		{name: "without a session provider", audience: "backend", expectedToken: "/default"},
+		{name: "with an unknown provider", providerID: "unknown", audience: "backend", expectDenied: true, expectedError: `token exchange denied: session was created by unknown provider "unknown"`},
+		{name: "when the exchange is denied", audience: "forbidden", expectDenied: true, expectedError: "token exchange denied: invalid_target"},
+		{name: "when the provider rejects the client", audience: "misconfigured", expectedError: "invalid_client"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			session := &sessions.SessionState{AccessToken: "user-token", ProviderID: tc.providerID}
+			token, err := exchanger(context.Background(), session, options.TokenExchange{Audience: tc.audience})
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				assert.Equal(t, tc.expectDenied, errors.Is(err, upstream.ErrTokenExchangeDenied))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedToken, token.AccessToken)
+		})
+	}
+}
+
+func TestTokenIntrospection(t *testing.T) {
+	introspections := 0
+	idp := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseForm())
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/introspect":
+			introspections++
+			if req.PostForm.Get("token") == "opaque-token" {
+				_, _ = rw.Write([]byte(`{"active":true,"client_id":"` + clientID + `","sub":"123","email":"john@example.com","scope":"read"}`))
+				return
+			}
+			if req.PostForm.Get("token") == "other-client-token" {
+				_, _ = rw.Write([]byte(`{"active":true,"client_id":"other-client","sub":"456","email":"jane@example.com"}`))
+				return
+			}
+			_, _ = rw.Write([]byte(`{"active":false}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer idp.Close()
+
+	opts := baseTestOptions()
+	opts.SkipJwtBearerTokens = true
+	opts.Providers[0].Type = options.GenericOAuth2Provider
+	opts.Providers[0].LoginURL = idp.URL + "/authorize"
+	opts.Providers[0].RedeemURL = idp.URL + "/token"
+	opts.Providers[0].ProfileURL = idp.URL + "/profile"
+	opts.Providers[0].TokenIntrospection = ptr.To(true)
+	opts.Providers[0].IntrospectionURL = idp.URL + "/introspect"
+	require.NoError(t, validation.Validate(opts))
+
+	proxy, err := NewOAuthProxy(opts, func(email string) bool { return strings.HasSuffix(email, "@example.com") })
+	require.NoError(t, err)
+
+	userInfo := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/userinfo", nil)
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("with an active opaque token", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			rec := userInfo("Bearer opaque-token")
+			require.Equal(t, http.StatusOK, rec.Code)
+			body := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, "john@example.com", body["email"])
+		}
+		// The second request is served from the introspection cache
+		assert.Equal(t, 1, introspections)
+	})
+
+	t.Run("with an inactive opaque token", func(t *testing.T) {
+		rec := userInfo("Bearer revoked-token")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("with a token issued to another client", func(t *testing.T) {
+		rec := userInfo("Bearer other-client-token")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -41,10 +41,25 @@ type AlphaOptions struct {
 	// To use the secure server you must configure a TLS certificate and key.
 	MetricsServer Server `yaml:"metricsServer,omitempty"`
 
-	// Providers is used to configure your provider. **Multiple-providers is not
-	// yet working.** [This feature is tracked in
-	// #925](https://github.com/oauth2-proxy/oauth2-proxy/issues/926)
+	// Providers is used to configure your providers. Each provider must have
+	// a unique ID. The first provider is used by default, other providers are
+	// selected with the `provider` query parameter on the start endpoint.
 	Providers Providers `yaml:"providers,omitempty"`
+
+	// LDAP is used to validate the users of the sign in form and of basic
+	// auth against an LDAP directory, alongside or instead of the htpasswd
+	// file.
+	LDAP LDAPOptions `yaml:"ldap,omitempty"`
+
+	// APIKeys is used to authenticate non-interactive clients with static
+	// API keys. Each key authenticates as a user whose session is authorized
+	// and passed upstream like the sessions of users that logged in.
+	APIKeys APIKeyOptions `yaml:"apiKeys,omitempty"`
+
+	// ClientCertificateAuth is used to authenticate clients with the
+	// certificates they present to the server, when the server verifies
+	// client certificates against a client CA.
+	ClientCertificateAuth *ClientCertificateAuthOptions `yaml:"clientCertificateAuth,omitempty"`
 }
 
 // Initialize alpha options with default values and settings of the core options
@@ -63,6 +78,9 @@ func (a *AlphaOptions) ExtractFrom(opts *Options) {
 	a.Server = opts.Server
 	a.MetricsServer = opts.MetricsServer
 	a.Providers = opts.Providers
+	a.LDAP = opts.LDAP
+	a.APIKeys = opts.APIKeys
+	a.ClientCertificateAuth = opts.ClientCertificateAuth
 }
 
 // MergeOptionsWithDefaults replaces alpha options in the Options struct
@@ -74,4 +92,7 @@ func (a *AlphaOptions) MergeOptionsWithDefaults(opts *Options) {
 	opts.Server = a.Server
 	opts.MetricsServer = a.MetricsServer
 	opts.Providers = a.Providers
+	opts.LDAP = a.LDAP
+	opts.APIKeys = a.APIKeys
+	opts.ClientCertificateAuth = a.ClientCertificateAuth
 }
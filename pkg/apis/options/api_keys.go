@@ -0,0 +1,67 @@
+package options
+
+// DefaultAPIKeyHeader is the default value for APIKeyOptions.Header
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// APIKeyOptions contains the configuration for authenticating
+// non-interactive clients, such as batch jobs and webhooks, with static API
+// keys.
+type APIKeyOptions struct {
+	// Header is the request header API keys are presented in. Keys can also
+	// be presented with the `ApiKey` scheme of the Authorization header.
+	// default set to 'X-API-Key'
+	Header string `yaml:"header,omitempty"`
+
+	// Keys are the API keys and the users they authenticate as
+	Keys []APIKey `yaml:"keys,omitempty"`
+
+	// KeysFile is the path to a YAML file with a list of further API keys,
+	// in the same format as Keys. The file is reloaded when it changes.
+	KeysFile string `yaml:"keysFile,omitempty"`
+}
+
+// APIKey is an API key and the user it authenticates as. Only one of Key
+// and KeyHash should be set.
+type APIKey struct {
+	// Key is the API key
+	Key *SecretSource `yaml:"key,omitempty"`
+
+	// KeyHash is the hex encoded SHA-256 hash of the API key, so that the key
+	// itself doesn't have to be stored in the configuration
+	KeyHash string `yaml:"keyHash,omitempty"`
+
+	// User is the user of the session the key authenticates as.
+	// It defaults to the Email when it is empty.
+	User string `yaml:"user,omitempty"`
+
+	// Email is the email address of the session the key authenticates as
+	Email string `yaml:"email,omitempty"`
+
+	// Groups are the groups of the session the key authenticates as
+	Groups []string `yaml:"groups,omitempty"`
+
+	// ExpiresAt is when the key expires, in RFC 3339 format,
+	// e.g. `2030-01-01T00:00:00Z`. Keys don't expire when it is empty.
+	ExpiresAt string `yaml:"expiresAt,omitempty"`
+}
+
+// Enabled returns whether any API keys are configured
+func (a *APIKeyOptions) Enabled() bool {
+	return len(a.Keys) > 0 || a.KeysFile != ""
+}
+
+// EnsureDefaults sets any default values for APIKeyOptions fields when API
+// keys are configured.
+func (a *APIKeyOptions) EnsureDefaults() {
+	if !a.Enabled() {
+		return
+	}
+	if a.Header == "" {
+		a.Header = DefaultAPIKeyHeader
+	}
+	for i := range a.Keys {
+		if a.Keys[i].Key != nil {
+			a.Keys[i].Key.EnsureDefaults()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package options
+
+const (
+	// DefaultClientCertificateUserField is the default value for
+	// ClientCertificateAuthOptions.UserField
+	DefaultClientCertificateUserField = ClientCertificateCommonName
+
+	// DefaultClientCertificateGroupsField is the default value for
+	// ClientCertificateAuthOptions.GroupsField
+	DefaultClientCertificateGroupsField = ClientCertificateOrganizationalUnit
+)
+
+// ClientCertificateField is a field of a client certificate that users and
+// groups are read from.
+// Valid options are: commonName, email, uri and organizationalUnit.
+type ClientCertificateField string
+
+const (
+	// ClientCertificateCommonName is the common name of the subject
+	ClientCertificateCommonName ClientCertificateField = "commonName"
+
+	// ClientCertificateEmail are the email address SANs
+	ClientCertificateEmail ClientCertificateField = "email"
+
+	// ClientCertificateURI are the URI SANs, such as SPIFFE IDs
+	ClientCertificateURI ClientCertificateField = "uri"
+
+	// ClientCertificateOrganizationalUnit are the organizational units of the
+	// subject
+	ClientCertificateOrganizationalUnit ClientCertificateField = "organizationalUnit"
+)
+
+// ClientCertificateAuthOptions contains the configuration for authenticating
+// clients with the certificates they present to the TLS server.
+// The server must verify client certificates against a ClientCA, only
+// verified certificates are used to authenticate.
+type ClientCertificateAuthOptions struct {
+	// UserField is the field of the certificate that is the user of the
+	// session. The first value of the field is used.
+	// Valid options are: commonName, email and uri.
+	// default set to 'commonName'
+	UserField ClientCertificateField `yaml:"userField,omitempty"`
+
+	// GroupsField is the field of the certificate whose values are the
+	// groups of the session.
+	// Valid options are: organizationalUnit and uri.
+	// default set to 'organizationalUnit'
+	GroupsField ClientCertificateField `yaml:"groupsField,omitempty"`
+
+	// CertificateHeader is the header the URL encoded PEM certificate of the
+	// client is passed to upstreams in. The certificate isn't passed when it
+	// is empty.
+	CertificateHeader string `yaml:"certificateHeader,omitempty"`
+
+	// FingerprintHeader is the header the hex encoded SHA-256 fingerprint of
+	// the certificate of the client is passed to upstreams in. The
+	// fingerprint isn't passed when it is empty.
+	FingerprintHeader string `yaml:"fingerprintHeader,omitempty"`
+}
+
+// EnsureDefaults sets any default values for ClientCertificateAuthOptions
+// fields.
+func (c *ClientCertificateAuthOptions) EnsureDefaults() {
+	if c.UserField == "" {
+		c.UserField = DefaultClientCertificateUserField
+	}
+	if c.GroupsField == "" {
+		c.GroupsField = DefaultClientCertificateGroupsField
+	}
+}
@@ -0,0 +1,132 @@
+package options
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+)
+
+const (
+	// DefaultLDAPUserFilter is the default value for LDAPOptions.UserFilter
+	DefaultLDAPUserFilter = "(uid={username})"
+
+	// DefaultLDAPMemberOfAttribute is the default value for
+	// LDAPOptions.MemberOfAttribute
+	DefaultLDAPMemberOfAttribute = "memberOf"
+
+	// DefaultLDAPGroupFilter is the default value for LDAPOptions.GroupFilter
+	DefaultLDAPGroupFilter = "(member={dn})"
+
+	// DefaultLDAPGroupNameAttribute is the default value for
+	// LDAPOptions.GroupNameAttribute
+	DefaultLDAPGroupNameAttribute = "cn"
+
+	// DefaultLDAPTimeout is the default value for LDAPOptions.Timeout
+	DefaultLDAPTimeout time.Duration = 10 * time.Second
+
+	// DefaultLDAPCacheTTL is the default value for LDAPOptions.CacheTTL
+	DefaultLDAPCacheTTL time.Duration = 30 * time.Second
+)
+
+// LDAPOptions contains the configuration for validating the users of the
+// sign in form and of basic auth against an LDAP directory, alongside or
+// instead of an htpasswd file.
+type LDAPOptions struct {
+	// URL is the URL of the LDAP server, e.g. `ldaps://ldap.example.com`.
+	// LDAP is disabled when it is empty.
+	URL string `yaml:"url,omitempty"`
+
+	// StartTLS upgrades `ldap://` connections to TLS before binding.
+	// Either an `ldaps://` URL or StartTLS is required, so that passwords are
+	// never sent in the clear.
+	StartTLS bool `yaml:"startTLS,omitempty"`
+
+	// CAFiles is a list of paths to CA certificates that should be used when
+	// connecting to the LDAP server.
+	// If not specified, the default Go trust sources are used instead.
+	CAFiles []string `yaml:"caFiles,omitempty"`
+
+	// InsecureSkipTLSVerify skips verifying the certificate of the LDAP server
+	InsecureSkipTLSVerify bool `yaml:"insecureSkipTLSVerify,omitempty"`
+
+	// BindDN is the DN of the service account users and groups are searched
+	// with. The directory is searched anonymously when it is empty.
+	BindDN string `yaml:"bindDN,omitempty"`
+
+	// BindPassword is the password of the service account
+	BindPassword *SecretSource `yaml:"bindPassword,omitempty"`
+
+	// UserBaseDN is the DN users are searched under
+	UserBaseDN string `yaml:"userBaseDN,omitempty"`
+
+	// UserFilter is the filter users are searched with. `{username}` is
+	// replaced with the escaped username the user signs in with.
+	// default set to '(uid={username})'
+	UserFilter string `yaml:"userFilter,omitempty"`
+
+	// MemberOfAttribute is the attribute of the user entry listing the DNs of
+	// the groups of the user. It is used when GroupBaseDN is empty.
+	// default set to 'memberOf'
+	MemberOfAttribute string `yaml:"memberOfAttribute,omitempty"`
+
+	// GroupBaseDN is the DN groups are searched under. When it is set, the
+	// groups of the user are searched with the GroupFilter instead of read
+	// from the MemberOfAttribute.
+	GroupBaseDN string `yaml:"groupBaseDN,omitempty"`
+
+	// GroupFilter is the filter groups are searched with. `{dn}` is replaced
+	// with the escaped DN of the user and `{username}` with the escaped
+	// username.
+	// default set to '(member={dn})'
+	GroupFilter string `yaml:"groupFilter,omitempty"`
+
+	// GroupNameAttribute is the attribute of the groups of the user that is
+	// set as the group name in the session. With the MemberOfAttribute, it is
+	// taken from the group DN when it is the attribute of its first RDN.
+	// default set to 'cn'
+	GroupNameAttribute string `yaml:"groupNameAttribute,omitempty"`
+
+	// NestedGroups also adds the groups the groups of the user are members
+	// of, recursively.
+	// default set to 'false'
+	NestedGroups bool `yaml:"nestedGroups,omitempty"`
+
+	// Timeout is the timeout of the requests to the LDAP server.
+	// default set to '10s'
+	Timeout *time.Duration `yaml:"timeout,omitempty"`
+
+	// CacheTTL is how long successful logins are cached for, so that repeated
+	// basic auth requests don't bind to the directory each time. Failed
+	// logins are never cached. Set to 0 to disable the cache.
+	// default set to '30s'
+	CacheTTL *time.Duration `yaml:"cacheTTL,omitempty"`
+}
+
+// EnsureDefaults sets any default values for LDAPOptions fields when LDAP is
+// enabled.
+func (l *LDAPOptions) EnsureDefaults() {
+	if l.URL == "" {
+		return
+	}
+	if l.UserFilter == "" {
+		l.UserFilter = DefaultLDAPUserFilter
+	}
+	if l.MemberOfAttribute == "" {
+		l.MemberOfAttribute = DefaultLDAPMemberOfAttribute
+	}
+	if l.GroupFilter == "" {
+		l.GroupFilter = DefaultLDAPGroupFilter
+	}
+	if l.GroupNameAttribute == "" {
+		l.GroupNameAttribute = DefaultLDAPGroupNameAttribute
+	}
+	if l.Timeout == nil {
+		l.Timeout = ptr.To(DefaultLDAPTimeout)
+	}
+	if l.CacheTTL == nil {
+		l.CacheTTL = ptr.To(DefaultLDAPCacheTTL)
+	}
+	if l.BindPassword != nil {
+		l.BindPassword.EnsureDefaults()
+	}
+}
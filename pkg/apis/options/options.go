@@ -52,6 +52,10 @@ type Options struct {
 
 	Providers Providers `cfg:",internal"`
 
+	LDAP                  LDAPOptions                   `cfg:",internal"`
+	APIKeys               APIKeyOptions                 `cfg:",internal"`
+	ClientCertificateAuth *ClientCertificateAuthOptions `cfg:",internal"`
+
 	APIRoutes                []string `flag:"api-route" cfg:"api_routes"`
 	SkipAuthRegex            []string `flag:"skip-auth-regex" cfg:"skip_auth_regex"`
 	SkipAuthRoutes           []string `flag:"skip-auth-route" cfg:"skip_auth_routes"`
@@ -174,6 +178,11 @@ func NewFlagSet() *pflag.FlagSet {
 func (o *Options) EnsureDefaults() {
 	o.Providers.EnsureDefaults()
 	o.UpstreamServers.EnsureDefaults()
+	o.LDAP.EnsureDefaults()
+	o.APIKeys.EnsureDefaults()
+	if o.ClientCertificateAuth != nil {
+		o.ClientCertificateAuth.EnsureDefaults()
+	}
 
 	for i := range o.InjectRequestHeaders {
 		o.InjectRequestHeaders[i].EnsureDefaults()
@@ -1,6 +1,10 @@
 package options
 
-import "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+)
 
 const (
 	// OIDCEmailClaim is the generic email claim used by the OIDC provider.
@@ -37,6 +41,14 @@ const (
 	// for MicrosoftEntraIDOptions.FederatedTokenAuth
 	DefaultMicrosoftEntraIDUseFederatedToken bool = false
 
+	// DefaultMicrosoftEntraIDGroupNameCacheTTL is the default value
+	// for MicrosoftEntraIDOptions.GroupNameCacheTTL
+	DefaultMicrosoftEntraIDGroupNameCacheTTL time.Duration = time.Hour
+
+	// DefaultMicrosoftEntraIDAppRolesAsGroups is the default value
+	// for MicrosoftEntraIDOptions.AppRolesAsGroups
+	DefaultMicrosoftEntraIDAppRolesAsGroups bool = false
+
 	// DefaultGoogleUseOrganizationID is the default value
 	// for GoogleOptions.UseOrganizationID
 	DefaultGoogleUseOrganizationID bool = false
@@ -45,9 +57,41 @@ const (
 	// for GoogleOptions.UseApplicationDefaultCredentials
 	DefaultUseApplicationDefaultCredentials bool = false
 
+	// DefaultGoogleTransitiveGroups is the default value
+	// for GoogleOptions.TransitiveGroups
+	DefaultGoogleTransitiveGroups bool = false
+
+	// DefaultGoogleGroupMembershipCacheTTL is the default value
+	// for GoogleOptions.GroupMembershipCacheTTL
+	DefaultGoogleGroupMembershipCacheTTL time.Duration = 5 * time.Minute
+
 	// DefaultUseSystemTrustStore is the default value
 	// for Provider.UseSystemTrustStore
 	DefaultUseSystemTrustStore bool = false
+
+	// DefaultRPInitiatedLogout is the default value
+	// for OIDCOptions.RPInitiatedLogout
+	DefaultRPInitiatedLogout bool = false
+
+	// DefaultDeviceAuthorizationGrant is the default value
+	// for Provider.DeviceAuthorizationGrant
+	DefaultDeviceAuthorizationGrant bool = false
+
+	// DefaultRequirePushedAuthorizationRequests is the default value
+	// for Provider.RequirePushedAuthorizationRequests
+	DefaultRequirePushedAuthorizationRequests bool = false
+
+	// DefaultTokenIntrospection is the default value
+	// for Provider.TokenIntrospection
+	DefaultTokenIntrospection bool = false
+
+	// DefaultIntrospectionCacheTTL is the default value
+	// for Provider.IntrospectionCacheTTL
+	DefaultIntrospectionCacheTTL time.Duration = time.Minute
+
+	// DefaultRevalidateInterval is the default value
+	// for Provider.RevalidateInterval
+	DefaultRevalidateInterval time.Duration = 0
 )
 
 // OIDCAudienceClaims is the generic audience claim list used by the OIDC provider.
@@ -56,8 +100,9 @@ var OIDCAudienceClaims = []string{"aud"}
 // The provider can be selected using the `provider` configuration value, or
 // set in the [`providers` array using
 // AlphaConfig](https://oauth2-proxy.github.io/oauth2-proxy/configuration/alpha-config#providers).
-// However, [**the feature to implement multiple providers is not
-// complete**](https://github.com/oauth2-proxy/oauth2-proxy/issues/926).
+// When multiple providers are configured, the first provider is the default.
+// Other providers are selected with the `provider` query parameter on the
+// start endpoint, for example `/oauth2/start?provider=<id>`.
 type Providers []Provider
 
 // Provider holds all configuration for a single provider
@@ -71,17 +116,34 @@ type Provider struct {
 	// ClientSecretFile is the name of the file
 	// containing the OAuth Client Secret, it will be used if ClientSecret is not set.
 	ClientSecretFile string `yaml:"clientSecretFile,omitempty"`
+	// ClientAuthentication configures how the client authenticates to the
+	// token endpoint of OIDC based providers. Defaults to the client secret.
+	ClientAuthentication ClientAuthenticationOptions `yaml:"clientAuthentication,omitempty"`
+	// ClientCertificate is the client certificate presented on every request
+	// to OIDC based providers (mutual TLS, RFC 8705).
+	ClientCertificate *ClientCertificate `yaml:"clientCertificate,omitempty"`
+	// DPoP binds the tokens issued to OIDC based providers to a key pair
+	// (RFC 9449) by sending a DPoP proof on every token request. The access
+	// tokens are presented with the DPoP scheme and a proof on requests to
+	// the userinfo and profile endpoints.
+	DPoP *DPoPOptions `yaml:"dpop,omitempty"`
 
 	// KeycloakConfig holds all configurations for Keycloak provider.
 	KeycloakConfig KeycloakOptions `yaml:"keycloakConfig,omitempty"`
 	// AzureConfig holds all configurations for Azure provider.
 	AzureConfig AzureOptions `yaml:"azureConfig,omitempty"`
+	// AppleConfig holds all configurations for the Apple provider.
+	AppleConfig AppleOptions `yaml:"appleConfig,omitempty"`
 	// MicrosoftEntraIDConfig holds all configurations for Entra ID provider.
 	MicrosoftEntraIDConfig MicrosoftEntraIDOptions `yaml:"microsoftEntraIDConfig,omitempty"`
 	// ADFSConfig holds all configurations for ADFS provider.
 	ADFSConfig ADFSOptions `yaml:"ADFSConfig,omitempty"`
 	// BitbucketConfig holds all configurations for Bitbucket provider.
 	BitbucketConfig BitbucketOptions `yaml:"bitbucketConfig,omitempty"`
+	// DiscordConfig holds all configurations for the Discord provider.
+	DiscordConfig DiscordOptions `yaml:"discordConfig,omitempty"`
+	// GiteaConfig holds all configurations for the Gitea provider.
+	GiteaConfig GiteaOptions `yaml:"giteaConfig,omitempty"`
 	// GitHubConfig holds all configurations for GitHubC provider.
 	GitHubConfig GitHubOptions `yaml:"githubConfig,omitempty"`
 	// GitLabConfig holds all configurations for GitLab provider.
@@ -93,6 +155,12 @@ type Provider struct {
 	OIDCConfig OIDCOptions `yaml:"oidcConfig,omitempty"`
 	// LoginGovConfig holds all configurations for LoginGov provider.
 	LoginGovConfig LoginGovOptions `yaml:"loginGovConfig,omitempty"`
+	// MockConfig holds all configurations for the mock provider.
+	MockConfig MockOptions `yaml:"mockConfig,omitempty"`
+	// GenericOAuth2Config holds all configurations for the generic OAuth2 provider.
+	GenericOAuth2Config GenericOAuth2Options `yaml:"genericOAuth2Config,omitempty"`
+	// SAMLConfig holds all configurations for the SAML provider.
+	SAMLConfig SAMLOptions `yaml:"samlConfig,omitempty"`
 
 	// ID should be a unique identifier for the provider.
 	// This value is required for all providers.
@@ -104,6 +172,15 @@ type Provider struct {
 	// Name is the providers display name
 	// if set, it will be shown to the users in the login page.
 	Name string `yaml:"name,omitempty"`
+	// Logo is the path or URL to a logo to be displayed on the provider's
+	// sign in button. The logo can be either PNG, JPG/JPEG or SVG.
+	Logo string `yaml:"logo,omitempty"`
+	// LoginDomains is a list of email domains whose users should sign in
+	// with this provider. When any provider has login domains, the sign in
+	// page asks for the user's email address and starts the flow with the
+	// matching provider. Domains prefixed with `.` or `*.` also match
+	// subdomains, and `*` matches any domain no other provider claims.
+	LoginDomains []string `yaml:"loginDomains,omitempty"`
 	// CAFiles is a list of paths to CA certificates that should be used when connecting to the provider.
 	// If not specified, the default Go trust sources are used instead
 	CAFiles []string `yaml:"caFiles,omitempty"`
@@ -116,6 +193,16 @@ type Provider struct {
 	LoginURLParameters []LoginURLParameter `yaml:"loginURLParameters,omitempty"`
 	// AuthRequestResponseMode defines the response mode to request during authorization request
 	AuthRequestResponseMode string `yaml:"authRequestResponseMode,omitempty"`
+	// PushedAuthorizationRequestURL is the pushed authorization request
+	// endpoint (RFC 9126). When set, the authorization request parameters are
+	// posted to it and the browser is redirected with only the request URI.
+	// It is discovered for OIDC providers unless discovery is skipped.
+	PushedAuthorizationRequestURL string `yaml:"pushedAuthorizationRequestURL,omitempty"`
+	// RequirePushedAuthorizationRequests fails the login rather than falling
+	// back to a front-channel authorization request when the pushed
+	// authorization request can't be made
+	// default set to 'false'
+	RequirePushedAuthorizationRequests *bool `yaml:"requirePushedAuthorizationRequests,omitempty"`
 	// RedeemURL is the token redemption endpoint
 	RedeemURL string `yaml:"redeemURL,omitempty"`
 	// ProfileURL is the profile access endpoint
@@ -127,6 +214,37 @@ type Provider struct {
 	ProtectedResource string `yaml:"resource,omitempty"`
 	// ValidateURL is the access token validation endpoint
 	ValidateURL string `yaml:"validateURL,omitempty"`
+	// DeviceAuthorizationGrant enables the device authorization grant
+	// (RFC 8628) endpoints for users without a browser
+	// default set to 'false'
+	DeviceAuthorizationGrant *bool `yaml:"deviceAuthorizationGrant,omitempty"`
+	// DeviceAuthorizationURL is the device authorization endpoint.
+	// It is discovered for OIDC providers unless discovery is skipped.
+	DeviceAuthorizationURL string `yaml:"deviceAuthorizationURL,omitempty"`
+	// TokenIntrospection accepts opaque bearer tokens from API clients by
+	// introspecting them at the provider (RFC 7662). Only tokens issued to
+	// the client ID or one of the extra audiences are accepted. It requires
+	// skipJwtBearerTokens.
+	// default set to 'false'
+	TokenIntrospection *bool `yaml:"tokenIntrospection,omitempty"`
+	// IntrospectionURL is the token introspection endpoint.
+	// It is discovered for OIDC providers unless discovery is skipped.
+	IntrospectionURL string `yaml:"introspectionURL,omitempty"`
+	// IntrospectionCacheTTL is the longest time an introspection result is
+	// reused for. Active tokens are never cached beyond their expiry.
+	// default set to '1m'
+	IntrospectionCacheTTL *time.Duration `yaml:"introspectionCacheTTL,omitempty"`
+	// RevalidateInterval is how often the provider re-runs its membership
+	// checks on each session with the stored access token, independently of
+	// the cookie refresh. Sessions of users that are no longer authorized are
+	// cleared, as are sessions whose access token the provider rejects.
+	// Sessions that fail to re-validate because the provider can't be
+	// reached or answers with a server error are kept and re-validated again
+	// after a minute, or the interval when it is shorter.
+	// Only supported by the bitbucket, discord, gitea, github and gitlab
+	// providers.
+	// default set to '0' (disabled)
+	RevalidateInterval *time.Duration `yaml:"revalidateInterval,omitempty"`
 	// Scope is the OAuth scope specification
 	Scope string `yaml:"scope,omitempty"`
 	// AllowedGroups is a list of restrict logins to members of this group
@@ -137,20 +255,28 @@ type Provider struct {
 	// Additional claims to be obtained from the upstream IDP, either from the id_token or from the userinfo endpoint if configured.
 	AdditionalClaims []string `yaml:"additionalClaims,omitempty"`
 
-	// URL to call to perform backend logout, `{id_token}` would be replaced by the actual `id_token` if available in the session
+	// ClaimExpressions are CEL expressions that compute session fields from
+	// the claims of the provider and authorize sessions.
+	ClaimExpressions *ClaimExpressions `yaml:"claimExpressions,omitempty"`
+
+	// URL to call to perform backend logout, `{id_token}` would be replaced by the actual `id_token` if available in the session.
+	// The request is sent server side with the same HTTP client (and CA files) used for all other provider requests.
 	BackendLogoutURL string `yaml:"backendLogoutURL"`
 }
 
 // ProviderType is used to enumerate the different provider type options
-// Valid options are: adfs, azure, bitbucket, digitalocean facebook, github,
-// gitlab, google, keycloak, keycloak-oidc, linkedin, login.gov, nextcloud
-// and oidc.
+// Valid options are: adfs, apple, azure, bitbucket, digitalocean, discord,
+// facebook, generic-oauth2, gitea, github, gitlab, google, keycloak,
+// keycloak-oidc, linkedin, login.gov, mock, nextcloud, oidc and saml.
 type ProviderType string
 
 const (
 	// ADFSProvider is the provider type for ADFS
 	ADFSProvider ProviderType = "adfs"
 
+	// AppleProvider is the provider type for Sign in with Apple
+	AppleProvider ProviderType = "apple"
+
 	// AzureProvider is the provider type for Azure
 	AzureProvider ProviderType = "azure"
 
@@ -166,9 +292,19 @@ const (
 	// DigitalOceanProvider is the provider type for DigitalOcean
 	DigitalOceanProvider ProviderType = "digitalocean"
 
+	// DiscordProvider is the provider type for Discord
+	DiscordProvider ProviderType = "discord"
+
 	// FacebookProvider is the provider type for Facebook
 	FacebookProvider ProviderType = "facebook"
 
+	// GenericOAuth2Provider is the provider type for plain OAuth2 identity
+	// providers with a JSON profile endpoint
+	GenericOAuth2Provider ProviderType = "generic-oauth2"
+
+	// GiteaProvider is the provider type for Gitea and Forgejo
+	GiteaProvider ProviderType = "gitea"
+
 	// GitHubProvider is the provider type for GitHub
 	GitHubProvider ProviderType = "github"
 
@@ -190,12 +326,19 @@ const (
 	// LoginGovProvider is the provider type for LoginGov
 	LoginGovProvider ProviderType = "login.gov"
 
+	// MockProvider is the provider type for the built-in mock identity
+	// provider, for development and testing only
+	MockProvider ProviderType = "mock"
+
 	// NextCloudProvider is the provider type for NextCloud
 	NextCloudProvider ProviderType = "nextcloud"
 
 	// OIDCProvider is the provider type for OIDC
 	OIDCProvider ProviderType = "oidc"
 
+	// SAMLProvider is the provider type for SAML 2.0 identity providers
+	SAMLProvider ProviderType = "saml"
+
 	// SourceHutProvider is the provider type for SourceHut
 	SourceHutProvider ProviderType = "sourcehut"
 )
@@ -227,14 +370,56 @@ type MicrosoftEntraIDOptions struct {
 	// FederatedTokenAuth enable oAuth2 client authentication with federated token projected
 	// by Entra Workload Identity plugin, instead of client secret.
 	FederatedTokenAuth *bool `yaml:"federatedTokenAuth,omitempty"`
+
+	// GroupNameAttribute resolves the group object IDs of the session to this
+	// attribute of the groups through Microsoft Graph, so that allowedGroups
+	// and the groups header can use group names. Group IDs are kept when unset.
+	// Valid options are: displayName and onPremisesSamAccountName.
+	GroupNameAttribute MicrosoftEntraIDGroupNameAttribute `yaml:"groupNameAttribute,omitempty"`
+
+	// GroupNameCacheTTL is how long resolved group names are cached for.
+	// default set to '1h'
+	GroupNameCacheTTL *time.Duration `yaml:"groupNameCacheTTL,omitempty"`
+
+	// AppRolesAsGroups adds the app roles of the `roles` ID token claim to
+	// the groups of the session.
+	// default set to 'false'
+	AppRolesAsGroups *bool `yaml:"appRolesAsGroups,omitempty"`
+
+	// AppRolePrefix is prepended to the app roles added to the groups, to
+	// tell them apart from groups, e.g. `role:`
+	AppRolePrefix string `yaml:"appRolePrefix,omitempty"`
 }
 
+// MicrosoftEntraIDGroupNameAttribute is the group attribute that group object
+// IDs are resolved to
+type MicrosoftEntraIDGroupNameAttribute string
+
+const (
+	// MicrosoftEntraIDGroupDisplayName resolves groups to their display name
+	MicrosoftEntraIDGroupDisplayName MicrosoftEntraIDGroupNameAttribute = "displayName"
+
+	// MicrosoftEntraIDGroupSamAccountName resolves groups to the SAM account
+	// name synchronized from on-premises Active Directory
+	MicrosoftEntraIDGroupSamAccountName MicrosoftEntraIDGroupNameAttribute = "onPremisesSamAccountName"
+)
+
 type ADFSOptions struct {
 	// Skip adding the scope parameter in login request
 	// Default value is 'false'
 	SkipScope *bool `yaml:"skipScope,omitempty"`
 }
 
+type AppleOptions struct {
+	// TeamID is the ID of the Apple developer team the client belongs to
+	TeamID string `yaml:"teamID,omitempty"`
+	// KeyID is the ID of the Sign in with Apple private key
+	KeyID string `yaml:"keyID,omitempty"`
+	// PrivateKey is the PEM encoded Sign in with Apple private key, used to
+	// sign the client secret
+	PrivateKey *SecretSource `yaml:"privateKey,omitempty"`
+}
+
 type BitbucketOptions struct {
 	// Team sets restrict logins to members of this team
 	Team string `yaml:"team,omitempty"`
@@ -242,6 +427,25 @@ type BitbucketOptions struct {
 	Repository string `yaml:"repository,omitempty"`
 }
 
+type DiscordOptions struct {
+	// Guilds restricts logins to members of any of these guilds, given by ID
+	Guilds []string `yaml:"guilds,omitempty"`
+	// Roles restricts logins to members with any of these guild roles,
+	// given as guild:role IDs, or as role IDs when a single guild is set
+	Roles []string `yaml:"roles,omitempty"`
+}
+
+type GiteaOptions struct {
+	// Org restricts logins to members of this organisation
+	Org string `yaml:"org,omitempty"`
+	// Teams restricts logins to members of any of these teams. Teams are
+	// given by name within Org, or as org:team when Org is not set.
+	Teams []string `yaml:"teams,omitempty"`
+	// Repo restricts logins to users with access to this repository,
+	// formatted as owner/repo
+	Repo string `yaml:"repo,omitempty"`
+}
+
 type GitHubOptions struct {
 	// Org sets restrict logins to members of this organisation
 	Org string `yaml:"org,omitempty"`
@@ -264,6 +468,56 @@ type GitLabOptions struct {
 	Projects []string `yaml:"projects,omitempty"`
 }
 
+type GenericOAuth2Options struct {
+	// EmailPath is the JSON path of the user's email in the profile response
+	// default set to 'email'
+	EmailPath string `yaml:"emailPath,omitempty"`
+	// UserPath is the JSON path of the user's ID in the profile response
+	// default set to 'sub', the email is used if the path is not present
+	UserPath string `yaml:"userPath,omitempty"`
+	// PreferredUsernamePath is the JSON path of the user's preferred username
+	// in the profile response
+	// default set to 'preferred_username'
+	PreferredUsernamePath string `yaml:"preferredUsernamePath,omitempty"`
+	// GroupsPath is the JSON path of the user's groups in the profile response.
+	// Groups can be an array of strings, a comma separated string or an array
+	// of objects, in which case GroupNameField is used as the group name.
+	// default set to 'groups'
+	GroupsPath string `yaml:"groupsPath,omitempty"`
+	// GroupNameField is the field holding the group name when groups are
+	// given as an array of objects
+	// default set to 'name'
+	GroupNameField string `yaml:"groupNameField,omitempty"`
+}
+
+type SAMLOptions struct {
+	// IDPMetadataURL is the URL of the identity provider's SAML metadata
+	IDPMetadataURL string `yaml:"idpMetadataURL,omitempty"`
+	// IDPMetadataFile is the path to a file containing the identity
+	// provider's SAML metadata, it will be used if IDPMetadataURL is not set.
+	IDPMetadataFile string `yaml:"idpMetadataFile,omitempty"`
+	// Binding is the binding used to send authentication requests to the
+	// identity provider, either `redirect` or `post`.
+	// default set to 'redirect'
+	Binding string `yaml:"binding,omitempty"`
+	// SigningKey is the PEM encoded private key used to sign authentication
+	// requests. Requests are not signed when no key is set.
+	SigningKey *SecretSource `yaml:"signingKey,omitempty"`
+	// SigningCert is the PEM encoded certificate matching SigningKey that is
+	// published in the service provider metadata.
+	SigningCert *SecretSource `yaml:"signingCert,omitempty"`
+	// EmailAttribute is the assertion attribute holding the user's email
+	// default set to 'email', the NameID is used if the attribute is not
+	// present and the NameID format is emailAddress
+	EmailAttribute string `yaml:"emailAttribute,omitempty"`
+	// UserAttribute is the assertion attribute holding the user's ID
+	// the NameID is used if not set
+	UserAttribute string `yaml:"userAttribute,omitempty"`
+	// GroupsAttribute is the assertion attribute holding the user's groups
+	// default set to 'groups'
+	GroupsAttribute string `yaml:"groupsAttribute,omitempty"`
+}
+
 type GoogleOptions struct {
 	// Groups sets restrict logins to members of this Google group
 	Groups []string `yaml:"group,omitempty"`
@@ -279,6 +533,15 @@ type GoogleOptions struct {
 	UseOrganizationID *bool `yaml:"useOrganizationID,omitempty"`
 	// admin scope needed for fetching user organization information from admin api, can be one of cloud, user or defaults to readonly
 	AdminAPIUserScope string `yaml:"adminAPIUserScope,omitempty"`
+	// TransitiveGroups includes the groups that the user is a nested member of,
+	// by walking up the group hierarchy with the Admin SDK
+	// default set to 'false'
+	TransitiveGroups *bool `yaml:"transitiveGroups,omitempty"`
+	// GroupMembershipCacheTTL is how long the group memberships read from the
+	// Admin SDK are cached for. The cache is shared by all users, so group
+	// changes may take this long to apply.
+	// default set to '5m'
+	GroupMembershipCacheTTL *time.Duration `yaml:"groupMembershipCacheTTL,omitempty"`
 }
 
 type OIDCOptions struct {
@@ -321,6 +584,87 @@ type OIDCOptions struct {
 	// ExtraAudiences is a list of additional audiences that are allowed
 	// to pass verification in addition to the client id.
 	ExtraAudiences []string `yaml:"extraAudiences,omitempty"`
+	// RPInitiatedLogout redirects users signing out to the provider's
+	// end_session_endpoint so that they are logged out of the provider too.
+	// default set to 'false'
+	RPInitiatedLogout *bool `yaml:"rpInitiatedLogout,omitempty"`
+	// EndSessionURL is the OpenID Connect end_session_endpoint used for
+	// RP-initiated logout. It is discovered from the issuer unless SkipDiscovery is set.
+	// eg: https://keycloak.example.com/realms/example/protocol/openid-connect/logout
+	EndSessionURL string `yaml:"endSessionURL,omitempty"`
+}
+
+// ClientAuthenticationMethod is the method used to authenticate the client to
+// the token endpoint.
+// Valid options are: client_secret, private_key_jwt, client_secret_jwt and
+// tls_client_auth.
+type ClientAuthenticationMethod string
+
+const (
+	// ClientSecretAuthentication sends the client secret to the token endpoint
+	ClientSecretAuthentication ClientAuthenticationMethod = "client_secret"
+
+	// PrivateKeyJWTAuthentication sends a client assertion signed with a
+	// private key to the token endpoint (RFC 7523)
+	PrivateKeyJWTAuthentication ClientAuthenticationMethod = "private_key_jwt"
+
+	// ClientSecretJWTAuthentication sends a client assertion signed with the
+	// client secret to the token endpoint (RFC 7523)
+	ClientSecretJWTAuthentication ClientAuthenticationMethod = "client_secret_jwt"
+
+	// TLSClientAuthentication authenticates with the client certificate
+	// presented on the TLS connection to the token endpoint (RFC 8705)
+	TLSClientAuthentication ClientAuthenticationMethod = "tls_client_auth"
+)
+
+type ClientAuthenticationOptions struct {
+	// Method is the client authentication method.
+	// Valid options are: client_secret, private_key_jwt, client_secret_jwt and
+	// tls_client_auth.
+	// tls_client_auth requires a ClientCertificate.
+	// default set to 'client_secret'
+	Method ClientAuthenticationMethod `yaml:"method,omitempty"`
+	// SigningKey is the PEM encoded RSA, EC or Ed25519 private key used to sign
+	// the client assertion with private_key_jwt.
+	SigningKey *SecretSource `yaml:"signingKey,omitempty"`
+	// KeyID is set as the `kid` header of the client assertion
+	KeyID string `yaml:"keyID,omitempty"`
+	// Audience is the audience of the client assertion.
+	// default set to the token endpoint URL
+	Audience string `yaml:"audience,omitempty"`
+}
+
+type ClientCertificate struct {
+	// Cert is the PEM encoded client certificate, optionally followed by its
+	// intermediate certificates.
+	Cert *SecretSource `yaml:"cert,omitempty"`
+	// Key is the PEM encoded private key of the client certificate.
+	Key *SecretSource `yaml:"key,omitempty"`
+}
+
+// ClaimExpressions are CEL expressions evaluated with the `claims` of the ID
+// token (or of the access token when there is no ID token) and the `session`
+// as built by the provider, with the `user`, `email`, `groups`,
+// `preferredUsername` and `additionalClaims` fields.
+type ClaimExpressions struct {
+	// User computes the user of the session as a string
+	User string `yaml:"user,omitempty"`
+	// Email computes the email of the session as a string
+	Email string `yaml:"email,omitempty"`
+	// Groups computes the groups of the session as a list of strings
+	Groups string `yaml:"groups,omitempty"`
+	// PreferredUsername computes the preferred username of the session as a string
+	PreferredUsername string `yaml:"preferredUsername,omitempty"`
+	// Authorize must evaluate to true for the session to be authorized, in
+	// addition to the allowed groups
+	Authorize string `yaml:"authorize,omitempty"`
+}
+
+type DPoPOptions struct {
+	// KeyFile is the path of the PEM encoded RSA, EC or Ed25519 private key
+	// that signs the DPoP proofs. When the file does not exist, a new EC P-256
+	// key is generated and written to it.
+	KeyFile string `yaml:"keyFile,omitempty"`
 }
 
 type LoginGovOptions struct {
@@ -332,6 +676,26 @@ type LoginGovOptions struct {
 	PubJWKURL string `yaml:"pubjwkURL,omitempty"`
 }
 
+type MockOptions struct {
+	// InsecureDevelopmentMode must be set to use the mock provider, which
+	// logs anyone in as any of the configured users without a password
+	InsecureDevelopmentMode bool `yaml:"insecureDevelopmentMode,omitempty"`
+	// Users are the users that can be picked on the login page of the mock
+	// provider
+	Users []MockUser `yaml:"users,omitempty"`
+}
+
+// MockUser is a user of the mock provider
+type MockUser struct {
+	// Email is the email address of the user, which is also the subject of
+	// the ID tokens issued for the user
+	Email string `yaml:"email,omitempty"`
+	// Groups are set as the groups claim of the ID tokens
+	Groups []string `yaml:"groups,omitempty"`
+	// Claims are extra claims added to the ID tokens
+	Claims map[string]interface{} `yaml:"claims,omitempty"`
+}
+
 // Legacy default providers configuration
 func providerDefaults() Providers {
 	providers := Providers{
@@ -370,6 +734,21 @@ func (p *Provider) EnsureDefaults() {
 	if p.UseSystemTrustStore == nil {
 		p.UseSystemTrustStore = ptr.To(DefaultUseSystemTrustStore)
 	}
+	if p.DeviceAuthorizationGrant == nil {
+		p.DeviceAuthorizationGrant = ptr.To(DefaultDeviceAuthorizationGrant)
+	}
+	if p.RequirePushedAuthorizationRequests == nil {
+		p.RequirePushedAuthorizationRequests = ptr.To(DefaultRequirePushedAuthorizationRequests)
+	}
+	if p.TokenIntrospection == nil {
+		p.TokenIntrospection = ptr.To(DefaultTokenIntrospection)
+	}
+	if p.IntrospectionCacheTTL == nil {
+		p.IntrospectionCacheTTL = ptr.To(DefaultIntrospectionCacheTTL)
+	}
+	if p.RevalidateInterval == nil {
+		p.RevalidateInterval = ptr.To(DefaultRevalidateInterval)
+	}
 
 	p.OIDCConfig.EnsureDefaults()
 	p.MicrosoftEntraIDConfig.EnsureDefaults()
@@ -389,6 +768,9 @@ func (o *OIDCOptions) EnsureDefaults() {
 	if o.SkipDiscovery == nil {
 		o.SkipDiscovery = ptr.To(DefaultSkipDiscovery)
 	}
+	if o.RPInitiatedLogout == nil {
+		o.RPInitiatedLogout = ptr.To(DefaultRPInitiatedLogout)
+	}
 	if o.UserIDClaim == "" {
 		o.UserIDClaim = OIDCEmailClaim
 	}
@@ -408,6 +790,12 @@ func (me *MicrosoftEntraIDOptions) EnsureDefaults() {
 	if me.FederatedTokenAuth == nil {
 		me.FederatedTokenAuth = ptr.To(DefaultMicrosoftEntraIDUseFederatedToken)
 	}
+	if me.GroupNameCacheTTL == nil {
+		me.GroupNameCacheTTL = ptr.To(DefaultMicrosoftEntraIDGroupNameCacheTTL)
+	}
+	if me.AppRolesAsGroups == nil {
+		me.AppRolesAsGroups = ptr.To(DefaultMicrosoftEntraIDAppRolesAsGroups)
+	}
 }
 
 // EnsureDefaults sets any default values for ADFSOptions fields.
@@ -426,4 +814,12 @@ func (g *GoogleOptions) EnsureDefaults() {
 	if g.UseApplicationDefaultCredentials == nil {
 		g.UseApplicationDefaultCredentials = ptr.To(DefaultUseApplicationDefaultCredentials)
 	}
+
+	if g.TransitiveGroups == nil {
+		g.TransitiveGroups = ptr.To(DefaultGoogleTransitiveGroups)
+	}
+
+	if g.GroupMembershipCacheTTL == nil {
+		g.GroupMembershipCacheTTL = ptr.To(DefaultGoogleGroupMembershipCacheTTL)
+	}
 }
@@ -37,4 +37,33 @@ type TLS struct {
 	// If not specified, the default Go safe cipher list is used.
 	// List of valid cipher suites can be found in the [crypto/tls documentation](https://pkg.go.dev/crypto/tls#pkg-constants).
 	CipherSuites []string `yaml:"cipherSuites,omitempty"`
+
+	// ClientCA is the CA certificate data client certificates are verified
+	// against. Typically this will come from a file.
+	ClientCA *SecretSource `yaml:"clientCA,omitempty"`
+
+	// ClientAuth is the policy for requesting certificates from clients.
+	// Valid options are: request, require and verify-if-given.
+	// request asks for a certificate without verifying it, require and
+	// verify-if-given verify certificates against the ClientCA, with
+	// verify-if-given also accepting clients without a certificate.
+	// Defaults to require when the ClientCA is set, clients aren't asked
+	// for certificates otherwise.
+	ClientAuth TLSClientAuth `yaml:"clientAuth,omitempty"`
 }
+
+// TLSClientAuth is the policy of a TLS server for requesting certificates
+// from clients
+type TLSClientAuth string
+
+const (
+	// TLSClientAuthRequest requests a client certificate, without verifying it
+	TLSClientAuthRequest TLSClientAuth = "request"
+
+	// TLSClientAuthRequire requires a verified client certificate
+	TLSClientAuthRequire TLSClientAuth = "require"
+
+	// TLSClientAuthVerifyIfGiven verifies client certificates when they are
+	// presented
+	TLSClientAuthVerifyIfGiven TLSClientAuth = "verify-if-given"
+)
@@ -129,6 +129,74 @@ type Upstream struct {
 	// DisableKeepAlives disables HTTP keep-alive connections to the upstream server.
 	// Defaults to false.
 	DisableKeepAlives *bool `yaml:"disableKeepAlives,omitempty"`
+
+	// TokenExchange exchanges the access token of the session for a token
+	// issued for this upstream before proxying the request. The token is sent
+	// to the upstream server in the Authorization header.
+	// This option can only be used with HTTP(S) and unix upstreams.
+	TokenExchange *TokenExchange `yaml:"tokenExchange,omitempty"`
+
+	// Authorization restricts the users allowed to access the upstream, in
+	// addition to the authorization of the provider.
+	// The first rule that matches the path and method of the request is
+	// applied. Requests that match no rule are allowed.
+	Authorization []AuthorizationRule `yaml:"authorization,omitempty"`
+}
+
+// AuthorizationRule restricts the users allowed to access the paths and
+// methods of an upstream that it matches.
+// Users must satisfy all of the allowed groups, emails, email domains and
+// claims that are set. A rule without any of them allows all users.
+type AuthorizationRule struct {
+	// Path is a regular expression the request path must match for the rule
+	// to apply, e.g. `^/admin/`. The rule applies to all paths when it is
+	// empty.
+	Path string `yaml:"path,omitempty"`
+
+	// Methods are the request methods the rule applies to.
+	// The rule applies to all methods when it is empty.
+	Methods []string `yaml:"methods,omitempty"`
+
+	// AllowedGroups restricts access to members of one of the groups
+	AllowedGroups []string `yaml:"allowedGroups,omitempty"`
+
+	// AllowedEmails restricts access to users with one of the email addresses
+	AllowedEmails []string `yaml:"allowedEmails,omitempty"`
+
+	// AllowedEmailDomains restricts access to users with an email address in
+	// one of the domains. Domains prefixed with `.` or `*.` also allow their
+	// subdomains.
+	AllowedEmailDomains []string `yaml:"allowedEmailDomains,omitempty"`
+
+	// AllowedClaims restricts access to users with one of the allowed values
+	// of each of the claims
+	AllowedClaims []AllowedClaim `yaml:"allowedClaims,omitempty"`
+}
+
+// AllowedClaim is a claim of the session and the values allowed for it
+type AllowedClaim struct {
+	// Claim is the name of the claim in the session, one of the claims
+	// available to a ClaimSource.
+	Claim string `yaml:"claim,omitempty"`
+
+	// Values are the allowed values of the claim
+	Values []string `yaml:"values,omitempty"`
+}
+
+// TokenExchange configures the token exchange (RFC 8693) at the token endpoint
+// of the provider that created the session.
+// At least one of Audience or Resource must be set.
+type TokenExchange struct {
+	// Audience is the logical name of the upstream service the token is
+	// requested for, e.g. its client ID at the provider.
+	Audience string `yaml:"audience,omitempty"`
+
+	// Resource is the URI of the upstream service the token is requested for.
+	Resource string `yaml:"resource,omitempty"`
+
+	// Scopes is the list of scopes requested for the exchanged token.
+	// When empty, the provider decides which scopes are granted.
+	Scopes []string `yaml:"scopes,omitempty"`
 }
 
 // EnsureDefaults sets any default values for UpstreamConfig fields.
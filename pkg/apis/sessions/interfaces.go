@@ -15,6 +15,22 @@ type SessionStore interface {
 	VerifyConnection(ctx context.Context) error
 }
 
+// SessionRevoker is implemented by session stores that can revoke sessions
+// without the user's session cookie, e.g. on an OIDC back-channel logout
+type SessionRevoker interface {
+	// RevokeSessions clears all sessions of the provider that match the given
+	// sid or, when sid is empty, the given sub.
+	RevokeSessions(ctx context.Context, providerID, sid, sub string) error
+}
+
+// ReplayTracker is implemented by session stores that can record single use
+// identifiers, e.g. the jti of DPoP proofs, to detect replayed requests
+type ReplayTracker interface {
+	// MarkUsed records the identifier until it expires. It returns false when
+	// the identifier was already recorded.
+	MarkUsed(ctx context.Context, id string, exp time.Duration) (bool, error)
+}
+
 var ErrLockNotObtained = errors.New("lock: not obtained")
 var ErrNotLocked = errors.New("tried to release not existing lock")
 
@@ -32,12 +32,44 @@ type SessionState struct {
 	// Additional claims
 	AdditionalClaims map[string]interface{} `msgpack:"ac,omitempty"`
 
+	// ProviderID is the ID of the provider that authenticated this session
+	ProviderID string `msgpack:"pid,omitempty"`
+
+	// SID is the provider's session ID (the `sid` claim of the ID Token)
+	SID string `msgpack:"sid,omitempty"`
+
+	// Subject is the provider's user ID (the `sub` claim of the ID Token).
+	// Unlike User, it is never remapped by the user claim.
+	Subject string `msgpack:"sub,omitempty"`
+
+	// ProviderValues are the user fields as the provider set them, before
+	// they were computed by the claim expressions of the provider
+	ProviderValues *ProviderValues `msgpack:"pv,omitempty"`
+
+	// ValidatedAt is when the authorization of the session was last checked
+	// by the provider, when periodic re-validation is enabled
+	ValidatedAt *time.Time `msgpack:"va,omitempty"`
+
+	// RevalidationFailedAt is when the last re-validation of the session
+	// failed to reach the provider, it is cleared once the session is
+	// re-validated
+	RevalidationFailedAt *time.Time `msgpack:"rfa,omitempty"`
+
 	// Internal helpers, not serialized
 	Clock     func() time.Time `msgpack:"-"` // override for time.Now, for testing
 	Lock      Lock             `msgpack:"-"`
 	Refreshed bool             `msgpack:"-"` // indicates whether the session was refreshed
 }
 
+// ProviderValues holds the user fields of a session that claim expressions
+// can compute
+type ProviderValues struct {
+	Email             string   `msgpack:"e,omitempty"`
+	User              string   `msgpack:"u,omitempty"`
+	Groups            []string `msgpack:"g,omitempty"`
+	PreferredUsername string   `msgpack:"pu,omitempty"`
+}
+
 func (s *SessionState) now() time.Time {
 	if s.Clock != nil {
 		return s.Clock()
@@ -110,6 +142,41 @@ func (s *SessionState) Age() time.Duration {
 	return 0
 }
 
+// ValidatedAtNow sets the ValidatedAt time to the current time
+func (s *SessionState) ValidatedAtNow() {
+	now := s.now()
+	s.ValidatedAt = &now
+}
+
+// RevalidationFailedNow sets the RevalidationFailedAt time to the current time
+func (s *SessionState) RevalidationFailedNow() {
+	now := s.now()
+	s.RevalidationFailedAt = &now
+}
+
+// RevalidationFailedAge returns the time since the last re-validation of the
+// session failed to reach the provider, zero when it didn't fail
+func (s *SessionState) RevalidationFailedAge() time.Duration {
+	if s.RevalidationFailedAt != nil && !s.RevalidationFailedAt.IsZero() {
+		return s.now().Truncate(time.Second).Sub(*s.RevalidationFailedAt)
+	}
+	return 0
+}
+
+// ValidatedAge returns the time since the authorization of the session was
+// last checked by the provider. Sessions that were never re-validated were
+// checked when they were created.
+func (s *SessionState) ValidatedAge() time.Duration {
+	validatedAt := s.ValidatedAt
+	if validatedAt == nil {
+		validatedAt = s.CreatedAt
+	}
+	if validatedAt != nil && !validatedAt.IsZero() {
+		return s.now().Truncate(time.Second).Sub(*validatedAt)
+	}
+	return 0
+}
+
 // String constructs a summary of the session state
 func (s *SessionState) String() string {
 	o := fmt.Sprintf("Session{email:%s user:%s PreferredUsername:%s", s.Email, s.User, s.PreferredUsername)
@@ -131,6 +198,12 @@ func (s *SessionState) String() string {
 	if len(s.Groups) > 0 {
 		o += fmt.Sprintf(" groups:%v", s.Groups)
 	}
+	if s.ProviderID != "" {
+		o += fmt.Sprintf(" provider:%s", s.ProviderID)
+	}
+	if s.SID != "" {
+		o += fmt.Sprintf(" sid:%s", s.SID)
+	}
 	return o + "}"
 }
 
@@ -47,8 +47,20 @@ type Opts struct {
 	DisplayLoginForm bool
 
 	// ProviderName is the name of the provider that should be displayed on the login button.
+	// It is only used when Providers is empty.
 	ProviderName string
 
+	// Providers are the providers that a sign in button should be displayed for.
+	Providers []SignInProvider
+
+	// DisplayEmailDiscovery determines whether the sign-in page asks for the
+	// user's email address to route them to a provider by email domain.
+	DisplayEmailDiscovery bool
+
+	// SkipProviderButton hides the provider buttons behind the email discovery
+	// step. They are only displayed when no single provider matched the email.
+	SkipProviderButton bool
+
 	// SignInMessage is the messge displayed above the login button.
 	SignInMessage string
 
@@ -71,6 +83,11 @@ func NewWriter(opts Opts) (Writer, error) {
 		return nil, fmt.Errorf("error loading logo: %v", err)
 	}
 
+	providers, err := loadSignInProviders(opts.Providers, opts.ProviderName)
+	if err != nil {
+		return nil, fmt.Errorf("error loading provider logo: %v", err)
+	}
+
 	errorPage := &errorPageWriter{
 		template:    templates.Lookup("error.html"),
 		proxyPrefix: opts.ProxyPrefix,
@@ -80,15 +97,18 @@ func NewWriter(opts Opts) (Writer, error) {
 	}
 
 	signInPage := &signInPageWriter{
-		template:         templates.Lookup("sign_in.html"),
-		errorPageWriter:  errorPage,
-		proxyPrefix:      opts.ProxyPrefix,
-		providerName:     opts.ProviderName,
-		signInMessage:    opts.SignInMessage,
-		footer:           opts.Footer,
-		version:          opts.Version,
-		displayLoginForm: opts.DisplayLoginForm,
-		logoData:         logoData,
+		template:              templates.Lookup("sign_in.html"),
+		errorPageWriter:       errorPage,
+		proxyPrefix:           opts.ProxyPrefix,
+		providerName:          opts.ProviderName,
+		providers:             providers,
+		signInMessage:         opts.SignInMessage,
+		footer:                opts.Footer,
+		version:               opts.Version,
+		displayLoginForm:      opts.DisplayLoginForm,
+		displayEmailDiscovery: opts.DisplayEmailDiscovery,
+		skipProviderButton:    opts.SkipProviderButton,
+		logoData:              logoData,
 	}
 
 	staticPages, err := newStaticPageWriter(opts.TemplatesPath, errorPage)
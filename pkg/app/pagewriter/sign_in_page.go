@@ -20,6 +20,26 @@ import (
 //go:embed default_logo.svg
 var defaultLogoData string
 
+// SignInProvider describes a provider that a sign in button is rendered for.
+type SignInProvider struct {
+	// ID is the provider ID passed to the start endpoint.
+	ID string
+
+	// Name is the name of the provider displayed on the button.
+	Name string
+
+	// Logo is the path or URL to a logo displayed on the button.
+	// The logo can be either PNG, JPG/JPEG or SVG.
+	Logo string
+}
+
+// signInProvider is the template data for a single provider button.
+type signInProvider struct {
+	ID       string
+	Name     string
+	LogoData template.HTML
+}
+
 // signInPageWriter is used to render sign-in pages.
 type signInPageWriter struct {
 	// Template is the sign-in page HTML template.
@@ -34,6 +54,9 @@ type signInPageWriter struct {
 	// ProviderName is the name of the provider that should be displayed on the login button.
 	providerName string
 
+	// Providers are the providers that sign in buttons are rendered for.
+	providers []signInProvider
+
 	// SignInMessage is the messge displayed above the login button.
 	signInMessage string
 
@@ -47,6 +70,12 @@ type signInPageWriter struct {
 	// DisplayLoginForm determines whether or not the basic auth password form is displayed on the sign-in page.
 	displayLoginForm bool
 
+	// DisplayEmailDiscovery determines whether the email discovery form is displayed on the sign-in page.
+	displayEmailDiscovery bool
+
+	// SkipProviderButton hides the provider buttons until email discovery failed to pick a provider.
+	skipProviderButton bool
+
 	// LogoData is the logo to render in the template.
 	// This should contain valid html.
 	logoData string
@@ -55,26 +84,38 @@ type signInPageWriter struct {
 // WriteSignInPage writes the sign-in page to the given response writer.
 // It uses the redirectURL to be able to set the final destination for the user post login.
 func (s *signInPageWriter) WriteSignInPage(rw http.ResponseWriter, req *http.Request, redirectURL string, statusCode int) {
+	// An email on the request means discovery has already been attempted and
+	// did not find a single provider, so the user has to pick one.
+	email := req.URL.Query().Get("email")
+
 	t := struct {
-		ProviderName  string
-		SignInMessage template.HTML
-		StatusCode    int
-		CustomLogin   bool
-		Redirect      string
-		Version       string
-		ProxyPrefix   string
-		Footer        template.HTML
-		LogoData      template.HTML
+		ProviderName        string
+		Providers           []signInProvider
+		EmailDiscovery      bool
+		Email               string
+		ShowProviderButtons bool
+		SignInMessage       template.HTML
+		StatusCode          int
+		CustomLogin         bool
+		Redirect            string
+		Version             string
+		ProxyPrefix         string
+		Footer              template.HTML
+		LogoData            template.HTML
 	}{
-		ProviderName:  s.providerName,
-		SignInMessage: template.HTML(s.signInMessage), // #nosec G203 -- We allow unescaped template.HTML since it is user configured options
-		StatusCode:    statusCode,
-		CustomLogin:   s.displayLoginForm,
-		Redirect:      redirectURL,
-		Version:       s.version,
-		ProxyPrefix:   s.proxyPrefix,
-		Footer:        template.HTML(s.footer),   // #nosec G203 -- We allow unescaped template.HTML since it is user configured options
-		LogoData:      template.HTML(s.logoData), // #nosec G203 -- We allow unescaped template.HTML since it is user configured options
+		ProviderName:        s.providerName,
+		Providers:           s.providers,
+		EmailDiscovery:      s.displayEmailDiscovery,
+		Email:               email,
+		ShowProviderButtons: !s.displayEmailDiscovery || !s.skipProviderButton || email != "",
+		SignInMessage:       template.HTML(s.signInMessage), // #nosec G203 -- We allow unescaped template.HTML since it is user configured options
+		StatusCode:          statusCode,
+		CustomLogin:         s.displayLoginForm,
+		Redirect:            redirectURL,
+		Version:             s.version,
+		ProxyPrefix:         s.proxyPrefix,
+		Footer:              template.HTML(s.footer),   // #nosec G203 -- We allow unescaped template.HTML since it is user configured options
+		LogoData:            template.HTML(s.logoData), // #nosec G203 -- We allow unescaped template.HTML since it is user configured options
 	}
 
 	err := s.template.Execute(rw, t)
@@ -90,6 +131,34 @@ func (s *signInPageWriter) WriteSignInPage(rw http.ResponseWriter, req *http.Req
 	}
 }
 
+// loadSignInProviders loads the logos of the providers displayed on the
+// sign-in page. If no providers are given, a single button using the
+// providerName is displayed instead.
+func loadSignInProviders(providers []SignInProvider, providerName string) ([]signInProvider, error) {
+	if len(providers) == 0 {
+		return []signInProvider{{Name: providerName}}, nil
+	}
+
+	signInProviders := make([]signInProvider, 0, len(providers))
+	for _, provider := range providers {
+		var logoData string
+		if provider.Logo != "" {
+			var err error
+			logoData, err = loadCustomLogo(provider.Logo)
+			if err != nil {
+				return nil, fmt.Errorf("provider %q: %v", provider.ID, err)
+			}
+		}
+
+		signInProviders = append(signInProviders, signInProvider{
+			ID:       provider.ID,
+			Name:     provider.Name,
+			LogoData: template.HTML(logoData), // #nosec G203 -- We allow unescaped template.HTML since it is user configured options
+		})
+	}
+	return signInProviders, nil
+}
+
 // loadCustomLogo loads the logo file from the path and encodes it to an HTML
 // entity or if a URL is provided then it's used directly,
 // otherwise if no custom logo is provided, the OAuth2 Proxy Icon is used instead.
@@ -60,6 +60,50 @@ var _ = Describe("SignIn Page", func() {
 				Expect(string(body)).To(Equal("/prefix/ My Provider Sign In Here Custom Footer Text v0.0.0-test /redirect true Logo Data"))
 			})
 
+			It("Writes a button for every provider", func() {
+				tmpl, err := template.New("").Parse("{{range .Providers}}{{.ID}}:{{.Name}}:{{.LogoData}} {{end}}{{.ShowProviderButtons}}")
+				Expect(err).ToNot(HaveOccurred())
+				signInPage.template = tmpl
+				signInPage.providers = []signInProvider{
+					{ID: "entra", Name: "Entra ID", LogoData: "<svg></svg>"},
+					{ID: "github", Name: "GitHub"},
+				}
+
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusOK)
+
+				body, err := io.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("entra:Entra ID:<svg></svg> github:GitHub: true"))
+			})
+
+			It("Hides the provider buttons behind email discovery when skipping the provider button", func() {
+				tmpl, err := template.New("").Parse("{{.EmailDiscovery}} {{.ShowProviderButtons}} {{.Email}}")
+				Expect(err).ToNot(HaveOccurred())
+				signInPage.template = tmpl
+				signInPage.displayEmailDiscovery = true
+				signInPage.skipProviderButton = true
+
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusOK)
+
+				body, err := io.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("true false "))
+
+				// Once discovery failed to find a single provider, the buttons are shown
+				discoveryRequest := httptest.NewRequest("", "http://127.0.0.1/?email=user@example.com", nil)
+				discoveryRequest = middlewareapi.AddRequestScope(discoveryRequest, &middlewareapi.RequestScope{
+					RequestID: testRequestID,
+				})
+				recorder = httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, discoveryRequest, "/redirect", http.StatusOK)
+
+				body, err = io.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("true true user@example.com"))
+			})
+
 			It("Writes an error if the template can't be rendered", func() {
 				// Overwrite the template with something bad
 				tmpl, err := template.New("").Parse("{{.Unknown}}")
@@ -41,10 +41,14 @@ var _ = Describe("Templates", func() {
 				Footer      string
 
 				// For default sign_in template
-				SignInMessage string
-				ProviderName  string
-				CustomLogin   bool
-				LogoData      string
+				SignInMessage       string
+				ProviderName        string
+				Providers           []signInProvider
+				EmailDiscovery      bool
+				Email               string
+				ShowProviderButtons bool
+				CustomLogin         bool
+				LogoData            string
 
 				// For default error template
 				StatusCode int
@@ -59,10 +63,13 @@ var _ = Describe("Templates", func() {
 				Redirect:    "<redirect>",
 				Footer:      "<footer>",
 
-				SignInMessage: "<sign-in-message>",
-				ProviderName:  "<provider-name>",
-				CustomLogin:   false,
-				LogoData:      "<logo>",
+				SignInMessage:       "<sign-in-message>",
+				ProviderName:        "<provider-name>",
+				Providers:           []signInProvider{{Name: "<provider-name>"}},
+				EmailDiscovery:      false,
+				ShowProviderButtons: true,
+				CustomLogin:         false,
+				LogoData:            "<logo>",
 
 				StatusCode: 404,
 				Title:      "<title>",
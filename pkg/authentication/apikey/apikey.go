@@ -0,0 +1,189 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/watcher"
+	"go.yaml.in/yaml/v3"
+)
+
+// ErrUnknownKey is returned for API keys that aren't configured
+var ErrUnknownKey = errors.New("unknown API key")
+
+// Store holds the configured API keys and the users they authenticate as.
+// Only the SHA-256 hashes of the keys are kept.
+type Store struct {
+	static map[string]apiKey
+
+	fromFile map[string]apiKey
+	rwm      sync.RWMutex
+}
+
+// apiKey is the user an API key authenticates as
+type apiKey struct {
+	user      string
+	email     string
+	groups    []string
+	expiresAt *time.Time
+}
+
+// NewStore constructs a store from the API keys of the options, and loads
+// the keys file, reloading it when it is updated.
+func NewStore(opts options.APIKeyOptions) (*Store, error) {
+	static, err := newAPIKeyMap(opts.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API keys: %v", err)
+	}
+	s := &Store{static: static}
+
+	if opts.KeysFile == "" {
+		return s, nil
+	}
+	if err := s.loadKeysFile(opts.KeysFile); err != nil {
+		return nil, err
+	}
+	if err := watcher.WatchFileForUpdates(opts.KeysFile, nil, func() {
+		if err := s.loadKeysFile(opts.KeysFile); err != nil {
+			logger.Errorf("%v: no changes were made to the current API keys", err)
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("could not watch API keys file: %v", err)
+	}
+	return s, nil
+}
+
+// loadKeysFile replaces the keys of the store from the keys file
+func (s *Store) loadKeysFile(filename string) error {
+	// The API keys file is a configurable option
+	data, err := os.ReadFile(filename) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("could not read API keys file: %v", err)
+	}
+
+	var intermediate interface{}
+	if err := yaml.Unmarshal(data, &intermediate); err != nil {
+		return fmt.Errorf("could not parse API keys file: %v", err)
+	}
+	var keys []options.APIKey
+	if err := options.Decode(intermediate, &keys); err != nil {
+		return fmt.Errorf("could not parse API keys file: %v", err)
+	}
+
+	fromFile, err := newAPIKeyMap(keys)
+	if err != nil {
+		return fmt.Errorf("invalid API keys in file: %v", err)
+	}
+
+	s.rwm.Lock()
+	s.fromFile = fromFile
+	s.rwm.Unlock()
+	return nil
+}
+
+// newAPIKeyMap maps the hashes of the keys to the users they authenticate as
+func newAPIKeyMap(keys []options.APIKey) (map[string]apiKey, error) {
+	m := make(map[string]apiKey, len(keys))
+	for i, key := range keys {
+		hash, err := keyHash(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %v", i, err)
+		}
+		if _, ok := m[hash]; ok {
+			return nil, fmt.Errorf("key %d: duplicate key", i)
+		}
+
+		user := key.User
+		if user == "" {
+			user = key.Email
+		}
+		if user == "" {
+			return nil, fmt.Errorf("key %d: a user or email is required", i)
+		}
+
+		var expiresAt *time.Time
+		if key.ExpiresAt != "" {
+			t, err := time.Parse(time.RFC3339, key.ExpiresAt)
+			if err != nil {
+				return nil, fmt.Errorf("key %d: invalid expiresAt: %v", i, err)
+			}
+			expiresAt = &t
+		}
+
+		m[hash] = apiKey{
+			user:      user,
+			email:     key.Email,
+			groups:    key.Groups,
+			expiresAt: expiresAt,
+		}
+	}
+	return m, nil
+}
+
+// keyHash returns the configured hash of the key, or hashes the key
+func keyHash(key options.APIKey) (string, error) {
+	switch {
+	case key.Key != nil && key.KeyHash != "":
+		return "", errors.New("only one of key and keyHash can be set")
+	case key.Key != nil:
+		value, err := util.GetSecretValue(key.Key)
+		if err != nil {
+			return "", fmt.Errorf("could not load key: %v", err)
+		}
+		value = []byte(strings.TrimSpace(string(value)))
+		if len(value) == 0 {
+			return "", errors.New("the key is empty")
+		}
+		return hashKey(string(value)), nil
+	case key.KeyHash != "":
+		hash, err := hex.DecodeString(key.KeyHash)
+		if err != nil || len(hash) != sha256.Size {
+			return "", errors.New("keyHash must be a hex encoded SHA-256 hash")
+		}
+		return hex.EncodeToString(hash), nil
+	default:
+		return "", errors.New("one of key or keyHash is required")
+	}
+}
+
+func hashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// Authenticate returns a session for the user the API key authenticates as.
+// An error is returned when the key is unknown or has expired.
+func (s *Store) Authenticate(key string) (*sessionsapi.SessionState, error) {
+	hash := hashKey(key)
+
+	k, ok := s.static[hash]
+	if !ok {
+		s.rwm.RLock()
+		k, ok = s.fromFile[hash]
+		s.rwm.RUnlock()
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if k.expiresAt != nil && time.Now().After(*k.expiresAt) {
+		return nil, fmt.Errorf("the API key of %s expired at %s", k.user, k.expiresAt.Format(time.RFC3339))
+	}
+
+	return &sessionsapi.SessionState{
+		User:      k.user,
+		Email:     k.email,
+		Groups:    append([]string(nil), k.groups...),
+		ExpiresOn: k.expiresAt,
+	}, nil
+}
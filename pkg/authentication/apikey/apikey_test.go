@@ -0,0 +1,272 @@
+package apikey
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	batchKey   = "b4tch-k3y"
+	webhookKey = "w3bh00k-k3y"
+	expiredKey = "3xp1r3d-k3y"
+	fileKey    = "f1l3-k3y"
+)
+
+var _ = Describe("API Key Suite", func() {
+	expiresAt := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiredAt := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	staticKeys := []options.APIKey{
+		{
+			Key:       &options.SecretSource{Value: []byte(batchKey)},
+			User:      "batch",
+			Groups:    []string{"jobs"},
+			ExpiresAt: expiresAt.Format(time.RFC3339),
+		},
+		{
+			KeyHash: hashKey(webhookKey),
+			Email:   "webhook@example.com",
+		},
+		{
+			Key:       &options.SecretSource{Value: []byte(expiredKey)},
+			User:      "expired",
+			ExpiresAt: expiredAt.Format(time.RFC3339),
+		},
+	}
+
+	Context("Authenticate", func() {
+		type authenticateTableInput struct {
+			key             string
+			expectedSession *sessionsapi.SessionState
+			expectedErr     string
+		}
+
+		DescribeTable("with static keys",
+			func(in authenticateTableInput) {
+				store, err := NewStore(options.APIKeyOptions{Keys: staticKeys})
+				Expect(err).ToNot(HaveOccurred())
+
+				session, err := store.Authenticate(in.key)
+				if in.expectedErr != "" {
+					Expect(err).To(MatchError(in.expectedErr))
+				} else {
+					Expect(err).ToNot(HaveOccurred())
+				}
+				Expect(session).To(Equal(in.expectedSession))
+			},
+			Entry("with a key", authenticateTableInput{
+				key: batchKey,
+				expectedSession: &sessionsapi.SessionState{
+					User:      "batch",
+					Groups:    []string{"jobs"},
+					ExpiresOn: &expiresAt,
+				},
+			}),
+			Entry("with a key hash", authenticateTableInput{
+				key: webhookKey,
+				expectedSession: &sessionsapi.SessionState{
+					User:  "webhook@example.com",
+					Email: "webhook@example.com",
+				},
+			}),
+			Entry("with an expired key", authenticateTableInput{
+				key:         expiredKey,
+				expectedErr: "the API key of expired expired at 2000-01-01T00:00:00Z",
+			}),
+			Entry("with an unknown key", authenticateTableInput{
+				key:         "unknown",
+				expectedErr: ErrUnknownKey.Error(),
+			}),
+			Entry("with an empty key", authenticateTableInput{
+				key:         "",
+				expectedErr: ErrUnknownKey.Error(),
+			}),
+		)
+
+		It("does not share groups between sessions", func() {
+			store, err := NewStore(options.APIKeyOptions{Keys: staticKeys})
+			Expect(err).ToNot(HaveOccurred())
+
+			session, err := store.Authenticate(batchKey)
+			Expect(err).ToNot(HaveOccurred())
+			session.Groups[0] = "admins"
+
+			session, err = store.Authenticate(batchKey)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.Groups).To(Equal([]string{"jobs"}))
+		})
+	})
+
+	Context("NewStore", func() {
+		type newStoreTableInput struct {
+			keys        []options.APIKey
+			expectedErr string
+		}
+
+		DescribeTable("with invalid keys",
+			func(in newStoreTableInput) {
+				_, err := NewStore(options.APIKeyOptions{Keys: in.keys})
+				Expect(err).To(MatchError(in.expectedErr))
+			},
+			Entry("with a key and a key hash", newStoreTableInput{
+				keys: []options.APIKey{{
+					Key:     &options.SecretSource{Value: []byte(batchKey)},
+					KeyHash: hashKey(batchKey),
+					User:    "batch",
+				}},
+				expectedErr: "invalid API keys: key 0: only one of key and keyHash can be set",
+			}),
+			Entry("without a key", newStoreTableInput{
+				keys:        []options.APIKey{{User: "batch"}},
+				expectedErr: "invalid API keys: key 0: one of key or keyHash is required",
+			}),
+			Entry("with an empty key", newStoreTableInput{
+				keys: []options.APIKey{{
+					Key:  &options.SecretSource{Value: []byte(" ")},
+					User: "batch",
+				}},
+				expectedErr: "invalid API keys: key 0: the key is empty",
+			}),
+			Entry("with an invalid key hash", newStoreTableInput{
+				keys: []options.APIKey{{
+					KeyHash: "abcdef",
+					User:    "batch",
+				}},
+				expectedErr: "invalid API keys: key 0: keyHash must be a hex encoded SHA-256 hash",
+			}),
+			Entry("without a user", newStoreTableInput{
+				keys: []options.APIKey{{
+					Key: &options.SecretSource{Value: []byte(batchKey)},
+				}},
+				expectedErr: "invalid API keys: key 0: a user or email is required",
+			}),
+			Entry("with a duplicate key", newStoreTableInput{
+				keys: []options.APIKey{
+					{
+						Key:  &options.SecretSource{Value: []byte(batchKey)},
+						User: "batch",
+					},
+					{
+						KeyHash: hashKey(batchKey),
+						User:    "other",
+					},
+				},
+				expectedErr: "invalid API keys: key 1: duplicate key",
+			}),
+			Entry("with an invalid expiry", newStoreTableInput{
+				keys: []options.APIKey{{
+					Key:       &options.SecretSource{Value: []byte(batchKey)},
+					User:      "batch",
+					ExpiresAt: "tomorrow",
+				}},
+				expectedErr: "invalid API keys: key 0: invalid expiresAt: parsing time \"tomorrow\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"tomorrow\" as \"2006\"",
+			}),
+		)
+	})
+
+	Context("with a keys file", func() {
+		var keysFile string
+
+		writeKeysFile := func(content string) {
+			Expect(os.WriteFile(keysFile, []byte(content), 0600)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			keysFile = filepath.Join(GinkgoT().TempDir(), "api-keys.yaml")
+		})
+
+		It("loads the keys from the file", func() {
+			writeKeysFile(`
+- key:
+    value: ` + fileKey + `
+  email: file@example.com
+  groups:
+  - files
+`)
+			store, err := NewStore(options.APIKeyOptions{Keys: staticKeys, KeysFile: keysFile})
+			Expect(err).ToNot(HaveOccurred())
+
+			session, err := store.Authenticate(fileKey)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session).To(Equal(&sessionsapi.SessionState{
+				User:   "file@example.com",
+				Email:  "file@example.com",
+				Groups: []string{"files"},
+			}))
+
+			_, err = store.Authenticate(batchKey)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("reloads the keys when the file changes", func() {
+			writeKeysFile(`
+- key:
+    value: ` + batchKey + `
+  user: batch
+`)
+			store, err := NewStore(options.APIKeyOptions{KeysFile: keysFile})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = store.Authenticate(batchKey)
+			Expect(err).ToNot(HaveOccurred())
+
+			writeKeysFile(`
+- keyHash: ` + hashKey(webhookKey) + `
+  user: webhook
+`)
+			Eventually(func() error {
+				_, err := store.Authenticate(webhookKey)
+				return err
+			}).Should(Succeed())
+			_, err = store.Authenticate(batchKey)
+			Expect(err).To(MatchError(ErrUnknownKey))
+		})
+
+		It("keeps the current keys when the file becomes invalid", func() {
+			writeKeysFile(`
+- key:
+    value: ` + batchKey + `
+  user: batch
+`)
+			store, err := NewStore(options.APIKeyOptions{KeysFile: keysFile})
+			Expect(err).ToNot(HaveOccurred())
+
+			writeKeysFile(`
+- key:
+    value: ` + batchKey + `
+`)
+			Consistently(func() error {
+				_, err := store.Authenticate(batchKey)
+				return err
+			}, 500*time.Millisecond).Should(Succeed())
+		})
+
+		It("returns an error for an invalid file", func() {
+			writeKeysFile(`keys: [`)
+			_, err := NewStore(options.APIKeyOptions{KeysFile: keysFile})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("could not parse API keys file: "))
+		})
+
+		It("returns an error for invalid keys in the file", func() {
+			writeKeysFile(`
+- key:
+    value: ` + batchKey + `
+`)
+			_, err := NewStore(options.APIKeyOptions{KeysFile: keysFile})
+			Expect(err).To(MatchError("invalid API keys in file: key 0: a user or email is required"))
+		})
+
+		It("returns an error for a missing file", func() {
+			_, err := NewStore(options.APIKeyOptions{KeysFile: keysFile})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("could not read API keys file: "))
+		})
+	})
+})
@@ -0,0 +1,396 @@
+package basic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	pkgutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+)
+
+// ldapConn is the part of an LDAP connection the ldapValidator uses
+type ldapConn interface {
+	Bind(username, password string) error
+	UnauthenticatedBind(username string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// ldapValidator validates users by binding to an LDAP directory with their
+// password, after searching for their entry with a service account.
+type ldapValidator struct {
+	opts         options.LDAPOptions
+	bindPassword string
+	dial         func() (ldapConn, error)
+	cache        *ldapCache
+}
+
+var _ GroupsValidator = (*ldapValidator)(nil)
+
+// NewLDAPValidator constructs a validator that binds to the LDAP directory
+// with the users passwords and looks up their groups.
+func NewLDAPValidator(opts options.LDAPOptions) (Validator, error) {
+	var bindPassword string
+	if opts.BindPassword != nil {
+		value, err := util.GetSecretValue(opts.BindPassword)
+		if err != nil {
+			return nil, fmt.Errorf("could not load the LDAP bind password: %v", err)
+		}
+		bindPassword = strings.TrimSpace(string(value))
+	}
+
+	dial, err := newLDAPDialer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := newLDAPCache(ptr.Deref(opts.CacheTTL, options.DefaultLDAPCacheTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ldapValidator{
+		opts:         opts,
+		bindPassword: bindPassword,
+		dial:         dial,
+		cache:        cache,
+	}, nil
+}
+
+// newLDAPDialer returns a function that opens TLS connections to the LDAP
+// server, either directly for ldaps:// URLs or with StartTLS
+func newLDAPDialer(opts options.LDAPOptions) (func() (ldapConn, error), error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the LDAP URL: %v", err)
+	}
+	if u.Scheme != "ldaps" && !opts.StartTLS {
+		return nil, errors.New("LDAP connections must use TLS: use an ldaps:// URL or StartTLS")
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: opts.InsecureSkipTLSVerify, // #nosec G402 -- InsecureSkipVerify is a configurable option we allow
+		MinVersion:         tls.VersionTLS12,
+	}
+	if len(opts.CAFiles) > 0 {
+		pool, err := pkgutil.GetCertPool(opts.CAFiles, false)
+		if err != nil {
+			return nil, fmt.Errorf("could not load the LDAP CA files: %v", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	timeout := ptr.Deref(opts.Timeout, options.DefaultLDAPTimeout)
+	return func() (ldapConn, error) {
+		conn, err := ldap.DialURL(opts.URL,
+			ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+			ldap.DialWithTLSConfig(tlsConfig),
+		)
+		if err != nil {
+			return nil, err
+		}
+		conn.SetTimeout(timeout)
+
+		if u.Scheme != "ldaps" {
+			if err := conn.StartTLS(tlsConfig); err != nil {
+				_ = conn.Close()
+				return nil, fmt.Errorf("could not start TLS: %v", err)
+			}
+		}
+		return conn, nil
+	}, nil
+}
+
+// Validate checks the users password by binding to the LDAP directory
+func (v *ldapValidator) Validate(user, password string) bool {
+	_, valid := v.ValidateGroups(user, password)
+	return valid
+}
+
+// ValidateGroups checks the users password by binding to the LDAP directory
+// and returns the groups of the user
+func (v *ldapValidator) ValidateGroups(user, password string) ([]string, bool) {
+	// An empty password would make an unauthenticated bind, which succeeds
+	// for any DN
+	if user == "" || password == "" {
+		return nil, false
+	}
+
+	if groups, ok := v.cache.get(user, password); ok {
+		return groups, true
+	}
+
+	groups, err := v.authenticate(user, password)
+	if err != nil {
+		logger.Errorf("Error validating %q against LDAP: %v", user, err)
+		return nil, false
+	}
+	if groups == nil {
+		return nil, false
+	}
+
+	v.cache.set(user, password, groups)
+	return groups, true
+}
+
+// authenticate searches for the user with the service account, binds as the
+// user and loads the groups of the user with the service account again.
+// The groups are nil without an error when the user is not found or the
+// password is wrong.
+func (v *ldapValidator) authenticate(user, password string) ([]string, error) {
+	conn, err := v.dial()
+	if err != nil {
+		return nil, fmt.Errorf("could not connect: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if err := v.bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+
+	entry, err := v.findUser(conn, user)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not bind as %q: %v", entry.DN, err)
+	}
+
+	if err := v.bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+	return v.findGroups(conn, user, entry)
+}
+
+func (v *ldapValidator) bindServiceAccount(conn ldapConn) error {
+	var err error
+	if v.opts.BindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(v.opts.BindDN, v.bindPassword)
+	}
+	if err != nil {
+		return fmt.Errorf("could not bind as the service account: %v", err)
+	}
+	return nil
+}
+
+// findUser returns the entry of the user, or nil when there isn't exactly
+// one entry matching the user filter
+func (v *ldapValidator) findUser(conn ldapConn, user string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(v.opts.UserFilter, "{username}", ldap.EscapeFilter(user))
+	result, err := conn.Search(ldap.NewSearchRequest(
+		v.opts.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		filter, []string{v.opts.MemberOfAttribute}, nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("could not search for the user: %v", err)
+	}
+	if result == nil || len(result.Entries) != 1 {
+		return nil, nil
+	}
+	return result.Entries[0], nil
+}
+
+// findGroups returns the groups of the user, from the member of attribute of
+// the user or by searching for the groups with the group filter
+func (v *ldapValidator) findGroups(conn ldapConn, user string, entry *ldap.Entry) ([]string, error) {
+	groups := []string{}
+	seen := map[string]bool{}
+
+	if v.opts.GroupBaseDN == "" {
+		pending := entry.GetAttributeValues(v.opts.MemberOfAttribute)
+		for len(pending) > 0 {
+			dn := pending[0]
+			pending = pending[1:]
+			if seen[strings.ToLower(dn)] {
+				continue
+			}
+			seen[strings.ToLower(dn)] = true
+
+			// The group entry is only read when the group name isn't the
+			// value of the first RDN, which it is for the default `cn`, or
+			// when nested groups are resolved
+			name, ok := ldapRDNValue(dn, v.opts.GroupNameAttribute)
+			if !ok || v.opts.NestedGroups {
+				group, err := v.searchGroupEntry(conn, dn)
+				if err != nil {
+					return nil, err
+				}
+				if group != nil {
+					if !ok {
+						name = group.GetAttributeValue(v.opts.GroupNameAttribute)
+					}
+					pending = append(pending, group.GetAttributeValues(v.opts.MemberOfAttribute)...)
+				}
+			}
+			if name != "" {
+				groups = append(groups, name)
+			}
+		}
+		return groups, nil
+	}
+
+	pending := []string{entry.DN}
+	for len(pending) > 0 {
+		memberDN := pending[0]
+		pending = pending[1:]
+
+		found, err := v.searchGroups(conn, user, memberDN)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range found {
+			if seen[strings.ToLower(group.DN)] {
+				continue
+			}
+			seen[strings.ToLower(group.DN)] = true
+			groups = append(groups, group.GetAttributeValue(v.opts.GroupNameAttribute))
+			if v.opts.NestedGroups {
+				pending = append(pending, group.DN)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// searchGroupEntry returns the group with the DN, with its name and member of
+// attributes, or nil when it doesn't exist
+func (v *ldapValidator) searchGroupEntry(conn ldapConn, dn string) (*ldap.Entry, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{v.opts.GroupNameAttribute, v.opts.MemberOfAttribute}, nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not search the group %q: %v", dn, err)
+	}
+	if len(result.Entries) == 0 {
+		return nil, nil
+	}
+	return result.Entries[0], nil
+}
+
+// searchGroups searches for the groups with the member DN as a member
+func (v *ldapValidator) searchGroups(conn ldapConn, user, memberDN string) ([]*ldap.Entry, error) {
+	filter := strings.NewReplacer(
+		"{dn}", ldap.EscapeFilter(memberDN),
+		"{username}", ldap.EscapeFilter(user),
+	).Replace(v.opts.GroupFilter)
+	result, err := conn.Search(ldap.NewSearchRequest(
+		v.opts.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, []string{v.opts.GroupNameAttribute}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("could not search the groups of %q: %v", memberDN, err)
+	}
+	return result.Entries, nil
+}
+
+// ldapRDNValue returns the value of the first RDN of the DN when it is the
+// given attribute, e.g. the common name of a group
+func ldapRDNValue(dn, attribute string) (string, bool) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return "", false
+	}
+	for _, rdn := range parsed.RDNs[0].Attributes {
+		if strings.EqualFold(rdn.Type, attribute) {
+			return rdn.Value, true
+		}
+	}
+	return "", false
+}
+
+// ldapCache caches the groups of successful logins, keyed by a keyed hash of
+// the username and password so that the passwords aren't kept in memory
+type ldapCache struct {
+	ttl     time.Duration
+	hashKey []byte
+
+	mutex   sync.Mutex
+	entries map[string]ldapCacheEntry
+}
+
+type ldapCacheEntry struct {
+	groups    []string
+	expiresAt time.Time
+}
+
+func newLDAPCache(ttl time.Duration) (*ldapCache, error) {
+	hashKey, err := encryption.Nonce(32)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate the LDAP cache key: %v", err)
+	}
+	return &ldapCache{
+		ttl:     ttl,
+		hashKey: hashKey,
+		entries: map[string]ldapCacheEntry{},
+	}, nil
+}
+
+func (c *ldapCache) key(user, password string) string {
+	mac := hmac.New(sha256.New, c.hashKey)
+	_, _ = mac.Write([]byte(user))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *ldapCache) get(user, password string) ([]string, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[c.key(user, password)]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.groups, true
+}
+
+func (c *ldapCache) set(user, password string, groups []string) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[c.key(user, password)] = ldapCacheEntry{
+		groups:    groups,
+		expiresAt: now.Add(c.ttl),
+	}
+}
@@ -0,0 +1,259 @@
+package basic
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	ldapServiceDN       = "cn=proxy,ou=services,dc=example,dc=com"
+	ldapServicePassword = "s3rv1ce"
+	ldapJaneDN          = "uid=jane,ou=people,dc=example,dc=com"
+	ldapJanePassword    = "j4n3P455"
+	ldapAdminsDN        = "cn=admins,ou=groups,dc=example,dc=com"
+	ldapStaffDN         = "cn=staff,ou=groups,dc=example,dc=com"
+	ldapEveryoneDN      = "cn=everyone,ou=groups,dc=example,dc=com"
+)
+
+// fakeLDAPDirectory answers the searches of the validator from its entries,
+// keyed by filter for subtree searches and by DN for base object searches
+type fakeLDAPDirectory struct {
+	passwords map[string]string
+	searches  map[string][]*ldap.Entry
+
+	dials    int
+	boundDNs []string
+}
+
+func (d *fakeLDAPDirectory) dial() (ldapConn, error) {
+	d.dials++
+	return &fakeLDAPConn{directory: d}, nil
+}
+
+type fakeLDAPConn struct {
+	directory *fakeLDAPDirectory
+}
+
+func (c *fakeLDAPConn) Bind(username, password string) error {
+	if realPassword, ok := c.directory.passwords[username]; !ok || realPassword != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	c.directory.boundDNs = append(c.directory.boundDNs, username)
+	return nil
+}
+
+func (c *fakeLDAPConn) UnauthenticatedBind(username string) error {
+	c.directory.boundDNs = append(c.directory.boundDNs, username)
+	return nil
+}
+
+func (c *fakeLDAPConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	key := req.Filter
+	if req.Scope == ldap.ScopeBaseObject {
+		key = req.BaseDN
+	}
+	return &ldap.SearchResult{Entries: c.directory.searches[key]}, nil
+}
+
+func (c *fakeLDAPConn) Close() error {
+	return nil
+}
+
+var _ = Describe("LDAP Suite", func() {
+	var directory *fakeLDAPDirectory
+	var opts options.LDAPOptions
+
+	newValidator := func() *ldapValidator {
+		opts.EnsureDefaults()
+		validator, err := NewLDAPValidator(opts)
+		Expect(err).ToNot(HaveOccurred())
+
+		v := validator.(*ldapValidator)
+		v.dial = directory.dial
+		return v
+	}
+
+	BeforeEach(func() {
+		directory = &fakeLDAPDirectory{
+			passwords: map[string]string{
+				ldapServiceDN: ldapServicePassword,
+				ldapJaneDN:    ldapJanePassword,
+			},
+			searches: map[string][]*ldap.Entry{
+				"(uid=jane)": {
+					ldap.NewEntry(ldapJaneDN, map[string][]string{"memberOf": {ldapAdminsDN}}),
+				},
+				"(uid=twins)": {
+					ldap.NewEntry("uid=twin1,ou=people,dc=example,dc=com", nil),
+					ldap.NewEntry("uid=twin2,ou=people,dc=example,dc=com", nil),
+				},
+				ldapAdminsDN: {
+					ldap.NewEntry(ldapAdminsDN, map[string][]string{"memberOf": {ldapStaffDN}}),
+				},
+				ldapStaffDN: {
+					ldap.NewEntry(ldapStaffDN, map[string][]string{"memberOf": {ldapEveryoneDN, ldapAdminsDN}}),
+				},
+				"(member=" + ldapJaneDN + ")": {
+					ldap.NewEntry(ldapAdminsDN, map[string][]string{"cn": {"admins"}}),
+				},
+				"(member=" + ldapAdminsDN + ")": {
+					ldap.NewEntry(ldapStaffDN, map[string][]string{"cn": {"staff"}}),
+				},
+				"(member=" + ldapStaffDN + ")": {
+					ldap.NewEntry(ldapAdminsDN, map[string][]string{"cn": {"admins"}}),
+				},
+			},
+		}
+		opts = options.LDAPOptions{
+			URL:          "ldaps://ldap.example.com",
+			BindDN:       ldapServiceDN,
+			BindPassword: &options.SecretSource{Value: []byte(ldapServicePassword)},
+			UserBaseDN:   "ou=people,dc=example,dc=com",
+		}
+	})
+
+	Context("NewLDAPValidator", func() {
+		It("requires TLS", func() {
+			opts.URL = "ldap://ldap.example.com"
+			_, err := NewLDAPValidator(opts)
+			Expect(err).To(MatchError("LDAP connections must use TLS: use an ldaps:// URL or StartTLS"))
+
+			opts.StartTLS = true
+			_, err = NewLDAPValidator(opts)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("fails without the bind password", func() {
+			opts.BindPassword = &options.SecretSource{FromFile: "/does/not/exist"}
+			_, err := NewLDAPValidator(opts)
+			Expect(err).To(MatchError(ContainSubstring("could not load the LDAP bind password")))
+		})
+	})
+
+	Context("with the groups from the memberOf attribute", func() {
+		It("accepts the correct password", func() {
+			v := newValidator()
+			groups, valid := v.ValidateGroups("jane", ldapJanePassword)
+			Expect(valid).To(BeTrue())
+			Expect(groups).To(Equal([]string{"admins"}))
+			Expect(directory.boundDNs).To(Equal([]string{ldapServiceDN, ldapJaneDN, ldapServiceDN}))
+		})
+
+		It("rejects incorrect passwords", func() {
+			v := newValidator()
+			Expect(v.Validate("jane", "wrong")).To(BeFalse())
+			Expect(v.Validate("jane", "")).To(BeFalse())
+		})
+
+		It("rejects unknown and ambiguous users", func() {
+			v := newValidator()
+			Expect(v.Validate("john", ldapJanePassword)).To(BeFalse())
+			Expect(v.Validate("twins", ldapJanePassword)).To(BeFalse())
+		})
+
+		It("escapes the username in the filter", func() {
+			v := newValidator()
+			Expect(v.Validate("jane)(uid=*", ldapJanePassword)).To(BeFalse())
+		})
+
+		It("resolves nested groups", func() {
+			opts.NestedGroups = true
+			v := newValidator()
+			groups, valid := v.ValidateGroups("jane", ldapJanePassword)
+			Expect(valid).To(BeTrue())
+			Expect(groups).To(Equal([]string{"admins", "staff", "everyone"}))
+		})
+
+		It("reads the group name attribute from the groups", func() {
+			directory.searches[ldapAdminsDN] = []*ldap.Entry{
+				ldap.NewEntry(ldapAdminsDN, map[string][]string{
+					"displayName": {"Administrators"},
+					"memberOf":    {ldapStaffDN},
+				}),
+			}
+			directory.searches[ldapStaffDN] = []*ldap.Entry{
+				ldap.NewEntry(ldapStaffDN, map[string][]string{"displayName": {"Staff"}}),
+			}
+			opts.GroupNameAttribute = "displayName"
+			opts.NestedGroups = true
+			v := newValidator()
+			groups, valid := v.ValidateGroups("jane", ldapJanePassword)
+			Expect(valid).To(BeTrue())
+			Expect(groups).To(Equal([]string{"Administrators", "Staff"}))
+		})
+	})
+
+	Context("with the groups from a group search", func() {
+		BeforeEach(func() {
+			opts.GroupBaseDN = "ou=groups,dc=example,dc=com"
+		})
+
+		It("accepts the correct password", func() {
+			v := newValidator()
+			groups, valid := v.ValidateGroups("jane", ldapJanePassword)
+			Expect(valid).To(BeTrue())
+			Expect(groups).To(Equal([]string{"admins"}))
+		})
+
+		It("resolves nested groups", func() {
+			opts.NestedGroups = true
+			v := newValidator()
+			groups, valid := v.ValidateGroups("jane", ldapJanePassword)
+			Expect(valid).To(BeTrue())
+			Expect(groups).To(Equal([]string{"admins", "staff"}))
+		})
+
+		It("returns no groups for users without groups", func() {
+			opts.GroupFilter = "(memberUid={username})"
+			v := newValidator()
+			groups, valid := v.ValidateGroups("jane", ldapJanePassword)
+			Expect(valid).To(BeTrue())
+			Expect(groups).To(BeEmpty())
+			Expect(groups).ToNot(BeNil())
+		})
+	})
+
+	Context("with the cache", func() {
+		It("caches successful logins", func() {
+			v := newValidator()
+			Expect(v.Validate("jane", ldapJanePassword)).To(BeTrue())
+			Expect(v.Validate("jane", ldapJanePassword)).To(BeTrue())
+			Expect(directory.dials).To(Equal(1))
+		})
+
+		It("doesn't cache failed logins", func() {
+			v := newValidator()
+			Expect(v.Validate("jane", "wrong")).To(BeFalse())
+			Expect(v.Validate("jane", "wrong")).To(BeFalse())
+			Expect(directory.dials).To(Equal(2))
+
+			// A cached login doesn't accept other passwords
+			Expect(v.Validate("jane", ldapJanePassword)).To(BeTrue())
+			Expect(v.Validate("jane", "wrong")).To(BeFalse())
+			Expect(directory.dials).To(Equal(4))
+		})
+
+		It("expires cached logins", func() {
+			opts.CacheTTL = ptr.To(time.Millisecond)
+			v := newValidator()
+			Expect(v.Validate("jane", ldapJanePassword)).To(BeTrue())
+			time.Sleep(5 * time.Millisecond)
+			Expect(v.Validate("jane", ldapJanePassword)).To(BeTrue())
+			Expect(directory.dials).To(Equal(2))
+		})
+
+		It("can be disabled", func() {
+			opts.CacheTTL = ptr.To(time.Duration(0))
+			v := newValidator()
+			Expect(v.Validate("jane", ldapJanePassword)).To(BeTrue())
+			Expect(v.Validate("jane", ldapJanePassword)).To(BeTrue())
+			Expect(directory.dials).To(Equal(2))
+		})
+	})
+})
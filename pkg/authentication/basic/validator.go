@@ -5,3 +5,60 @@ package basic
 type Validator interface {
 	Validate(user, password string) bool
 }
+
+// GroupsValidator is a Validator that also looks up the groups of the users
+// it validates, such as a directory.
+type GroupsValidator interface {
+	Validator
+
+	// ValidateGroups validates the username and password combination and
+	// returns the groups of the user when it is valid.
+	ValidateGroups(user, password string) ([]string, bool)
+}
+
+// ValidateGroups validates the username and password combination with the
+// validator. The groups of the user are returned when the validator looks
+// them up, the static groups otherwise.
+func ValidateGroups(validator Validator, user, password string, staticGroups []string) ([]string, bool) {
+	if v, ok := validator.(GroupsValidator); ok {
+		groups, valid := v.ValidateGroups(user, password)
+		if valid && groups == nil {
+			groups = staticGroups
+		}
+		return groups, valid
+	}
+	if validator.Validate(user, password) {
+		return staticGroups, true
+	}
+	return nil, false
+}
+
+// multiValidator validates users against each of its validators in turn
+type multiValidator []Validator
+
+// NewMultiValidator constructs a validator that accepts the users any of the
+// validators accepts, in the order they are given.
+func NewMultiValidator(validators ...Validator) Validator {
+	if len(validators) == 1 {
+		return validators[0]
+	}
+	return multiValidator(validators)
+}
+
+// Validate checks the users password against each of the validators
+func (m multiValidator) Validate(user, password string) bool {
+	_, valid := m.ValidateGroups(user, password)
+	return valid
+}
+
+// ValidateGroups returns the groups of the first validator that accepts the
+// user. The groups are nil when that validator doesn't look up groups, so
+// that the static groups are used for it.
+func (m multiValidator) ValidateGroups(user, password string) ([]string, bool) {
+	for _, validator := range m {
+		if groups, valid := ValidateGroups(validator, user, password, nil); valid {
+			return groups, true
+		}
+	}
+	return nil, false
+}
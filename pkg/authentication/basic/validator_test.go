@@ -0,0 +1,95 @@
+package basic
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeValidator map[string]string
+
+func (f fakeValidator) Validate(user, password string) bool {
+	realPassword, ok := f[user]
+	return ok && realPassword == password
+}
+
+type fakeGroupsValidator struct {
+	fakeValidator
+	groups map[string][]string
+}
+
+func (f fakeGroupsValidator) ValidateGroups(user, password string) ([]string, bool) {
+	if !f.Validate(user, password) {
+		return nil, false
+	}
+	return f.groups[user], true
+}
+
+var _ = Describe("Validator Suite", func() {
+	staticGroups := []string{"static"}
+	htpasswd := fakeValidator{adminUser: adminPassword}
+	directory := fakeGroupsValidator{
+		fakeValidator: fakeValidator{user1: user1Password, user2: user2Password},
+		groups:        map[string][]string{user1: {"users"}, user2: {}},
+	}
+
+	type validateGroupsTableInput struct {
+		validator      Validator
+		user           string
+		password       string
+		expectedGroups []string
+		expectedValid  bool
+	}
+
+	DescribeTable("ValidateGroups",
+		func(in validateGroupsTableInput) {
+			groups, valid := ValidateGroups(in.validator, in.user, in.password, staticGroups)
+			Expect(valid).To(Equal(in.expectedValid))
+			Expect(groups).To(Equal(in.expectedGroups))
+		},
+		Entry("with a validator without groups", validateGroupsTableInput{
+			validator:      htpasswd,
+			user:           adminUser,
+			password:       adminPassword,
+			expectedGroups: staticGroups,
+			expectedValid:  true,
+		}),
+		Entry("with a validator without groups and an invalid password", validateGroupsTableInput{
+			validator: htpasswd,
+			user:      adminUser,
+			password:  user1Password,
+		}),
+		Entry("with a groups validator", validateGroupsTableInput{
+			validator:      directory,
+			user:           user1,
+			password:       user1Password,
+			expectedGroups: []string{"users"},
+			expectedValid:  true,
+		}),
+		Entry("with a groups validator and a user without groups", validateGroupsTableInput{
+			validator:      directory,
+			user:           user2,
+			password:       user2Password,
+			expectedGroups: []string{},
+			expectedValid:  true,
+		}),
+		Entry("with a multi validator and a user of the first validator", validateGroupsTableInput{
+			validator:      NewMultiValidator(htpasswd, directory),
+			user:           adminUser,
+			password:       adminPassword,
+			expectedGroups: staticGroups,
+			expectedValid:  true,
+		}),
+		Entry("with a multi validator and a user of the second validator", validateGroupsTableInput{
+			validator:      NewMultiValidator(htpasswd, directory),
+			user:           user1,
+			password:       user1Password,
+			expectedGroups: []string{"users"},
+			expectedValid:  true,
+		}),
+		Entry("with a multi validator and an invalid password", validateGroupsTableInput{
+			validator: NewMultiValidator(htpasswd, directory),
+			user:      user1,
+			password:  adminPassword,
+		}),
+	)
+})
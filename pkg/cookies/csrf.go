@@ -22,6 +22,8 @@ type CSRF interface {
 	CheckOAuthState(string) bool
 	CheckOIDCNonce(string) bool
 	GetCodeVerifier() string
+	GetProviderID() string
+	SetProviderID(string)
 
 	SetSessionNonce(s *sessions.SessionState)
 
@@ -45,6 +47,11 @@ type csrf struct {
 	// authentication code.
 	CodeVerifier string `msgpack:"cv,omitempty"`
 
+	// ProviderID holds the ID of the provider the authentication flow was
+	// started with, so that the callback redeems the code against the same
+	// provider.
+	ProviderID string `msgpack:"p,omitempty"`
+
 	cookieOpts *options.Cookie
 	clock      func() time.Time
 }
@@ -108,6 +115,16 @@ func (c *csrf) GetCodeVerifier() string {
 	return c.CodeVerifier
 }
 
+// GetProviderID returns the ID of the provider that started the flow
+func (c *csrf) GetProviderID() string {
+	return c.ProviderID
+}
+
+// SetProviderID records the ID of the provider that started the flow
+func (c *csrf) SetProviderID(providerID string) {
+	c.ProviderID = providerID
+}
+
 // HashOAuthState returns the hash of the OAuth state nonce
 func (c *csrf) HashOAuthState() string {
 	return encryption.HashNonce(c.OAuthState)
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/justinas/alice"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/apikey"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// apiKeyAuthScheme is the Authorization header scheme API keys can be
+// presented with
+const apiKeyAuthScheme = "ApiKey"
+
+// NewAPIKeySessionLoader creates a new handler that loads sessions from the
+// API keys presented in the header, or with the ApiKey scheme of the
+// Authorization header.
+func NewAPIKeySessionLoader(store *apikey.Store, header string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return loadAPIKeySession(store, header, next)
+	}
+}
+
+// loadAPIKeySession attempts to load a session from an API key in the
+// request.
+// If no API key is found, or the key is unknown or expired, no session will
+// be loaded and the request will be passed to the next handler.
+// If a session was loaded by a previous handler, it will not be replaced.
+func loadAPIKeySession(store *apikey.Store, header string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		scope := middlewareapi.GetRequestScope(req)
+		// If scope is nil, this will panic.
+		// A scope should always be injected before this handler is called.
+		if scope.Session != nil {
+			// The session was already loaded, pass to the next handler
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		scope.Session = getAPIKeySession(store, header, req)
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// getAPIKeySession attempts to load a session for the API key in the
+// request.
+func getAPIKeySession(store *apikey.Store, header string, req *http.Request) *sessionsapi.SessionState {
+	key := findAPIKey(header, req)
+	if key == "" {
+		// No API key provided, so don't attempt to load a session
+		return nil
+	}
+
+	session, err := store.Authenticate(key)
+	if err != nil {
+		logger.PrintAuthf("", req, logger.AuthFailure, "Invalid authentication via API key: %v", err)
+		return nil
+	}
+
+	logger.PrintAuthf(session.User, req, logger.AuthSuccess, "Authenticated via API key")
+	return session
+}
+
+// findAPIKey returns the API key from the header, or from the Authorization
+// header with the ApiKey scheme
+func findAPIKey(header string, req *http.Request) string {
+	if key := strings.TrimSpace(req.Header.Get(header)); key != "" {
+		return key
+	}
+
+	tokenType, token, err := splitAuthHeader(req.Header.Get("Authorization"))
+	if err != nil || !strings.EqualFold(tokenType, apiKeyAuthScheme) {
+		return ""
+	}
+	return token
+}
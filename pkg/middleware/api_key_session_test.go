@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/apikey"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("API Key Session Suite", func() {
+	Context("APIKeySessionLoader", func() {
+		const apiKey = "4p1-k3y"
+
+		type apiKeySessionLoaderTableInput struct {
+			headers         map[string]string
+			existingSession *sessionsapi.SessionState
+			expectedSession *sessionsapi.SessionState
+		}
+
+		DescribeTable("with API key headers",
+			func(in apiKeySessionLoaderTableInput) {
+				store, err := apikey.NewStore(options.APIKeyOptions{
+					Keys: []options.APIKey{{
+						Key:    &options.SecretSource{Value: []byte(apiKey)},
+						User:   "batch",
+						Groups: []string{"jobs"},
+					}},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				scope := &middlewareapi.RequestScope{
+					Session: in.existingSession,
+				}
+
+				// Set up the request with the headers and a request scope
+				req := httptest.NewRequest("", "/", nil)
+				for name, value := range in.headers {
+					req.Header.Set(name, value)
+				}
+				req = middlewareapi.AddRequestScope(req, scope)
+
+				rw := httptest.NewRecorder()
+
+				// Create the handler with a next handler that will capture the session
+				// from the scope
+				var gotSession *sessionsapi.SessionState
+				handler := NewAPIKeySessionLoader(store, options.DefaultAPIKeyHeader)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					gotSession = middlewareapi.GetRequestScope(r).Session
+				}))
+				handler.ServeHTTP(rw, req)
+
+				Expect(gotSession).To(Equal(in.expectedSession))
+			},
+			Entry("without a key", apiKeySessionLoaderTableInput{
+				headers:         map[string]string{},
+				existingSession: nil,
+				expectedSession: nil,
+			}),
+			Entry("with a key in the header", apiKeySessionLoaderTableInput{
+				headers:         map[string]string{"X-API-Key": apiKey},
+				existingSession: nil,
+				expectedSession: &sessionsapi.SessionState{User: "batch", Groups: []string{"jobs"}},
+			}),
+			Entry("with a key in the Authorization header", apiKeySessionLoaderTableInput{
+				headers:         map[string]string{"Authorization": "ApiKey " + apiKey},
+				existingSession: nil,
+				expectedSession: &sessionsapi.SessionState{User: "batch", Groups: []string{"jobs"}},
+			}),
+			Entry("with a key in the Authorization header with a lowercase scheme", apiKeySessionLoaderTableInput{
+				headers:         map[string]string{"Authorization": "apikey " + apiKey},
+				existingSession: nil,
+				expectedSession: &sessionsapi.SessionState{User: "batch", Groups: []string{"jobs"}},
+			}),
+			Entry("with a bearer token in the Authorization header", apiKeySessionLoaderTableInput{
+				headers:         map[string]string{"Authorization": "Bearer " + apiKey},
+				existingSession: nil,
+				expectedSession: nil,
+			}),
+			Entry("with an unknown key", apiKeySessionLoaderTableInput{
+				headers:         map[string]string{"X-API-Key": "unknown"},
+				existingSession: nil,
+				expectedSession: nil,
+			}),
+			Entry("with a key and an existing session", apiKeySessionLoaderTableInput{
+				headers:         map[string]string{"X-API-Key": apiKey},
+				existingSession: &sessionsapi.SessionState{User: "user"},
+				expectedSession: &sessionsapi.SessionState{User: "user"},
+			}),
+		)
+	})
+})
@@ -60,8 +60,9 @@ func loadBasicAuthSession(validator basic.Validator, sessionGroups []string, pre
 }
 
 // getBasicSession attempts to load a basic session from the request.
-// If the credentials in the request exist within the htpasswdMap,
-// a new session will be created.
+// If the credentials in the request are accepted by the validator,
+// a new session will be created with the groups of the user, or the
+// session groups when the validator doesn't look up groups.
 func getBasicSession(validator basic.Validator, sessionGroups []string, req *http.Request) (*sessionsapi.SessionState, error) {
 	auth := req.Header.Get("Authorization")
 	if auth == "" {
@@ -74,13 +75,13 @@ func getBasicSession(validator basic.Validator, sessionGroups []string, req *htt
 		return nil, err
 	}
 
-	if validator.Validate(user, password) {
-		logger.PrintAuthf(user, req, logger.AuthSuccess, "Authenticated via basic auth and HTpasswd File")
+	if groups, ok := basic.ValidateGroups(validator, user, password, sessionGroups); ok {
+		logger.PrintAuthf(user, req, logger.AuthSuccess, "Authenticated via basic auth")
 
-		return &sessionsapi.SessionState{User: user, Groups: sessionGroups}, nil
+		return &sessionsapi.SessionState{User: user, Groups: groups}, nil
 	}
 
-	logger.PrintAuthf(user, req, logger.AuthFailure, "Invalid authentication via basic auth: not in Htpasswd File")
+	logger.PrintAuthf(user, req, logger.AuthFailure, "Invalid authentication via basic auth")
 	return nil, nil
 }
 
@@ -7,6 +7,7 @@ import (
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/basic"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -27,6 +28,7 @@ var _ = Describe("Basic Auth Session Suite", func() {
 			authorizationHeader string
 			preferEmail         bool
 			sessionGroups       []string
+			userGroups          map[string][]string
 			existingSession     *sessionsapi.SessionState
 			expectedSession     *sessionsapi.SessionState
 		}
@@ -44,13 +46,19 @@ var _ = Describe("Basic Auth Session Suite", func() {
 
 				rw := httptest.NewRecorder()
 
-				validator := fakeBasicValidator{
+				var validator basic.Validator = fakeBasicValidator{
 					users: map[string]string{
 						adminUser: adminPassword,
 						user1:     user1Password,
 						user2:     user2Password,
 					},
 				}
+				if in.userGroups != nil {
+					validator = fakeGroupsValidator{
+						fakeBasicValidator: validator.(fakeBasicValidator),
+						groups:             in.userGroups,
+					}
+				}
 
 				// Create the handler with a next handler that will capture the session
 				// from the scope
@@ -118,6 +126,20 @@ var _ = Describe("Basic Auth Session Suite", func() {
 				existingSession:     nil,
 				expectedSession:     &sessionsapi.SessionState{User: "admin", Groups: []string{"a", "b"}},
 			}),
+			Entry("Basic with groups from the validator", basicAuthSessionLoaderTableInput{
+				authorizationHeader: "Basic YWRtaW46QWRtMW4xc3RyJHQwcg==",
+				sessionGroups:       []string{"a", "b"},
+				userGroups:          map[string][]string{adminUser: {"admins"}},
+				existingSession:     nil,
+				expectedSession:     &sessionsapi.SessionState{User: "admin", Groups: []string{"admins"}},
+			}),
+			Entry("Basic with groups from the validator (invalid password)", basicAuthSessionLoaderTableInput{
+				authorizationHeader: "Basic dXNlcjI6VXNFck9uM1A0NTU=",
+				sessionGroups:       []string{"a", "b"},
+				userGroups:          map[string][]string{user2: {"users"}},
+				existingSession:     nil,
+				expectedSession:     nil,
+			}),
 			Entry("Basic Base64(user1:<user1Password>) (with PreferEmailToUser)", basicAuthSessionLoaderTableInput{
 				authorizationHeader: "Basic dXNlcjE6VXNFck9uM1A0NTU=",
 				preferEmail:         true,
@@ -141,3 +163,15 @@ func (f fakeBasicValidator) Validate(user, password string) bool {
 	}
 	return false
 }
+
+type fakeGroupsValidator struct {
+	fakeBasicValidator
+	groups map[string][]string
+}
+
+func (f fakeGroupsValidator) ValidateGroups(user, password string) ([]string, bool) {
+	if !f.Validate(user, password) {
+		return nil, false
+	}
+	return f.groups[user], true
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/justinas/alice"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	// ClientCertificateClaim is the session claim holding the URL encoded PEM
+	// certificate of the client
+	ClientCertificateClaim = "client_certificate"
+
+	// ClientCertificateFingerprintClaim is the session claim holding the hex
+	// encoded SHA-256 fingerprint of the certificate of the client
+	ClientCertificateFingerprintClaim = "client_certificate_fingerprint"
+)
+
+// NewClientCertificateSessionLoader creates a new handler that loads sessions
+// from the verified certificates clients present to the TLS server.
+func NewClientCertificateSessionLoader(opts options.ClientCertificateAuthOptions) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return loadClientCertificateSession(opts, next)
+	}
+}
+
+// loadClientCertificateSession attempts to load a session from the verified
+// certificate of the client.
+// If the client didn't present a verified certificate, or the certificate
+// has no user, no session will be loaded and the request will be passed to
+// the next handler.
+// If a session was loaded by a previous handler, it will not be replaced.
+func loadClientCertificateSession(opts options.ClientCertificateAuthOptions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		scope := middlewareapi.GetRequestScope(req)
+		// If scope is nil, this will panic.
+		// A scope should always be injected before this handler is called.
+		if scope.Session != nil {
+			// The session was already loaded, pass to the next handler
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		scope.Session = getClientCertificateSession(opts, req)
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// getClientCertificateSession maps the verified certificate of the client to
+// a session.
+func getClientCertificateSession(opts options.ClientCertificateAuthOptions, req *http.Request) *sessionsapi.SessionState {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		// No verified certificate presented, so don't attempt to load a session
+		return nil
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+
+	users := clientCertificateValues(cert, opts.UserField)
+	if len(users) == 0 {
+		logger.PrintAuthf("", req, logger.AuthFailure, "Invalid authentication via client certificate: %q has no %s", cert.Subject, opts.UserField)
+		return nil
+	}
+
+	notAfter := cert.NotAfter
+	fingerprint := sha256.Sum256(cert.Raw)
+	session := &sessionsapi.SessionState{
+		User:      users[0],
+		Groups:    clientCertificateValues(cert, opts.GroupsField),
+		ExpiresOn: &notAfter,
+		AdditionalClaims: map[string]interface{}{
+			ClientCertificateClaim:            url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))),
+			ClientCertificateFingerprintClaim: hex.EncodeToString(fingerprint[:]),
+		},
+	}
+	if len(cert.EmailAddresses) > 0 {
+		session.Email = cert.EmailAddresses[0]
+	}
+
+	logger.PrintAuthf(session.User, req, logger.AuthSuccess, "Authenticated via client certificate")
+	return session
+}
+
+// clientCertificateValues returns the non-empty values of the field of the
+// certificate
+func clientCertificateValues(cert *x509.Certificate, field options.ClientCertificateField) []string {
+	var values []string
+	switch field {
+	case options.ClientCertificateCommonName:
+		values = []string{cert.Subject.CommonName}
+	case options.ClientCertificateEmail:
+		values = cert.EmailAddresses
+	case options.ClientCertificateURI:
+		for _, u := range cert.URIs {
+			values = append(values, u.String())
+		}
+	case options.ClientCertificateOrganizationalUnit:
+		values = cert.Subject.OrganizationalUnit
+	}
+
+	var result []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client Certificate Session Suite", func() {
+	Context("ClientCertificateSessionLoader", func() {
+		notAfter := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+		spiffeID, _ := url.Parse("spiffe://example.com/ns/jobs/sa/batch")
+
+		cert := &x509.Certificate{
+			Raw: []byte("certificate"),
+			Subject: pkix.Name{
+				CommonName:         "device-1",
+				OrganizationalUnit: []string{"devices", "sensors"},
+			},
+			EmailAddresses: []string{"device-1@example.com"},
+			URIs:           []*url.URL{spiffeID},
+			NotAfter:       notAfter,
+		}
+		fingerprint := sha256.Sum256(cert.Raw)
+		certificateClaims := map[string]interface{}{
+			ClientCertificateClaim:            "-----BEGIN+CERTIFICATE-----%0AY2VydGlmaWNhdGU%3D%0A-----END+CERTIFICATE-----%0A",
+			ClientCertificateFingerprintClaim: hex.EncodeToString(fingerprint[:]),
+		}
+
+		type clientCertificateSessionLoaderTableInput struct {
+			tls             *tls.ConnectionState
+			userField       options.ClientCertificateField
+			groupsField     options.ClientCertificateField
+			existingSession *sessionsapi.SessionState
+			expectedSession *sessionsapi.SessionState
+		}
+
+		DescribeTable("with a client certificate",
+			func(in clientCertificateSessionLoaderTableInput) {
+				opts := options.ClientCertificateAuthOptions{
+					UserField:   in.userField,
+					GroupsField: in.groupsField,
+				}
+				opts.EnsureDefaults()
+
+				scope := &middlewareapi.RequestScope{
+					Session: in.existingSession,
+				}
+
+				// Set up the request with the TLS connection state and a request scope
+				req := httptest.NewRequest("", "/", nil)
+				req.TLS = in.tls
+				req = middlewareapi.AddRequestScope(req, scope)
+
+				rw := httptest.NewRecorder()
+
+				// Create the handler with a next handler that will capture the session
+				// from the scope
+				var gotSession *sessionsapi.SessionState
+				handler := NewClientCertificateSessionLoader(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					gotSession = middlewareapi.GetRequestScope(r).Session
+				}))
+				handler.ServeHTTP(rw, req)
+
+				Expect(gotSession).To(Equal(in.expectedSession))
+			},
+			Entry("without TLS", clientCertificateSessionLoaderTableInput{
+				tls:             nil,
+				existingSession: nil,
+				expectedSession: nil,
+			}),
+			Entry("without a verified certificate", clientCertificateSessionLoaderTableInput{
+				tls:             &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+				existingSession: nil,
+				expectedSession: nil,
+			}),
+			Entry("with the default fields", clientCertificateSessionLoaderTableInput{
+				tls:             &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+				existingSession: nil,
+				expectedSession: &sessionsapi.SessionState{
+					User:             "device-1",
+					Email:            "device-1@example.com",
+					Groups:           []string{"devices", "sensors"},
+					ExpiresOn:        &notAfter,
+					AdditionalClaims: certificateClaims,
+				},
+			}),
+			Entry("with the email as the user", clientCertificateSessionLoaderTableInput{
+				tls:             &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+				userField:       options.ClientCertificateEmail,
+				existingSession: nil,
+				expectedSession: &sessionsapi.SessionState{
+					User:             "device-1@example.com",
+					Email:            "device-1@example.com",
+					Groups:           []string{"devices", "sensors"},
+					ExpiresOn:        &notAfter,
+					AdditionalClaims: certificateClaims,
+				},
+			}),
+			Entry("with SPIFFE IDs as the user and groups", clientCertificateSessionLoaderTableInput{
+				tls:             &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+				userField:       options.ClientCertificateURI,
+				groupsField:     options.ClientCertificateURI,
+				existingSession: nil,
+				expectedSession: &sessionsapi.SessionState{
+					User:             "spiffe://example.com/ns/jobs/sa/batch",
+					Email:            "device-1@example.com",
+					Groups:           []string{"spiffe://example.com/ns/jobs/sa/batch"},
+					ExpiresOn:        &notAfter,
+					AdditionalClaims: certificateClaims,
+				},
+			}),
+			Entry("without a value for the user field", clientCertificateSessionLoaderTableInput{
+				tls: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{
+					Raw:      []byte("certificate"),
+					Subject:  pkix.Name{OrganizationalUnit: []string{"devices"}},
+					NotAfter: notAfter,
+				}}}},
+				existingSession: nil,
+				expectedSession: nil,
+			}),
+			Entry("with an existing session", clientCertificateSessionLoaderTableInput{
+				tls:             &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+				existingSession: &sessionsapi.SessionState{User: "user"},
+				expectedSession: &sessionsapi.SessionState{User: "user"},
+			}),
+		)
+	})
+})
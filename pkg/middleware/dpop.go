@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+)
+
+const (
+	// dpopScheme is the authorization scheme of DPoP-bound access tokens
+	dpopScheme = "DPoP"
+
+	// dpopHeader is the request header that carries the DPoP proof
+	dpopHeader = "DPoP"
+
+	// dpopProofType is the typ header of DPoP proofs
+	dpopProofType = "dpop+jwt"
+
+	// dpopProofMaxAge is how far the iat of a DPoP proof may be from now
+	dpopProofMaxAge = 5 * time.Minute
+)
+
+// dpopSigningAlgorithms are the asymmetric algorithms accepted for DPoP proofs
+var dpopSigningAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// confirmation is the confirmation (cnf) claim of a token that binds the
+// token to a client certificate (RFC 8705) or a DPoP key (RFC 9449)
+type confirmation struct {
+	CertificateThumbprint string `json:"x5t#S256"`
+	KeyThumbprint         string `json:"jkt"`
+}
+
+// dpopProofClaims are the claims of a DPoP proof
+type dpopProofClaims struct {
+	HTTPMethod      string `json:"htm"`
+	HTTPURI         string `json:"htu"`
+	AccessTokenHash string `json:"ath"`
+	jwt.RegisteredClaims
+}
+
+// tokenConfirmation returns the confirmation claim of the JWT payload
+func tokenConfirmation(token string) (*confirmation, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		Confirmation confirmation `json:"cnf"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+	return &claims.Confirmation, nil
+}
+
+// verifyDPoPBinding checks that a DPoP-bound token (RFC 9449) is presented
+// with the DPoP scheme and a valid proof of possession of the bound key, and
+// that tokens presented with the DPoP scheme are bound to a key.
+func (j *jwtSessionLoader) verifyDPoPBinding(req *http.Request, token string, presentedWithDPoP bool) error {
+	cnf, err := tokenConfirmation(token)
+	if err != nil {
+		return fmt.Errorf("unable to check DPoP binding: %v", err)
+	}
+
+	if !presentedWithDPoP {
+		if cnf.KeyThumbprint != "" {
+			return errors.New("token is bound to a DPoP key but was not presented with the DPoP scheme")
+		}
+		return nil
+	}
+	if cnf.KeyThumbprint == "" {
+		return errors.New("token presented with the DPoP scheme is not bound to a DPoP key")
+	}
+
+	thumbprint, proof, err := verifyDPoPProof(req, token)
+	if err != nil {
+		return fmt.Errorf("invalid DPoP proof: %v", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(cnf.KeyThumbprint)) != 1 {
+		return errors.New("token is bound to a different DPoP key")
+	}
+
+	if j.replayTracker == nil {
+		return errors.New("DPoP proofs can't be accepted without replay detection")
+	}
+	// Proofs are recorded for longer than they are accepted for, so that a
+	// replay is detected for the whole time its iat is within the window
+	unused, err := j.replayTracker.MarkUsed(req.Context(), "dpop\x00"+thumbprint+"\x00"+proof.ID, 2*dpopProofMaxAge)
+	if err != nil {
+		return fmt.Errorf("unable to check DPoP proof replay: %v", err)
+	}
+	if !unused {
+		return errors.New("DPoP proof was already used")
+	}
+	return nil
+}
+
+// verifyDPoPProof verifies the DPoP proof of the request for the access token
+// and returns the thumbprint of the key that signed it
+func verifyDPoPProof(req *http.Request, token string) (string, *dpopProofClaims, error) {
+	proofs := req.Header.Values(dpopHeader)
+	if len(proofs) != 1 {
+		return "", nil, fmt.Errorf("expected one %s header, got %d", dpopHeader, len(proofs))
+	}
+
+	var key jose.JSONWebKey
+	claims := &dpopProofClaims{}
+	_, err := jwt.ParseWithClaims(proofs[0], claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Header["typ"] != dpopProofType {
+			return nil, fmt.Errorf("typ must be %q", dpopProofType)
+		}
+		if _, ok := t.Header["jwk"]; !ok {
+			return nil, errors.New("missing jwk")
+		}
+		jwk, err := json.Marshal(t.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		if err := key.UnmarshalJSON(jwk); err != nil {
+			return nil, fmt.Errorf("invalid jwk: %v", err)
+		}
+		if !key.Valid() || !key.IsPublic() {
+			return nil, errors.New("jwk must be a public key")
+		}
+		return key.Key, nil
+	}, jwt.WithValidMethods(dpopSigningAlgorithms), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return "", nil, err
+	}
+
+	if claims.ID == "" {
+		return "", nil, errors.New("missing jti")
+	}
+	if claims.IssuedAt == nil {
+		return "", nil, errors.New("missing iat")
+	}
+	if age := time.Since(claims.IssuedAt.Time); age > dpopProofMaxAge || age < -dpopProofMaxAge {
+		return "", nil, errors.New("iat is outside of the acceptable window")
+	}
+	if claims.HTTPMethod != req.Method {
+		return "", nil, fmt.Errorf("htm %q does not match the request method", claims.HTTPMethod)
+	}
+	if !matchesRequestURI(req, claims.HTTPURI) {
+		return "", nil, fmt.Errorf("htu %q does not match the request URI", claims.HTTPURI)
+	}
+	ath := sha256.Sum256([]byte(token))
+	if subtle.ConstantTimeCompare([]byte(claims.AccessTokenHash), []byte(base64.RawURLEncoding.EncodeToString(ath[:]))) != 1 {
+		return "", nil, errors.New("ath does not match the access token")
+	}
+
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", nil, err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), claims, nil
+}
+
+// matchesRequestURI compares the htu of a DPoP proof with the URI the client
+// sent the request to, ignoring the query and fragment
+func matchesRequestURI(req *http.Request, htu string) bool {
+	proofURI, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+
+	scheme := requestutil.GetRequestProto(req)
+	if scheme == "" {
+		scheme = "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+	}
+
+	return strings.EqualFold(proofURI.Scheme, scheme) &&
+		strings.EqualFold(proofURI.Host, requestutil.GetRequestHost(req)) &&
+		proofURI.Path == requestutil.GetRequestPath(req)
+}
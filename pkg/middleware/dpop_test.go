@@ -0,0 +1,279 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"regexp"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeReplayTracker struct {
+	used map[string]bool
+}
+
+func (f *fakeReplayTracker) MarkUsed(_ context.Context, id string, _ time.Duration) (bool, error) {
+	if f.used[id] {
+		return false, nil
+	}
+	f.used[id] = true
+	return true, nil
+}
+
+var _ = Describe("DPoP Suite", func() {
+	Context("getJWTSession with a DPoP-bound token", func() {
+		const requestURL = "https://app.example.com/api/items?page=2"
+
+		var j *jwtSessionLoader
+
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+		keyThumbprint := func(k *ecdsa.PrivateKey) string {
+			thumbprint, _ := (&jose.JSONWebKey{Key: k.Public()}).Thumbprint(crypto.SHA256)
+			return base64.RawURLEncoding.EncodeToString(thumbprint)
+		}
+
+		newToken := func(cnf map[string]string) string {
+			claims := map[string]interface{}{
+				"sub": "1234567890",
+				"aud": "https://test.myapp.com",
+				"iss": "https://issuer.example.com",
+			}
+			if cnf != nil {
+				claims["cnf"] = cnf
+			}
+			payload, _ := json.Marshal(claims)
+			return "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl"
+		}
+		boundToken := newToken(map[string]string{"jkt": keyThumbprint(key)})
+		unboundToken := newToken(nil)
+
+		type proofInput struct {
+			key    *ecdsa.PrivateKey
+			typ    string
+			jti    string
+			htm    string
+			htu    string
+			iat    time.Time
+			token  string
+			noKeys bool
+		}
+
+		newProof := func(in proofInput) string {
+			ath := sha256.Sum256([]byte(in.token))
+			t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+				"jti": in.jti,
+				"htm": in.htm,
+				"htu": in.htu,
+				"iat": in.iat.Unix(),
+				"ath": base64.RawURLEncoding.EncodeToString(ath[:]),
+			})
+			t.Header["typ"] = in.typ
+			if !in.noKeys {
+				t.Header["jwk"] = &jose.JSONWebKey{Key: in.key.Public()}
+			}
+			signed, _ := t.SignedString(in.key)
+			return signed
+		}
+
+		validProof := func() proofInput {
+			return proofInput{
+				key:   key,
+				typ:   "dpop+jwt",
+				jti:   "proof-id",
+				htm:   "GET",
+				htu:   "https://app.example.com/api/items",
+				iat:   time.Now(),
+				token: boundToken,
+			}
+		}
+
+		BeforeEach(func() {
+			verifier := oidc.NewVerifier(
+				"https://issuer.example.com",
+				noOpKeySet{},
+				&oidc.Config{
+					ClientID:        "https://test.myapp.com",
+					SkipExpiryCheck: true,
+				},
+			).Verify
+
+			j = &jwtSessionLoader{
+				jwtRegex: regexp.MustCompile(jwtRegexFormat),
+				sessionLoaders: []middlewareapi.TokenToSessionFunc{
+					middlewareapi.CreateTokenToSessionFunc(verifier),
+				},
+				replayTracker: &fakeReplayTracker{used: map[string]bool{}},
+			}
+		})
+
+		type dpopBindingTableInput struct {
+			scheme      string
+			token       string
+			proofs      []proofInput
+			expectedErr error
+		}
+
+		DescribeTable("with a DPoP proof",
+			func(in dpopBindingTableInput) {
+				req := httptest.NewRequest("GET", requestURL, nil)
+				req.Header.Set("Authorization", in.scheme+" "+in.token)
+				for _, proof := range in.proofs {
+					req.Header.Add("DPoP", newProof(proof))
+				}
+
+				session, err := j.getJwtSession(req)
+				if in.expectedErr != nil {
+					Expect(err).To(MatchError(in.expectedErr))
+					Expect(session).To(BeNil())
+				} else {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(session).ToNot(BeNil())
+				}
+			},
+			Entry("with a valid proof", dpopBindingTableInput{
+				scheme: "DPoP",
+				token:  boundToken,
+				proofs: []proofInput{validProof()},
+			}),
+			Entry("with a bound token presented as a bearer token", dpopBindingTableInput{
+				scheme:      "Bearer",
+				token:       boundToken,
+				expectedErr: errors.New("token is bound to a DPoP key but was not presented with the DPoP scheme"),
+			}),
+			Entry("with an unbound token presented as a bearer token", dpopBindingTableInput{
+				scheme: "Bearer",
+				token:  unboundToken,
+			}),
+			Entry("with an unbound token presented with the DPoP scheme", dpopBindingTableInput{
+				scheme:      "DPoP",
+				token:       unboundToken,
+				proofs:      []proofInput{validProof()},
+				expectedErr: errors.New("token presented with the DPoP scheme is not bound to a DPoP key"),
+			}),
+			Entry("without a proof", dpopBindingTableInput{
+				scheme:      "DPoP",
+				token:       boundToken,
+				expectedErr: errors.New("invalid DPoP proof: expected one DPoP header, got 0"),
+			}),
+			Entry("with two proofs", dpopBindingTableInput{
+				scheme:      "DPoP",
+				token:       boundToken,
+				proofs:      []proofInput{validProof(), validProof()},
+				expectedErr: errors.New("invalid DPoP proof: expected one DPoP header, got 2"),
+			}),
+			Entry("with the wrong typ", dpopBindingTableInput{
+				scheme: "DPoP",
+				token:  boundToken,
+				proofs: []proofInput{func() proofInput {
+					p := validProof()
+					p.typ = "JWT"
+					return p
+				}()},
+				expectedErr: errors.New("invalid DPoP proof: token is unverifiable: error while executing keyfunc: typ must be \"dpop+jwt\""),
+			}),
+			Entry("without a jwk", dpopBindingTableInput{
+				scheme: "DPoP",
+				token:  boundToken,
+				proofs: []proofInput{func() proofInput {
+					p := validProof()
+					p.noKeys = true
+					return p
+				}()},
+				expectedErr: errors.New("invalid DPoP proof: token is unverifiable: error while executing keyfunc: missing jwk"),
+			}),
+			Entry("with another HTTP method", dpopBindingTableInput{
+				scheme: "DPoP",
+				token:  boundToken,
+				proofs: []proofInput{func() proofInput {
+					p := validProof()
+					p.htm = "POST"
+					return p
+				}()},
+				expectedErr: errors.New("invalid DPoP proof: htm \"POST\" does not match the request method"),
+			}),
+			Entry("with another URI", dpopBindingTableInput{
+				scheme: "DPoP",
+				token:  boundToken,
+				proofs: []proofInput{func() proofInput {
+					p := validProof()
+					p.htu = "https://app.example.com/api/other"
+					return p
+				}()},
+				expectedErr: errors.New("invalid DPoP proof: htu \"https://app.example.com/api/other\" does not match the request URI"),
+			}),
+			Entry("with an old proof", dpopBindingTableInput{
+				scheme: "DPoP",
+				token:  boundToken,
+				proofs: []proofInput{func() proofInput {
+					p := validProof()
+					p.iat = time.Now().Add(-10 * time.Minute)
+					return p
+				}()},
+				expectedErr: errors.New("invalid DPoP proof: iat is outside of the acceptable window"),
+			}),
+			Entry("with a proof for another token", dpopBindingTableInput{
+				scheme: "DPoP",
+				token:  boundToken,
+				proofs: []proofInput{func() proofInput {
+					p := validProof()
+					p.token = unboundToken
+					return p
+				}()},
+				expectedErr: errors.New("invalid DPoP proof: ath does not match the access token"),
+			}),
+			Entry("with a proof signed by another key", dpopBindingTableInput{
+				scheme: "DPoP",
+				token:  boundToken,
+				proofs: []proofInput{func() proofInput {
+					p := validProof()
+					p.key = otherKey
+					return p
+				}()},
+				expectedErr: errors.New("token is bound to a different DPoP key"),
+			}),
+		)
+
+		It("rejects a replayed proof", func() {
+			proof := newProof(validProof())
+			for i, expectedErr := range []error{nil, errors.New("DPoP proof was already used")} {
+				req := httptest.NewRequest("GET", requestURL, nil)
+				req.Header.Set("Authorization", "DPoP "+boundToken)
+				req.Header.Set("DPoP", proof)
+
+				_, err := j.getJwtSession(req)
+				if expectedErr == nil {
+					Expect(err).ToNot(HaveOccurred(), "request %d", i)
+				} else {
+					Expect(err).To(MatchError(expectedErr), "request %d", i)
+				}
+			}
+		})
+
+		It("rejects proofs without replay detection", func() {
+			j.replayTracker = nil
+
+			req := httptest.NewRequest("GET", requestURL, nil)
+			req.Header.Set("Authorization", "DPoP "+boundToken)
+			req.Header.Set("DPoP", newProof(validProof()))
+
+			_, err := j.getJwtSession(req)
+			Expect(err).To(MatchError("DPoP proofs can't be accepted without replay detection"))
+		})
+	})
+})
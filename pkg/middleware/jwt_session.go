@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/justinas/alice"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
@@ -15,11 +19,17 @@ import (
 
 const jwtRegexFormat = `^ey[a-zA-Z0-9_-]*\.ey[a-zA-Z0-9_-]*\.[a-zA-Z0-9_-]+$`
 
-func NewJwtSessionLoader(sessionLoaders []middlewareapi.TokenToSessionFunc, bearerTokenLoginFallback bool) alice.Constructor {
+// NewJwtSessionLoader creates a new jwtSessionLoader. The opaqueTokenLoaders
+// load sessions for bearer tokens that are not JWTs, and are tried after the
+// sessionLoaders for JWTs. The replayTracker is used to detect replayed DPoP
+// proofs; without it, DPoP-bound tokens are rejected.
+func NewJwtSessionLoader(sessionLoaders, opaqueTokenLoaders []middlewareapi.TokenToSessionFunc, bearerTokenLoginFallback bool, replayTracker sessionsapi.ReplayTracker) alice.Constructor {
 	js := &jwtSessionLoader{
-		jwtRegex:        regexp.MustCompile(jwtRegexFormat),
-		sessionLoaders:  sessionLoaders,
-		denyInvalidJWTs: !bearerTokenLoginFallback,
+		jwtRegex:           regexp.MustCompile(jwtRegexFormat),
+		sessionLoaders:     sessionLoaders,
+		opaqueTokenLoaders: opaqueTokenLoaders,
+		denyInvalidJWTs:    !bearerTokenLoginFallback,
+		replayTracker:      replayTracker,
 	}
 	return js.loadSession
 }
@@ -27,9 +37,11 @@ func NewJwtSessionLoader(sessionLoaders []middlewareapi.TokenToSessionFunc, bear
 // jwtSessionLoader is responsible for loading sessions from JWTs in
 // Authorization headers.
 type jwtSessionLoader struct {
-	jwtRegex        *regexp.Regexp
-	sessionLoaders  []middlewareapi.TokenToSessionFunc
-	denyInvalidJWTs bool
+	jwtRegex           *regexp.Regexp
+	sessionLoaders     []middlewareapi.TokenToSessionFunc
+	opaqueTokenLoaders []middlewareapi.TokenToSessionFunc
+	denyInvalidJWTs    bool
+	replayTracker      sessionsapi.ReplayTracker
 }
 
 // loadSession attempts to load a session from a JWT stored in an Authorization
@@ -77,6 +89,13 @@ func (j *jwtSessionLoader) getJwtSession(req *http.Request) (*sessionsapi.Sessio
 	if err != nil {
 		return nil, err
 	}
+	presentedWithDPoP := strings.HasPrefix(auth, dpopScheme+" ")
+
+	// Only opaque tokens are introspected, so that JWTs the verifier rejects,
+	// for example for another audience, can't be accepted by introspection
+	if !j.jwtRegex.MatchString(token) {
+		return j.getOpaqueTokenSession(req, token, presentedWithDPoP)
+	}
 
 	// This leading error message only occurs if all session loaders fail
 	errs := []error{errors.New("unable to verify bearer token")}
@@ -86,12 +105,65 @@ func (j *jwtSessionLoader) getJwtSession(req *http.Request) (*sessionsapi.Sessio
 			errs = append(errs, err)
 			continue
 		}
+		if err := verifyCertificateBinding(req, token); err != nil {
+			return nil, err
+		}
+		if err := j.verifyDPoPBinding(req, token, presentedWithDPoP); err != nil {
+			return nil, err
+		}
 		return session, nil
 	}
 
 	return nil, k8serrors.NewAggregate(errs)
 }
 
+// getOpaqueTokenSession loads a session for a bearer token that is not a JWT.
+// The binding of sender-constrained tokens can't be checked without the token
+// claims, so opaque tokens are only accepted with the Bearer scheme.
+func (j *jwtSessionLoader) getOpaqueTokenSession(req *http.Request, token string, presentedWithDPoP bool) (*sessionsapi.SessionState, error) {
+	if presentedWithDPoP {
+		return nil, errors.New("opaque tokens can't be presented with the DPoP scheme")
+	}
+
+	// This leading error message only occurs if all session loaders fail
+	errs := []error{errors.New("unable to verify opaque bearer token")}
+	for _, loader := range j.opaqueTokenLoaders {
+		session, err := loader(req.Context(), token)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return session, nil
+	}
+
+	return nil, k8serrors.NewAggregate(errs)
+}
+
+// verifyCertificateBinding checks the cnf.x5t#S256 claim of a certificate-bound
+// token (RFC 8705) against the client certificate presented to the proxy.
+// The binding can only be checked when the proxy terminates TLS itself, so
+// bound tokens are rejected on requests without a TLS client certificate.
+// Tokens without the claim are not bound and always pass.
+func verifyCertificateBinding(req *http.Request, token string) error {
+	cnf, err := tokenConfirmation(token)
+	if err != nil {
+		return fmt.Errorf("unable to check certificate binding: %v", err)
+	}
+	if cnf.CertificateThumbprint == "" {
+		return nil
+	}
+
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return errors.New("token is bound to a client certificate but none was presented")
+	}
+	thumbprint := sha256.Sum256(req.TLS.PeerCertificates[0].Raw)
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(thumbprint[:])),
+		[]byte(cnf.CertificateThumbprint)) != 1 {
+		return errors.New("token is bound to a different client certificate")
+	}
+	return nil
+}
+
 // findTokenFromHeader finds a valid JWT token from the Authorization header of a given request.
 func (j *jwtSessionLoader) findTokenFromHeader(header string) (string, error) {
 	tokenType, token, err := splitAuthHeader(header)
@@ -99,8 +171,13 @@ func (j *jwtSessionLoader) findTokenFromHeader(header string) (string, error) {
 		return "", err
 	}
 
-	if tokenType == "Bearer" && j.jwtRegex.MatchString(token) {
-		// Found a JWT as a bearer token
+	if (tokenType == "Bearer" || tokenType == dpopScheme) && j.jwtRegex.MatchString(token) {
+		// Found a JWT as a bearer or DPoP-bound token
+		return token, nil
+	}
+
+	if tokenType == "Bearer" && len(j.opaqueTokenLoaders) > 0 {
+		// Opaque bearer tokens are accepted when they can be introspected
 		return token, nil
 	}
 
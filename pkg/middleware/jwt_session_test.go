@@ -4,7 +4,11 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -115,7 +119,7 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 				// Create the handler with a next handler that will capture the session
 				// from the scope
 				var gotSession *sessionsapi.SessionState
-				handler := NewJwtSessionLoader(sessionLoaders, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler := NewJwtSessionLoader(sessionLoaders, nil, true, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 					gotSession = middlewareapi.GetRequestScope(r).Session
 				}))
 				handler.ServeHTTP(rw, req)
@@ -185,7 +189,7 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 				// Create the handler with a next handler that will capture the session
 				// from the scope
 				var gotSession *sessionsapi.SessionState
-				handler := NewJwtSessionLoader(sessionLoaders, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler := NewJwtSessionLoader(sessionLoaders, nil, false, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 					gotSession = middlewareapi.GetRequestScope(r).Session
 				}))
 				handler.ServeHTTP(rw, req)
@@ -242,6 +246,79 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 				expectedStatus:      200,
 			}),
 		)
+
+		// otherIssuerToken is a JWT the verifier rejects, which must not be
+		// introspected
+		otherIssuerToken := "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9." +
+			base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1234567890","iss":"https://other.example.com"}`)) + ".c2lnbmF0dXJl"
+
+		DescribeTable("with an authorization header, opaque token loaders",
+			func(in jwtSessionLoaderTableInput) {
+				scope := &middlewareapi.RequestScope{
+					Session: in.existingSession,
+				}
+
+				// Set up the request with the authorization header and a request scope
+				req := httptest.NewRequest("", "/", nil)
+				req.Header.Set("Authorization", in.authorizationHeader)
+				req = middlewareapi.AddRequestScope(req, scope)
+
+				rw := httptest.NewRecorder()
+
+				sessionLoaders := []middlewareapi.TokenToSessionFunc{
+					middlewareapi.CreateTokenToSessionFunc(verifier),
+				}
+				opaqueTokenLoaders := []middlewareapi.TokenToSessionFunc{
+					func(_ context.Context, token string) (*sessionsapi.SessionState, error) {
+						if token == "opaque-token" || token == otherIssuerToken {
+							return &sessionsapi.SessionState{User: "introspected", AccessToken: token}, nil
+						}
+						return nil, errors.New("token is not active")
+					},
+				}
+
+				// Create the handler with a next handler that will capture the session
+				// from the scope
+				var gotSession *sessionsapi.SessionState
+				handler := NewJwtSessionLoader(sessionLoaders, opaqueTokenLoaders, false, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					gotSession = middlewareapi.GetRequestScope(r).Session
+				}))
+				handler.ServeHTTP(rw, req)
+
+				Expect(gotSession).To(Equal(in.expectedSession))
+				Expect(rw.Code).To(Equal(in.expectedStatus))
+			},
+			Entry("Bearer opaque-token", jwtSessionLoaderTableInput{
+				authorizationHeader: "Bearer opaque-token",
+				existingSession:     nil,
+				expectedSession:     &sessionsapi.SessionState{User: "introspected", AccessToken: "opaque-token"},
+				expectedStatus:      200,
+			}),
+			Entry("Bearer inactive-token", jwtSessionLoaderTableInput{
+				authorizationHeader: "Bearer inactive-token",
+				existingSession:     nil,
+				expectedSession:     nil,
+				expectedStatus:      403,
+			}),
+			Entry("DPoP opaque-token", jwtSessionLoaderTableInput{
+				authorizationHeader: "DPoP opaque-token",
+				existingSession:     nil,
+				expectedSession:     nil,
+				expectedStatus:      403,
+			}),
+			Entry("Bearer <verifiedToken>", jwtSessionLoaderTableInput{
+				authorizationHeader: fmt.Sprintf("Bearer %s", verifiedToken),
+				existingSession:     nil,
+				expectedSession:     verifiedSession,
+				expectedStatus:      200,
+			}),
+			Entry("Bearer <otherIssuerToken> (not introspected)", jwtSessionLoaderTableInput{
+				authorizationHeader: fmt.Sprintf("Bearer %s", otherIssuerToken),
+				existingSession:     nil,
+				expectedSession:     nil,
+				expectedStatus:      403,
+			}),
+		)
 	})
 
 	Context("getJWTSession", func() {
@@ -329,6 +406,92 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 		)
 	})
 
+	Context("getJWTSession with a certificate-bound token", func() {
+		var j *jwtSessionLoader
+
+		clientCert := &x509.Certificate{Raw: []byte("client certificate")}
+		otherCert := &x509.Certificate{Raw: []byte("other certificate")}
+		thumbprint := sha256.Sum256(clientCert.Raw)
+
+		payload, _ := json.Marshal(map[string]interface{}{
+			"sub": "1234567890",
+			"aud": "https://test.myapp.com",
+			"iss": "https://issuer.example.com",
+			"cnf": map[string]string{
+				"x5t#S256": base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+			},
+		})
+		boundToken := "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl"
+
+		BeforeEach(func() {
+			verifier := oidc.NewVerifier(
+				"https://issuer.example.com",
+				noOpKeySet{},
+				&oidc.Config{
+					ClientID:        "https://test.myapp.com",
+					SkipExpiryCheck: true,
+				},
+			).Verify
+
+			j = &jwtSessionLoader{
+				jwtRegex: regexp.MustCompile(jwtRegexFormat),
+				sessionLoaders: []middlewareapi.TokenToSessionFunc{
+					middlewareapi.CreateTokenToSessionFunc(verifier),
+				},
+			}
+		})
+
+		type certificateBindingTableInput struct {
+			tls         *tls.ConnectionState
+			token       string
+			expectedErr error
+		}
+
+		DescribeTable("with a TLS connection",
+			func(in certificateBindingTableInput) {
+				req := httptest.NewRequest("", "/", nil)
+				req.Header.Set("Authorization", "Bearer "+in.token)
+				req.TLS = in.tls
+
+				session, err := j.getJwtSession(req)
+				if in.expectedErr != nil {
+					Expect(err).To(MatchError(in.expectedErr))
+					Expect(session).To(BeNil())
+				} else {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(session).ToNot(BeNil())
+				}
+			},
+			Entry("without TLS termination", certificateBindingTableInput{
+				tls:         nil,
+				token:       boundToken,
+				expectedErr: errors.New("token is bound to a client certificate but none was presented"),
+			}),
+			Entry("with a token that is not bound without TLS termination", certificateBindingTableInput{
+				tls:   nil,
+				token: verifiedToken,
+			}),
+			Entry("with the bound client certificate", certificateBindingTableInput{
+				tls:   &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}},
+				token: boundToken,
+			}),
+			Entry("without a client certificate", certificateBindingTableInput{
+				tls:         &tls.ConnectionState{},
+				token:       boundToken,
+				expectedErr: errors.New("token is bound to a client certificate but none was presented"),
+			}),
+			Entry("with another client certificate", certificateBindingTableInput{
+				tls:         &tls.ConnectionState{PeerCertificates: []*x509.Certificate{otherCert}},
+				token:       boundToken,
+				expectedErr: errors.New("token is bound to a different client certificate"),
+			}),
+			Entry("with a token that is not bound", certificateBindingTableInput{
+				tls:   &tls.ConnectionState{},
+				token: verifiedToken,
+			}),
+		)
+	})
+
 	Context("findTokenFromHeader", func() {
 		var j *jwtSessionLoader
 
@@ -379,6 +542,11 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 				expectedErr:   nil,
 				expectedToken: validTokenWithSpace,
 			}),
+			Entry("DPoP <valid-token>", findBearerTokenFromHeaderTableInput{
+				header:        fmt.Sprintf("DPoP %s", validToken),
+				expectedErr:   nil,
+				expectedToken: validToken,
+			}),
 			Entry("Basic invalid-base64", findBearerTokenFromHeaderTableInput{
 				header:        "Basic invalid-base64",
 				expectedErr:   errors.New("invalid basic auth token: illegal base64 data at input byte 7"),
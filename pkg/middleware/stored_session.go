@@ -29,11 +29,16 @@ const (
 	// How long to wait after failing to obtain the lock before trying again.
 	// TODO: This should probably be configurable by the end user.
 	sessionRefreshRetryPeriod = 10 * time.Millisecond
+
+	// How long to wait after a re-validation failed to reach the provider
+	// before re-validating the session again, unless the re-validation
+	// interval is shorter.
+	revalidateRetryBackoff = time.Minute
 )
 
 // StoredSessionLoaderOptions contains all of the requirements to construct
 // a stored session loader.
-// All options must be provided.
+// All options must be provided, except for the optional re-validation.
 type StoredSessionLoaderOptions struct {
 	// Session storage backend
 	SessionStore sessionsapi.SessionStore
@@ -48,6 +53,14 @@ type StoredSessionLoaderOptions struct {
 	// If the sesssion is older than `RefreshPeriod` but the provider doesn't
 	// refresh it, we must re-validate using this validation.
 	ValidateSession func(context.Context, *sessionsapi.SessionState) bool
+
+	// How often the authorization of the session should be re-validated,
+	// independently of the refresh. Zero disables re-validation.
+	RevalidateInterval func(*sessionsapi.SessionState) time.Duration
+
+	// Provider based re-validation of the authorization of the session.
+	// Sessions that are no longer authorized are cleared.
+	RevalidateSession func(context.Context, *sessionsapi.SessionState) (bool, error)
 }
 
 // NewStoredSessionLoader creates a new storedSessionLoader which loads
@@ -60,6 +73,9 @@ func NewStoredSessionLoader(opts *StoredSessionLoaderOptions) alice.Constructor
 		refreshPeriod:    opts.RefreshPeriod,
 		sessionRefresher: opts.RefreshSession,
 		sessionValidator: opts.ValidateSession,
+
+		revalidateInterval: opts.RevalidateInterval,
+		sessionRevalidator: opts.RevalidateSession,
 	}
 	return ss.loadSession
 }
@@ -71,6 +87,9 @@ type storedSessionLoader struct {
 	refreshPeriod    time.Duration
 	sessionRefresher func(context.Context, *sessionsapi.SessionState) (bool, error)
 	sessionValidator func(context.Context, *sessionsapi.SessionState) bool
+
+	revalidateInterval func(*sessionsapi.SessionState) time.Duration
+	sessionRevalidator func(context.Context, *sessionsapi.SessionState) (bool, error)
 }
 
 // loadSession attempts to load a session as identified by the request cookies.
@@ -118,6 +137,11 @@ func (s *storedSessionLoader) getValidatedSession(rw http.ResponseWriter, req *h
 		return nil, fmt.Errorf("error refreshing access token for session (%s): %v", session, err)
 	}
 
+	err = s.revalidateSessionIfNeeded(rw, req, session)
+	if err != nil {
+		return nil, fmt.Errorf("error revalidating session (%s): %v", session, err)
+	}
+
 	return session, nil
 }
 
@@ -130,6 +154,36 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 		return nil
 	}
 
+	// The rest of this function is carried out under lock, but we must release it
+	// wherever we exit from this function.
+	release, err := s.lockAndReloadSession(req, session)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if !needsRefresh(s.refreshPeriod, session) {
+		// The session must have already been refreshed while we were waiting to
+		// obtain the lock.
+		return nil
+	}
+
+	// We are holding the lock and the session needs a refresh
+	logger.Printf("Refreshing session - User: %s; SessionAge: %s", session.User, session.Age())
+	if err := s.refreshSession(rw, req, session); err != nil {
+		// If a preemptive refresh fails, we still keep the session
+		// if validateSession succeeds.
+		logger.Errorf("Unable to refresh session: %v", err)
+	}
+
+	// Validate all sessions after any Redeem/Refresh operation (fail or success)
+	return s.validateSession(req.Context(), session)
+}
+
+// lockAndReloadSession obtains the lock of the session, then reloads the
+// session in case it was changed underneath us while we were waiting.
+// The returned function releases the lock.
+func (s *storedSessionLoader) lockAndReloadSession(req *http.Request, session *sessionsapi.SessionState) (func(), error) {
 	var lockObtained bool
 	ctx, cancel := context.WithTimeout(context.Background(), sessionRefreshObtainTimeout)
 	defer cancel()
@@ -137,11 +191,11 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 	for !lockObtained {
 		select {
 		case <-ctx.Done():
-			return errors.New("timeout obtaining session lock")
+			return nil, errors.New("timeout obtaining session lock")
 		default:
 			err := session.ObtainLock(req.Context(), sessionRefreshLockDuration)
 			if err != nil && !errors.Is(err, sessionsapi.ErrLockNotObtained) {
-				return fmt.Errorf("error occurred while trying to obtain lock: %v", err)
+				return nil, fmt.Errorf("error occurred while trying to obtain lock: %v", err)
 			} else if errors.Is(err, sessionsapi.ErrLockNotObtained) {
 				time.Sleep(sessionRefreshRetryPeriod)
 				continue
@@ -151,24 +205,21 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 		}
 	}
 
-	// The rest of this function is carried out under lock, but we must release it
-	// wherever we exit from this function.
-	defer func() {
-		if session == nil {
-			return
-		}
+	release := func() {
 		if err := session.ReleaseLock(req.Context()); err != nil {
 			logger.Errorf("unable to release lock: %v", err)
 		}
-	}()
+	}
 
 	// Reload the session in case it was changed underneath us.
 	freshSession, err := s.store.Load(req)
 	if err != nil {
-		return fmt.Errorf("could not load session: %v", err)
+		release()
+		return nil, fmt.Errorf("could not load session: %v", err)
 	}
 	if freshSession == nil {
-		return errors.New("session no longer exists, it may have been removed by another request")
+		release()
+		return nil, errors.New("session no longer exists, it may have been removed by another request")
 	}
 	// Restore the state of the fresh session into the original pointer.
 	// This is important so that changes are passed up the to the parent scope.
@@ -179,22 +230,7 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 	// Loading from the session store creates a new lock in the session.
 	session.Lock = lock
 
-	if !needsRefresh(s.refreshPeriod, session) {
-		// The session must have already been refreshed while we were waiting to
-		// obtain the lock.
-		return nil
-	}
-
-	// We are holding the lock and the session needs a refresh
-	logger.Printf("Refreshing session - User: %s; SessionAge: %s", session.User, session.Age())
-	if err := s.refreshSession(rw, req, session); err != nil {
-		// If a preemptive refresh fails, we still keep the session
-		// if validateSession succeeds.
-		logger.Errorf("Unable to refresh session: %v", err)
-	}
-
-	// Validate all sessions after any Redeem/Refresh operation (fail or success)
-	return s.validateSession(req.Context(), session)
+	return release, nil
 }
 
 // needsRefresh determines whether we should attempt to refresh a session or not.
@@ -252,3 +288,71 @@ func (s *storedSessionLoader) validateSession(ctx context.Context, session *sess
 
 	return nil
 }
+
+// revalidateSessionIfNeeded re-validates the authorization of the session with
+// the provider if it was last validated longer ago than the re-validation
+// interval, and saves the updated session.
+// When the provider can't be reached, the session is kept and re-validated
+// again after the retry backoff.
+// An error implies the session is no longer authorized.
+func (s *storedSessionLoader) revalidateSessionIfNeeded(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
+	if s.sessionRevalidator == nil || !s.needsRevalidation(session) {
+		// Re-validation is disabled or the session was validated recently
+		return nil
+	}
+
+	release, err := s.lockAndReloadSession(req, session)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if !s.needsRevalidation(session) {
+		// The session must have already been re-validated while we were
+		// waiting to obtain the lock.
+		return nil
+	}
+
+	logger.Printf("Revalidating session - User: %s; ValidatedAge: %s", session.User, session.ValidatedAge())
+	authorized, err := s.sessionRevalidator(req.Context(), session)
+	if err != nil {
+		// As with a failed refresh, an error reaching the provider doesn't
+		// mean the session is no longer authorized, so it is kept and
+		// re-validated again after the retry backoff.
+		logger.Errorf("Unable to revalidate session: %v", err)
+		session.RevalidationFailedNow()
+		if err := s.store.Save(rw, req, session); err != nil {
+			logger.PrintAuthf(session.Email, req, logger.AuthError, "error saving session: %v", err)
+		}
+		return nil
+	}
+	if !authorized {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session is no longer authorized")
+		return errors.New("session is no longer authorized")
+	}
+
+	session.ValidatedAtNow()
+	session.RevalidationFailedAt = nil
+	if err := s.store.Save(rw, req, session); err != nil {
+		logger.PrintAuthf(session.Email, req, logger.AuthError, "error saving session: %v", err)
+		return fmt.Errorf("error saving session: %v", err)
+	}
+	return nil
+}
+
+// needsRevalidation determines whether the authorization of the session
+// should be re-validated. After a failed re-validation, it is retried after
+// the retry backoff or the interval, whichever is shorter.
+func (s *storedSessionLoader) needsRevalidation(session *sessionsapi.SessionState) bool {
+	if s.revalidateInterval == nil {
+		return false
+	}
+	interval := s.revalidateInterval(session)
+	if interval <= time.Duration(0) || session.ValidatedAge() <= interval {
+		return false
+	}
+	if session.RevalidationFailedAt == nil {
+		return true
+	}
+	return session.RevalidationFailedAge() > min(interval, revalidateRetryBackoff)
+}
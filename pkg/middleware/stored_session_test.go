@@ -724,6 +724,199 @@ var _ = Describe("Stored Session Suite", func() {
 		)
 	})
 
+	Context("revalidateSessionIfNeeded", func() {
+		type revalidateSessionIfNeededTableInput struct {
+			revalidateInterval         time.Duration
+			validatedAt                *time.Time
+			concurrentRevalidation     bool
+			authorized                 bool
+			revalidateErr              error
+			expectedErr                error
+			expectRevalidated          bool
+			expectSaved                bool
+			expectedValidatedAtUpdated bool
+		}
+
+		validatedPast := time.Now().Add(-10 * time.Minute)
+		validatedRecently := time.Now().Add(-1 * time.Minute)
+
+		DescribeTable("with a session",
+			func(in revalidateSessionIfNeededTableInput) {
+				revalidated := false
+				saved := false
+
+				created := time.Now().Add(-time.Hour)
+				session := &sessionsapi.SessionState{
+					Email:       "user@example.com",
+					CreatedAt:   &created,
+					ValidatedAt: in.validatedAt,
+					Lock:        &testLock{},
+				}
+				storedSession := &sessionsapi.SessionState{}
+				*storedSession = *session
+				if in.concurrentRevalidation {
+					// Update the session that Load returns.
+					// This simulates a concurrent re-validation in the background.
+					validatedNow := time.Now()
+					storedSession.ValidatedAt = &validatedNow
+				}
+				store := &fakeSessionStore{
+					LoadFunc: func(req *http.Request) (*sessionsapi.SessionState, error) {
+						// Loading the session from the provider creates a new lock
+						storedSession.Lock = &testLock{}
+						return storedSession, nil
+					},
+					SaveFunc: func(_ http.ResponseWriter, _ *http.Request, s *sessionsapi.SessionState) error {
+						saved = true
+						return nil
+					},
+				}
+
+				s := &storedSessionLoader{
+					store: store,
+					revalidateInterval: func(_ *sessionsapi.SessionState) time.Duration {
+						return in.revalidateInterval
+					},
+					sessionRevalidator: func(_ context.Context, _ *sessionsapi.SessionState) (bool, error) {
+						revalidated = true
+						return in.authorized, in.revalidateErr
+					},
+				}
+
+				req := httptest.NewRequest("", "/", nil)
+				req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+				err := s.revalidateSessionIfNeeded(nil, req, session)
+				if in.expectedErr != nil {
+					Expect(err).To(MatchError(in.expectedErr))
+				} else {
+					Expect(err).ToNot(HaveOccurred())
+				}
+				Expect(revalidated).To(Equal(in.expectRevalidated))
+				Expect(saved).To(Equal(in.expectSaved))
+				if in.expectedValidatedAtUpdated {
+					Expect(session.ValidatedAt).ToNot(BeNil())
+					Expect(*session.ValidatedAt).To(BeTemporally("~", time.Now(), time.Second))
+				}
+				if lock, ok := session.Lock.(*testLock); ok {
+					Expect(lock.locked).To(BeFalse(), "Expected lock should always be released")
+				}
+			},
+			Entry("when the interval is 0", revalidateSessionIfNeededTableInput{
+				revalidateInterval: 0,
+				validatedAt:        &validatedPast,
+				expectRevalidated:  false,
+			}),
+			Entry("when the session was validated recently", revalidateSessionIfNeededTableInput{
+				revalidateInterval: 5 * time.Minute,
+				validatedAt:        &validatedRecently,
+				expectRevalidated:  false,
+			}),
+			Entry("when the session was never validated and is older than the interval", revalidateSessionIfNeededTableInput{
+				revalidateInterval:         5 * time.Minute,
+				authorized:                 true,
+				expectRevalidated:          true,
+				expectSaved:                true,
+				expectedValidatedAtUpdated: true,
+			}),
+			Entry("when the session is due and still authorized", revalidateSessionIfNeededTableInput{
+				revalidateInterval:         5 * time.Minute,
+				validatedAt:                &validatedPast,
+				authorized:                 true,
+				expectRevalidated:          true,
+				expectSaved:                true,
+				expectedValidatedAtUpdated: true,
+			}),
+			Entry("when the session was re-validated concurrently", revalidateSessionIfNeededTableInput{
+				revalidateInterval:     5 * time.Minute,
+				validatedAt:            &validatedPast,
+				concurrentRevalidation: true,
+				expectRevalidated:      false,
+			}),
+			Entry("when the session is due and no longer authorized", revalidateSessionIfNeededTableInput{
+				revalidateInterval: 5 * time.Minute,
+				validatedAt:        &validatedPast,
+				authorized:         false,
+				expectedErr:        errors.New("session is no longer authorized"),
+				expectRevalidated:  true,
+			}),
+			Entry("when the re-validation returns an error", revalidateSessionIfNeededTableInput{
+				revalidateInterval: 5 * time.Minute,
+				validatedAt:        &validatedPast,
+				revalidateErr:      errors.New("provider unavailable"),
+				expectedErr:        nil,
+				expectRevalidated:  true,
+				expectSaved:        true,
+			}),
+		)
+
+		It("backs off before re-validating again after an error", func() {
+			validatedAt := validatedPast
+			session := &sessionsapi.SessionState{ValidatedAt: &validatedAt}
+			revalidations := 0
+			s := &storedSessionLoader{
+				store: &fakeSessionStore{
+					LoadFunc: func(req *http.Request) (*sessionsapi.SessionState, error) {
+						return session, nil
+					},
+				},
+				revalidateInterval: func(_ *sessionsapi.SessionState) time.Duration {
+					return 5 * time.Minute
+				},
+				sessionRevalidator: func(_ context.Context, _ *sessionsapi.SessionState) (bool, error) {
+					revalidations++
+					return false, errors.New("provider unavailable")
+				},
+			}
+
+			req := httptest.NewRequest("", "/", nil)
+			Expect(s.revalidateSessionIfNeeded(nil, req, session)).To(Succeed())
+			Expect(s.revalidateSessionIfNeeded(nil, req, session)).To(Succeed())
+			Expect(revalidations).To(Equal(1))
+			Expect(session.ValidatedAt).To(Equal(&validatedAt))
+			Expect(session.RevalidationFailedAt).ToNot(BeNil())
+
+			// The re-validation is retried once the backoff has passed
+			failedAt := time.Now().Add(-revalidateRetryBackoff - time.Second)
+			session.RevalidationFailedAt = &failedAt
+			Expect(s.revalidateSessionIfNeeded(nil, req, session)).To(Succeed())
+			Expect(revalidations).To(Equal(2))
+		})
+
+		It("clears the session when the provider rejects the revoked access token", func() {
+			validatedAt := validatedPast
+			failedAt := time.Now().Add(-revalidateRetryBackoff - time.Second)
+			session := &sessionsapi.SessionState{ValidatedAt: &validatedAt, RevalidationFailedAt: &failedAt}
+			s := &storedSessionLoader{
+				store: &fakeSessionStore{
+					LoadFunc: func(req *http.Request) (*sessionsapi.SessionState, error) {
+						return session, nil
+					},
+				},
+				revalidateInterval: func(_ *sessionsapi.SessionState) time.Duration {
+					return 5 * time.Minute
+				},
+				sessionRevalidator: func(_ context.Context, _ *sessionsapi.SessionState) (bool, error) {
+					// Providers report a 401 or 403 of their API as no longer authorized
+					return false, nil
+				},
+			}
+
+			req := httptest.NewRequest("", "/", nil)
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+			Expect(s.revalidateSessionIfNeeded(nil, req, session)).To(MatchError("session is no longer authorized"))
+		})
+
+		It("does not re-validate without a revalidator", func() {
+			s := &storedSessionLoader{
+				revalidateInterval: func(_ *sessionsapi.SessionState) time.Duration {
+					return time.Minute
+				},
+			}
+			req := httptest.NewRequest("", "/", nil)
+			Expect(s.revalidateSessionIfNeeded(nil, req, &sessionsapi.SessionState{})).To(Succeed())
+		})
+	})
+
 	Context("validateSession", func() {
 		var s *storedSessionLoader
 
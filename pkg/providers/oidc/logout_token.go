@@ -0,0 +1,51 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// BackchannelLogoutEvent is the event a logout token must contain to be
+// accepted as an OIDC back-channel logout request
+const BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutToken holds the claims of a verified back-channel logout token that
+// identify the sessions to revoke
+type LogoutToken struct {
+	Subject   string
+	SessionID string
+}
+
+// VerifyLogoutToken verifies an OIDC back-channel logout token with the
+// provider's IDTokenVerifier and validates the logout specific claims.
+func VerifyLogoutToken(ctx context.Context, verifier IDTokenVerifier, rawLogoutToken string) (*LogoutToken, error) {
+	token, err := verifier.Verify(ctx, rawLogoutToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		SessionID string                     `json:"sid"`
+		Events    map[string]json.RawMessage `json:"events"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse logout token claims: %v", err)
+	}
+
+	if _, ok := claims.Events[BackchannelLogoutEvent]; !ok {
+		return nil, errors.New("logout token does not contain the back-channel logout event")
+	}
+	if token.Nonce != "" {
+		return nil, errors.New("logout token must not contain a nonce")
+	}
+	if token.Subject == "" && claims.SessionID == "" {
+		return nil, errors.New("logout token must contain a sub or sid claim")
+	}
+
+	return &LogoutToken{
+		Subject:   token.Subject,
+		SessionID: claims.SessionID,
+	}, nil
+}
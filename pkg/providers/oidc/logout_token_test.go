@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VerifyLogoutToken", func() {
+	type verifyLogoutTokenTableInput struct {
+		claims        map[string]interface{}
+		expectedToken *LogoutToken
+		expectedError string
+	}
+
+	backchannelLogoutEvents := map[string]interface{}{
+		BackchannelLogoutEvent: map[string]interface{}{},
+	}
+
+	DescribeTable("should validate the logout token claims",
+		func(in verifyLogoutTokenTableInput) {
+			in.claims["iss"] = "https://foo"
+			in.claims["aud"] = "1226737"
+			rawToken, err := json.Marshal(in.claims)
+			Expect(err).ToNot(HaveOccurred())
+			token, err := createToken(rawToken)
+			Expect(err).ToNot(HaveOccurred())
+
+			verifier := NewVerifier(oidc.NewVerifier("https://foo", &testVerifier{jwk: token.PublicKey}, &oidc.Config{
+				ClientID:        "1226737",
+				SkipExpiryCheck: true,
+			}), IDTokenVerificationOptions{
+				AudienceClaims: []string{"aud"},
+				ClientID:       "1226737",
+			})
+
+			logoutToken, err := VerifyLogoutToken(context.Background(), verifier, token.Token)
+			if in.expectedError != "" {
+				Expect(err).To(MatchError(in.expectedError))
+				Expect(logoutToken).To(BeNil())
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logoutToken).To(Equal(in.expectedToken))
+		},
+		Entry("with a sid and sub", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"sub":    "user",
+				"sid":    "session",
+				"events": backchannelLogoutEvents,
+			},
+			expectedToken: &LogoutToken{Subject: "user", SessionID: "session"},
+		}),
+		Entry("with only a sid", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"sid":    "session",
+				"events": backchannelLogoutEvents,
+			},
+			expectedToken: &LogoutToken{SessionID: "session"},
+		}),
+		Entry("without the back-channel logout event", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"sub": "user",
+			},
+			expectedError: "logout token does not contain the back-channel logout event",
+		}),
+		Entry("with a nonce", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"sub":    "user",
+				"nonce":  "nonce",
+				"events": backchannelLogoutEvents,
+			},
+			expectedError: "logout token must not contain a nonce",
+		}),
+		Entry("without a sid or sub", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"events": backchannelLogoutEvents,
+			},
+			expectedError: "logout token must contain a sub or sid claim",
+		}),
+	)
+})
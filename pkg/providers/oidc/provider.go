@@ -11,22 +11,41 @@ import (
 
 // providerJSON represents the information we need from an OIDC discovery
 type providerJSON struct {
-	Issuer               string   `json:"issuer"`
-	AuthURL              string   `json:"authorization_endpoint"`
-	TokenURL             string   `json:"token_endpoint"`
-	JWKsURL              string   `json:"jwks_uri"`
-	UserInfoURL          string   `json:"userinfo_endpoint"`
-	CodeChallengeAlgs    []string `json:"code_challenge_methods_supported"`
-	SupportedSigningAlgs []string `json:"id_token_signing_alg_values_supported"`
+	Issuer               string              `json:"issuer"`
+	AuthURL              string              `json:"authorization_endpoint"`
+	TokenURL             string              `json:"token_endpoint"`
+	JWKsURL              string              `json:"jwks_uri"`
+	UserInfoURL          string              `json:"userinfo_endpoint"`
+	EndSessionURL        string              `json:"end_session_endpoint"`
+	DeviceAuthURL        string              `json:"device_authorization_endpoint"`
+	PARURL               string              `json:"pushed_authorization_request_endpoint"`
+	IntrospectionURL     string              `json:"introspection_endpoint"`
+	CodeChallengeAlgs    []string            `json:"code_challenge_methods_supported"`
+	SupportedSigningAlgs []string            `json:"id_token_signing_alg_values_supported"`
+	MTLSEndpointAliases  mtlsEndpointAliases `json:"mtls_endpoint_aliases"`
+}
+
+// mtlsEndpointAliases represents the endpoints to use with mutual TLS
+// (RFC 8705) instead of the regular discovered endpoints
+type mtlsEndpointAliases struct {
+	TokenURL         string `json:"token_endpoint,omitempty"`
+	UserInfoURL      string `json:"userinfo_endpoint,omitempty"`
+	DeviceAuthURL    string `json:"device_authorization_endpoint,omitempty"`
+	PARURL           string `json:"pushed_authorization_request_endpoint,omitempty"`
+	IntrospectionURL string `json:"introspection_endpoint,omitempty"`
 }
 
 // Endpoints represents the endpoints discovered as part of the OIDC discovery process
 // that will be used by the authentication providers.
 type Endpoints struct {
-	AuthURL     string
-	TokenURL    string
-	JWKsURL     string
-	UserInfoURL string
+	AuthURL          string
+	TokenURL         string
+	JWKsURL          string
+	UserInfoURL      string
+	EndSessionURL    string
+	DeviceAuthURL    string
+	PARURL           string
+	IntrospectionURL string
 }
 
 // PKCE holds information relevant to the PKCE (code challenge) support of the
@@ -39,6 +58,7 @@ type PKCE struct {
 // used OIDC discovery to retrieve the information.
 type DiscoveryProvider interface {
 	Endpoints() Endpoints
+	MTLSEndpoints() Endpoints
 	PKCE() PKCE
 	SupportedSigningAlgs() []string
 }
@@ -69,6 +89,15 @@ func NewProvider(ctx context.Context, issuerURL string, skipIssuerVerification b
 		tokenURL:             p.TokenURL,
 		jwksURL:              p.JWKsURL,
 		userInfoURL:          p.UserInfoURL,
+		endSessionURL:        p.EndSessionURL,
+		deviceAuthURL:        p.DeviceAuthURL,
+		parURL:               p.PARURL,
+		introspectionURL:     p.IntrospectionURL,
+		mtlsTokenURL:         p.MTLSEndpointAliases.TokenURL,
+		mtlsUserInfoURL:      p.MTLSEndpointAliases.UserInfoURL,
+		mtlsDeviceAuthURL:    p.MTLSEndpointAliases.DeviceAuthURL,
+		mtlsPARURL:           p.MTLSEndpointAliases.PARURL,
+		mtlsIntrospectionURL: p.MTLSEndpointAliases.IntrospectionURL,
 		codeChallengeAlgs:    p.CodeChallengeAlgs,
 		supportedSigningAlgs: p.SupportedSigningAlgs,
 	}, nil
@@ -80,6 +109,15 @@ type discoveryProvider struct {
 	tokenURL             string
 	jwksURL              string
 	userInfoURL          string
+	endSessionURL        string
+	deviceAuthURL        string
+	parURL               string
+	introspectionURL     string
+	mtlsTokenURL         string
+	mtlsUserInfoURL      string
+	mtlsDeviceAuthURL    string
+	mtlsPARURL           string
+	mtlsIntrospectionURL string
 	codeChallengeAlgs    []string
 	supportedSigningAlgs []string
 }
@@ -87,11 +125,38 @@ type discoveryProvider struct {
 // Endpoints returns the discovered endpoints needed for an authentication provider.
 func (p *discoveryProvider) Endpoints() Endpoints {
 	return Endpoints{
-		AuthURL:     p.authURL,
-		TokenURL:    p.tokenURL,
-		JWKsURL:     p.jwksURL,
-		UserInfoURL: p.userInfoURL,
+		AuthURL:          p.authURL,
+		TokenURL:         p.tokenURL,
+		JWKsURL:          p.jwksURL,
+		UserInfoURL:      p.userInfoURL,
+		EndSessionURL:    p.endSessionURL,
+		DeviceAuthURL:    p.deviceAuthURL,
+		PARURL:           p.parURL,
+		IntrospectionURL: p.introspectionURL,
+	}
+}
+
+// MTLSEndpoints returns the discovered endpoints to use when authenticating
+// with a client certificate. Endpoints with an mtls_endpoint_aliases entry
+// (RFC 8705) are replaced by their alias.
+func (p *discoveryProvider) MTLSEndpoints() Endpoints {
+	endpoints := p.Endpoints()
+	if p.mtlsTokenURL != "" {
+		endpoints.TokenURL = p.mtlsTokenURL
+	}
+	if p.mtlsUserInfoURL != "" {
+		endpoints.UserInfoURL = p.mtlsUserInfoURL
+	}
+	if p.mtlsDeviceAuthURL != "" {
+		endpoints.DeviceAuthURL = p.mtlsDeviceAuthURL
+	}
+	if p.mtlsPARURL != "" {
+		endpoints.PARURL = p.mtlsPARURL
+	}
+	if p.mtlsIntrospectionURL != "" {
+		endpoints.IntrospectionURL = p.mtlsIntrospectionURL
 	}
+	return endpoints
 }
 
 // PKCE returns information related to the PKCE (code challenge) support of the provider.
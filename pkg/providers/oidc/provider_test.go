@@ -121,6 +121,90 @@ var _ = Describe("Provider", func() {
 
 		Expect(provider.SupportedSigningAlgs()).To(ConsistOf("RS256", "HS256"))
 	})
+
+	It("with an end session endpoint on the provider, should populate the end session URL", func() {
+		m, err := mockoidc.NewServer(nil)
+		Expect(err).ToNot(HaveOccurred())
+		m.AddMiddleware(newEndSessionIssuerMiddleware(m))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(m.Start(ln, nil)).To(Succeed())
+		defer func() {
+			Expect(m.Shutdown()).To(Succeed())
+		}()
+
+		provider, err := NewProvider(context.Background(), m.Issuer(), false)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(provider.Endpoints().EndSessionURL).To(Equal(m.Issuer() + "/logout"))
+	})
+
+	It("with mtls endpoint aliases on the provider, should use the aliases for the mtls endpoints", func() {
+		m, err := mockoidc.NewServer(nil)
+		Expect(err).ToNot(HaveOccurred())
+		m.AddMiddleware(newMTLSIssuerMiddleware(m))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(m.Start(ln, nil)).To(Succeed())
+		defer func() {
+			Expect(m.Shutdown()).To(Succeed())
+		}()
+
+		provider, err := NewProvider(context.Background(), m.Issuer(), false)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(provider.Endpoints().TokenURL).To(Equal(m.TokenEndpoint()))
+		Expect(provider.MTLSEndpoints()).To(Equal(Endpoints{
+			AuthURL:     m.AuthorizationEndpoint(),
+			TokenURL:    "https://mtls.example.com/token",
+			JWKsURL:     m.JWKSEndpoint(),
+			UserInfoURL: m.UserinfoEndpoint(),
+		}))
+	})
+
+	It("with a pushed authorization request endpoint on the provider, should populate the PAR URL", func() {
+		m, err := mockoidc.NewServer(nil)
+		Expect(err).ToNot(HaveOccurred())
+		m.AddMiddleware(newPARIssuerMiddleware(m))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(m.Start(ln, nil)).To(Succeed())
+		defer func() {
+			Expect(m.Shutdown()).To(Succeed())
+		}()
+
+		provider, err := NewProvider(context.Background(), m.Issuer(), false)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(provider.Endpoints().PARURL).To(Equal(m.Issuer() + "/par"))
+		Expect(provider.MTLSEndpoints().PARURL).To(Equal("https://mtls.example.com/par"))
+	})
+
+	It("with an introspection endpoint on the provider, should populate the introspection URL", func() {
+		m, err := mockoidc.NewServer(nil)
+		Expect(err).ToNot(HaveOccurred())
+		m.AddMiddleware(newIntrospectionIssuerMiddleware(m))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(m.Start(ln, nil)).To(Succeed())
+		defer func() {
+			Expect(m.Shutdown()).To(Succeed())
+		}()
+
+		provider, err := NewProvider(context.Background(), m.Issuer(), false)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(provider.Endpoints().IntrospectionURL).To(Equal(m.Issuer() + "/introspect"))
+		Expect(provider.MTLSEndpoints().IntrospectionURL).To(Equal("https://mtls.example.com/introspect"))
+	})
 })
 
 func newInvalidIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) http.Handler {
@@ -189,3 +273,91 @@ func newBadRequestMiddleware() func(http.Handler) http.Handler {
 		})
 	}
 }
+
+func newEndSessionIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			p := providerJSON{
+				Issuer:        m.Issuer(),
+				AuthURL:       m.AuthorizationEndpoint(),
+				TokenURL:      m.TokenEndpoint(),
+				JWKsURL:       m.JWKSEndpoint(),
+				UserInfoURL:   m.UserinfoEndpoint(),
+				EndSessionURL: m.Issuer() + "/logout",
+			}
+			data, err := json.Marshal(p)
+			if err != nil {
+				rw.WriteHeader(500)
+			}
+			rw.Write(data)
+		})
+	}
+}
+
+func newMTLSIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			p := providerJSON{
+				Issuer:      m.Issuer(),
+				AuthURL:     m.AuthorizationEndpoint(),
+				TokenURL:    m.TokenEndpoint(),
+				JWKsURL:     m.JWKSEndpoint(),
+				UserInfoURL: m.UserinfoEndpoint(),
+				MTLSEndpointAliases: mtlsEndpointAliases{
+					TokenURL: "https://mtls.example.com/token",
+				},
+			}
+			data, err := json.Marshal(p)
+			if err != nil {
+				rw.WriteHeader(500)
+			}
+			rw.Write(data)
+		})
+	}
+}
+
+func newPARIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			p := providerJSON{
+				Issuer:      m.Issuer(),
+				AuthURL:     m.AuthorizationEndpoint(),
+				TokenURL:    m.TokenEndpoint(),
+				JWKsURL:     m.JWKSEndpoint(),
+				UserInfoURL: m.UserinfoEndpoint(),
+				PARURL:      m.Issuer() + "/par",
+				MTLSEndpointAliases: mtlsEndpointAliases{
+					PARURL: "https://mtls.example.com/par",
+				},
+			}
+			data, err := json.Marshal(p)
+			if err != nil {
+				rw.WriteHeader(500)
+			}
+			rw.Write(data)
+		})
+	}
+}
+
+func newIntrospectionIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			p := providerJSON{
+				Issuer:           m.Issuer(),
+				AuthURL:          m.AuthorizationEndpoint(),
+				TokenURL:         m.TokenEndpoint(),
+				JWKsURL:          m.JWKSEndpoint(),
+				UserInfoURL:      m.UserinfoEndpoint(),
+				IntrospectionURL: m.Issuer() + "/introspect",
+				MTLSEndpointAliases: mtlsEndpointAliases{
+					IntrospectionURL: "https://mtls.example.com/introspect",
+				},
+			}
+			data, err := json.Marshal(p)
+			if err != nil {
+				rw.WriteHeader(500)
+			}
+			rw.Write(data)
+		})
+	}
+}
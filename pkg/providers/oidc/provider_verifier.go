@@ -7,10 +7,12 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	"golang.org/x/oauth2"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
@@ -128,7 +130,10 @@ func NewProviderVerifier(ctx context.Context, opts ProviderVerifierOptions) (Pro
 type verifierBuilder func(*oidc.Config) *oidc.IDTokenVerifier
 
 func getVerifierBuilder(ctx context.Context, opts ProviderVerifierOptions) (verifierBuilder, DiscoveryProvider, error) {
-	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
+	if _, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); !ok {
+		// Discovery and the JWKs requests use the client carried by the context
+		ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
+	}
 
 	if opts.SkipDiscovery {
 		var keySet oidc.KeySet
@@ -0,0 +1,198 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	k8serrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// claimExpressionCostLimit bounds the evaluation cost of a single expression
+const claimExpressionCostLimit = 1000000
+
+// ClaimExpressions holds the compiled CEL expressions of a provider
+type ClaimExpressions struct {
+	user              cel.Program
+	email             cel.Program
+	groups            cel.Program
+	preferredUsername cel.Program
+	authorize         cel.Program
+}
+
+// NewClaimExpressions compiles the claim expressions and checks their result
+// types. It returns nil when no expressions are configured.
+func NewClaimExpressions(opts *options.ClaimExpressions) (*ClaimExpressions, error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	env, err := cel.NewEnv(
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("session", cel.MapType(cel.StringType, cel.DynType)),
+		ext.Strings(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ClaimExpressions{}
+	errs := []error{}
+	for _, e := range []struct {
+		name       string
+		expression string
+		outputType *cel.Type
+		dst        *cel.Program
+	}{
+		{"user", opts.User, cel.StringType, &c.user},
+		{"email", opts.Email, cel.StringType, &c.email},
+		{"groups", opts.Groups, cel.ListType(cel.StringType), &c.groups},
+		{"preferredUsername", opts.PreferredUsername, cel.StringType, &c.preferredUsername},
+		{"authorize", opts.Authorize, cel.BoolType, &c.authorize},
+	} {
+		if e.expression == "" {
+			continue
+		}
+		program, err := compileClaimExpression(env, e.expression, e.outputType)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", e.name, err))
+			continue
+		}
+		*e.dst = program
+	}
+	if len(errs) > 0 {
+		return nil, k8serrors.NewAggregate(errs)
+	}
+	return c, nil
+}
+
+func compileClaimExpression(env *cel.Env, expression string, outputType *cel.Type) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	// The claims are dynamically typed, so the type can only be checked at
+	// evaluation time when the expression depends on them
+	if !ast.OutputType().IsAssignableType(outputType) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must evaluate to %s, got %s", outputType, ast.OutputType())
+	}
+	return env.Program(ast, cel.CostLimit(claimExpressionCostLimit))
+}
+
+// MapSession sets the session fields that have an expression. The claims are
+// the claims of the ID token, or of the access token without an ID token.
+// The values the provider set are kept in the session, so that
+// RestoreSession can revert the fields before the session is mapped again.
+func (c *ClaimExpressions) MapSession(s *sessions.SessionState) error {
+	if c == nil {
+		return nil
+	}
+
+	s.ProviderValues = &sessions.ProviderValues{
+		Email:             s.Email,
+		User:              s.User,
+		Groups:            append([]string(nil), s.Groups...),
+		PreferredUsername: s.PreferredUsername,
+	}
+	vars := claimExpressionVariables(s)
+	for _, e := range []struct {
+		name    string
+		program cel.Program
+		dst     interface{}
+	}{
+		{"user", c.user, &s.User},
+		{"email", c.email, &s.Email},
+		{"groups", c.groups, &s.Groups},
+		{"preferredUsername", c.preferredUsername, &s.PreferredUsername},
+	} {
+		if e.program == nil {
+			continue
+		}
+		if err := evalClaimExpression(e.program, vars, e.dst); err != nil {
+			return fmt.Errorf("could not evaluate the %s expression: %v", e.name, err)
+		}
+	}
+	return nil
+}
+
+// RestoreSession reverts the fields computed by MapSession to the values the
+// provider set, so that a refreshed session is mapped from the provider's
+// values instead of the already mapped ones.
+func (c *ClaimExpressions) RestoreSession(s *sessions.SessionState) {
+	if c == nil || s.ProviderValues == nil {
+		return
+	}
+
+	s.Email = s.ProviderValues.Email
+	s.User = s.ProviderValues.User
+	s.Groups = append([]string(nil), s.ProviderValues.Groups...)
+	s.PreferredUsername = s.ProviderValues.PreferredUsername
+}
+
+// Authorize evaluates the authorize expression. Sessions are authorized when
+// there is no authorize expression.
+func (c *ClaimExpressions) Authorize(s *sessions.SessionState) (bool, error) {
+	if c == nil || c.authorize == nil {
+		return true, nil
+	}
+
+	var authorized bool
+	if err := evalClaimExpression(c.authorize, claimExpressionVariables(s), &authorized); err != nil {
+		return false, fmt.Errorf("could not evaluate the authorize expression: %v", err)
+	}
+	return authorized, nil
+}
+
+func evalClaimExpression(program cel.Program, vars map[string]interface{}, dst interface{}) error {
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return err
+	}
+	value, err := out.ConvertToNative(reflect.TypeOf(dst).Elem())
+	if err != nil {
+		return err
+	}
+	reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(value))
+	return nil
+}
+
+// claimExpressionVariables builds the `claims` and `session` variables of the
+// expressions
+func claimExpressionVariables(s *sessions.SessionState) map[string]interface{} {
+	claims := map[string]interface{}{}
+	token := s.IDToken
+	if token == "" {
+		token = s.AccessToken
+	}
+	// Opaque tokens have no claims
+	if payload, err := parseJWT(token); err == nil {
+		var tokenClaims map[string]interface{}
+		if err := json.Unmarshal(payload, &tokenClaims); err == nil && tokenClaims != nil {
+			claims = tokenClaims
+		}
+	}
+
+	groups := s.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	additionalClaims := s.AdditionalClaims
+	if additionalClaims == nil {
+		additionalClaims = map[string]interface{}{}
+	}
+
+	return map[string]interface{}{
+		"claims": claims,
+		"session": map[string]interface{}{
+			"user":              s.User,
+			"email":             s.Email,
+			"groups":            groups,
+			"preferredUsername": s.PreferredUsername,
+			"additionalClaims":  additionalClaims,
+		},
+	}
+}
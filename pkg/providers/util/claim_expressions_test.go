@@ -0,0 +1,192 @@
+package util
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Claim Expressions Suite", func() {
+	const claimsPayload = `{
+      "sub": "123",
+      "email": "jdoe@corp.com",
+      "email_verified": true,
+      "hd": "corp.com",
+      "department": "engineering",
+      "realm_access": {"roles": ["admin", "user"]}
+    }`
+
+	It("returns nil without expressions", func() {
+		expressions, err := NewClaimExpressions(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(expressions).To(BeNil())
+
+		s := &sessions.SessionState{User: "user"}
+		Expect(expressions.MapSession(s)).To(Succeed())
+		Expect(s.User).To(Equal("user"))
+		Expect(expressions.Authorize(s)).To(BeTrue())
+	})
+
+	type newClaimExpressionsTableInput struct {
+		expressions options.ClaimExpressions
+		expectedErr string
+	}
+
+	DescribeTable("NewClaimExpressions",
+		func(in newClaimExpressionsTableInput) {
+			_, err := NewClaimExpressions(&in.expressions)
+			if in.expectedErr != "" {
+				Expect(err).To(MatchError(in.expectedErr))
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+		},
+		Entry("with valid expressions", newClaimExpressionsTableInput{
+			expressions: options.ClaimExpressions{
+				User:      `claims.sub`,
+				Email:     `session.email.lowerAscii()`,
+				Groups:    `claims.realm_access.roles.map(r, "role:" + r)`,
+				Authorize: `claims.email_verified && claims.hd == "corp.com"`,
+			},
+		}),
+		Entry("with a syntax error", newClaimExpressionsTableInput{
+			expressions: options.ClaimExpressions{
+				User: `claims.sub +`,
+			},
+			expectedErr: "user: ERROR: <input>:1:13: Syntax error: mismatched input '<EOF>' expecting {'[', '{', '(', '.', '-', '!', 'true', 'false', 'null', NUM_FLOAT, NUM_INT, NUM_UINT, STRING, BYTES, IDENTIFIER}\n | claims.sub +\n | ............^",
+		}),
+		Entry("with an undeclared variable", newClaimExpressionsTableInput{
+			expressions: options.ClaimExpressions{
+				Authorize: `token.hd == "corp.com"`,
+			},
+			expectedErr: "authorize: ERROR: <input>:1:1: undeclared reference to 'token' (in container '')\n | token.hd == \"corp.com\"\n | ^",
+		}),
+		Entry("with the wrong result type", newClaimExpressionsTableInput{
+			expressions: options.ClaimExpressions{
+				Groups:    `"admins"`,
+				Authorize: `"yes"`,
+			},
+			expectedErr: "[groups: expression must evaluate to list(string), got string, authorize: expression must evaluate to bool, got string]",
+		}),
+	)
+
+	Context("MapSession", func() {
+		var s *sessions.SessionState
+
+		BeforeEach(func() {
+			s = &sessions.SessionState{
+				IDToken: createJWTFromPayload(claimsPayload),
+				User:    "provider-user",
+				Email:   "JDoe@Corp.com",
+				Groups:  []string{"provider-group"},
+			}
+		})
+
+		It("sets the fields with an expression", func() {
+			expressions, err := NewClaimExpressions(&options.ClaimExpressions{
+				Email:             `session.email.lowerAscii()`,
+				Groups:            `session.groups + claims.realm_access.roles.map(r, "role:" + r) + [claims.department]`,
+				PreferredUsername: `claims.sub`,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(expressions.MapSession(s)).To(Succeed())
+			Expect(s.User).To(Equal("provider-user"))
+			Expect(s.Email).To(Equal("jdoe@corp.com"))
+			Expect(s.Groups).To(Equal([]string{"provider-group", "role:admin", "role:user", "engineering"}))
+			Expect(s.PreferredUsername).To(Equal("123"))
+		})
+
+		It("maps the provider values again after RestoreSession", func() {
+			expressions, err := NewClaimExpressions(&options.ClaimExpressions{
+				Groups: `session.groups.map(g, "role:" + g)`,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(expressions.MapSession(s)).To(Succeed())
+			Expect(s.Groups).To(Equal([]string{"role:provider-group"}))
+
+			// A refresh that keeps the groups
+			expressions.RestoreSession(s)
+			Expect(s.Groups).To(Equal([]string{"provider-group"}))
+			Expect(expressions.MapSession(s)).To(Succeed())
+			Expect(s.Groups).To(Equal([]string{"role:provider-group"}))
+
+			// A refresh that replaces the groups
+			expressions.RestoreSession(s)
+			s.Groups = []string{"new-group"}
+			Expect(expressions.MapSession(s)).To(Succeed())
+			Expect(s.Groups).To(Equal([]string{"role:new-group"}))
+		})
+
+		It("uses the access token claims without an ID token", func() {
+			s.AccessToken = s.IDToken
+			s.IDToken = ""
+
+			expressions, err := NewClaimExpressions(&options.ClaimExpressions{User: `claims.sub`})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(expressions.MapSession(s)).To(Succeed())
+			Expect(s.User).To(Equal("123"))
+		})
+
+		It("returns an error for a missing claim", func() {
+			expressions, err := NewClaimExpressions(&options.ClaimExpressions{User: `claims.username`})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(expressions.MapSession(s)).To(MatchError("could not evaluate the user expression: no such key: username"))
+		})
+	})
+
+	type authorizeTableInput struct {
+		authorize          string
+		payload            string
+		expectedAuthorized bool
+		expectedErr        string
+	}
+
+	DescribeTable("Authorize",
+		func(in authorizeTableInput) {
+			expressions, err := NewClaimExpressions(&options.ClaimExpressions{Authorize: in.authorize})
+			Expect(err).ToNot(HaveOccurred())
+
+			authorized, err := expressions.Authorize(&sessions.SessionState{
+				IDToken: createJWTFromPayload(in.payload),
+				Email:   "jdoe@corp.com",
+			})
+			if in.expectedErr != "" {
+				Expect(err).To(MatchError(in.expectedErr))
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(authorized).To(Equal(in.expectedAuthorized))
+		},
+		Entry("with matching claims", authorizeTableInput{
+			authorize:          `claims.email_verified && claims.hd == "corp.com"`,
+			payload:            claimsPayload,
+			expectedAuthorized: true,
+		}),
+		Entry("with other claims", authorizeTableInput{
+			authorize:          `claims.email_verified && claims.hd == "other.com"`,
+			payload:            claimsPayload,
+			expectedAuthorized: false,
+		}),
+		Entry("with the session fields", authorizeTableInput{
+			authorize:          `session.email.endsWith("@corp.com")`,
+			payload:            emptyJSON,
+			expectedAuthorized: true,
+		}),
+		Entry("with a missing claim", authorizeTableInput{
+			authorize:          `claims.hd == "corp.com"`,
+			payload:            emptyJSON,
+			expectedAuthorized: false,
+			expectedErr:        "could not evaluate the authorize expression: no such key: hd",
+		}),
+		Entry("with a guarded missing claim", authorizeTableInput{
+			authorize:          `has(claims.hd) && claims.hd == "corp.com"`,
+			payload:            emptyJSON,
+			expectedAuthorized: false,
+		}),
+	)
+})
@@ -46,6 +46,33 @@ func NewClaimExtractor(ctx context.Context, idToken string, profileURL *url.URL,
 	}, nil
 }
 
+// NewProfileClaimExtractor constructs a new ClaimExtractor that looks up
+// claims from the profile URL only. This is used by providers that do not
+// issue an ID Token.
+func NewProfileClaimExtractor(ctx context.Context, profileURL *url.URL, profileRequestHeaders http.Header) ClaimExtractor {
+	return &claimExtractor{
+		ctx:            ctx,
+		profileURL:     profileURL,
+		requestHeaders: profileRequestHeaders,
+		tokenClaims:    simplejson.New(),
+	}
+}
+
+// NewJSONClaimExtractor constructs a new ClaimExtractor that extracts claims
+// from a JSON document only, such as a token introspection response.
+func NewJSONClaimExtractor(payload []byte) (ClaimExtractor, error) {
+	claims, err := simplejson.NewJson(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse claims: %w", err)
+	}
+
+	return &claimExtractor{
+		ctx:         context.Background(),
+		profileURL:  &url.URL{},
+		tokenClaims: claims,
+	}, nil
+}
+
 // claimExtractor implements the ClaimExtractor interface
 type claimExtractor struct {
 	profileURL     *url.URL
@@ -498,6 +498,50 @@ var _ = Describe("Claim Extractor Suite", func() {
 		Expect(exists).To(BeTrue())
 		Expect(value).To(Equal([]interface{}{"jwtGroup1", "jwtGroup2"}))
 	})
+
+	It("NewProfileClaimExtractor should read claims from the profile URL only", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if !hasAuthorizedHeader(req.Header) {
+				rw.WriteHeader(401)
+				return
+			}
+			rw.Write([]byte(nestedClaimPayload))
+		}))
+		defer server.Close()
+
+		profileURL, err := url.Parse(server.URL + profilePath)
+		Expect(err).ToNot(HaveOccurred())
+
+		claimExtractor := NewProfileClaimExtractor(context.Background(), profileURL, newAuthorizedHeader())
+
+		value, exists, err := claimExtractor.GetClaim("auth.user.username")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeTrue())
+		Expect(value).To(Equal("nestedUser"))
+
+		value, exists, err = claimExtractor.GetClaim("email")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeFalse())
+		Expect(value).To(BeNil())
+	})
+
+	It("NewJSONClaimExtractor should read claims from the JSON document only", func() {
+		claimExtractor, err := NewJSONClaimExtractor([]byte(nestedClaimPayload))
+		Expect(err).ToNot(HaveOccurred())
+
+		value, exists, err := claimExtractor.GetClaim("auth.user.username")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeTrue())
+		Expect(value).To(Equal("nestedUser"))
+
+		value, exists, err = claimExtractor.GetClaim("email")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeFalse())
+		Expect(value).To(BeNil())
+
+		_, err = NewJSONClaimExtractor([]byte("not json"))
+		Expect(err).To(HaveOccurred())
+	})
 })
 
 // ******************************************
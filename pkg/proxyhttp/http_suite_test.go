@@ -3,11 +3,17 @@ package proxyhttp
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
+	"math/big"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
@@ -19,6 +25,8 @@ import (
 var ipv4CertData, ipv6CertData []byte
 var ipv4CertDataSource, ipv4KeyDataSource options.SecretSource
 var ipv6CertDataSource, ipv6KeyDataSource options.SecretSource
+var clientCADataSource options.SecretSource
+var clientCertificate tls.Certificate
 var transport *http.Transport
 
 func TestHTTPSuite(t *testing.T) {
@@ -67,6 +75,42 @@ var _ = BeforeSuite(func() {
 		ipv6KeyDataSource.Value = keyOut.Bytes()
 	})
 
+	By("Generating a client CA and a client certificate for TLS tests", func() {
+		caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		caTemplate := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "OAuth2 Proxy Test Client CA"},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		caBytes, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+		Expect(err).ToNot(HaveOccurred())
+		caOut := new(bytes.Buffer)
+		Expect(pem.Encode(caOut, &pem.Block{Type: "CERTIFICATE", Bytes: caBytes})).To(Succeed())
+		clientCADataSource.Value = caOut.Bytes()
+
+		clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		clientTemplate := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "client"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		clientBytes, err := x509.CreateCertificate(rand.Reader, clientTemplate, caTemplate, &clientKey.PublicKey, caKey)
+		Expect(err).ToNot(HaveOccurred())
+		clientCertificate = tls.Certificate{
+			Certificate: [][]byte{clientBytes},
+			PrivateKey:  clientKey,
+		}
+	})
+
 	By("Setting up a http client", func() {
 		ipv4cert, err := tls.X509KeyPair(ipv4CertDataSource.Value, ipv4KeyDataSource.Value)
 		Expect(err).ToNot(HaveOccurred())
@@ -3,6 +3,7 @@ package proxyhttp
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net"
@@ -167,6 +168,10 @@ func (s *server) setupTLSListener(opts Opts) error {
 		}
 	}
 
+	if err := setupClientAuth(config, opts.TLS); err != nil {
+		return fmt.Errorf("could not set up client authentication: %v", err)
+	}
+
 	listenAddr := getListenAddress(opts.SecureBindAddress)
 
 	listener, err := net.Listen("tcp", listenAddr)
@@ -178,6 +183,47 @@ func (s *server) setupTLSListener(opts Opts) error {
 	return nil
 }
 
+// setupClientAuth configures how certificates are requested from clients and
+// the CAs they are verified against.
+func setupClientAuth(config *tls.Config, opts *options.TLS) error {
+	clientAuth := opts.ClientAuth
+	if clientAuth == "" && opts.ClientCA != nil {
+		clientAuth = options.TLSClientAuthRequire
+	}
+
+	switch clientAuth {
+	case "":
+		return nil
+	case options.TLSClientAuthRequest:
+		config.ClientAuth = tls.RequestClientCert
+	case options.TLSClientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	case options.TLSClientAuthVerifyIfGiven:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return fmt.Errorf("unknown TLS ClientAuth config provided %q", clientAuth)
+	}
+
+	if opts.ClientCA == nil {
+		if clientAuth == options.TLSClientAuthRequest {
+			return nil
+		}
+		return fmt.Errorf("a client CA is required to verify client certificates with ClientAuth %q", clientAuth)
+	}
+
+	caData, err := getSecretValue(opts.ClientCA)
+	if err != nil {
+		return fmt.Errorf("could not load client CA data: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return errors.New("no certificates found in client CA data")
+	}
+	config.ClientCAs = pool
+
+	return nil
+}
+
 // Start starts the HTTP and HTTPS server if applicable.
 // It will block until the context is cancelled.
 // If any errors occur, only the first error will be returned.
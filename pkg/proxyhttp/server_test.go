@@ -2,6 +2,7 @@ package proxyhttp
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -648,6 +649,77 @@ var _ = Describe("Server", func() {
 				expectTLSListener:  true,
 				ipv6:               true,
 			}),
+			Entry("with an ipv4 valid https bind address, and valid TLS config with a client CA", &newServerTableInput{
+				opts: Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					TLS: &options.TLS{
+						Key:      &ipv4KeyDataSource,
+						Cert:     &ipv4CertDataSource,
+						ClientCA: &clientCADataSource,
+					},
+				},
+				expectedErr:        nil,
+				expectHTTPListener: false,
+				expectTLSListener:  true,
+			}),
+			Entry("with an ipv4 valid https bind address, and valid TLS config with ClientAuth request", &newServerTableInput{
+				opts: Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					TLS: &options.TLS{
+						Key:        &ipv4KeyDataSource,
+						Cert:       &ipv4CertDataSource,
+						ClientAuth: options.TLSClientAuthRequest,
+					},
+				},
+				expectedErr:        nil,
+				expectHTTPListener: false,
+				expectTLSListener:  true,
+			}),
+			Entry("with an ipv4 valid https bind address, and invalid TLS config with unknown ClientAuth", &newServerTableInput{
+				opts: Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					TLS: &options.TLS{
+						Key:        &ipv4KeyDataSource,
+						Cert:       &ipv4CertDataSource,
+						ClientCA:   &clientCADataSource,
+						ClientAuth: "always",
+					},
+				},
+				expectedErr:        errors.New("error setting up TLS listener: could not set up client authentication: unknown TLS ClientAuth config provided \"always\""),
+				expectHTTPListener: false,
+				expectTLSListener:  false,
+			}),
+			Entry("with an ipv4 valid https bind address, and invalid TLS config with ClientAuth verify-if-given without a client CA", &newServerTableInput{
+				opts: Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					TLS: &options.TLS{
+						Key:        &ipv4KeyDataSource,
+						Cert:       &ipv4CertDataSource,
+						ClientAuth: options.TLSClientAuthVerifyIfGiven,
+					},
+				},
+				expectedErr:        errors.New("error setting up TLS listener: could not set up client authentication: a client CA is required to verify client certificates with ClientAuth \"verify-if-given\""),
+				expectHTTPListener: false,
+				expectTLSListener:  false,
+			}),
+			Entry("with an ipv4 valid https bind address, and invalid TLS config with an invalid client CA", &newServerTableInput{
+				opts: Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					TLS: &options.TLS{
+						Key:      &ipv4KeyDataSource,
+						Cert:     &ipv4CertDataSource,
+						ClientCA: &options.SecretSource{Value: []byte("invalid")},
+					},
+				},
+				expectedErr:        errors.New("error setting up TLS listener: could not set up client authentication: no certificates found in client CA data"),
+				expectHTTPListener: false,
+				expectTLSListener:  false,
+			}),
 		)
 	})
 
@@ -837,6 +909,61 @@ var _ = Describe("Server", func() {
 			})
 		})
 
+		Context("with an ipv4 https server requiring client certificates", func() {
+			var secureListenAddr string
+
+			BeforeEach(func() {
+				var err error
+				srv, err = NewServer(Opts{
+					Handler:           handler,
+					SecureBindAddress: "127.0.0.1:0",
+					TLS: &options.TLS{
+						Key:        &ipv4KeyDataSource,
+						Cert:       &ipv4CertDataSource,
+						ClientCA:   &clientCADataSource,
+						ClientAuth: options.TLSClientAuthRequire,
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				s, ok := srv.(*server)
+				Expect(ok).To(BeTrue())
+
+				secureListenAddr = fmt.Sprintf("https://%s/", s.tlsListener.Addr().String())
+			})
+
+			It("Rejects clients without a certificate", func() {
+				go func() {
+					defer GinkgoRecover()
+					Expect(srv.Start(ctx)).To(Succeed())
+				}()
+
+				_, err := httpGet(ctx, secureListenAddr)
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("Serves clients with a verified certificate", func() {
+				go func() {
+					defer GinkgoRecover()
+					Expect(srv.Start(ctx)).To(Succeed())
+				}()
+
+				clientTransport := transport.Clone()
+				clientTransport.TLSClientConfig.Certificates = []tls.Certificate{clientCertificate}
+				c := &http.Client{Transport: clientTransport}
+				req, err := http.NewRequestWithContext(ctx, "GET", secureListenAddr, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				resp, err := c.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(hello))
+			})
+		})
+
 		Context("with a fd ipv4 http and an ipv4 https server", func() {
 			var listenAddr, secureListenAddr string
 
@@ -39,6 +39,8 @@ func New(endpoint string) Builder {
 
 // WithContext adds a context to the request.
 // If no context is provided, context.Background() is used instead.
+// If the context carries an HTTP client (see ClientContext), the request is
+// performed with that client instead of the DefaultHTTPClient.
 func (r *builder) WithContext(ctx context.Context) Builder {
 	r.context = ctx
 	return r
@@ -99,7 +101,7 @@ func (r *builder) do() Result {
 	}
 	req.Header = r.header
 
-	resp, err := DefaultHTTPClient.Do(req)
+	resp, err := clientFromContext(r.context).Do(req)
 	if err != nil {
 		r.result = &result{err: fmt.Errorf("error performing request: %v", err)}
 		return r.result
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -67,6 +68,17 @@ var _ = Describe("Builder suite", func() {
 		})
 	})
 
+	Context("with a client in the context", func() {
+		BeforeEach(func() {
+			client := &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("context client used")
+			})}
+			b = b.WithContext(ClientContext(context.Background(), client))
+		})
+
+		assertRequestError(getBuilder, "context client used")
+	})
+
 	Context("with a body", func() {
 		const body = "{\"some\": \"body\"}"
 		header := baseHeaders.Clone()
@@ -376,3 +388,9 @@ func assertJSONError(builder func() Builder, errorMessage string) {
 		})
 	})
 }
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
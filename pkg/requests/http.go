@@ -1,9 +1,13 @@
 package requests
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"net/http"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/version"
+	"golang.org/x/oauth2"
 )
 
 type userAgentTransport struct {
@@ -24,6 +28,50 @@ var DefaultHTTPClient = &http.Client{Transport: &userAgentTransport{
 
 var DefaultTransport = http.DefaultTransport
 
+// NewTLSHTTPClient creates an HTTP client that verifies servers against the
+// given root CAs and presents the given client certificates on every
+// connection. The root CAs of the DefaultTransport are kept when rootCAs is
+// nil. It otherwise behaves like the DefaultHTTPClient, including any
+// verification settings of the DefaultTransport.
+func NewTLSHTTPClient(rootCAs *x509.CertPool, certificates []tls.Certificate) *http.Client {
+	var transport *http.Transport
+	if t, ok := DefaultTransport.(*http.Transport); ok {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if rootCAs != nil {
+		transport.TLSClientConfig.RootCAs = rootCAs
+	}
+	if len(certificates) > 0 {
+		transport.TLSClientConfig.Certificates = certificates
+	}
+
+	return &http.Client{Transport: &userAgentTransport{
+		next:      transport,
+		userAgent: "oauth2-proxy/" + version.VERSION,
+	}}
+}
+
+// ClientContext returns a copy of the context that carries the HTTP client.
+// Requests made with this context use the client, this includes the requests
+// made by the go-oidc and oauth2 packages which share the same context key.
+func ClientContext(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, client)
+}
+
+// clientFromContext returns the HTTP client carried by the context or the
+// DefaultHTTPClient when there is none.
+func clientFromContext(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		return client
+	}
+	return DefaultHTTPClient
+}
+
 func setDefaultUserAgent(header http.Header, userAgent string) {
 	if header != nil && len(header.Values("User-Agent")) == 0 {
 		header.Set("User-Agent", userAgent)
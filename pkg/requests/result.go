@@ -91,8 +91,20 @@ func (r *result) getBodyForUnmarshal() ([]byte, error) {
 
 	// Only unmarshal body if the response was successful
 	if r.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status \"%d\": %s", r.StatusCode(), r.Body())
+		return nil, &UnexpectedStatusError{StatusCode: r.StatusCode(), Body: r.Body()}
 	}
 
 	return r.Body(), nil
 }
+
+// UnexpectedStatusError is returned when a response to be unmarshalled doesn't
+// have a 200 status, so that callers can tell rejected requests apart from
+// unavailable servers.
+type UnexpectedStatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("unexpected status \"%d\": %s", e.StatusCode, e.Body)
+}
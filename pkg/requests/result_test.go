@@ -144,7 +144,7 @@ var _ = Describe("Result suite", func() {
 					},
 					body: []byte("{\"a\": \"foo\"}"),
 				},
-				expectedErr:    errors.New("unexpected status \"409\": {\"a\": \"foo\"}"),
+				expectedErr:    &UnexpectedStatusError{StatusCode: 409, Body: []byte("{\"a\": \"foo\"}")},
 				expectedOutput: &testStruct{},
 			}),
 			Entry("when the response has a valid json response", unmarshalIntoTableInput{
@@ -232,7 +232,7 @@ var _ = Describe("Result suite", func() {
 					},
 					body: []byte("{\"a\": \"foo\"}"),
 				},
-				expectedErr:    errors.New("unexpected status \"409\": {\"a\": \"foo\"}"),
+				expectedErr:    &UnexpectedStatusError{StatusCode: 409, Body: []byte("{\"a\": \"foo\"}")},
 				expectedOutput: &testStruct{},
 			}),
 			Entry("when the response has a valid json response", unmarshalJSONTableInput{
@@ -306,7 +306,7 @@ var _ = Describe("Result suite", func() {
 					},
 					body: []byte("body"),
 				},
-				expectedErr:  errors.New("unexpected status \"409\": body"),
+				expectedErr:  &UnexpectedStatusError{StatusCode: 409, Body: []byte("body")},
 				expectedBody: nil,
 			}),
 			Entry("when the response has a 200 status code", getBodyForUnmarshalTableInput{
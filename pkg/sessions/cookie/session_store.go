@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
@@ -20,10 +21,15 @@ const (
 	// including the cookie name, value, attributes; IE (http.cookie).String()
 	// Most browsers' max is 4096 -- but we give ourselves some leeway
 	maxCookieLength = 4000
+
+	// usedIDsSweepInterval is the minimum interval between removals of the
+	// expired identifiers recorded by MarkUsed
+	usedIDsSweepInterval = time.Minute
 )
 
-// Ensure CookieSessionStore implements the interface
+// Ensure CookieSessionStore implements the interfaces
 var _ sessions.SessionStore = &SessionStore{}
+var _ sessions.ReplayTracker = &SessionStore{}
 
 // SessionStore is an implementation of the sessions.SessionStore
 // interface that stores sessions in client side cookies
@@ -31,6 +37,12 @@ type SessionStore struct {
 	Cookie       *options.Cookie
 	CookieCipher encryption.Cipher
 	Minimal      bool
+
+	// usedIDs holds the identifiers recorded by MarkUsed with their expiry
+	usedIDs      map[string]time.Time
+	usedIDsMutex sync.Mutex
+	// usedIDsNextSweep is when the expired usedIDs are next removed
+	usedIDsNextSweep time.Time
 }
 
 // Save takes a sessions.SessionState and stores the information from it
@@ -91,6 +103,34 @@ func (s *SessionStore) VerifyConnection(_ context.Context) error {
 	return nil
 }
 
+// MarkUsed records the identifier in memory, as cookie sessions have no
+// shared backend. Replays are therefore only detected by the same instance.
+// Expired identifiers are removed at most once per usedIDsSweepInterval, so
+// that the cost of a call doesn't grow with the number of recorded ones.
+func (s *SessionStore) MarkUsed(_ context.Context, id string, exp time.Duration) (bool, error) {
+	s.usedIDsMutex.Lock()
+	defer s.usedIDsMutex.Unlock()
+
+	now := time.Now()
+	if s.usedIDs == nil {
+		s.usedIDs = make(map[string]time.Time)
+	}
+	if now.After(s.usedIDsNextSweep) {
+		for usedID, expiry := range s.usedIDs {
+			if now.After(expiry) {
+				delete(s.usedIDs, usedID)
+			}
+		}
+		s.usedIDsNextSweep = now.Add(usedIDsSweepInterval)
+	}
+
+	if expiry, ok := s.usedIDs[id]; ok && !now.After(expiry) {
+		return false, nil
+	}
+	s.usedIDs[id] = now.Add(exp)
+	return true, nil
+}
+
 // cookieForSession serializes a session state for storage in a cookie
 func (s *SessionStore) cookieForSession(ss *sessions.SessionState) ([]byte, error) {
 	if s.Minimal && (ss.AccessToken != "" || ss.IDToken != "" || ss.RefreshToken != "") {
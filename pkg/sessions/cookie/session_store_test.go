@@ -1,6 +1,7 @@
 package cookie
 
 import (
+	"context"
 	"fmt"
 	mathrand "math/rand"
 	"net/http"
@@ -34,6 +35,44 @@ var _ = Describe("Cookie SessionStore Tests", func() {
 		}, nil)
 })
 
+func TestMarkUsed(t *testing.T) {
+	store := &SessionStore{}
+
+	unused, err := store.MarkUsed(context.Background(), "id", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, unused)
+
+	unused, err = store.MarkUsed(context.Background(), "id", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, unused)
+
+	// Expired identifiers can be used again
+	unused, err = store.MarkUsed(context.Background(), "expiring", -time.Second)
+	assert.NoError(t, err)
+	assert.True(t, unused)
+	unused, err = store.MarkUsed(context.Background(), "expiring", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, unused)
+}
+
+func TestMarkUsedSweepsExpiredIDsPeriodically(t *testing.T) {
+	store := &SessionStore{}
+
+	_, err := store.MarkUsed(context.Background(), "expired", -time.Second)
+	assert.NoError(t, err)
+	_, err = store.MarkUsed(context.Background(), "id", time.Minute)
+	assert.NoError(t, err)
+
+	// Expired identifiers are kept until the next sweep
+	assert.Len(t, store.usedIDs, 2)
+
+	store.usedIDsNextSweep = time.Now().Add(-time.Second)
+	_, err = store.MarkUsed(context.Background(), "other", time.Minute)
+	assert.NoError(t, err)
+	assert.Len(t, store.usedIDs, 2)
+	assert.NotContains(t, store.usedIDs, "expired")
+}
+
 func Test_copyCookie(t *testing.T) {
 	expire, _ := time.Parse(time.RFC3339, "2020-03-17T00:00:00Z")
 	c := &http.Cookie{
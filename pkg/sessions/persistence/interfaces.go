@@ -16,4 +16,12 @@ type Store interface {
 	Clear(context.Context, string) error
 	Lock(key string) sessions.Lock
 	VerifyConnection(context.Context) error
+	// AddToIndex adds a key to a secondary index so that it can be looked up
+	// without the session ticket. The index expires after the given duration.
+	AddToIndex(ctx context.Context, index string, key string, exp time.Duration) error
+	// LoadIndex returns all keys that were added to an index
+	LoadIndex(ctx context.Context, index string) ([]string, error)
+	// SaveIfAbsent saves the value only when the key does not exist yet.
+	// It returns false when the key already existed.
+	SaveIfAbsent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error)
 }
@@ -2,6 +2,9 @@ package persistence
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -49,6 +52,10 @@ func (m *Manager) Save(rw http.ResponseWriter, req *http.Request, s *sessions.Se
 		return err
 	}
 
+	if err := m.indexSession(req.Context(), tckt, s); err != nil {
+		return err
+	}
+
 	return tckt.setCookie(rw, req, s)
 }
 
@@ -92,6 +99,66 @@ func (m *Manager) Clear(rw http.ResponseWriter, req *http.Request) error {
 	})
 }
 
+// RevokeSessions clears every stored session of the given provider that
+// matches the sid or, when no sid is given, the sub. The sessions are found
+// through the secondary indexes written on Save, so no ticket is required.
+func (m *Manager) RevokeSessions(ctx context.Context, providerID, sid, sub string) error {
+	var index string
+	switch {
+	case sid != "":
+		index = m.indexKey("sid", providerID, sid)
+	case sub != "":
+		index = m.indexKey("sub", providerID, sub)
+	default:
+		return errors.New("a sid or sub is required to revoke sessions")
+	}
+
+	keys, err := m.Store.LoadIndex(ctx, index)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := m.Store.Clear(ctx, key); err != nil {
+			return err
+		}
+	}
+	return m.Store.Clear(ctx, index)
+}
+
+// MarkUsed records a single use identifier in the Store so that replays are
+// detected across all instances sharing the Store
+func (m *Manager) MarkUsed(ctx context.Context, id string, exp time.Duration) (bool, error) {
+	hash := sha256.Sum256([]byte(id))
+	key := fmt.Sprintf("%s-replay-%s", m.Options.Name, hex.EncodeToString(hash[:]))
+	return m.Store.SaveIfAbsent(ctx, key, []byte{1}, exp)
+}
+
+// indexSession adds the ticket to the sid and sub indexes of the session so
+// that RevokeSessions can find it
+func (m *Manager) indexSession(ctx context.Context, tckt *ticket, s *sessions.SessionState) error {
+	indexes := []string{}
+	if s.SID != "" {
+		indexes = append(indexes, m.indexKey("sid", s.ProviderID, s.SID))
+	}
+	if s.Subject != "" {
+		indexes = append(indexes, m.indexKey("sub", s.ProviderID, s.Subject))
+	}
+
+	for _, index := range indexes {
+		if err := m.Store.AddToIndex(ctx, index, tckt.id, m.Options.Expire); err != nil {
+			return fmt.Errorf("error indexing the session: %v", err)
+		}
+	}
+	return nil
+}
+
+// indexKey builds the key of a secondary index. The indexed value is hashed
+// so that user identifiers are not stored in plain text.
+func (m *Manager) indexKey(kind, providerID, value string) string {
+	hash := sha256.Sum256([]byte(providerID + "\x00" + value))
+	return fmt.Sprintf("%s-%s-%s", m.Options.Name, kind, hex.EncodeToString(hash[:]))
+}
+
 // VerifyConnection validates the underlying store is ready and connected
 func (m *Manager) VerifyConnection(ctx context.Context) error {
 	return m.Store.VerifyConnection(ctx)
@@ -13,7 +13,10 @@ type Client interface {
 	Get(ctx context.Context, key string) ([]byte, error)
 	Lock(key string) sessions.Lock
 	Set(ctx context.Context, key string, value []byte, expiration time.Duration) error
+	SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error)
 	Del(ctx context.Context, key string) error
+	SAdd(ctx context.Context, key string, member string, expiration time.Duration) error
+	SMembers(ctx context.Context, key string) ([]string, error)
 	Ping(ctx context.Context) error
 }
 
@@ -37,10 +40,27 @@ func (c *client) Set(ctx context.Context, key string, value []byte, expiration t
 	return c.Client.Set(ctx, key, value, expiration).Err()
 }
 
+func (c *client) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	return c.Client.SetNX(ctx, key, value, expiration).Result()
+}
+
 func (c *client) Del(ctx context.Context, key string) error {
 	return c.Client.Del(ctx, key).Err()
 }
 
+func (c *client) SAdd(ctx context.Context, key string, member string, expiration time.Duration) error {
+	_, err := c.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, key, member)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+func (c *client) SMembers(ctx context.Context, key string) ([]string, error) {
+	return c.Client.SMembers(ctx, key).Result()
+}
+
 func (c *client) Lock(key string) sessions.Lock {
 	return NewLock(c.Client, key)
 }
@@ -69,10 +89,27 @@ func (c *clusterClient) Set(ctx context.Context, key string, value []byte, expir
 	return c.ClusterClient.Set(ctx, key, value, expiration).Err()
 }
 
+func (c *clusterClient) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	return c.ClusterClient.SetNX(ctx, key, value, expiration).Result()
+}
+
 func (c *clusterClient) Del(ctx context.Context, key string) error {
 	return c.ClusterClient.Del(ctx, key).Err()
 }
 
+func (c *clusterClient) SAdd(ctx context.Context, key string, member string, expiration time.Duration) error {
+	_, err := c.ClusterClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, key, member)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+func (c *clusterClient) SMembers(ctx context.Context, key string) ([]string, error) {
+	return c.ClusterClient.SMembers(ctx, key).Result()
+}
+
 func (c *clusterClient) Lock(key string) sessions.Lock {
 	return NewLock(c.ClusterClient, key)
 }
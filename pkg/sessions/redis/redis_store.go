@@ -68,6 +68,33 @@ func (store *SessionStore) Clear(ctx context.Context, key string) error {
 	return nil
 }
 
+// SaveIfAbsent stores the value in redis only when the key does not exist yet
+func (store *SessionStore) SaveIfAbsent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error) {
+	saved, err := store.Client.SetNX(ctx, key, value, exp)
+	if err != nil {
+		return false, fmt.Errorf("error saving to redis: %v", err)
+	}
+	return saved, nil
+}
+
+// AddToIndex adds a session key to a redis set used as a secondary index
+func (store *SessionStore) AddToIndex(ctx context.Context, index string, key string, exp time.Duration) error {
+	err := store.Client.SAdd(ctx, index, key, exp)
+	if err != nil {
+		return fmt.Errorf("error adding session to redis index: %v", err)
+	}
+	return nil
+}
+
+// LoadIndex reads all session keys stored in a secondary index
+func (store *SessionStore) LoadIndex(ctx context.Context, index string) ([]string, error) {
+	keys, err := store.Client.SMembers(ctx, index)
+	if err != nil {
+		return nil, fmt.Errorf("error loading redis index: %v", err)
+	}
+	return keys, nil
+}
+
 // Lock creates a lock object for sessions.SessionState
 func (store *SessionStore) Lock(key string) sessions.Lock {
 	return store.Client.Lock(key)
@@ -14,10 +14,17 @@ type entry struct {
 	expiration time.Duration
 }
 
+// indexEntry is a MockStore secondary index entry with an expiration
+type indexEntry struct {
+	keys       []string
+	expiration time.Duration
+}
+
 // MockStore is a generic in-memory implementation of persistence.Store
 // for mocking in tests
 type MockStore struct {
 	cache     map[string]entry
+	indexes   map[string]indexEntry
 	lockCache map[string]*MockLock
 	elapsed   time.Duration
 }
@@ -26,6 +33,7 @@ type MockStore struct {
 func NewMockStore() *MockStore {
 	return &MockStore{
 		cache:     map[string]entry{},
+		indexes:   map[string]indexEntry{},
 		lockCache: map[string]*MockLock{},
 		elapsed:   0 * time.Second,
 	}
@@ -50,12 +58,51 @@ func (s *MockStore) Load(_ context.Context, key string) ([]byte, error) {
 	return entry.data, nil
 }
 
-// Clear deletes an entry from the memory cache
+// SaveIfAbsent sets a key to the data in the memory cache unless it already
+// holds an entry that has not expired
+func (s *MockStore) SaveIfAbsent(_ context.Context, key string, value []byte, exp time.Duration) (bool, error) {
+	if entry, ok := s.cache[key]; ok && entry.expiration > s.elapsed {
+		return false, nil
+	}
+	s.cache[key] = entry{
+		data:       value,
+		expiration: s.elapsed + exp,
+	}
+	return true, nil
+}
+
+// Clear deletes an entry or index from the memory cache
 func (s *MockStore) Clear(_ context.Context, key string) error {
 	delete(s.cache, key)
+	delete(s.indexes, key)
+	return nil
+}
+
+// AddToIndex adds a key to an index in the memory cache
+func (s *MockStore) AddToIndex(_ context.Context, index string, key string, exp time.Duration) error {
+	idx := s.indexes[index]
+	idx.expiration = s.elapsed + exp
+	for _, k := range idx.keys {
+		if k == key {
+			s.indexes[index] = idx
+			return nil
+		}
+	}
+	idx.keys = append(idx.keys, key)
+	s.indexes[index] = idx
 	return nil
 }
 
+// LoadIndex gets the keys of an index from the memory cache
+func (s *MockStore) LoadIndex(_ context.Context, index string) ([]string, error) {
+	idx, ok := s.indexes[index]
+	if !ok || idx.expiration <= s.elapsed {
+		delete(s.indexes, index)
+		return []string{}, nil
+	}
+	return idx.keys, nil
+}
+
 func (s *MockStore) Lock(key string) sessions.Lock {
 	if s.lockCache[key] != nil {
 		return s.lockCache[key]
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"crypto/rand"
 	"net/http"
 	"net/http/httptest"
@@ -281,6 +282,106 @@ func PersistentSessionStoreInterfaceTests(in *testInput) {
 		CheckCookieOptions(in)
 	})
 
+	// Check that sessions can be revoked without the ticket cookie
+	Context("when RevokeSessions is called on a persistent store", func() {
+		var revoker sessionsapi.SessionRevoker
+
+		BeforeEach(func() {
+			var ok bool
+			revoker, ok = in.ss().(sessionsapi.SessionRevoker)
+			Expect(ok).To(BeTrue())
+
+			in.session.ProviderID = "provider"
+			in.session.SID = "sid"
+			in.session.Subject = "sub"
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			resp := httptest.NewRecorder()
+			Expect(in.ss().Save(resp, req, in.session)).To(Succeed())
+
+			for _, cookie := range resp.Result().Cookies() {
+				in.request.AddCookie(cookie)
+			}
+		})
+
+		It("revokes the session matching the sid", func() {
+			Expect(revoker.RevokeSessions(context.Background(), "provider", "sid", "")).To(Succeed())
+
+			loadedSession, err := in.ss().Load(in.request)
+			Expect(err).To(HaveOccurred())
+			Expect(loadedSession).To(BeNil())
+		})
+
+		It("revokes the session matching the sub", func() {
+			Expect(revoker.RevokeSessions(context.Background(), "provider", "", "sub")).To(Succeed())
+
+			loadedSession, err := in.ss().Load(in.request)
+			Expect(err).To(HaveOccurred())
+			Expect(loadedSession).To(BeNil())
+		})
+
+		It("keeps sessions whose user only matches the sub", func() {
+			Expect(revoker.RevokeSessions(context.Background(), "provider", "", in.session.User)).To(Succeed())
+
+			loadedSession, err := in.ss().Load(in.request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loadedSession.Subject).To(Equal("sub"))
+		})
+
+		It("keeps sessions of other providers", func() {
+			Expect(revoker.RevokeSessions(context.Background(), "other", "sid", "")).To(Succeed())
+
+			loadedSession, err := in.ss().Load(in.request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loadedSession.SID).To(Equal("sid"))
+		})
+
+		It("keeps sessions with a different sid", func() {
+			Expect(revoker.RevokeSessions(context.Background(), "provider", "other", "sub")).To(Succeed())
+
+			loadedSession, err := in.ss().Load(in.request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loadedSession.SID).To(Equal("sid"))
+		})
+	})
+
+	// Check that single use identifiers are detected across the store
+	Context("when MarkUsed is called on a persistent store", func() {
+		var tracker sessionsapi.ReplayTracker
+
+		BeforeEach(func() {
+			var ok bool
+			tracker, ok = in.ss().(sessionsapi.ReplayTracker)
+			Expect(ok).To(BeTrue())
+		})
+
+		It("reports the first use only", func() {
+			unused, err := tracker.MarkUsed(context.Background(), "id", time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unused).To(BeTrue())
+
+			unused, err = tracker.MarkUsed(context.Background(), "id", time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unused).To(BeFalse())
+
+			unused, err = tracker.MarkUsed(context.Background(), "other", time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unused).To(BeTrue())
+		})
+
+		It("forgets identifiers after they expire", func() {
+			unused, err := tracker.MarkUsed(context.Background(), "id", time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unused).To(BeTrue())
+
+			Expect(in.persistentFastForward(2 * time.Minute)).To(Succeed())
+
+			unused, err = tracker.MarkUsed(context.Background(), "id", time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unused).To(BeTrue())
+		})
+	})
+
 	// Test TTLs and cleanup of persistent session storage
 	// For non-persistent we rely on the browser cookie lifecycle
 	Context("when Load is called on a persistent store", func() {
@@ -0,0 +1,112 @@
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+)
+
+// authorizationRule is a compiled options.AuthorizationRule
+type authorizationRule struct {
+	path    *regexp.Regexp
+	methods []string
+
+	allowedGroups       []string
+	allowedEmails       []string
+	allowedEmailDomains []string
+	allowedClaims       []options.AllowedClaim
+}
+
+// newAuthorizationRules compiles the authorization rules of an upstream
+func newAuthorizationRules(rules []options.AuthorizationRule) ([]authorizationRule, error) {
+	compiled := make([]authorizationRule, 0, len(rules))
+	for i, rule := range rules {
+		r := authorizationRule{
+			allowedGroups:       rule.AllowedGroups,
+			allowedEmailDomains: rule.AllowedEmailDomains,
+			allowedClaims:       rule.AllowedClaims,
+		}
+		if rule.Path != "" {
+			path, err := regexp.Compile(rule.Path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q for authorization rule %d: %v", rule.Path, i, err)
+			}
+			r.path = path
+		}
+		for _, method := range rule.Methods {
+			r.methods = append(r.methods, strings.ToUpper(method))
+		}
+		for _, email := range rule.AllowedEmails {
+			r.allowedEmails = append(r.allowedEmails, strings.ToLower(email))
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// authorize applies the first of the rules that matches the request.
+// Requests that match no rule are allowed.
+func authorize(rules []authorizationRule, req *http.Request, session *sessionsapi.SessionState) bool {
+	for _, rule := range rules {
+		if rule.matches(req) {
+			return rule.allows(session)
+		}
+	}
+	return true
+}
+
+// matches returns whether the rule applies to the request
+func (r authorizationRule) matches(req *http.Request) bool {
+	if r.path != nil && !r.path.MatchString(req.URL.Path) {
+		return false
+	}
+	return len(r.methods) == 0 || slices.Contains(r.methods, req.Method)
+}
+
+// allows returns whether the session satisfies all of the restrictions of
+// the rule
+func (r authorizationRule) allows(session *sessionsapi.SessionState) bool {
+	if len(r.allowedGroups) > 0 && !slices.ContainsFunc(session.Groups, func(group string) bool {
+		return slices.Contains(r.allowedGroups, group)
+	}) {
+		return false
+	}
+
+	if len(r.allowedEmails) > 0 && !slices.Contains(r.allowedEmails, strings.ToLower(session.Email)) {
+		return false
+	}
+
+	if len(r.allowedEmailDomains) > 0 && !isEmailDomainAllowed(session.Email, r.allowedEmailDomains) {
+		return false
+	}
+
+	for _, claim := range r.allowedClaims {
+		if !slices.ContainsFunc(session.GetClaim(claim.Claim), func(value string) bool {
+			return slices.Contains(claim.Values, value)
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isEmailDomainAllowed returns whether the domain of the email is one of the
+// allowed domains, or one of their subdomains for domains prefixed with `.`
+// or `*.`
+func isEmailDomainAllowed(email string, allowedDomains []string) bool {
+	splitEmail := strings.Split(email, "@")
+	if len(splitEmail) != 2 {
+		return false
+	}
+
+	endpoint := &url.URL{Host: strings.ToLower(splitEmail[1])}
+	return util.IsEndpointAllowed(endpoint, allowedDomains)
+}
@@ -0,0 +1,203 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authorization Suite", func() {
+	admin := &sessionsapi.SessionState{
+		Email:  "admin@example.com",
+		Groups: []string{"admins", "users"},
+		AdditionalClaims: map[string]interface{}{
+			"department": "it",
+		},
+	}
+	user := &sessionsapi.SessionState{
+		Email:  "user@sub.example.com",
+		Groups: []string{"users"},
+		AdditionalClaims: map[string]interface{}{
+			"department": []interface{}{"sales", "marketing"},
+		},
+	}
+	other := &sessionsapi.SessionState{
+		Email: "other@example.org",
+	}
+
+	upstreams := options.UpstreamConfig{
+		Upstreams: []options.Upstream{
+			{
+				ID:     "grafana",
+				Path:   "/grafana/",
+				Static: ptr.To(true),
+				Authorization: []options.AuthorizationRule{
+					{
+						AllowedGroups: []string{"users"},
+					},
+				},
+			},
+			{
+				ID:     "admin",
+				Path:   "/admin/",
+				Static: ptr.To(true),
+				Authorization: []options.AuthorizationRule{
+					{
+						Path: "^/admin/public/",
+					},
+					{
+						Path:          "^/admin/",
+						AllowedGroups: []string{"admins"},
+						AllowedClaims: []options.AllowedClaim{
+							{Claim: "department", Values: []string{"it"}},
+						},
+					},
+				},
+			},
+			{
+				ID:            "reports",
+				Path:          "^/reports/(.*)$",
+				RewriteTarget: "/$1",
+				Static:        ptr.To(true),
+				Authorization: []options.AuthorizationRule{
+					{
+						Methods:       []string{"post", "delete"},
+						AllowedEmails: []string{"Admin@example.com"},
+					},
+					{
+						AllowedEmailDomains: []string{".example.com"},
+					},
+				},
+			},
+			{
+				ID:     "public",
+				Path:   "/",
+				Static: ptr.To(true),
+			},
+		},
+	}
+
+	type authorizeTableInput struct {
+		method           string
+		target           string
+		session          *sessionsapi.SessionState
+		expectedUpstream string
+		expectedAllowed  bool
+	}
+
+	DescribeTable("Proxy Authorize",
+		func(in authorizeTableInput) {
+			proxy, err := NewProxy(upstreams, nil, &pagewriter.WriterFuncs{}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			req := httptest.NewRequest(in.method, in.target, nil)
+			upstream, allowed := proxy.Authorize(req, in.session)
+			Expect(upstream).To(Equal(in.expectedUpstream))
+			Expect(allowed).To(Equal(in.expectedAllowed))
+		},
+		Entry("with a member of the allowed groups", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/grafana/dashboards",
+			session:          user,
+			expectedUpstream: "grafana",
+			expectedAllowed:  true,
+		}),
+		Entry("without a member of the allowed groups", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/grafana/dashboards",
+			session:          other,
+			expectedUpstream: "grafana",
+			expectedAllowed:  false,
+		}),
+		Entry("with a user with the allowed groups and claims", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/admin/users",
+			session:          admin,
+			expectedUpstream: "admin",
+			expectedAllowed:  true,
+		}),
+		Entry("with a user without the allowed groups", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/admin/users",
+			session:          user,
+			expectedUpstream: "admin",
+			expectedAllowed:  false,
+		}),
+		Entry("with a user with the allowed groups without the allowed claims", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/admin/users",
+			session:          &sessionsapi.SessionState{Groups: []string{"admins"}, AdditionalClaims: map[string]interface{}{"department": "sales"}},
+			expectedUpstream: "admin",
+			expectedAllowed:  false,
+		}),
+		Entry("with a rule without restrictions", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/admin/public/status",
+			session:          other,
+			expectedUpstream: "admin",
+			expectedAllowed:  true,
+		}),
+		Entry("with a rewritten path and an allowed email domain", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/reports/monthly",
+			session:          user,
+			expectedUpstream: "reports",
+			expectedAllowed:  true,
+		}),
+		Entry("with a rewritten path and a forbidden email domain", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/reports/monthly",
+			session:          other,
+			expectedUpstream: "reports",
+			expectedAllowed:  false,
+		}),
+		Entry("with a method rule and an allowed email", authorizeTableInput{
+			method:           http.MethodDelete,
+			target:           "/reports/monthly",
+			session:          admin,
+			expectedUpstream: "reports",
+			expectedAllowed:  true,
+		}),
+		Entry("with a method rule and a forbidden email", authorizeTableInput{
+			method:           http.MethodPost,
+			target:           "/reports/monthly",
+			session:          user,
+			expectedUpstream: "reports",
+			expectedAllowed:  false,
+		}),
+		Entry("with an upstream without rules", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/index.html",
+			session:          other,
+			expectedUpstream: "public",
+			expectedAllowed:  true,
+		}),
+		Entry("with the path of an upstream without its trailing slash", authorizeTableInput{
+			method:           http.MethodGet,
+			target:           "/grafana",
+			session:          other,
+			expectedUpstream: "public",
+			expectedAllowed:  true,
+		}),
+	)
+
+	It("returns an error for an invalid path", func() {
+		_, err := NewProxy(options.UpstreamConfig{
+			Upstreams: []options.Upstream{
+				{
+					ID:            "admin",
+					Path:          "/admin/",
+					Static:        ptr.To(true),
+					Authorization: []options.AuthorizationRule{{Path: "^/admin/(users"}},
+				},
+			},
+		}, nil, &pagewriter.WriterFuncs{}, nil)
+		Expect(err).To(MatchError("could not load authorization rules of upstream \"admin\": invalid path \"^/admin/(users\" for authorization rule 0: error parsing regexp: missing closing ): `^/admin/(users`"))
+	})
+})
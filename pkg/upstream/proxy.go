@@ -2,6 +2,7 @@ package upstream
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -12,6 +13,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
@@ -21,11 +23,25 @@ import (
 // HTTP proxies fail to connect to upstream servers.
 type ProxyErrorHandler func(http.ResponseWriter, *http.Request, error)
 
+// Proxy serves requests directed to multiple upstreams.
+type Proxy interface {
+	http.Handler
+
+	// Authorize checks the session against the authorization rules of the
+	// upstream the request is directed to. It returns the ID of the upstream,
+	// which is empty when the request isn't directed to an upstream, and
+	// whether the session is allowed to access it.
+	Authorize(req *http.Request, session *sessionsapi.SessionState) (string, bool)
+}
+
 // NewProxy creates a new multiUpstreamProxy that can serve requests directed to
 // multiple upstreams.
-func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData, writer pagewriter.Writer) (http.Handler, error) {
+// The exchanger is used by upstreams with a token exchange and may be nil when
+// none is configured.
+func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData, writer pagewriter.Writer, exchanger TokenExchanger) (Proxy, error) {
 	m := &multiUpstreamProxy{
-		serveMux: mux.NewRouter(),
+		serveMux:           mux.NewRouter(),
+		authorizationRules: make(map[string][]authorizationRule),
 	}
 
 	if ptr.Deref(upstreams.ProxyRawPath, options.DefaultUpstreamProxyRawPath) {
@@ -33,6 +49,12 @@ func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData,
 	}
 
 	for _, upstream := range sortByPathLongest(upstreams.Upstreams) {
+		rules, err := newAuthorizationRules(upstream.Authorization)
+		if err != nil {
+			return nil, fmt.Errorf("could not load authorization rules of upstream %q: %v", upstream.ID, err)
+		}
+		m.authorizationRules[upstream.ID] = rules
+
 		if ptr.Deref(upstream.Static, options.DefaultUpstreamStatic) {
 			if err := m.registerStaticResponseHandler(upstream, writer); err != nil {
 				return nil, fmt.Errorf("could not register static upstream %q: %v", upstream.ID, err)
@@ -50,7 +72,7 @@ func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData,
 				return nil, fmt.Errorf("could not register file upstream %q: %v", upstream.ID, err)
 			}
 		case httpScheme, httpsScheme, unixScheme:
-			if err := m.registerHTTPUpstreamProxy(upstream, u, sigData, writer); err != nil {
+			if err := m.registerHTTPUpstreamProxy(upstream, u, sigData, writer, exchanger); err != nil {
 				return nil, fmt.Errorf("could not register %s upstream %q: %v", u.Scheme, upstream.ID, err)
 			}
 		default:
@@ -66,6 +88,9 @@ func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData,
 // registered in the serverMux.
 type multiUpstreamProxy struct {
 	serveMux *mux.Router
+
+	// authorizationRules are the authorization rules of the upstreams by ID
+	authorizationRules map[string][]authorizationRule
 }
 
 // ServerHTTP handles HTTP requests.
@@ -73,6 +98,19 @@ func (m *multiUpstreamProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request
 	m.serveMux.ServeHTTP(rw, req)
 }
 
+// Authorize checks the session against the authorization rules of the
+// upstream the request is directed to.
+// The routes of the upstreams are named with their IDs.
+func (m *multiUpstreamProxy) Authorize(req *http.Request, session *sessionsapi.SessionState) (string, bool) {
+	match := &mux.RouteMatch{}
+	if !m.serveMux.Match(req, match) || match.Route == nil || match.Route.GetName() == "" {
+		return "", true
+	}
+
+	upstreamID := match.Route.GetName()
+	return upstreamID, authorize(m.authorizationRules[upstreamID], req, session)
+}
+
 // registerStaticResponseHandler registers a static response handler with at the given path.
 func (m *multiUpstreamProxy) registerStaticResponseHandler(upstream options.Upstream, writer pagewriter.Writer) error {
 	logger.Printf("mapping path %q => static response %d", upstream.Path, ptr.Deref(upstream.StaticCode, options.DefaultUpstreamStaticCode))
@@ -86,15 +124,22 @@ func (m *multiUpstreamProxy) registerFileServer(upstream options.Upstream, u *ur
 }
 
 // registerHTTPUpstreamProxy registers a new httpUpstreamProxy based on the configuration given.
-func (m *multiUpstreamProxy) registerHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, writer pagewriter.Writer) error {
+func (m *multiUpstreamProxy) registerHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, writer pagewriter.Writer, exchanger TokenExchanger) error {
 	logger.Printf("mapping path %q => upstream %q", upstream.Path, upstream.URI)
-	return m.registerHandler(upstream, newHTTPUpstreamProxy(upstream, u, sigData, writer.ProxyErrorHandler), writer)
+	handler := newHTTPUpstreamProxy(upstream, u, sigData, writer.ProxyErrorHandler)
+	if upstream.TokenExchange != nil {
+		if exchanger == nil {
+			return errors.New("token exchange is not available")
+		}
+		handler = newTokenExchangeHandler(upstream, exchanger, writer, handler)
+	}
+	return m.registerHandler(upstream, handler, writer)
 }
 
 // registerHandler ensures the given handler is regiestered with the serveMux.
 func (m *multiUpstreamProxy) registerHandler(upstream options.Upstream, handler http.Handler, writer pagewriter.Writer) error {
 	if upstream.RewriteTarget == "" {
-		m.registerSimpleHandler(upstream.Path, handler)
+		m.registerSimpleHandler(upstream.Path, handler).Name(upstream.ID)
 		return nil
 	}
 
@@ -103,12 +148,11 @@ func (m *multiUpstreamProxy) registerHandler(upstream options.Upstream, handler
 
 // registerSimpleHandler maintains the behaviour of the go standard serveMux
 // by ensuring any path with a trailing `/` matches all paths under that prefix.
-func (m *multiUpstreamProxy) registerSimpleHandler(path string, handler http.Handler) {
+func (m *multiUpstreamProxy) registerSimpleHandler(path string, handler http.Handler) *mux.Route {
 	if strings.HasSuffix(path, "/") {
-		m.serveMux.PathPrefix(path).Handler(handler)
-	} else {
-		m.serveMux.Path(path).Handler(handler)
+		return m.serveMux.PathPrefix(path).Handler(handler)
 	}
+	return m.serveMux.Path(path).Handler(handler)
 }
 
 // registerRewriteHandler ensures the handler is registered for all paths
@@ -125,7 +169,7 @@ func (m *multiUpstreamProxy) registerRewriteHandler(upstream options.Upstream, h
 	h := alice.New(rewrite).Then(handler)
 	m.serveMux.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
 		return rewriteRegExp.MatchString(req.URL.Path)
-	}).Handler(h)
+	}).Handler(h).Name(upstream.ID)
 
 	return nil
 }
@@ -0,0 +1,164 @@
+package upstream
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrTokenExchangeDenied is wrapped by the errors of a TokenExchanger when the
+// provider refuses to issue a token for the upstream. The request is then
+// rejected with a forbidden error rather than a bad gateway error.
+var ErrTokenExchangeDenied = errors.New("token exchange denied")
+
+const (
+	// maxExchangedTokens bounds the memory used by the exchanged tokens of an
+	// upstream, as there is one per session.
+	maxExchangedTokens = 10000
+
+	// tokenExchangeTimeout bounds an exchange shared by concurrent requests,
+	// which must not be cancelled when the request that started it is.
+	tokenExchangeTimeout = 30 * time.Second
+)
+
+// TokenExchanger exchanges the access token of the session for a token issued
+// for the audience and resource of an upstream (RFC 8693).
+type TokenExchanger func(ctx context.Context, session *sessionsapi.SessionState, exchange options.TokenExchange) (*oauth2.Token, error)
+
+// newTokenExchangeHandler creates a handler that exchanges the access token of
+// the session and injects the exchanged token in the Authorization header
+// before passing the request to the next handler.
+func newTokenExchangeHandler(upstream options.Upstream, exchanger TokenExchanger, writer pagewriter.Writer, next http.Handler) http.Handler {
+	return &tokenExchangeHandler{
+		upstream:  upstream.ID,
+		exchange:  *upstream.TokenExchange,
+		exchanger: exchanger,
+		writer:    writer,
+		next:      next,
+		tokens:    make(map[string]*oauth2.Token),
+	}
+}
+
+// tokenExchangeHandler exchanges tokens for a single upstream. Exchanged
+// tokens are cached per session until they expire, up to maxExchangedTokens.
+type tokenExchangeHandler struct {
+	upstream  string
+	exchange  options.TokenExchange
+	exchanger TokenExchanger
+	writer    pagewriter.Writer
+	next      http.Handler
+
+	group  singleflight.Group
+	mutex  sync.Mutex
+	tokens map[string]*oauth2.Token
+}
+
+// ServeHTTP injects the exchanged token for requests with a session.
+// Requests without a session, e.g. to routes that skip authentication, are
+// passed on unchanged.
+func (h *tokenExchangeHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	scope := middleware.GetRequestScope(req)
+	if scope == nil || scope.Session == nil {
+		h.next.ServeHTTP(rw, req)
+		return
+	}
+
+	token, err := h.getToken(req.Context(), scope.Session)
+	if err != nil {
+		logger.Errorf("Error exchanging access token for upstream %q: %v", h.upstream, err)
+		status := http.StatusBadGateway
+		message := "There was a problem obtaining an access token for the upstream server."
+		if errors.Is(err, ErrTokenExchangeDenied) {
+			status = http.StatusForbidden
+			message = "You do not have permission to access this upstream server."
+		}
+		h.writer.WriteErrorPage(rw, pagewriter.ErrorPageOpts{
+			Status:    status,
+			RequestID: scope.RequestID,
+			AppError:  err.Error(),
+			Messages:  []interface{}{message},
+		})
+		return
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	h.next.ServeHTTP(rw, req)
+}
+
+// getToken returns the cached token for the session or exchanges the access
+// token of the session. Concurrent requests of a session share one exchange.
+func (h *tokenExchangeHandler) getToken(ctx context.Context, session *sessionsapi.SessionState) (*oauth2.Token, error) {
+	if session.AccessToken == "" {
+		return nil, fmt.Errorf("%w: the session has no access token", ErrTokenExchangeDenied)
+	}
+
+	sum := sha256.Sum256([]byte(session.AccessToken))
+	key := hex.EncodeToString(sum[:])
+	if token, ok := h.cachedToken(key); ok {
+		return token, nil
+	}
+
+	token, err, _ := h.group.Do(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenExchangeTimeout)
+		defer cancel()
+
+		token, err := h.exchanger(ctx, session, h.exchange)
+		if err != nil {
+			return nil, err
+		}
+		h.cacheToken(key, token)
+		return token, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token.(*oauth2.Token), nil
+}
+
+func (h *tokenExchangeHandler) cachedToken(key string) (*oauth2.Token, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	token, ok := h.tokens[key]
+	if !ok || !token.Valid() {
+		return nil, false
+	}
+	return token, true
+}
+
+// cacheToken stores tokens with an expiry. Expired tokens are removed when
+// the cache is full, and when none has expired the token is not cached.
+// Tokens without an expiry are exchanged again on every request.
+func (h *tokenExchangeHandler) cacheToken(key string, token *oauth2.Token) {
+	if token.Expiry.IsZero() {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, exists := h.tokens[key]; !exists && len(h.tokens) >= maxExchangedTokens {
+		for k, t := range h.tokens {
+			if !t.Valid() {
+				delete(h.tokens, k)
+			}
+		}
+		if len(h.tokens) >= maxExchangedTokens {
+			return
+		}
+	}
+	h.tokens[key] = token
+}
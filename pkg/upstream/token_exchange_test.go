@@ -0,0 +1,192 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+)
+
+var _ = Describe("Token Exchange Suite", func() {
+	const upstreamID = "token-exchange-backend"
+
+	var (
+		exchanges       int
+		exchanger       TokenExchanger
+		exchangedTokens []options.TokenExchange
+	)
+
+	BeforeEach(func() {
+		exchanges = 0
+		exchangedTokens = nil
+		exchanger = func(_ context.Context, session *sessionsapi.SessionState, exchange options.TokenExchange) (*oauth2.Token, error) {
+			exchanges++
+			exchangedTokens = append(exchangedTokens, exchange)
+			switch session.AccessToken {
+			case "denied":
+				return nil, fmt.Errorf("%w: invalid_target", ErrTokenExchangeDenied)
+			case "unavailable":
+				return nil, errors.New("unexpected status 503 from token endpoint")
+			case "no-expiry":
+				return &oauth2.Token{AccessToken: "exchanged-" + session.AccessToken}, nil
+			}
+			return &oauth2.Token{
+				AccessToken: "exchanged-" + session.AccessToken,
+				Expiry:      time.Now().Add(time.Hour),
+			}, nil
+		}
+	})
+
+	serve := func(handler http.Handler, session *sessionsapi.SessionState) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("", "/", nil)
+		req.Header.Set("Authorization", "Bearer original")
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{Session: session})
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	newHandler := func(authorization *string) http.Handler {
+		upstream := options.Upstream{
+			ID: upstreamID,
+			TokenExchange: &options.TokenExchange{
+				Audience: "backend",
+				Scopes:   []string{"read"},
+			},
+		}
+		return newTokenExchangeHandler(upstream, exchanger, &pagewriter.WriterFuncs{}, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			*authorization = req.Header.Get("Authorization")
+			rw.WriteHeader(http.StatusOK)
+		}))
+	}
+
+	It("injects the exchanged token", func() {
+		var authorization string
+		rw := serve(newHandler(&authorization), &sessionsapi.SessionState{AccessToken: "user-token"})
+
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(authorization).To(Equal("Bearer exchanged-user-token"))
+		Expect(exchangedTokens).To(Equal([]options.TokenExchange{{Audience: "backend", Scopes: []string{"read"}}}))
+	})
+
+	It("caches the exchanged token per session until it expires", func() {
+		var authorization string
+		handler := newHandler(&authorization)
+
+		serve(handler, &sessionsapi.SessionState{AccessToken: "user-token"})
+		serve(handler, &sessionsapi.SessionState{AccessToken: "user-token"})
+		Expect(exchanges).To(Equal(1))
+
+		rw := serve(handler, &sessionsapi.SessionState{AccessToken: "other-user-token"})
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(authorization).To(Equal("Bearer exchanged-other-user-token"))
+		Expect(exchanges).To(Equal(2))
+	})
+
+	It("does not cache tokens without an expiry", func() {
+		var authorization string
+		handler := newHandler(&authorization)
+
+		serve(handler, &sessionsapi.SessionState{AccessToken: "no-expiry"})
+		serve(handler, &sessionsapi.SessionState{AccessToken: "no-expiry"})
+		Expect(exchanges).To(Equal(2))
+		Expect(authorization).To(Equal("Bearer exchanged-no-expiry"))
+	})
+
+	It("does not cache tokens when the cache is full of unexpired tokens", func() {
+		var authorization string
+		handler := newHandler(&authorization).(*tokenExchangeHandler)
+		for i := 0; i < maxExchangedTokens; i++ {
+			handler.tokens[fmt.Sprintf("session-%d", i)] = &oauth2.Token{AccessToken: "cached", Expiry: time.Now().Add(time.Hour)}
+		}
+
+		serve(handler, &sessionsapi.SessionState{AccessToken: "user-token"})
+		serve(handler, &sessionsapi.SessionState{AccessToken: "user-token"})
+		Expect(exchanges).To(Equal(2))
+		Expect(authorization).To(Equal("Bearer exchanged-user-token"))
+		Expect(handler.tokens).To(HaveLen(maxExchangedTokens))
+	})
+
+	It("removes expired tokens when the cache is full", func() {
+		var authorization string
+		handler := newHandler(&authorization).(*tokenExchangeHandler)
+		for i := 0; i < maxExchangedTokens; i++ {
+			handler.tokens[fmt.Sprintf("session-%d", i)] = &oauth2.Token{AccessToken: "cached", Expiry: time.Now().Add(-time.Hour)}
+		}
+
+		serve(handler, &sessionsapi.SessionState{AccessToken: "user-token"})
+		serve(handler, &sessionsapi.SessionState{AccessToken: "user-token"})
+		Expect(exchanges).To(Equal(1))
+		Expect(handler.tokens).To(HaveLen(1))
+	})
+
+	It("does not cancel the exchange when the request that started it is cancelled", func() {
+		var exchangeErr error
+		exchanger = func(ctx context.Context, session *sessionsapi.SessionState, _ options.TokenExchange) (*oauth2.Token, error) {
+			exchangeErr = ctx.Err()
+			_, hasDeadline := ctx.Deadline()
+			Expect(hasDeadline).To(BeTrue())
+			return &oauth2.Token{AccessToken: "exchanged-" + session.AccessToken, Expiry: time.Now().Add(time.Hour)}, nil
+		}
+		var authorization string
+		handler := newHandler(&authorization)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest("", "/", nil).WithContext(ctx)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{Session: &sessionsapi.SessionState{AccessToken: "user-token"}})
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		Expect(exchangeErr).ToNot(HaveOccurred())
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(authorization).To(Equal("Bearer exchanged-user-token"))
+	})
+
+	It("passes requests without a session on unchanged", func() {
+		var authorization string
+		rw := serve(newHandler(&authorization), nil)
+
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(authorization).To(Equal("Bearer original"))
+		Expect(exchanges).To(Equal(0))
+	})
+
+	type errorTableInput struct {
+		accessToken  string
+		expectedCode int
+	}
+
+	DescribeTable("with an exchange error",
+		func(in errorTableInput) {
+			var authorization string
+			rw := serve(newHandler(&authorization), &sessionsapi.SessionState{AccessToken: in.accessToken})
+
+			Expect(rw.Code).To(Equal(in.expectedCode))
+			Expect(authorization).To(BeEmpty())
+		},
+		Entry("when the provider denies the exchange", errorTableInput{
+			accessToken:  "denied",
+			expectedCode: http.StatusForbidden,
+		}),
+		Entry("when the provider is unavailable", errorTableInput{
+			accessToken:  "unavailable",
+			expectedCode: http.StatusBadGateway,
+		}),
+		Entry("when the session has no access token", errorTableInput{
+			accessToken:  "",
+			expectedCode: http.StatusForbidden,
+		}),
+	)
+})
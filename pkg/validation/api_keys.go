@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateAPIKeys validates the static API keys, when they are configured
+func validateAPIKeys(config options.APIKeyOptions) []string {
+	msgs := []string{}
+	for i, key := range config.Keys {
+		msgs = append(msgs, prefixValues(fmt.Sprintf("apiKeys: key %d: ", i), validateAPIKey(key)...)...)
+	}
+
+	if config.KeysFile != "" {
+		if _, err := os.Stat(config.KeysFile); err != nil {
+			msgs = append(msgs, fmt.Sprintf("apiKeys: invalid keysFile: %v", err))
+		}
+	}
+
+	return msgs
+}
+
+func validateAPIKey(key options.APIKey) []string {
+	msgs := []string{}
+	switch {
+	case key.Key != nil && key.KeyHash != "":
+		msgs = append(msgs, "only one of key or keyHash can be set")
+	case key.Key != nil:
+		if msg := validateSecretSource(*key.Key); msg != "" {
+			msgs = append(msgs, "invalid key: "+msg)
+		}
+	case key.KeyHash != "":
+		if hash, err := hex.DecodeString(key.KeyHash); err != nil || len(hash) != sha256.Size {
+			msgs = append(msgs, "invalid keyHash: must be a hex encoded SHA-256 hash")
+		}
+	default:
+		msgs = append(msgs, "missing setting: key or keyHash")
+	}
+
+	if key.User == "" && key.Email == "" {
+		msgs = append(msgs, "missing setting: user or email")
+	}
+
+	if key.ExpiresAt != "" {
+		if _, err := time.Parse(time.RFC3339, key.ExpiresAt); err != nil {
+			msgs = append(msgs, fmt.Sprintf("invalid expiresAt %q: must be in RFC 3339 format", key.ExpiresAt))
+		}
+	}
+
+	return msgs
+}
@@ -0,0 +1,114 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("API Keys", func() {
+	type validateAPIKeysTableInput struct {
+		config     options.APIKeyOptions
+		errStrings []string
+	}
+
+	// sha256("key")
+	const keyHash = "2c70e12b7a0646f92279f427c7b38e7334d8e5389cff167a1dc30e73f826b683"
+
+	keysFile := filepath.Join(os.TempDir(), "api-keys-validation.yaml")
+	BeforeEach(func() {
+		Expect(os.WriteFile(keysFile, []byte("[]"), 0600)).To(Succeed())
+		DeferCleanup(os.Remove, keysFile)
+	})
+
+	DescribeTable("validateAPIKeys",
+		func(in validateAPIKeysTableInput) {
+			Expect(validateAPIKeys(in.config)).To(ConsistOf(in.errStrings))
+		},
+		Entry("without API keys", validateAPIKeysTableInput{
+			config:     options.APIKeyOptions{},
+			errStrings: []string{},
+		}),
+		Entry("with valid keys", validateAPIKeysTableInput{
+			config: options.APIKeyOptions{
+				Keys: []options.APIKey{
+					{
+						Key:  &options.SecretSource{Value: []byte("key")},
+						User: "batch",
+					},
+					{
+						KeyHash:   keyHash,
+						Email:     "webhook@example.com",
+						ExpiresAt: "2030-01-01T00:00:00Z",
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a keys file", validateAPIKeysTableInput{
+			config:     options.APIKeyOptions{KeysFile: keysFile},
+			errStrings: []string{},
+		}),
+		Entry("with a missing keys file", validateAPIKeysTableInput{
+			config: options.APIKeyOptions{KeysFile: "/does/not/exist.yaml"},
+			errStrings: []string{
+				"apiKeys: invalid keysFile: stat /does/not/exist.yaml: no such file or directory",
+			},
+		}),
+		Entry("with a key and a key hash", validateAPIKeysTableInput{
+			config: options.APIKeyOptions{
+				Keys: []options.APIKey{{
+					Key:     &options.SecretSource{Value: []byte("key")},
+					KeyHash: keyHash,
+					User:    "batch",
+				}},
+			},
+			errStrings: []string{"apiKeys: key 0: only one of key or keyHash can be set"},
+		}),
+		Entry("without a key", validateAPIKeysTableInput{
+			config: options.APIKeyOptions{
+				Keys: []options.APIKey{{User: "batch"}},
+			},
+			errStrings: []string{"apiKeys: key 0: missing setting: key or keyHash"},
+		}),
+		Entry("with an invalid key", validateAPIKeysTableInput{
+			config: options.APIKeyOptions{
+				Keys: []options.APIKey{{
+					Key:  &options.SecretSource{},
+					User: "batch",
+				}},
+			},
+			errStrings: []string{"apiKeys: key 0: invalid key: " + multipleValuesForSecretSource},
+		}),
+		Entry("with an invalid key hash", validateAPIKeysTableInput{
+			config: options.APIKeyOptions{
+				Keys: []options.APIKey{{
+					KeyHash: "key",
+					User:    "batch",
+				}},
+			},
+			errStrings: []string{"apiKeys: key 0: invalid keyHash: must be a hex encoded SHA-256 hash"},
+		}),
+		Entry("without a user or email, with an invalid expiry", validateAPIKeysTableInput{
+			config: options.APIKeyOptions{
+				Keys: []options.APIKey{
+					{
+						Key:  &options.SecretSource{Value: []byte("key")},
+						User: "batch",
+					},
+					{
+						KeyHash:   keyHash,
+						ExpiresAt: "2030-01-01",
+					},
+				},
+			},
+			errStrings: []string{
+				"apiKeys: key 1: missing setting: user or email",
+				"apiKeys: key 1: invalid expiresAt \"2030-01-01\": must be in RFC 3339 format",
+			},
+		}),
+	)
+})
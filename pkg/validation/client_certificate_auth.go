@@ -0,0 +1,36 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateClientCertificateAuth validates the authentication of clients with
+// their certificates, when it is configured
+func validateClientCertificateAuth(o *options.Options) []string {
+	config := o.ClientCertificateAuth
+	if config == nil {
+		return []string{}
+	}
+
+	msgs := []string{}
+	switch config.UserField {
+	case options.ClientCertificateCommonName, options.ClientCertificateEmail, options.ClientCertificateURI:
+	default:
+		msgs = append(msgs, fmt.Sprintf("clientCertificateAuth: invalid userField %q: must be one of commonName, email or uri", config.UserField))
+	}
+
+	switch config.GroupsField {
+	case options.ClientCertificateOrganizationalUnit, options.ClientCertificateURI:
+	default:
+		msgs = append(msgs, fmt.Sprintf("clientCertificateAuth: invalid groupsField %q: must be one of organizationalUnit or uri", config.GroupsField))
+	}
+
+	tls := o.Server.TLS
+	if tls == nil || tls.ClientCA == nil || tls.ClientAuth == options.TLSClientAuthRequest {
+		msgs = append(msgs, "clientCertificateAuth: the server must verify client certificates: set a clientCA and a clientAuth of require or verify-if-given")
+	}
+
+	return msgs
+}
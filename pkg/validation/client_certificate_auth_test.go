@@ -0,0 +1,79 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client Certificate Auth", func() {
+	type validateClientCertificateAuthTableInput struct {
+		clientCertificateAuth *options.ClientCertificateAuthOptions
+		tls                   *options.TLS
+		errStrings            []string
+	}
+
+	const serverMsg = "clientCertificateAuth: the server must verify client certificates: set a clientCA and a clientAuth of require or verify-if-given"
+
+	clientCA := &options.SecretSource{FromFile: "/etc/ssl/client-ca.pem"}
+
+	defaultConfig := func() *options.ClientCertificateAuthOptions {
+		config := &options.ClientCertificateAuthOptions{}
+		config.EnsureDefaults()
+		return config
+	}
+
+	DescribeTable("validateClientCertificateAuth",
+		func(in validateClientCertificateAuthTableInput) {
+			opts := &options.Options{
+				ClientCertificateAuth: in.clientCertificateAuth,
+				Server:                options.Server{TLS: in.tls},
+			}
+			Expect(validateClientCertificateAuth(opts)).To(ConsistOf(in.errStrings))
+		},
+		Entry("without client certificate auth", validateClientCertificateAuthTableInput{
+			clientCertificateAuth: nil,
+			tls:                   nil,
+			errStrings:            []string{},
+		}),
+		Entry("with a client CA", validateClientCertificateAuthTableInput{
+			clientCertificateAuth: defaultConfig(),
+			tls:                   &options.TLS{ClientCA: clientCA},
+			errStrings:            []string{},
+		}),
+		Entry("with verify-if-given", validateClientCertificateAuthTableInput{
+			clientCertificateAuth: &options.ClientCertificateAuthOptions{
+				UserField:   options.ClientCertificateURI,
+				GroupsField: options.ClientCertificateURI,
+			},
+			tls:        &options.TLS{ClientCA: clientCA, ClientAuth: options.TLSClientAuthVerifyIfGiven},
+			errStrings: []string{},
+		}),
+		Entry("without TLS", validateClientCertificateAuthTableInput{
+			clientCertificateAuth: defaultConfig(),
+			tls:                   nil,
+			errStrings:            []string{serverMsg},
+		}),
+		Entry("without a client CA", validateClientCertificateAuthTableInput{
+			clientCertificateAuth: defaultConfig(),
+			tls:                   &options.TLS{},
+			errStrings:            []string{serverMsg},
+		}),
+		Entry("with certificates that aren't verified", validateClientCertificateAuthTableInput{
+			clientCertificateAuth: defaultConfig(),
+			tls:                   &options.TLS{ClientCA: clientCA, ClientAuth: options.TLSClientAuthRequest},
+			errStrings:            []string{serverMsg},
+		}),
+		Entry("with invalid fields", validateClientCertificateAuthTableInput{
+			clientCertificateAuth: &options.ClientCertificateAuthOptions{
+				UserField:   options.ClientCertificateOrganizationalUnit,
+				GroupsField: options.ClientCertificateEmail,
+			},
+			tls: &options.TLS{ClientCA: clientCA},
+			errStrings: []string{
+				"clientCertificateAuth: invalid userField \"organizationalUnit\": must be one of commonName, email or uri",
+				"clientCertificateAuth: invalid groupsField \"email\": must be one of organizationalUnit or uri",
+			},
+		}),
+	)
+})
@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+)
+
+// validateLDAP validates the LDAP directory users are validated against,
+// when it is configured
+func validateLDAP(config options.LDAPOptions) []string {
+	if config.URL == "" {
+		return []string{}
+	}
+
+	msgs := []string{}
+	u, err := url.Parse(config.URL)
+	switch {
+	case err != nil:
+		msgs = append(msgs, fmt.Sprintf("invalid ldap url %q: %v", config.URL, err))
+	case u.Scheme != "ldap" && u.Scheme != "ldaps":
+		msgs = append(msgs, fmt.Sprintf("invalid ldap url %q: the scheme must be ldap or ldaps", config.URL))
+	case u.Scheme == "ldap" && !config.StartTLS:
+		msgs = append(msgs, "ldap passwords must not be sent in the clear: use an ldaps url or set startTLS")
+	}
+
+	if config.BindDN != "" {
+		if config.BindPassword == nil {
+			msgs = append(msgs, "missing setting: ldap bindPassword is required with bindDN")
+		} else if msg := validateSecretSource(*config.BindPassword); msg != "" {
+			msgs = append(msgs, "invalid ldap bindPassword: "+msg)
+		}
+	}
+
+	if config.UserBaseDN == "" {
+		msgs = append(msgs, "missing setting: ldap userBaseDN")
+	}
+	if !strings.Contains(config.UserFilter, "{username}") {
+		msgs = append(msgs, fmt.Sprintf("invalid ldap userFilter %q: it must contain {username}", config.UserFilter))
+	} else if msg := validateLDAPFilter("userFilter", config.UserFilter); msg != "" {
+		msgs = append(msgs, msg)
+	}
+	if config.GroupBaseDN != "" {
+		if msg := validateLDAPFilter("groupFilter", config.GroupFilter); msg != "" {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	if ptr.Deref(config.Timeout, options.DefaultLDAPTimeout) <= 0 {
+		msgs = append(msgs, "ldap timeout must be positive")
+	}
+	if ptr.Deref(config.CacheTTL, options.DefaultLDAPCacheTTL) < 0 {
+		msgs = append(msgs, "ldap cacheTTL must not be negative")
+	}
+
+	return msgs
+}
+
+// validateLDAPFilter checks that the filter compiles with its placeholders
+// substituted
+func validateLDAPFilter(name, filter string) string {
+	example := strings.NewReplacer("{username}", "user", "{dn}", "cn=user").Replace(filter)
+	if _, err := ldap.CompileFilter(example); err != nil {
+		return fmt.Sprintf("invalid ldap %s %q: %v", name, filter, err)
+	}
+	return ""
+}
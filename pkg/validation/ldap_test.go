@@ -0,0 +1,137 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LDAP", func() {
+	type validateLDAPTableInput struct {
+		config     options.LDAPOptions
+		errStrings []string
+	}
+
+	validConfig := func() options.LDAPOptions {
+		config := options.LDAPOptions{
+			URL:          "ldaps://ldap.example.com",
+			BindDN:       "cn=proxy,dc=example,dc=com",
+			BindPassword: &options.SecretSource{Value: []byte("password")},
+			UserBaseDN:   "ou=people,dc=example,dc=com",
+		}
+		config.EnsureDefaults()
+		return config
+	}
+
+	DescribeTable("validateLDAP",
+		func(in validateLDAPTableInput) {
+			Expect(validateLDAP(in.config)).To(ConsistOf(in.errStrings))
+		},
+		Entry("without LDAP", validateLDAPTableInput{
+			config:     options.LDAPOptions{},
+			errStrings: []string{},
+		}),
+		Entry("with a valid configuration", validateLDAPTableInput{
+			config:     validConfig(),
+			errStrings: []string{},
+		}),
+		Entry("with StartTLS", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.URL = "ldap://ldap.example.com"
+				config.StartTLS = true
+				return config
+			}(),
+			errStrings: []string{},
+		}),
+		Entry("without TLS", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.URL = "ldap://ldap.example.com"
+				return config
+			}(),
+			errStrings: []string{"ldap passwords must not be sent in the clear: use an ldaps url or set startTLS"},
+		}),
+		Entry("with an invalid scheme", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.URL = "https://ldap.example.com"
+				return config
+			}(),
+			errStrings: []string{"invalid ldap url \"https://ldap.example.com\": the scheme must be ldap or ldaps"},
+		}),
+		Entry("with an anonymous search", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.BindDN = ""
+				config.BindPassword = nil
+				return config
+			}(),
+			errStrings: []string{},
+		}),
+		Entry("without a bind password", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.BindPassword = nil
+				return config
+			}(),
+			errStrings: []string{"missing setting: ldap bindPassword is required with bindDN"},
+		}),
+		Entry("with an invalid bind password", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.BindPassword = &options.SecretSource{}
+				return config
+			}(),
+			errStrings: []string{"invalid ldap bindPassword: " + multipleValuesForSecretSource},
+		}),
+		Entry("without a user base DN", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.UserBaseDN = ""
+				return config
+			}(),
+			errStrings: []string{"missing setting: ldap userBaseDN"},
+		}),
+		Entry("with a user filter without the username", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.UserFilter = "(objectClass=person)"
+				return config
+			}(),
+			errStrings: []string{"invalid ldap userFilter \"(objectClass=person)\": it must contain {username}"},
+		}),
+		Entry("with an invalid user filter", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.UserFilter = "(&(uid={username})"
+				return config
+			}(),
+			errStrings: []string{"invalid ldap userFilter \"(&(uid={username})\": LDAP Result Code 201 \"Filter Compile Error\": ldap: unexpected end of filter"},
+		}),
+		Entry("with an invalid group filter", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.GroupBaseDN = "ou=groups,dc=example,dc=com"
+				config.GroupFilter = "member={dn}"
+				return config
+			}(),
+			errStrings: []string{"invalid ldap groupFilter \"member={dn}\": LDAP Result Code 201 \"Filter Compile Error\": ldap: filter does not start with an '('"},
+		}),
+		Entry("with invalid durations", validateLDAPTableInput{
+			config: func() options.LDAPOptions {
+				config := validConfig()
+				config.Timeout = ptr.To(time.Duration(0))
+				config.CacheTTL = ptr.To(-time.Second)
+				return config
+			}(),
+			errStrings: []string{
+				"ldap timeout must be positive",
+				"ldap cacheTTL must not be negative",
+			},
+		}),
+	)
+})
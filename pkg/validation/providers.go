@@ -3,8 +3,11 @@ package validation
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/util"
+	pkgutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
 )
 
@@ -17,14 +20,51 @@ func validateProviders(o *options.Options) []string {
 	if len(o.Providers) == 0 {
 		msgs = append(msgs, "at least one provider has to be defined")
 	}
-	if o.SkipProviderButton && len(o.Providers) > 1 {
+	if o.SkipProviderButton && len(o.Providers) > 1 && !hasLoginDomains(o.Providers) {
 		msgs = append(msgs, "SkipProviderButton and multiple providers are mutually exclusive")
 	}
 
 	providerIDs := make(map[string]struct{})
+	loginDomains := make(map[string]string)
 
 	for _, provider := range o.Providers {
 		msgs = append(msgs, validateProvider(provider, providerIDs)...)
+		msgs = append(msgs, validateLoginDomains(provider, loginDomains)...)
+
+		if ptr.Deref(provider.TokenIntrospection, options.DefaultTokenIntrospection) && !o.SkipJwtBearerTokens {
+			msgs = append(msgs, fmt.Sprintf("provider %s has tokenIntrospection enabled, but skipJwtBearerTokens is not set: this will have no effect", provider.ID))
+		}
+	}
+
+	return msgs
+}
+
+// hasLoginDomains checks if any provider can be selected by email domain,
+// which allows the sign in page to be skipped with multiple providers
+func hasLoginDomains(providers options.Providers) bool {
+	for _, provider := range providers {
+		if len(provider.LoginDomains) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLoginDomains ensures every login domain routes to a single provider
+func validateLoginDomains(provider options.Provider, loginDomains map[string]string) []string {
+	msgs := []string{}
+
+	for _, domain := range provider.LoginDomains {
+		domain = strings.ToLower(domain)
+		if domain == "" {
+			msgs = append(msgs, fmt.Sprintf("provider %s has an empty login domain", provider.ID))
+			continue
+		}
+		if owner, ok := loginDomains[domain]; ok && owner != provider.ID {
+			msgs = append(msgs, fmt.Sprintf("login domain %s is configured for providers %s and %s: login domains must be unique", domain, owner, provider.ID))
+			continue
+		}
+		loginDomains[domain] = provider.ID
 	}
 
 	return msgs
@@ -59,6 +99,46 @@ func validateProvider(provider options.Provider, providerIDs map[string]struct{}
 		msgs = append(msgs, validateEntraConfig(provider)...)
 	}
 
+	if provider.Type == options.GenericOAuth2Provider {
+		msgs = append(msgs, validateGenericOAuth2Config(provider)...)
+	}
+
+	if provider.Type == options.SAMLProvider {
+		msgs = append(msgs, validateSAMLConfig(provider)...)
+	}
+
+	if provider.Type == options.GiteaProvider {
+		msgs = append(msgs, validateGiteaConfig(provider)...)
+	}
+
+	if provider.Type == options.DiscordProvider {
+		msgs = append(msgs, validateDiscordConfig(provider)...)
+	}
+
+	if provider.Type == options.AppleProvider {
+		msgs = append(msgs, validateAppleConfig(provider)...)
+	}
+
+	if provider.Type == options.MockProvider {
+		msgs = append(msgs, validateMockConfig(provider)...)
+	}
+
+	msgs = append(msgs, validateCAFiles(provider)...)
+	msgs = append(msgs, validateClientAuthentication(provider)...)
+	msgs = append(msgs, validateClientCertificate(provider)...)
+	msgs = append(msgs, validateDPoP(provider)...)
+	msgs = append(msgs, validateDeviceAuthorizationGrant(provider)...)
+	msgs = append(msgs, validatePushedAuthorizationRequests(provider)...)
+	msgs = append(msgs, validateTokenIntrospection(provider)...)
+	msgs = append(msgs, validateClaimExpressions(provider)...)
+	msgs = append(msgs, validateRevalidateInterval(provider)...)
+
+	if ptr.Deref(provider.OIDCConfig.RPInitiatedLogout, options.DefaultRPInitiatedLogout) &&
+		ptr.Deref(provider.OIDCConfig.SkipDiscovery, options.DefaultSkipDiscovery) &&
+		provider.OIDCConfig.EndSessionURL == "" {
+		msgs = append(msgs, "missing setting: oidc endSessionURL is required for rpInitiatedLogout when discovery is skipped")
+	}
+
 	return msgs
 }
 
@@ -73,6 +153,25 @@ func providerRequiresClientSecret(provider options.Provider) bool {
 		return false
 	}
 
+	if provider.Type == options.SAMLProvider {
+		return false
+	}
+
+	// The Apple client secret is signed with the Apple private key
+	if provider.Type == options.AppleProvider {
+		return false
+	}
+
+	// The mock provider only checks the client secret when one is set
+	if provider.Type == options.MockProvider {
+		return false
+	}
+
+	switch provider.ClientAuthentication.Method {
+	case options.PrivateKeyJWTAuthentication, options.TLSClientAuthentication:
+		return false
+	}
+
 	return true
 }
 
@@ -99,6 +198,10 @@ func validateGoogleConfig(provider options.Provider) []string {
 	hasSAJSON := provider.GoogleConfig.ServiceAccountJSON != ""
 	useADC := ptr.Deref(provider.GoogleConfig.UseApplicationDefaultCredentials, options.DefaultUseApplicationDefaultCredentials)
 
+	if ptr.Deref(provider.GoogleConfig.GroupMembershipCacheTTL, options.DefaultGoogleGroupMembershipCacheTTL) < 0 {
+		msgs = append(msgs, "invalid setting: google groupMembershipCacheTTL must not be negative")
+	}
+
 	if !hasAdminEmail && !hasSAJSON && !useADC {
 		return msgs
 	}
@@ -138,5 +241,339 @@ func validateEntraConfig(provider options.Provider) []string {
 		}
 	}
 
+	switch provider.MicrosoftEntraIDConfig.GroupNameAttribute {
+	case "", options.MicrosoftEntraIDGroupDisplayName, options.MicrosoftEntraIDGroupSamAccountName:
+	default:
+		msgs = append(msgs, fmt.Sprintf("invalid entra groupNameAttribute %q, must be one of: %s, %s",
+			provider.MicrosoftEntraIDConfig.GroupNameAttribute, options.MicrosoftEntraIDGroupDisplayName, options.MicrosoftEntraIDGroupSamAccountName))
+	}
+	if ptr.Deref(provider.MicrosoftEntraIDConfig.GroupNameCacheTTL, options.DefaultMicrosoftEntraIDGroupNameCacheTTL) < 0 {
+		msgs = append(msgs, "entra groupNameCacheTTL must not be negative")
+	}
+
+	return msgs
+}
+
+func validateGenericOAuth2Config(provider options.Provider) []string {
+	msgs := []string{}
+
+	if provider.LoginURL == "" {
+		msgs = append(msgs, "missing setting for generic-oauth2 provider: login-url")
+	}
+	if provider.RedeemURL == "" {
+		msgs = append(msgs, "missing setting for generic-oauth2 provider: redeem-url")
+	}
+	if provider.ProfileURL == "" {
+		msgs = append(msgs, "missing setting for generic-oauth2 provider: profile-url")
+	}
+
+	return msgs
+}
+
+func validateClientAuthentication(provider options.Provider) []string {
+	msgs := []string{}
+	config := provider.ClientAuthentication
+
+	switch config.Method {
+	case "", options.ClientSecretAuthentication:
+		return msgs
+	case options.PrivateKeyJWTAuthentication, options.ClientSecretJWTAuthentication, options.TLSClientAuthentication:
+	default:
+		return append(msgs, fmt.Sprintf("invalid clientAuthentication method %q: must be one of client_secret, private_key_jwt, client_secret_jwt or tls_client_auth", config.Method))
+	}
+
+	if !providerIsOIDCBased(provider.Type) {
+		msgs = append(msgs, fmt.Sprintf("clientAuthentication method %q is not supported by the %s provider", config.Method, provider.Type))
+	}
+
+	switch config.Method {
+	case options.PrivateKeyJWTAuthentication:
+		if config.SigningKey == nil {
+			msgs = append(msgs, "missing setting: clientAuthentication signingKey is required for private_key_jwt")
+		} else if msg := validateSecretSource(*config.SigningKey); msg != "" {
+			msgs = append(msgs, "invalid clientAuthentication signingKey: "+msg)
+		}
+	case options.TLSClientAuthentication:
+		if provider.ClientCertificate == nil {
+			msgs = append(msgs, "missing setting: clientCertificate is required for tls_client_auth")
+		}
+	}
+
+	return msgs
+}
+
+func validateClientCertificate(provider options.Provider) []string {
+	msgs := []string{}
+	config := provider.ClientCertificate
+	if config == nil {
+		return msgs
+	}
+
+	if !providerIsOIDCBased(provider.Type) {
+		msgs = append(msgs, fmt.Sprintf("clientCertificate is not supported by the %s provider", provider.Type))
+	}
+
+	if config.Cert == nil {
+		msgs = append(msgs, "missing setting: clientCertificate cert")
+	} else if msg := validateSecretSource(*config.Cert); msg != "" {
+		msgs = append(msgs, "invalid clientCertificate cert: "+msg)
+	}
+	if config.Key == nil {
+		msgs = append(msgs, "missing setting: clientCertificate key")
+	} else if msg := validateSecretSource(*config.Key); msg != "" {
+		msgs = append(msgs, "invalid clientCertificate key: "+msg)
+	}
+
+	return msgs
+}
+
+func validateDPoP(provider options.Provider) []string {
+	msgs := []string{}
+	if provider.DPoP == nil {
+		return msgs
+	}
+
+	if !providerIsOIDCBased(provider.Type) {
+		msgs = append(msgs, fmt.Sprintf("dpop is not supported by the %s provider", provider.Type))
+	}
+	if provider.DPoP.KeyFile == "" {
+		msgs = append(msgs, "missing setting: dpop keyFile")
+	}
+
 	return msgs
 }
+
+func validateDeviceAuthorizationGrant(provider options.Provider) []string {
+	msgs := []string{}
+	if !ptr.Deref(provider.DeviceAuthorizationGrant, options.DefaultDeviceAuthorizationGrant) {
+		return msgs
+	}
+
+	if provider.Type == options.SAMLProvider {
+		return append(msgs, "deviceAuthorizationGrant is not supported by the saml provider")
+	}
+
+	discovered := providerIsOIDCBased(provider.Type) &&
+		!ptr.Deref(provider.OIDCConfig.SkipDiscovery, options.DefaultSkipDiscovery)
+	if provider.DeviceAuthorizationURL == "" && !discovered {
+		msgs = append(msgs, "missing setting: deviceAuthorizationURL is required for deviceAuthorizationGrant when discovery is not used")
+	}
+
+	return msgs
+}
+
+func validatePushedAuthorizationRequests(provider options.Provider) []string {
+	msgs := []string{}
+	required := ptr.Deref(provider.RequirePushedAuthorizationRequests, options.DefaultRequirePushedAuthorizationRequests)
+	if provider.PushedAuthorizationRequestURL == "" && !required {
+		return msgs
+	}
+
+	if provider.Type == options.SAMLProvider {
+		return append(msgs, "pushed authorization requests are not supported by the saml provider")
+	}
+
+	discovered := providerIsOIDCBased(provider.Type) &&
+		!ptr.Deref(provider.OIDCConfig.SkipDiscovery, options.DefaultSkipDiscovery)
+	if required && provider.PushedAuthorizationRequestURL == "" && !discovered {
+		msgs = append(msgs, "missing setting: pushedAuthorizationRequestURL is required for requirePushedAuthorizationRequests when discovery is not used")
+	}
+
+	return msgs
+}
+
+func validateTokenIntrospection(provider options.Provider) []string {
+	msgs := []string{}
+	if !ptr.Deref(provider.TokenIntrospection, options.DefaultTokenIntrospection) {
+		return msgs
+	}
+
+	if provider.Type == options.SAMLProvider {
+		return append(msgs, "tokenIntrospection is not supported by the saml provider")
+	}
+
+	discovered := providerIsOIDCBased(provider.Type) &&
+		!ptr.Deref(provider.OIDCConfig.SkipDiscovery, options.DefaultSkipDiscovery)
+	if provider.IntrospectionURL == "" && !discovered {
+		msgs = append(msgs, "missing setting: introspectionURL is required for tokenIntrospection when discovery is not used")
+	}
+	if ptr.Deref(provider.IntrospectionCacheTTL, options.DefaultIntrospectionCacheTTL) < 0 {
+		msgs = append(msgs, "introspectionCacheTTL must not be negative")
+	}
+
+	return msgs
+}
+
+func validateClaimExpressions(provider options.Provider) []string {
+	msgs := []string{}
+	if provider.ClaimExpressions == nil {
+		return msgs
+	}
+
+	if provider.Type == options.SAMLProvider {
+		return append(msgs, "claimExpressions are not supported by the saml provider")
+	}
+
+	if _, err := util.NewClaimExpressions(provider.ClaimExpressions); err != nil {
+		msgs = append(msgs, fmt.Sprintf("invalid claimExpressions: %v", err))
+	}
+
+	return msgs
+}
+
+func validateRevalidateInterval(provider options.Provider) []string {
+	msgs := []string{}
+	interval := ptr.Deref(provider.RevalidateInterval, options.DefaultRevalidateInterval)
+	if interval == 0 {
+		return msgs
+	}
+
+	switch provider.Type {
+	case options.GitHubProvider, options.GitLabProvider, options.BitbucketProvider, options.GiteaProvider,
+		options.DiscordProvider:
+	default:
+		msgs = append(msgs, fmt.Sprintf("revalidateInterval is not supported by the %s provider", provider.Type))
+	}
+	if interval < 0 {
+		msgs = append(msgs, "revalidateInterval must not be negative")
+	}
+
+	return msgs
+}
+
+// providerIsOIDCBased returns whether the provider type is built on the OIDC
+// provider and so supports its client authentication and mutual TLS options
+func providerIsOIDCBased(providerType options.ProviderType) bool {
+	switch providerType {
+	case options.OIDCProvider, options.ADFSProvider, options.AppleProvider, options.CidaasProvider, options.GiteaProvider,
+		options.GitLabProvider, options.KeycloakOIDCProvider, options.MicrosoftEntraIDProvider:
+		return true
+	default:
+		return false
+	}
+}
+
+func validateGiteaConfig(provider options.Provider) []string {
+	msgs := []string{}
+	config := provider.GiteaConfig
+
+	if config.Org == "" {
+		for _, team := range config.Teams {
+			if !strings.Contains(team, ":") {
+				msgs = append(msgs, fmt.Sprintf("invalid gitea team %q: teams must be formatted as org:team when no org is set", team))
+			}
+		}
+	}
+	if config.Repo != "" && len(strings.Split(config.Repo, "/")) != 2 {
+		msgs = append(msgs, fmt.Sprintf("invalid gitea repo %q: must be formatted as owner/repo", config.Repo))
+	}
+
+	return msgs
+}
+
+func validateDiscordConfig(provider options.Provider) []string {
+	msgs := []string{}
+	config := provider.DiscordConfig
+
+	guilds := make(map[string]struct{}, len(config.Guilds))
+	for _, guild := range config.Guilds {
+		guilds[guild] = struct{}{}
+	}
+
+	for _, role := range config.Roles {
+		guild, _, found := strings.Cut(role, ":")
+		switch {
+		case !found && len(config.Guilds) != 1:
+			msgs = append(msgs, fmt.Sprintf("invalid discord role %q: roles must be formatted as guild:role unless a single guild is set", role))
+		case found:
+			if _, ok := guilds[guild]; !ok {
+				msgs = append(msgs, fmt.Sprintf("invalid discord role %q: the guild of the role must be one of the discord guilds", role))
+			}
+		}
+	}
+
+	return msgs
+}
+
+func validateAppleConfig(provider options.Provider) []string {
+	msgs := []string{}
+	config := provider.AppleConfig
+
+	if config.TeamID == "" {
+		msgs = append(msgs, "missing setting: apple teamID")
+	}
+	if config.KeyID == "" {
+		msgs = append(msgs, "missing setting: apple keyID")
+	}
+	if config.PrivateKey == nil {
+		msgs = append(msgs, "missing setting: apple privateKey")
+	}
+
+	return msgs
+}
+
+func validateMockConfig(provider options.Provider) []string {
+	msgs := []string{}
+	config := provider.MockConfig
+
+	if !config.InsecureDevelopmentMode {
+		msgs = append(msgs, "the mock provider logs anyone in without a password: set mockConfig.insecureDevelopmentMode to use it for development and testing")
+	}
+	if len(config.Users) == 0 {
+		msgs = append(msgs, "missing setting: mock users")
+	}
+
+	emails := make(map[string]struct{}, len(config.Users))
+	for i, user := range config.Users {
+		if user.Email == "" {
+			msgs = append(msgs, fmt.Sprintf("invalid mock user %d: an email is required", i))
+			continue
+		}
+		if _, ok := emails[user.Email]; ok {
+			msgs = append(msgs, fmt.Sprintf("duplicate mock user %q", user.Email))
+		}
+		emails[user.Email] = struct{}{}
+	}
+
+	return msgs
+}
+
+func validateSAMLConfig(provider options.Provider) []string {
+	msgs := []string{}
+	config := provider.SAMLConfig
+
+	if config.IDPMetadataURL == "" && config.IDPMetadataFile == "" {
+		msgs = append(msgs, "missing setting for saml provider: idpMetadataURL or idpMetadataFile")
+	}
+
+	switch config.Binding {
+	case "", "redirect", "post":
+	default:
+		msgs = append(msgs, fmt.Sprintf("invalid saml binding %q: must be one of redirect or post", config.Binding))
+	}
+
+	if config.SigningKey != nil {
+		if msg := validateSecretSource(*config.SigningKey); msg != "" {
+			msgs = append(msgs, "invalid saml signingKey: "+msg)
+		}
+		if config.SigningCert == nil {
+			msgs = append(msgs, "missing setting for saml provider: signingCert is required with signingKey")
+		} else if msg := validateSecretSource(*config.SigningCert); msg != "" {
+			msgs = append(msgs, "invalid saml signingCert: "+msg)
+		}
+	}
+
+	return msgs
+}
+
+// validateCAFiles checks that the CA files the requests to the provider are
+// verified against can be loaded
+func validateCAFiles(provider options.Provider) []string {
+	if len(provider.CAFiles) == 0 {
+		return []string{}
+	}
+	if _, err := pkgutil.GetCertPool(provider.CAFiles, ptr.Deref(provider.UseSystemTrustStore, options.DefaultUseSystemTrustStore)); err != nil {
+		return []string{fmt.Sprintf("unable to load provider CA file(s): %v", err)}
+	}
+	return []string{}
+}
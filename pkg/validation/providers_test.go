@@ -1,7 +1,10 @@
 package validation
 
 import (
+	"time"
+
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -25,6 +28,20 @@ var _ = Describe("Providers", func() {
 		ClientSecret: "ClientSecret",
 	}
 
+	corpProvider := options.Provider{
+		ID:           "corp",
+		ClientID:     "ClientID",
+		ClientSecret: "ClientSecret",
+		LoginDomains: []string{"corp.com"},
+	}
+
+	catchAllProvider := options.Provider{
+		ID:           "github",
+		ClientID:     "ClientID",
+		ClientSecret: "ClientSecret",
+		LoginDomains: []string{"*"},
+	}
+
 	missingIDProvider := options.Provider{
 		ClientID:     "ClientID",
 		ClientSecret: "ClientSecret",
@@ -34,6 +51,7 @@ var _ = Describe("Providers", func() {
 	emptyIDMsg := "provider has empty id: ids are required for all providers"
 	duplicateProviderIDMsg := "multiple providers found with id ProviderID: provider ids must be unique"
 	skipButtonAndMultipleProvidersMsg := "SkipProviderButton and multiple providers are mutually exclusive"
+	duplicateLoginDomainMsg := "login domain corp.com is configured for providers corp and ProviderID: login domains must be unique"
 
 	DescribeTable("validateProviders",
 		func(o *validateProvidersTableInput) {
@@ -79,5 +97,638 @@ var _ = Describe("Providers", func() {
 			},
 			errStrings: []string{skipButtonAndMultipleProvidersMsg},
 		}),
+		Entry("with multiple providers, login domains and skip provider button", &validateProvidersTableInput{
+			options: &options.Options{
+				SkipProviderButton: true,
+				Providers: options.Providers{
+					corpProvider,
+					catchAllProvider,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with the same login domain on multiple providers", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					corpProvider,
+					{
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						LoginDomains: []string{"Corp.com"},
+					},
+				},
+			},
+			errStrings: []string{duplicateLoginDomainMsg},
+		}),
+		Entry("with a valid generic-oauth2 provider", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.GenericOAuth2Provider,
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						LoginURL:     "https://example.com/oauth/authorize",
+						RedeemURL:    "https://example.com/oauth/token",
+						ProfileURL:   "https://example.com/api/me",
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a generic-oauth2 provider missing its endpoints", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.GenericOAuth2Provider,
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+					},
+				},
+			},
+			errStrings: []string{
+				"missing setting for generic-oauth2 provider: login-url",
+				"missing setting for generic-oauth2 provider: redeem-url",
+				"missing setting for generic-oauth2 provider: profile-url",
+			},
+		}),
+		Entry("with a valid saml provider", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.SAMLProvider,
+						ID:       "ProviderID",
+						ClientID: "https://proxy.example.com/oauth2/saml/metadata",
+						SAMLConfig: options.SAMLOptions{
+							IDPMetadataURL: "https://idp.example.com/metadata",
+							Binding:        "post",
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid saml provider", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.SAMLProvider,
+						ID:       "ProviderID",
+						ClientID: "https://proxy.example.com/oauth2/saml/metadata",
+						SAMLConfig: options.SAMLOptions{
+							Binding:    "artifact",
+							SigningKey: &options.SecretSource{Value: []byte("key")},
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"missing setting for saml provider: idpMetadataURL or idpMetadataFile",
+				"invalid saml binding \"artifact\": must be one of redirect or post",
+				"missing setting for saml provider: signingCert is required with signingKey",
+			},
+		}),
+		Entry("with rp-initiated logout and skipped discovery", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.OIDCProvider,
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						OIDCConfig: options.OIDCOptions{
+							SkipDiscovery:     ptr.To(true),
+							RPInitiatedLogout: ptr.To(true),
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"missing setting: oidc endSessionURL is required for rpInitiatedLogout when discovery is skipped",
+			},
+		}),
+		Entry("with private_key_jwt client authentication", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.OIDCProvider,
+						ID:       "ProviderID",
+						ClientID: "ClientID",
+						ClientAuthentication: options.ClientAuthenticationOptions{
+							Method:     options.PrivateKeyJWTAuthentication,
+							SigningKey: &options.SecretSource{Value: []byte("key")},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with the device authorization grant", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:                     options.OIDCProvider,
+						ID:                       "ProviderID",
+						ClientID:                 "ClientID",
+						ClientSecret:             "ClientSecret",
+						DeviceAuthorizationGrant: ptr.To(true),
+					},
+					{
+						Type:                     options.GitHubProvider,
+						ID:                       "ProviderID2",
+						ClientID:                 "ClientID",
+						ClientSecret:             "ClientSecret",
+						DeviceAuthorizationGrant: ptr.To(true),
+						DeviceAuthorizationURL:   "https://github.com/login/device/code",
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with the device authorization grant and no endpoint", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:                     options.GitHubProvider,
+						ID:                       "ProviderID",
+						ClientID:                 "ClientID",
+						ClientSecret:             "ClientSecret",
+						DeviceAuthorizationGrant: ptr.To(true),
+					},
+				},
+			},
+			errStrings: []string{
+				"missing setting: deviceAuthorizationURL is required for deviceAuthorizationGrant when discovery is not used",
+			},
+		}),
+		Entry("with pushed authorization requests", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:                               options.OIDCProvider,
+						ID:                                 "ProviderID",
+						ClientID:                           "ClientID",
+						ClientSecret:                       "ClientSecret",
+						RequirePushedAuthorizationRequests: ptr.To(true),
+					},
+					{
+						Type:                               options.GitHubProvider,
+						ID:                                 "ProviderID2",
+						ClientID:                           "ClientID",
+						ClientSecret:                       "ClientSecret",
+						PushedAuthorizationRequestURL:      "https://idp.example.com/par",
+						RequirePushedAuthorizationRequests: ptr.To(true),
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with required pushed authorization requests and no endpoint", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:                               options.GitHubProvider,
+						ID:                                 "ProviderID",
+						ClientID:                           "ClientID",
+						ClientSecret:                       "ClientSecret",
+						RequirePushedAuthorizationRequests: ptr.To(true),
+					},
+				},
+			},
+			errStrings: []string{
+				"missing setting: pushedAuthorizationRequestURL is required for requirePushedAuthorizationRequests when discovery is not used",
+			},
+		}),
+		Entry("with tls_client_auth client authentication", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.OIDCProvider,
+						ID:       "ProviderID",
+						ClientID: "ClientID",
+						ClientAuthentication: options.ClientAuthenticationOptions{
+							Method: options.TLSClientAuthentication,
+						},
+						ClientCertificate: &options.ClientCertificate{
+							Cert: &options.SecretSource{Value: []byte("cert")},
+							Key:  &options.SecretSource{Value: []byte("key")},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid client certificates", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.OIDCProvider,
+						ID:       "ProviderID",
+						ClientID: "ClientID",
+						ClientAuthentication: options.ClientAuthenticationOptions{
+							Method: options.TLSClientAuthentication,
+						},
+					},
+					{
+						Type:              options.GitHubProvider,
+						ID:                "ProviderID2",
+						ClientID:          "ClientID",
+						ClientSecret:      "ClientSecret",
+						ClientCertificate: &options.ClientCertificate{},
+					},
+				},
+			},
+			errStrings: []string{
+				"missing setting: clientCertificate is required for tls_client_auth",
+				"clientCertificate is not supported by the github provider",
+				"missing setting: clientCertificate cert",
+				"missing setting: clientCertificate key",
+			},
+		}),
+		Entry("with token introspection", &validateProvidersTableInput{
+			options: &options.Options{
+				SkipJwtBearerTokens: true,
+				Providers: options.Providers{
+					{
+						Type:               options.OIDCProvider,
+						ID:                 "ProviderID",
+						ClientID:           "ClientID",
+						ClientSecret:       "ClientSecret",
+						TokenIntrospection: ptr.To(true),
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid token introspection", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:                  options.GitHubProvider,
+						ID:                    "ProviderID",
+						ClientID:              "ClientID",
+						ClientSecret:          "ClientSecret",
+						TokenIntrospection:    ptr.To(true),
+						IntrospectionCacheTTL: ptr.To(-time.Minute),
+					},
+				},
+			},
+			errStrings: []string{
+				"provider ProviderID has tokenIntrospection enabled, but skipJwtBearerTokens is not set: this will have no effect",
+				"missing setting: introspectionURL is required for tokenIntrospection when discovery is not used",
+				"introspectionCacheTTL must not be negative",
+			},
+		}),
+		Entry("with claim expressions", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.OIDCProvider,
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						ClaimExpressions: &options.ClaimExpressions{
+							Groups:    `claims.realm_access.roles.map(r, "role:" + r)`,
+							Authorize: `claims.email_verified && claims.hd == "corp.com"`,
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid claim expressions", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.OIDCProvider,
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						ClaimExpressions: &options.ClaimExpressions{
+							Authorize: `1 + 1`,
+						},
+					},
+					{
+						Type:     options.SAMLProvider,
+						ID:       "SAMLProviderID",
+						ClientID: "https://proxy.example.com/oauth2/saml/metadata",
+						SAMLConfig: options.SAMLOptions{
+							IDPMetadataURL: "https://idp.example.com/metadata",
+						},
+						ClaimExpressions: &options.ClaimExpressions{User: "claims.sub"},
+					},
+				},
+			},
+			errStrings: []string{
+				"invalid claimExpressions: authorize: expression must evaluate to bool, got int",
+				"claimExpressions are not supported by the saml provider",
+			},
+		}),
+		Entry("with invalid entra group names", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.MicrosoftEntraIDProvider,
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						MicrosoftEntraIDConfig: options.MicrosoftEntraIDOptions{
+							GroupNameAttribute: "mail",
+							GroupNameCacheTTL:  ptr.To(-time.Minute),
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"invalid entra groupNameAttribute \"mail\", must be one of: displayName, onPremisesSamAccountName",
+				"entra groupNameCacheTTL must not be negative",
+			},
+		}),
+		Entry("with a negative google group membership cache TTL", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.GoogleProvider,
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						GoogleConfig: options.GoogleOptions{
+							GroupMembershipCacheTTL: ptr.To(-time.Minute),
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"invalid setting: google groupMembershipCacheTTL must not be negative",
+			},
+		}),
+		Entry("with revalidateInterval", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:               options.GitHubProvider,
+						ID:                 "ProviderID",
+						ClientID:           "ClientID",
+						ClientSecret:       "ClientSecret",
+						RevalidateInterval: ptr.To(10 * time.Minute),
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid revalidateInterval", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:               options.OIDCProvider,
+						ID:                 "ProviderID",
+						ClientID:           "ClientID",
+						ClientSecret:       "ClientSecret",
+						RevalidateInterval: ptr.To(time.Minute),
+					},
+					{
+						Type:               options.GitLabProvider,
+						ID:                 "GitLabProviderID",
+						ClientID:           "ClientID",
+						ClientSecret:       "ClientSecret",
+						RevalidateInterval: ptr.To(-time.Minute),
+					},
+				},
+			},
+			errStrings: []string{
+				"revalidateInterval is not supported by the oidc provider",
+				"revalidateInterval must not be negative",
+			},
+		}),
+		Entry("with gitea restrictions", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.GiteaProvider,
+						ID:           "GiteaProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						GiteaConfig: options.GiteaOptions{
+							Org:   "org",
+							Teams: []string{"developers", "other:admins"},
+							Repo:  "org/repo",
+						},
+						RevalidateInterval: ptr.To(time.Minute),
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid gitea restrictions", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.GiteaProvider,
+						ID:           "GiteaProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						GiteaConfig: options.GiteaOptions{
+							Teams: []string{"developers", "other:admins"},
+							Repo:  "repo",
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"invalid gitea team \"developers\": teams must be formatted as org:team when no org is set",
+				"invalid gitea repo \"repo\": must be formatted as owner/repo",
+			},
+		}),
+		Entry("with discord guilds and roles", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.DiscordProvider,
+						ID:           "DiscordProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						DiscordConfig: options.DiscordOptions{
+							Guilds: []string{"1001"},
+							Roles:  []string{"2001", "1001:2002"},
+						},
+						RevalidateInterval: ptr.To(time.Minute),
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid discord roles", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.DiscordProvider,
+						ID:           "DiscordProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						DiscordConfig: options.DiscordOptions{
+							Guilds: []string{"1001", "1002"},
+							Roles:  []string{"2001", "1003:2002"},
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"invalid discord role \"2001\": roles must be formatted as guild:role unless a single guild is set",
+				"invalid discord role \"1003:2002\": the guild of the role must be one of the discord guilds",
+			},
+		}),
+		Entry("with an apple provider", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.AppleProvider,
+						ID:       "AppleProviderID",
+						ClientID: "ClientID",
+						AppleConfig: options.AppleOptions{
+							TeamID:     "TEAMID",
+							KeyID:      "KEYID",
+							PrivateKey: &options.SecretSource{FromFile: "/etc/oauth2-proxy/apple.p8"},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an apple provider missing settings", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.AppleProvider,
+						ID:       "AppleProviderID",
+						ClientID: "ClientID",
+					},
+				},
+			},
+			errStrings: []string{
+				"missing setting: apple teamID",
+				"missing setting: apple keyID",
+				"missing setting: apple privateKey",
+			},
+		}),
+		Entry("with a mock provider", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.MockProvider,
+						ID:       "MockProviderID",
+						ClientID: "ClientID",
+						MockConfig: options.MockOptions{
+							InsecureDevelopmentMode: true,
+							Users: []options.MockUser{
+								{Email: "jane@example.com", Groups: []string{"admins"}},
+								{Email: "john@example.com"},
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a mock provider without the development mode", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.MockProvider,
+						ID:       "MockProviderID",
+						ClientID: "ClientID",
+						MockConfig: options.MockOptions{
+							Users: []options.MockUser{
+								{Email: "jane@example.com"},
+								{},
+								{Email: "jane@example.com"},
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"the mock provider logs anyone in without a password: set mockConfig.insecureDevelopmentMode to use it for development and testing",
+				"invalid mock user 1: an email is required",
+				"duplicate mock user \"jane@example.com\"",
+			},
+		}),
+		Entry("with a mock provider without users", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.MockProvider,
+						ID:       "MockProviderID",
+						ClientID: "ClientID",
+						MockConfig: options.MockOptions{
+							InsecureDevelopmentMode: true,
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"missing setting: mock users",
+			},
+		}),
+		Entry("with DPoP", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.OIDCProvider,
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						DPoP:         &options.DPoPOptions{KeyFile: "/etc/oauth2-proxy/dpop.pem"},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid DPoP", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.GitHubProvider,
+						ID:           "ProviderID",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						DPoP:         &options.DPoPOptions{},
+					},
+				},
+			},
+			errStrings: []string{
+				"dpop is not supported by the github provider",
+				"missing setting: dpop keyFile",
+			},
+		}),
+		Entry("with invalid client authentication", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:     options.GitHubProvider,
+						ID:       "ProviderID",
+						ClientID: "ClientID",
+						ClientAuthentication: options.ClientAuthenticationOptions{
+							Method: options.PrivateKeyJWTAuthentication,
+						},
+					},
+					{
+						Type:         options.OIDCProvider,
+						ID:           "ProviderID2",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						ClientAuthentication: options.ClientAuthenticationOptions{
+							Method: "basic",
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"clientAuthentication method \"private_key_jwt\" is not supported by the github provider",
+				"missing setting: clientAuthentication signingKey is required for private_key_jwt",
+				"invalid clientAuthentication method \"basic\": must be one of client_secret, private_key_jwt, client_secret_jwt or tls_client_auth",
+			},
+		}),
 	)
 })
@@ -3,6 +3,7 @@ package validation
 import (
 	"fmt"
 	"net/url"
+	"regexp"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
@@ -47,6 +48,62 @@ func validateUpstream(upstream options.Upstream, ids, paths map[string]struct{})
 
 	msgs = append(msgs, validateUpstreamURI(upstream)...)
 	msgs = append(msgs, validateStaticUpstream(upstream)...)
+	msgs = append(msgs, validateTokenExchange(upstream)...)
+	msgs = append(msgs, validateAuthorizationRules(upstream)...)
+	return msgs
+}
+
+// validateAuthorizationRules checks that the paths of the authorization rules
+// are valid regular expressions and that allowed claims have a name and
+// values.
+func validateAuthorizationRules(upstream options.Upstream) []string {
+	msgs := []string{}
+	for i, rule := range upstream.Authorization {
+		if rule.Path != "" {
+			if _, err := regexp.Compile(rule.Path); err != nil {
+				msgs = append(msgs, fmt.Sprintf("upstream %q has authorization rule %d with invalid path %q: %v", upstream.ID, i, rule.Path, err))
+			}
+		}
+		for _, method := range rule.Methods {
+			if method == "" {
+				msgs = append(msgs, fmt.Sprintf("upstream %q has authorization rule %d with an empty method", upstream.ID, i))
+			}
+		}
+		for _, claim := range rule.AllowedClaims {
+			if claim.Claim == "" {
+				msgs = append(msgs, fmt.Sprintf("upstream %q has authorization rule %d with an allowed claim without a name", upstream.ID, i))
+			} else if len(claim.Values) == 0 {
+				msgs = append(msgs, fmt.Sprintf("upstream %q has authorization rule %d with allowed claim %q without values", upstream.ID, i, claim.Claim))
+			}
+		}
+	}
+	return msgs
+}
+
+// validateTokenExchange checks that the token exchange requests a token for
+// an audience or resource and is only configured for upstreams that proxy
+// requests to a server.
+func validateTokenExchange(upstream options.Upstream) []string {
+	msgs := []string{}
+	if upstream.TokenExchange == nil {
+		return msgs
+	}
+
+	if upstream.TokenExchange.Audience == "" && upstream.TokenExchange.Resource == "" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has tokenExchange without audience or resource: one of them is required", upstream.ID))
+	}
+	if upstream.TokenExchange.Resource != "" {
+		if _, err := url.ParseRequestURI(upstream.TokenExchange.Resource); err != nil {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid tokenExchange resource: %v", upstream.ID, err))
+		}
+	}
+
+	if ptr.Deref(upstream.Static, options.DefaultUpstreamStatic) {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has tokenExchange, but is a static upstream, this will have no effect.", upstream.ID))
+	} else if u, err := url.Parse(upstream.URI); err == nil && u.Scheme == "file" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has tokenExchange, but is a file upstream, this will have no effect.", upstream.ID))
+	}
+
 	return msgs
 }
 
@@ -47,6 +47,9 @@ var _ = Describe("Upstreams", func() {
 	multipleIDsMsg := "multiple upstreams found with id \"foo\": upstream ids must be unique"
 	multiplePathsMsg := "multiple upstreams found with path \"/foo\": upstream paths must be unique"
 	staticCodeMsg := "upstream \"foo\" has staticCode (200), but is not a static upstream, set 'static' for a static response"
+	tokenExchangeTargetMsg := "upstream \"foo\" has tokenExchange without audience or resource: one of them is required"
+	tokenExchangeStaticMsg := "upstream \"foo\" has tokenExchange, but is a static upstream, this will have no effect."
+	tokenExchangeFileMsg := "upstream \"foo\" has tokenExchange, but is a file upstream, this will have no effect."
 
 	DescribeTable("validateUpstreams",
 		func(o *validateUpstreamTableInput) {
@@ -225,5 +228,115 @@ var _ = Describe("Upstreams", func() {
 			},
 			errStrings: []string{emptyURIMsg, staticCodeMsg},
 		}),
+		Entry("with a token exchange", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						TokenExchange: &options.TokenExchange{
+							Audience: "foo",
+							Resource: "https://foo.example.com",
+							Scopes:   []string{"read"},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a token exchange without audience or resource", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:            "foo",
+						Path:          "/foo",
+						URI:           "http://foo",
+						TokenExchange: &options.TokenExchange{},
+					},
+				},
+			},
+			errStrings: []string{tokenExchangeTargetMsg},
+		}),
+		Entry("with a token exchange on a static upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:            "foo",
+						Path:          "/foo",
+						Static:        ptr.To(true),
+						TokenExchange: &options.TokenExchange{Audience: "foo"},
+					},
+				},
+			},
+			errStrings: []string{tokenExchangeStaticMsg},
+		}),
+		Entry("with a token exchange on a file upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:            "foo",
+						Path:          "/foo",
+						URI:           "file://var/lib/foo",
+						TokenExchange: &options.TokenExchange{Audience: "foo"},
+					},
+				},
+			},
+			errStrings: []string{tokenExchangeFileMsg},
+		}),
+		Entry("with authorization rules", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						Authorization: []options.AuthorizationRule{
+							{
+								Path:          "^/foo/admin/",
+								Methods:       []string{"POST", "DELETE"},
+								AllowedGroups: []string{"admins"},
+								AllowedClaims: []options.AllowedClaim{
+									{Claim: "department", Values: []string{"it"}},
+								},
+							},
+							{
+								AllowedEmailDomains: []string{"example.com"},
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid authorization rules", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						Authorization: []options.AuthorizationRule{
+							{
+								Path:    "^/foo/(admin",
+								Methods: []string{""},
+							},
+							{
+								AllowedClaims: []options.AllowedClaim{
+									{Values: []string{"it"}},
+									{Claim: "department"},
+								},
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"upstream \"foo\" has authorization rule 0 with invalid path \"^/foo/(admin\": error parsing regexp: missing closing ): `^/foo/(admin`",
+				"upstream \"foo\" has authorization rule 0 with an empty method",
+				"upstream \"foo\" has authorization rule 1 with an allowed claim without a name",
+				"upstream \"foo\" has authorization rule 1 with allowed claim \"department\" without values",
+			},
+		}),
 	)
 })
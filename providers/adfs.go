@@ -94,8 +94,8 @@ func (p *ADFSProvider) RefreshSession(ctx context.Context, s *sessions.SessionSt
 	return refreshed, err
 }
 
-func (p *ADFSProvider) fallbackUPN(_ context.Context, s *sessions.SessionState) error {
-	claims, err := p.getClaimExtractor(s.IDToken, s.AccessToken)
+func (p *ADFSProvider) fallbackUPN(ctx context.Context, s *sessions.SessionState) error {
+	claims, err := p.getClaimExtractor(ctx, s.IDToken, s.AccessToken)
 	if err != nil {
 		return fmt.Errorf("could not extract claims: %v", err)
 	}
@@ -0,0 +1,180 @@
+package providers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+const (
+	appleProviderName = "Apple"
+	appleDefaultScope = "openid name email"
+
+	// appleDefaultIssuerURL is the issuer of the Sign in with Apple ID tokens
+	// and the audience of the client secret
+	appleDefaultIssuerURL = "https://appleid.apple.com"
+
+	// appleDefaultResponseMode is the response mode Apple requires when the
+	// name or email scopes are requested
+	appleDefaultResponseMode = "form_post"
+
+	// appleClientSecretLifetime is how long a signed client secret is valid,
+	// Apple rejects client secrets valid for more than 6 months
+	appleClientSecretLifetime = 180 * 24 * time.Hour
+
+	// appleClientSecretRenewal is how long before its expiry the client
+	// secret is signed again
+	appleClientSecretRenewal = 24 * time.Hour
+)
+
+// AppleProvider represents a Sign in with Apple based Identity Provider.
+// Apple doesn't issue client secrets, the client secret is a JWT signed with
+// the private key of the developer team instead.
+type AppleProvider struct {
+	*OIDCProvider
+
+	// Expose this for unit testing
+	oidcRefreshFunc func(context.Context, *sessions.SessionState) (bool, error)
+}
+
+var _ CallbackSessionEnricher = (*AppleProvider)(nil)
+
+// NewAppleProvider initiates a new AppleProvider
+func NewAppleProvider(p *ProviderData, opts options.Provider) (*AppleProvider, error) {
+	clientSecret, err := newAppleClientSecret(opts.ClientID, opts.AppleConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	p.setProviderDefaults(providerDefaults{
+		name:  appleProviderName,
+		scope: appleDefaultScope,
+	})
+	if p.AuthRequestResponseMode == "" {
+		p.AuthRequestResponseMode = appleDefaultResponseMode
+	}
+	p.getClientSecretFunc = clientSecret.get
+
+	oidcProvider := NewOIDCProvider(p, opts.OIDCConfig)
+
+	return &AppleProvider{
+		OIDCProvider:    oidcProvider,
+		oidcRefreshFunc: oidcProvider.RefreshSession,
+	}, nil
+}
+
+// EnrichSessionFromCallback sets the name of the user from the user posted to
+// the callback. Apple only posts the user on the first login of the user to
+// the client and never includes the name in the ID token.
+func (p *AppleProvider) EnrichSessionFromCallback(s *sessions.SessionState, form url.Values) error {
+	// https://developer.apple.com/documentation/sign_in_with_apple/sign_in_with_apple_js/incorporating_sign_in_with_apple_into_other_platforms
+	payload := form.Get("user")
+	if payload == "" {
+		return nil
+	}
+
+	var user struct {
+		Name struct {
+			FirstName string `json:"firstName"`
+			LastName  string `json:"lastName"`
+		} `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(payload), &user); err != nil {
+		return fmt.Errorf("could not parse the Apple user: %v", err)
+	}
+
+	s.PreferredUsername = strings.TrimSpace(user.Name.FirstName + " " + user.Name.LastName)
+	return nil
+}
+
+// RefreshSession refreshes the session with the OIDCProvider implementation,
+// keeping the name of the user which the refreshed ID token doesn't carry
+func (p *AppleProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	preferredUsername := s.PreferredUsername
+	refreshed, err := p.oidcRefreshFunc(ctx, s)
+	if refreshed && s.PreferredUsername == "" {
+		s.PreferredUsername = preferredUsername
+	}
+	return refreshed, err
+}
+
+// appleClientSecret signs the client secret JWT and caches it until shortly
+// before it expires
+type appleClientSecret struct {
+	key      *ecdsa.PrivateKey
+	keyID    string
+	teamID   string
+	clientID string
+
+	mutex     sync.Mutex
+	secret    string
+	expiresAt time.Time
+}
+
+func newAppleClientSecret(clientID string, opts options.AppleOptions) (*appleClientSecret, error) {
+	if opts.PrivateKey == nil {
+		return nil, errors.New("an Apple private key is required")
+	}
+	keyPEM, err := util.GetSecretValue(opts.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the Apple private key: %v", err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the Apple private key: %v", err)
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("unsupported Apple private key curve %s: must be P-256", key.Curve.Params().Name)
+	}
+
+	return &appleClientSecret{
+		key:      key,
+		keyID:    opts.KeyID,
+		teamID:   opts.TeamID,
+		clientID: clientID,
+	}, nil
+}
+
+// get returns the cached client secret, or signs a new one when the cached
+// secret expires soon
+func (c *appleClientSecret) get() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if c.secret != "" && now.Add(appleClientSecretRenewal).Before(c.expiresAt) {
+		return c.secret, nil
+	}
+
+	// https://developer.apple.com/documentation/accountorganizationaldatasharing/creating-a-client-secret
+	expiresAt := now.Add(appleClientSecretLifetime)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": c.teamID,
+		"sub": c.clientID,
+		"aud": appleDefaultIssuerURL,
+		"iat": now.Unix(),
+		"exp": expiresAt.Unix(),
+	})
+	token.Header["kid"] = c.keyID
+
+	secret, err := token.SignedString(c.key)
+	if err != nil {
+		return "", fmt.Errorf("could not sign the Apple client secret: %v", err)
+	}
+
+	c.secret = secret
+	c.expiresAt = expiresAt
+	return secret, nil
+}
@@ -0,0 +1,207 @@
+package providers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/gomega"
+)
+
+func newTestAppleKey(t *testing.T, curve elliptic.Curve) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func testAppleProvider(t *testing.T, keyPEM []byte) *AppleProvider {
+	p, err := NewAppleProvider(&ProviderData{ClientID: "com.example.service"}, options.Provider{
+		ClientID: "com.example.service",
+		AppleConfig: options.AppleOptions{
+			TeamID:     "TEAMID1234",
+			KeyID:      "KEYID12345",
+			PrivateKey: &options.SecretSource{Value: keyPEM},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNewAppleProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	_, keyPEM := newTestAppleKey(t, elliptic.P256())
+
+	// Test that defaults are set when calling for a new provider with nothing set
+	providerData := testAppleProvider(t, keyPEM).Data()
+	g.Expect(providerData.ProviderName).To(Equal("Apple"))
+	g.Expect(providerData.Scope).To(Equal("openid name email"))
+	g.Expect(providerData.AuthRequestResponseMode).To(Equal("form_post"))
+
+	providerData = testAppleProvider(t, keyPEM).Data()
+	providerData.AuthRequestResponseMode = "query"
+	p, err := NewAppleProvider(providerData, options.Provider{
+		AppleConfig: options.AppleOptions{PrivateKey: &options.SecretSource{Value: keyPEM}},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.Data().AuthRequestResponseMode).To(Equal("query"))
+}
+
+func TestNewAppleProviderPrivateKey(t *testing.T) {
+	_, p384KeyPEM := newTestAppleKey(t, elliptic.P384())
+
+	testCases := map[string]struct {
+		privateKey    *options.SecretSource
+		expectedError string
+	}{
+		"without a private key": {
+			expectedError: "an Apple private key is required",
+		},
+		"with an invalid secret source": {
+			privateKey:    &options.SecretSource{},
+			expectedError: "could not load the Apple private key: secret source is invalid: exactly one entry required, specify either value, fromEnv or fromFile",
+		},
+		"with an invalid private key": {
+			privateKey:    &options.SecretSource{Value: []byte("not a key")},
+			expectedError: "could not parse the Apple private key: invalid key: Key must be a PEM encoded PKCS1 or PKCS8 key",
+		},
+		"with a P-384 private key": {
+			privateKey:    &options.SecretSource{Value: p384KeyPEM},
+			expectedError: "unsupported Apple private key curve P-384: must be P-256",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			_, err := NewAppleProvider(&ProviderData{}, options.Provider{
+				AppleConfig: options.AppleOptions{PrivateKey: tc.privateKey},
+			})
+			g.Expect(err).To(MatchError(tc.expectedError))
+		})
+	}
+}
+
+func TestAppleProviderClientSecret(t *testing.T) {
+	g := NewWithT(t)
+
+	key, keyPEM := newTestAppleKey(t, elliptic.P256())
+	p := testAppleProvider(t, keyPEM)
+
+	secret, err := p.GetClientSecret()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(secret, claims, func(*jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token.Header["kid"]).To(Equal("KEYID12345"))
+	g.Expect(claims["iss"]).To(Equal("TEAMID1234"))
+	g.Expect(claims["sub"]).To(Equal("com.example.service"))
+	g.Expect(claims["aud"]).To(Equal("https://appleid.apple.com"))
+
+	expiresAt, err := claims.GetExpirationTime()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(expiresAt.Time).To(BeTemporally("~", time.Now().Add(180*24*time.Hour), time.Minute))
+
+	// The client secret is cached until it expires soon
+	cached, err := p.GetClientSecret()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cached).To(Equal(secret))
+
+	p.getClientSecretFunc = nil
+	p.ClientSecret = "static"
+	static, err := p.GetClientSecret()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(static).To(Equal("static"))
+}
+
+func TestAppleProviderEnrichSessionFromCallback(t *testing.T) {
+	testCases := map[string]struct {
+		form              url.Values
+		expectedError     string
+		preferredUsername string
+	}{
+		"on the first login": {
+			form:              url.Values{"user": {`{"name": {"firstName": "Jane", "lastName": "Appleseed"}, "email": "jane@example.com"}`}},
+			preferredUsername: "Jane Appleseed",
+		},
+		"without a last name": {
+			form:              url.Values{"user": {`{"name": {"firstName": "Jane"}}`}},
+			preferredUsername: "Jane",
+		},
+		"on later logins": {
+			form:              url.Values{"code": {"code"}},
+			preferredUsername: "existing",
+		},
+		"with an invalid user": {
+			form:          url.Values{"user": {"not json"}},
+			expectedError: "could not parse the Apple user: invalid character 'o' in literal null (expecting 'u')",
+		},
+	}
+
+	_, keyPEM := newTestAppleKey(t, elliptic.P256())
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			p := testAppleProvider(t, keyPEM)
+			session := &sessions.SessionState{PreferredUsername: "existing"}
+			err := p.EnrichSessionFromCallback(session, tc.form)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(session.PreferredUsername).To(Equal(tc.preferredUsername))
+		})
+	}
+}
+
+func TestAppleProviderRefreshSession(t *testing.T) {
+	g := NewWithT(t)
+
+	_, keyPEM := newTestAppleKey(t, elliptic.P256())
+	p := testAppleProvider(t, keyPEM)
+
+	// The refreshed ID token doesn't carry the name of the user
+	p.oidcRefreshFunc = func(_ context.Context, s *sessions.SessionState) (bool, error) {
+		s.AccessToken = "refreshed"
+		s.PreferredUsername = ""
+		return true, nil
+	}
+	session := &sessions.SessionState{AccessToken: "expired", PreferredUsername: "Jane Appleseed"}
+	refreshed, err := p.RefreshSession(context.Background(), session)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refreshed).To(BeTrue())
+	g.Expect(session.AccessToken).To(Equal("refreshed"))
+	g.Expect(session.PreferredUsername).To(Equal("Jane Appleseed"))
+
+	p.oidcRefreshFunc = func(_ context.Context, _ *sessions.SessionState) (bool, error) {
+		return false, errors.New("refresh failed")
+	}
+	refreshed, err = p.RefreshSession(context.Background(), session)
+	g.Expect(err).To(MatchError("refresh failed"))
+	g.Expect(refreshed).To(BeFalse())
+}
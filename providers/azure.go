@@ -165,7 +165,7 @@ func (p *AzureProvider) Redeem(ctx context.Context, redirectURL, code, codeVerif
 	}
 
 	err = requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithMethod("POST").
 		WithBody(bytes.NewBufferString(params.Encode())).
 		SetHeader("Content-Type", "application/x-www-form-urlencoded").
@@ -263,9 +263,9 @@ func (p *AzureProvider) extractClaimsIntoSession(ctx context.Context, session *s
 	// due to above issues, id_token may not be signed by AAD
 	// in that case, we will fallback to access token
 	var err error
-	s, err = p.buildSessionFromClaims(session.IDToken, session.AccessToken)
+	s, err = p.buildSessionFromClaims(ctx, session.IDToken, session.AccessToken)
 	if err != nil || s.Email == "" {
-		s, err = p.buildSessionFromClaims(session.AccessToken, session.AccessToken)
+		s, err = p.buildSessionFromClaims(ctx, session.AccessToken, session.AccessToken)
 	}
 	if err != nil {
 		return fmt.Errorf("unable to get claims from token: %v", err)
@@ -333,7 +333,7 @@ func (p *AzureProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sess
 	}
 
 	err = requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithMethod("POST").
 		WithBody(bytes.NewBufferString(params.Encode())).
 		SetHeader("Content-Type", "application/x-www-form-urlencoded").
@@ -379,7 +379,7 @@ func (p *AzureProvider) getGroupsFromProfileAPI(ctx context.Context, s *sessions
 
 	for groupsURL != "" {
 		jsonRequest, err := requests.New(groupsURL).
-			WithContext(ctx).
+			WithContext(p.ClientContext(ctx)).
 			WithHeaders(extraHeader).
 			Do().
 			UnmarshalSimpleJSON()
@@ -416,7 +416,7 @@ func (p *AzureProvider) getEmailFromProfileAPI(ctx context.Context, accessToken
 	}
 
 	json, err := requests.New(p.ProfileURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithHeaders(makeAzureHeader(accessToken)).
 		Do().
 		UnmarshalSimpleJSON()
@@ -113,7 +113,7 @@ func (p *BitbucketProvider) GetEmailAddress(ctx context.Context, s *sessions.Ses
 
 	requestURL := p.ValidateURL.String() + "?access_token=" + s.AccessToken
 	err := requests.New(requestURL).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		Do().
 		UnmarshalInto(&emails)
 	if err != nil {
@@ -129,7 +129,7 @@ func (p *BitbucketProvider) GetEmailAddress(ctx context.Context, s *sessions.Ses
 		requestURL := teamURL.String() + "?role=member&access_token=" + s.AccessToken
 
 		err := requests.New(requestURL).
-			WithContext(ctx).
+			WithContext(p.ClientContext(ctx)).
 			Do().
 			UnmarshalInto(&teams)
 		if err != nil {
@@ -159,7 +159,7 @@ func (p *BitbucketProvider) GetEmailAddress(ctx context.Context, s *sessions.Ses
 			"&access_token=" + s.AccessToken
 
 		err := requests.New(requestURL).
-			WithContext(ctx).
+			WithContext(p.ClientContext(ctx)).
 			Do().
 			UnmarshalInto(&repositories)
 		if err != nil {
@@ -188,3 +188,13 @@ func (p *BitbucketProvider) GetEmailAddress(ctx context.Context, s *sessions.Ses
 
 	return "", nil
 }
+
+// RevalidateSession re-runs the team and repository checks with the stored
+// access token
+func (p *BitbucketProvider) RevalidateSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	email, err := p.GetEmailAddress(ctx, s)
+	if err != nil {
+		return revalidationResult(s.User, err)
+	}
+	return email != "", nil
+}
@@ -168,3 +168,22 @@ func TestBitbucketProviderGetEmailAddressEmailNotPresentInPayload(t *testing.T)
 	assert.Equal(t, "", email)
 	assert.Equal(t, nil, err)
 }
+
+func TestBitbucketProviderRevalidateSession(t *testing.T) {
+	b := testBitbucketBackend("{\"values\": [ { \"email\": \"michael.bland@gsa.gov\", \"is_primary\": true } ] }")
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testBitbucketProvider(bURL.Host, "", "")
+
+	session := CreateAuthorizedSession()
+	authorized, err := p.RevalidateSession(context.Background(), session)
+	assert.NoError(t, err)
+	assert.True(t, authorized)
+
+	// The user is no longer a member of the required team
+	p = testBitbucketProvider(bURL.Host, "bioinformatics", "")
+	authorized, err = p.RevalidateSession(context.Background(), session)
+	assert.NoError(t, err)
+	assert.False(t, authorized)
+}
@@ -87,7 +87,7 @@ func (p *CIDAASProvider) EnrichSession(ctx context.Context, s *sessions.SessionS
 func (p *CIDAASProvider) enrichFromUserinfoEndpoint(ctx context.Context, s *sessions.SessionState) error {
 	// profile url is userinfo url in case of Cidaas
 	respJSON, err := requests.New(p.ProfileURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithHeaders(makeOIDCHeader(s.AccessToken)).
 		Do().
 		UnmarshalSimpleJSON()
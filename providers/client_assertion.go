@@ -0,0 +1,207 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/elliptic"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// clientAssertionType is the RFC 7523 client assertion type
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// clientAssertionLifetime is how long a signed client assertion is valid
+	clientAssertionLifetime = 5 * time.Minute
+)
+
+// clientAssertion holds the configuration to sign the client assertions of
+// the private_key_jwt and client_secret_jwt client authentication methods
+type clientAssertion struct {
+	signingMethod jwt.SigningMethod
+	// key is the private key for private_key_jwt, or nil for
+	// client_secret_jwt which signs with the client secret
+	key      crypto.Signer
+	keyID    string
+	audience string
+}
+
+// newClientAssertion creates the client assertion configuration for the
+// JWT client authentication methods. It returns nil for any other method.
+func newClientAssertion(opts options.ClientAuthenticationOptions) (*clientAssertion, error) {
+	switch opts.Method {
+	case options.ClientSecretJWTAuthentication:
+		return &clientAssertion{
+			signingMethod: jwt.SigningMethodHS256,
+			keyID:         opts.KeyID,
+			audience:      opts.Audience,
+		}, nil
+	case options.PrivateKeyJWTAuthentication:
+		if opts.SigningKey == nil {
+			return nil, errors.New("a signing key is required for private_key_jwt")
+		}
+		keyPEM, err := util.GetSecretValue(opts.SigningKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load signing key: %v", err)
+		}
+		key, signingMethod, err := parseClientAssertionKey(keyPEM)
+		if err != nil {
+			return nil, err
+		}
+		return &clientAssertion{
+			signingMethod: signingMethod,
+			key:           key,
+			keyID:         opts.KeyID,
+			audience:      opts.Audience,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// parseClientAssertionKey parses a PEM encoded RSA, EC or Ed25519 private key
+// and picks the matching signing method
+func parseClientAssertionKey(keyPEM []byte) (crypto.Signer, jwt.SigningMethod, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(keyPEM); err == nil {
+		return key, jwt.SigningMethodRS256, nil
+	}
+	if key, err := jwt.ParseECPrivateKeyFromPEM(keyPEM); err == nil {
+		switch key.Curve {
+		case elliptic.P256():
+			return key, jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return key, jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return key, jwt.SigningMethodES512, nil
+		default:
+			return nil, nil, fmt.Errorf("unsupported signing key curve %s", key.Curve.Params().Name)
+		}
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(keyPEM); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, jwt.SigningMethodEdDSA, nil
+		}
+	}
+	return nil, nil, errors.New("unsupported signing key: must be a PEM encoded RSA, EC or Ed25519 private key")
+}
+
+// signClientAssertion creates a signed client assertion for the token endpoint
+func (p *ProviderData) signClientAssertion() (string, error) {
+	a := p.clientAssertion
+
+	audience := a.audience
+	if audience == "" {
+		audience = p.RedeemURL.String()
+	}
+
+	jti, err := encryption.Nonce(32)
+	if err != nil {
+		return "", fmt.Errorf("could not create client assertion ID: %v", err)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(a.signingMethod, jwt.RegisteredClaims{
+		Issuer:    p.ClientID,
+		Subject:   p.ClientID,
+		Audience:  jwt.ClaimStrings{audience},
+		ID:        fmt.Sprintf("%x", jti),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(clientAssertionLifetime)),
+	})
+	if a.keyID != "" {
+		token.Header["kid"] = a.keyID
+	}
+
+	var key interface{} = a.key
+	if a.key == nil {
+		clientSecret, err := p.GetClientSecret()
+		if err != nil {
+			return "", err
+		}
+		key = []byte(clientSecret)
+	}
+
+	return token.SignedString(key)
+}
+
+// usesClientSecretAuthentication returns whether the client authenticates to
+// the token endpoint with the client secret, the default method
+func (p *ProviderData) usesClientSecretAuthentication() bool {
+	return p.clientAssertion == nil && p.clientAuthenticationMethod != options.TLSClientAuthentication
+}
+
+// setClientAuthentication adds the client credentials of the configured
+// client authentication method to the parameters of a token endpoint request
+func (p *ProviderData) setClientAuthentication(params url.Values) error {
+	params.Set("client_id", p.ClientID)
+
+	if p.clientAuthenticationMethod == options.TLSClientAuthentication {
+		// The client is authenticated by the certificate it presents on the
+		// TLS connection
+		return nil
+	}
+
+	if p.clientAssertion == nil {
+		clientSecret, err := p.GetClientSecret()
+		if err != nil {
+			return err
+		}
+		params.Set("client_secret", clientSecret)
+		return nil
+	}
+
+	assertion, err := p.signClientAssertion()
+	if err != nil {
+		return fmt.Errorf("could not sign client assertion: %v", err)
+	}
+	params.Set("client_assertion", assertion)
+	params.Set("client_assertion_type", clientAssertionType)
+	return nil
+}
+
+// fetchToken posts the parameters to the token endpoint and returns the token
+// from the response, including the raw response as extra fields
+func (p *ProviderData) fetchToken(ctx context.Context, params url.Values) (*oauth2.Token, error) {
+	resp := requests.New(p.RedeemURL.String()).
+		WithContext(p.ClientContext(ctx)).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		Do()
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, tokenErrorFromResponse(resp, "token endpoint")
+	}
+
+	var token *oauth2.Token
+	var rawResponse interface{}
+
+	body := resp.Body()
+	if err := json.Unmarshal(body, &rawResponse); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal raw response body: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal token response body: %w", err)
+	}
+	if token.Expiry.IsZero() && token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return token.WithExtra(rawResponse), nil
+}
@@ -0,0 +1,212 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestPrivateKey(t *testing.T, key crypto.Signer) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestParseClientAssertionKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		key            crypto.Signer
+		expectedMethod jwt.SigningMethod
+	}{
+		"RSA key": {
+			key:            rsaKey,
+			expectedMethod: jwt.SigningMethodRS256,
+		},
+		"EC key": {
+			key:            ecKey,
+			expectedMethod: jwt.SigningMethodES384,
+		},
+		"Ed25519 key": {
+			key:            edKey,
+			expectedMethod: jwt.SigningMethodEdDSA,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			key, method, err := parseClientAssertionKey(encodeTestPrivateKey(t, tc.key))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedMethod, method)
+			assert.Equal(t, tc.key.Public(), key.Public())
+		})
+	}
+
+	t.Run("invalid key", func(t *testing.T) {
+		_, _, err := parseClientAssertionKey([]byte("not a key"))
+		assert.Error(t, err)
+	})
+}
+
+func TestNewClientAssertion(t *testing.T) {
+	a, err := newClientAssertion(options.ClientAuthenticationOptions{})
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+
+	a, err = newClientAssertion(options.ClientAuthenticationOptions{Method: options.ClientSecretAuthentication})
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+
+	_, err = newClientAssertion(options.ClientAuthenticationOptions{Method: options.PrivateKeyJWTAuthentication})
+	assert.Error(t, err)
+
+	_, err = newClientAssertion(options.ClientAuthenticationOptions{
+		Method:     options.PrivateKeyJWTAuthentication,
+		SigningKey: &options.SecretSource{Value: []byte("not a key")},
+	})
+	assert.Error(t, err)
+}
+
+func TestSignClientAssertion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	redeemURL, _ := url.Parse("https://idp.example.com/token")
+
+	t.Run("private_key_jwt", func(t *testing.T) {
+		a, err := newClientAssertion(options.ClientAuthenticationOptions{
+			Method:     options.PrivateKeyJWTAuthentication,
+			SigningKey: &options.SecretSource{Value: encodeTestPrivateKey(t, rsaKey)},
+			KeyID:      "key-1",
+		})
+		require.NoError(t, err)
+
+		p := &ProviderData{ClientID: "client", RedeemURL: redeemURL, clientAssertion: a}
+		signed, err := p.signClientAssertion()
+		require.NoError(t, err)
+
+		claims := &jwt.RegisteredClaims{}
+		token, err := jwt.ParseWithClaims(signed, claims, func(*jwt.Token) (interface{}, error) {
+			return &rsaKey.PublicKey, nil
+		}, jwt.WithValidMethods([]string{"RS256"}))
+		require.NoError(t, err)
+		assert.Equal(t, "key-1", token.Header["kid"])
+		assert.Equal(t, "client", claims.Issuer)
+		assert.Equal(t, "client", claims.Subject)
+		assert.Equal(t, jwt.ClaimStrings{redeemURL.String()}, claims.Audience)
+		assert.NotEmpty(t, claims.ID)
+	})
+
+	t.Run("client_secret_jwt", func(t *testing.T) {
+		a, err := newClientAssertion(options.ClientAuthenticationOptions{
+			Method:   options.ClientSecretJWTAuthentication,
+			Audience: "https://idp.example.com",
+		})
+		require.NoError(t, err)
+
+		p := &ProviderData{ClientID: "client", ClientSecret: "secret", RedeemURL: redeemURL, clientAssertion: a}
+		signed, err := p.signClientAssertion()
+		require.NoError(t, err)
+
+		claims := &jwt.RegisteredClaims{}
+		token, err := jwt.ParseWithClaims(signed, claims, func(*jwt.Token) (interface{}, error) {
+			return []byte("secret"), nil
+		}, jwt.WithValidMethods([]string{"HS256"}))
+		require.NoError(t, err)
+		assert.NotContains(t, token.Header, "kid")
+		assert.Equal(t, jwt.ClaimStrings{"https://idp.example.com"}, claims.Audience)
+	})
+}
+
+func TestOIDCProviderClientAssertion(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	a, err := newClientAssertion(options.ClientAuthenticationOptions{
+		Method:     options.PrivateKeyJWTAuthentication,
+		SigningKey: &options.SecretSource{Value: encodeTestPrivateKey(t, ecKey)},
+	})
+	require.NoError(t, err)
+
+	idToken, _ := newSignedTestIDToken(defaultIDToken)
+	body, _ := json.Marshal(redeemTokenResponse{
+		AccessToken:  accessToken,
+		ExpiresIn:    10,
+		TokenType:    "Bearer",
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+	})
+
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login/oauth/access_token" {
+			_ = r.ParseForm()
+			form = r.PostForm
+		}
+		rw.Header().Add("content-type", "application/json")
+		_, _ = rw.Write(body)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	provider := newOIDCProvider(serverURL, false)
+	provider.clientAssertion = a
+
+	assertForm := func(t *testing.T, grantType string) {
+		assert.Equal(t, grantType, form.Get("grant_type"))
+		assert.Equal(t, oidcClientID, form.Get("client_id"))
+		assert.Equal(t, clientAssertionType, form.Get("client_assertion_type"))
+		assert.NotContains(t, form, "client_secret")
+
+		_, err := jwt.Parse(form.Get("client_assertion"), func(*jwt.Token) (interface{}, error) {
+			return &ecKey.PublicKey, nil
+		}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(provider.RedeemURL.String()))
+		assert.NoError(t, err)
+	}
+
+	t.Run("Redeem", func(t *testing.T) {
+		session, err := provider.Redeem(context.Background(), "https://app.example.com/callback", "code1234", "verifier")
+		require.NoError(t, err)
+		assertForm(t, "authorization_code")
+		assert.Equal(t, "code1234", form.Get("code"))
+		assert.Equal(t, "verifier", form.Get("code_verifier"))
+		assert.Equal(t, accessToken, session.AccessToken)
+		assert.Equal(t, idToken, session.IDToken)
+		assert.Equal(t, refreshToken, session.RefreshToken)
+	})
+
+	t.Run("RefreshSession", func(t *testing.T) {
+		existingSession := &sessions.SessionState{
+			AccessToken:  "changeit",
+			IDToken:      "changeit",
+			RefreshToken: refreshToken,
+		}
+		refreshed, err := provider.RefreshSession(context.Background(), existingSession)
+		require.NoError(t, err)
+		assert.True(t, refreshed)
+		assertForm(t, "refresh_token")
+		assert.Equal(t, refreshToken, form.Get("refresh_token"))
+		assert.Equal(t, accessToken, existingSession.AccessToken)
+	})
+}
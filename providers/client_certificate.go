@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	pkgutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+)
+
+// newProviderHTTPClient creates the HTTP client used for all requests to a
+// provider configured with its own CA files or a client certificate. It
+// returns nil when neither is configured, so that requests use the
+// DefaultHTTPClient.
+func newProviderHTTPClient(providerConfig options.Provider) (*http.Client, error) {
+	if len(providerConfig.CAFiles) == 0 && providerConfig.ClientCertificate == nil {
+		return nil, nil
+	}
+
+	var rootCAs *x509.CertPool
+	if len(providerConfig.CAFiles) > 0 {
+		pool, err := pkgutil.GetCertPool(providerConfig.CAFiles, ptr.Deref(providerConfig.UseSystemTrustStore, options.DefaultUseSystemTrustStore))
+		if err != nil {
+			return nil, fmt.Errorf("could not load CA files: %v", err)
+		}
+		rootCAs = pool
+	}
+
+	var certificates []tls.Certificate
+	if providerConfig.ClientCertificate != nil {
+		cert, err := loadClientCertificate(providerConfig.ClientCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %v", err)
+		}
+		certificates = []tls.Certificate{cert}
+	}
+
+	return requests.NewTLSHTTPClient(rootCAs, certificates), nil
+}
+
+// loadClientCertificate loads the certificate and key of the client
+// certificate
+func loadClientCertificate(opts *options.ClientCertificate) (tls.Certificate, error) {
+	if opts.Cert == nil || opts.Key == nil {
+		return tls.Certificate{}, errors.New("both a certificate and a key are required")
+	}
+
+	certPEM, err := util.GetSecretValue(opts.Cert)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not load certificate: %v", err)
+	}
+	keyPEM, err := util.GetSecretValue(opts.Key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not load key: %v", err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not parse certificate and key: %v", err)
+	}
+	return cert, nil
+}
+
+// ClientContext returns a copy of the context that carries the HTTP client
+// for the requests to the provider, so that requests made with it trust the
+// provider's CA files and present its client certificate and DPoP proofs
+func (p *ProviderData) ClientContext(ctx context.Context) context.Context {
+	client := requests.DefaultHTTPClient
+	if p.httpClient != nil {
+		client = p.httpClient
+	}
+	if p.dpop != nil {
+		client = p.dpopClient(client)
+	}
+	return requests.ClientContext(ctx, client)
+}
@@ -0,0 +1,280 @@
+package providers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClientCertificate(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "oauth2-proxy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), encodeTestPrivateKey(t, key)
+}
+
+func TestNewProviderHTTPClient(t *testing.T) {
+	certPEM, keyPEM := newTestClientCertificate(t)
+
+	client, err := newProviderHTTPClient(options.Provider{})
+	assert.NoError(t, err)
+	assert.Nil(t, client)
+
+	client, err = newProviderHTTPClient(options.Provider{ClientCertificate: &options.ClientCertificate{
+		Cert: &options.SecretSource{Value: certPEM},
+		Key:  &options.SecretSource{Value: keyPEM},
+	}})
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+
+	_, err = newProviderHTTPClient(options.Provider{ClientCertificate: &options.ClientCertificate{
+		Cert: &options.SecretSource{Value: certPEM},
+	}})
+	assert.Error(t, err)
+
+	_, err = newProviderHTTPClient(options.Provider{ClientCertificate: &options.ClientCertificate{
+		Cert: &options.SecretSource{Value: certPEM},
+		Key:  &options.SecretSource{Value: []byte("not a key")},
+	}})
+	assert.Error(t, err)
+
+	_, err = newProviderHTTPClient(options.Provider{CAFiles: []string{filepath.Join(t.TempDir(), "missing.pem")}})
+	assert.Error(t, err)
+}
+
+func TestProviderHTTPClientTrustsProviderCAFiles(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	// The default transport doesn't trust the test server
+	_, err := requests.DefaultHTTPClient.Get(server.URL)
+	require.Error(t, err)
+
+	client, err := newProviderHTTPClient(options.Provider{CAFiles: []string{caFile}})
+	require.NoError(t, err)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestProviderAPIRequestsTrustProviderCAFiles(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Add("content-type", "application/json")
+		switch r.URL.Path {
+		case "/user/orgs", "/user/teams":
+			_, _ = rw.Write([]byte(`[]`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	client, err := newProviderHTTPClient(options.Provider{CAFiles: []string{caFile}})
+	require.NoError(t, err)
+
+	serverURL, _ := url.Parse(server.URL)
+	provider := NewGitHubProvider(&ProviderData{
+		LoginURL:    &url.URL{},
+		RedeemURL:   &url.URL{},
+		ProfileURL:  &url.URL{},
+		ValidateURL: &url.URL{Scheme: serverURL.Scheme, Host: serverURL.Host},
+		httpClient:  client,
+	}, options.GitHubOptions{})
+
+	// The GitHub API is only trusted through the CA files of the provider
+	session := &sessions.SessionState{AccessToken: accessToken}
+	assert.NoError(t, provider.getOrgAndTeam(context.Background(), session))
+}
+
+func TestOIDCProviderTLSClientAuthentication(t *testing.T) {
+	certPEM, keyPEM := newTestClientCertificate(t)
+
+	idToken, _ := newSignedTestIDToken(defaultIDToken)
+	body, _ := json.Marshal(redeemTokenResponse{
+		AccessToken:  accessToken,
+		ExpiresIn:    10,
+		TokenType:    "Bearer",
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+	})
+
+	var form url.Values
+	var peerCertificates []*x509.Certificate
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login/oauth/access_token" {
+			_ = r.ParseForm()
+			form = r.PostForm
+			peerCertificates = r.TLS.PeerCertificates
+		}
+		rw.Header().Add("content-type", "application/json")
+		_, _ = rw.Write(body)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	// Trust the test server through the default transport
+	defaultTransport := requests.DefaultTransport
+	requests.DefaultTransport = server.Client().Transport
+	defer func() { requests.DefaultTransport = defaultTransport }()
+
+	client, err := newProviderHTTPClient(options.Provider{ClientCertificate: &options.ClientCertificate{
+		Cert: &options.SecretSource{Value: certPEM},
+		Key:  &options.SecretSource{Value: keyPEM},
+	}})
+	require.NoError(t, err)
+
+	serverURL, _ := url.Parse(server.URL)
+	provider := newOIDCProvider(serverURL, false)
+	provider.clientAuthenticationMethod = options.TLSClientAuthentication
+	provider.httpClient = client
+
+	session, err := provider.Redeem(context.Background(), "https://app.example.com/callback", "code1234", "")
+	require.NoError(t, err)
+	assert.Equal(t, accessToken, session.AccessToken)
+
+	assert.Equal(t, oidcClientID, form.Get("client_id"))
+	assert.NotContains(t, form, "client_secret")
+	assert.NotContains(t, form, "client_assertion")
+	require.Len(t, peerCertificates, 1)
+	certBlock, _ := pem.Decode(certPEM)
+	assert.Equal(t, certBlock.Bytes, peerCertificates[0].Raw)
+}
+
+func TestProviderAPIRequestsPresentClientCertificate(t *testing.T) {
+	certPEM, keyPEM := newTestClientCertificate(t)
+	certBlock, _ := pem.Decode(certPEM)
+
+	peerCertificates := map[string][]*x509.Certificate{}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		peerCertificates[r.URL.Path] = r.TLS.PeerCertificates
+		rw.Header().Add("content-type", "application/json")
+		switch r.URL.Path {
+		case "/oauth/userinfo":
+			_, _ = rw.Write([]byte(`{"email":"foo@bar.com","email_verified":true,"groups":["foo"]}`))
+		case "/api/v4/projects/my/project":
+			_, _ = rw.Write([]byte(`{"archived":false,"path_with_namespace":"my/project","permissions":{"project_access":{"access_level":30}}}`))
+		case "/profile":
+			_, _ = rw.Write([]byte(`{"email":"foo@bar.com","groups":[{"groupId":"foo","roles":["bar"]}]}`))
+		case "/api/v1/user":
+			_, _ = rw.Write([]byte(`{"id":42,"login":"jdoe","email":"jdoe@example.com"}`))
+		case "/api/v1/user/orgs", "/api/v1/user/teams":
+			_, _ = rw.Write([]byte(`[]`))
+		case "/api/v1/repos/my/repo":
+			_, _ = rw.Write([]byte(`{"private":false,"permissions":{"pull":true,"push":true}}`))
+		case "/api/users/@me":
+			_, _ = rw.Write([]byte(`{"id":"42","username":"jdoe","email":"jdoe@example.com","verified":true}`))
+		case "/api/users/@me/guilds/my-guild/member":
+			_, _ = rw.Write([]byte(`{"roles":["my-role"]}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	// Trust the test server through the default transport
+	defaultTransport := requests.DefaultTransport
+	requests.DefaultTransport = server.Client().Transport
+	defer func() { requests.DefaultTransport = defaultTransport }()
+
+	client, err := newProviderHTTPClient(options.Provider{ClientCertificate: &options.ClientCertificate{
+		Cert: &options.SecretSource{Value: certPEM},
+		Key:  &options.SecretSource{Value: keyPEM},
+	}})
+	require.NoError(t, err)
+	serverURL, _ := url.Parse(server.URL)
+
+	t.Run("GitLab", func(t *testing.T) {
+		provider, err := NewGitLabProvider(&ProviderData{
+			LoginURL:   serverURL,
+			httpClient: client,
+		}, options.Provider{GitLabConfig: options.GitLabOptions{Projects: []string{"my/project"}}})
+		require.NoError(t, err)
+
+		require.NoError(t, provider.EnrichSession(context.Background(), &sessions.SessionState{AccessToken: accessToken}))
+		for _, path := range []string{"/oauth/userinfo", "/api/v4/projects/my/project"} {
+			require.Len(t, peerCertificates[path], 1, path)
+			assert.Equal(t, certBlock.Bytes, peerCertificates[path][0].Raw, path)
+		}
+	})
+
+	t.Run("cidaas", func(t *testing.T) {
+		provider := newCidaasProvider(serverURL)
+		provider.httpClient = client
+
+		require.NoError(t, provider.EnrichSession(context.Background(), &sessions.SessionState{AccessToken: accessToken}))
+		require.Len(t, peerCertificates["/profile"], 1)
+		assert.Equal(t, certBlock.Bytes, peerCertificates["/profile"][0].Raw)
+	})
+
+	t.Run("Gitea", func(t *testing.T) {
+		provider, err := NewGiteaProvider(&ProviderData{
+			LoginURL:   &url.URL{},
+			RedeemURL:  &url.URL{},
+			httpClient: client,
+		}, options.Provider{
+			OIDCConfig:  options.OIDCOptions{IssuerURL: server.URL + "/"},
+			GiteaConfig: options.GiteaOptions{Repo: "my/repo"},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, provider.EnrichSession(context.Background(), &sessions.SessionState{AccessToken: accessToken, Email: "jdoe@example.com"}))
+		for _, path := range []string{"/api/v1/user", "/api/v1/user/orgs", "/api/v1/user/teams", "/api/v1/repos/my/repo"} {
+			require.Len(t, peerCertificates[path], 1, path)
+			assert.Equal(t, certBlock.Bytes, peerCertificates[path][0].Raw, path)
+		}
+	})
+
+	t.Run("Discord", func(t *testing.T) {
+		provider := NewDiscordProvider(&ProviderData{
+			ProfileURL: &url.URL{Scheme: serverURL.Scheme, Host: serverURL.Host, Path: "/api/users/@me"},
+			httpClient: client,
+		}, options.DiscordOptions{Guilds: []string{"my-guild"}})
+
+		require.NoError(t, provider.EnrichSession(context.Background(), &sessions.SessionState{AccessToken: accessToken}))
+		for _, path := range []string{"/api/users/@me", "/api/users/@me/guilds/my-guild/member"} {
+			require.Len(t, peerCertificates[path], 1, path)
+			assert.Equal(t, certBlock.Bytes, peerCertificates[path][0].Raw, path)
+		}
+	})
+}
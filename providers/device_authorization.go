@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	"golang.org/x/oauth2"
+)
+
+// deviceCodeGrantType is the grant type to redeem a device code (RFC 8628)
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// ErrDeviceAuthorizationNotEnabled is returned when the device authorization
+// grant is not enabled for a provider
+var ErrDeviceAuthorizationNotEnabled = errors.New("device authorization grant is not enabled")
+
+// DeviceAuthorization is the response of the device authorization endpoint
+// that the user needs to complete the login on another device
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval,omitempty"`
+}
+
+// TokenError is an OAuth2 error response of the provider, for example the
+// authorization_pending error while the user has not completed a device login
+type TokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *TokenError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// StartDeviceAuthorization requests a device and user code from the device
+// authorization endpoint of the provider
+func (p *ProviderData) StartDeviceAuthorization(ctx context.Context) (*DeviceAuthorization, error) {
+	if p.DeviceAuthorizationURL == nil || p.DeviceAuthorizationURL.String() == "" {
+		return nil, ErrDeviceAuthorizationNotEnabled
+	}
+
+	params := url.Values{}
+	if p.Scope != "" {
+		params.Add("scope", p.Scope)
+	}
+	if err := p.setClientAuthentication(params); err != nil {
+		return nil, err
+	}
+
+	resp := requests.New(p.DeviceAuthorizationURL.String()).
+		WithContext(p.ClientContext(ctx)).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetHeader("Accept", "application/json").
+		Do()
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, tokenErrorFromResponse(resp, "device authorization endpoint")
+	}
+
+	var authorization DeviceAuthorization
+	if err := resp.UnmarshalInto(&authorization); err != nil {
+		return nil, err
+	}
+	if authorization.DeviceCode == "" || authorization.UserCode == "" {
+		return nil, errors.New("device authorization response is missing the device or user code")
+	}
+	return &authorization, nil
+}
+
+// RedeemDeviceCode polls the token endpoint with the device code. It returns
+// a *TokenError such as authorization_pending until the user has completed
+// the login.
+func (p *ProviderData) RedeemDeviceCode(ctx context.Context, deviceCode string) (*sessions.SessionState, error) {
+	token, err := p.fetchDeviceToken(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sessions.SessionState{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+	}
+	s.CreatedAtNow()
+	if !token.Expiry.IsZero() {
+		s.SetExpiresOn(token.Expiry)
+	}
+	return s, nil
+}
+
+// fetchDeviceToken redeems the device code at the token endpoint
+func (p *ProviderData) fetchDeviceToken(ctx context.Context, deviceCode string) (*oauth2.Token, error) {
+	if p.DeviceAuthorizationURL == nil || p.DeviceAuthorizationURL.String() == "" {
+		return nil, ErrDeviceAuthorizationNotEnabled
+	}
+	if deviceCode == "" {
+		return nil, errors.New("missing device code")
+	}
+
+	params := url.Values{}
+	params.Add("grant_type", deviceCodeGrantType)
+	params.Add("device_code", deviceCode)
+	if err := p.setClientAuthentication(params); err != nil {
+		return nil, err
+	}
+
+	return p.fetchToken(ctx, params)
+}
+
+// tokenErrorFromResponse returns the OAuth2 error of an unsuccessful response
+// or a generic error when the response does not contain one
+func tokenErrorFromResponse(resp requests.Result, endpoint string) error {
+	var tokenErr TokenError
+	if err := json.Unmarshal(resp.Body(), &tokenErr); err == nil && tokenErr.Code != "" {
+		return &tokenErr
+	}
+	return fmt.Errorf("unexpected status %d from %s: %s", resp.StatusCode(), endpoint, resp.Body())
+}
@@ -0,0 +1,127 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDeviceAuthorizationServer(t *testing.T, tokenStatus int, tokenBody interface{}) (*httptest.Server, *url.Values) {
+	form := &url.Values{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		rw.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/device":
+			*form = r.PostForm
+			_ = json.NewEncoder(rw).Encode(DeviceAuthorization{
+				DeviceCode:      "device-code",
+				UserCode:        "ABCD-EFGH",
+				VerificationURI: "https://idp.example.com/device",
+				ExpiresIn:       600,
+				Interval:        5,
+			})
+		case "/login/oauth/access_token":
+			*form = r.PostForm
+			rw.WriteHeader(tokenStatus)
+			_ = json.NewEncoder(rw).Encode(tokenBody)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, form
+}
+
+func TestStartDeviceAuthorization(t *testing.T) {
+	server, form := newDeviceAuthorizationServer(t, http.StatusOK, nil)
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	provider := newOIDCProvider(serverURL, true)
+
+	_, err := provider.StartDeviceAuthorization(context.Background())
+	assert.Equal(t, ErrDeviceAuthorizationNotEnabled, err)
+
+	provider.DeviceAuthorizationURL, _ = url.Parse(server.URL + "/device")
+	authorization, err := provider.StartDeviceAuthorization(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &DeviceAuthorization{
+		DeviceCode:      "device-code",
+		UserCode:        "ABCD-EFGH",
+		VerificationURI: "https://idp.example.com/device",
+		ExpiresIn:       600,
+		Interval:        5,
+	}, authorization)
+
+	assert.Equal(t, oidcClientID, form.Get("client_id"))
+	assert.Equal(t, oidcSecret, form.Get("client_secret"))
+	assert.Equal(t, provider.Scope, form.Get("scope"))
+}
+
+func TestRedeemDeviceCode(t *testing.T) {
+	t.Run("authorization pending", func(t *testing.T) {
+		server, form := newDeviceAuthorizationServer(t, http.StatusBadRequest, TokenError{Code: "authorization_pending"})
+		defer server.Close()
+
+		serverURL, _ := url.Parse(server.URL)
+		provider := newOIDCProvider(serverURL, true)
+		provider.DeviceAuthorizationURL, _ = url.Parse(server.URL + "/device")
+
+		_, err := provider.RedeemDeviceCode(context.Background(), "device-code")
+		var tokenErr *TokenError
+		require.True(t, errors.As(err, &tokenErr))
+		assert.Equal(t, "authorization_pending", tokenErr.Code)
+
+		assert.Equal(t, deviceCodeGrantType, form.Get("grant_type"))
+		assert.Equal(t, "device-code", form.Get("device_code"))
+		assert.Equal(t, oidcClientID, form.Get("client_id"))
+	})
+
+	t.Run("with an OIDC provider", func(t *testing.T) {
+		idToken, _ := newSignedTestIDToken(defaultIDToken)
+		server, _ := newDeviceAuthorizationServer(t, http.StatusOK, redeemTokenResponse{
+			AccessToken:  accessToken,
+			ExpiresIn:    10,
+			TokenType:    "Bearer",
+			RefreshToken: refreshToken,
+			IDToken:      idToken,
+		})
+		defer server.Close()
+
+		serverURL, _ := url.Parse(server.URL)
+		provider := newOIDCProvider(serverURL, true)
+		provider.DeviceAuthorizationURL, _ = url.Parse(server.URL + "/device")
+
+		session, err := provider.RedeemDeviceCode(context.Background(), "device-code")
+		require.NoError(t, err)
+		assert.Equal(t, defaultIDToken.Email, session.Email)
+		assert.Equal(t, accessToken, session.AccessToken)
+		assert.Equal(t, idToken, session.IDToken)
+		assert.Equal(t, refreshToken, session.RefreshToken)
+	})
+
+	t.Run("with an OAuth2 provider", func(t *testing.T) {
+		server, _ := newDeviceAuthorizationServer(t, http.StatusOK, redeemTokenResponse{
+			AccessToken: accessToken,
+			ExpiresIn:   10,
+			TokenType:   "Bearer",
+		})
+		defer server.Close()
+
+		serverURL, _ := url.Parse(server.URL)
+		provider := newOIDCProvider(serverURL, true).ProviderData
+		provider.DeviceAuthorizationURL, _ = url.Parse(server.URL + "/device")
+
+		session, err := provider.RedeemDeviceCode(context.Background(), "device-code")
+		require.NoError(t, err)
+		assert.Equal(t, accessToken, session.AccessToken)
+		assert.NotNil(t, session.ExpiresOn)
+	})
+}
@@ -69,7 +69,7 @@ func (p *DigitalOceanProvider) GetEmailAddress(ctx context.Context, s *sessions.
 	}
 
 	json, err := requests.New(p.ProfileURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithHeaders(makeOIDCHeader(s.AccessToken)).
 		Do().
 		UnmarshalSimpleJSON()
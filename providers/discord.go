@@ -0,0 +1,302 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+)
+
+// DiscordProvider represents a Discord based Identity Provider
+type DiscordProvider struct {
+	*ProviderData
+
+	Guilds []string
+	// Roles are the allowed roles, formatted as guild:role
+	Roles []string
+}
+
+var _ Provider = (*DiscordProvider)(nil)
+
+const (
+	discordProviderName = "Discord"
+	discordDefaultScope = "identify email guilds.members.read"
+	guildRoleSeparator  = ":"
+)
+
+// errDiscordNotGuildMember is returned for guilds the user is not a member of
+var errDiscordNotGuildMember = errors.New("user is not a member of the guild")
+
+var (
+	// Default Login URL for Discord.
+	// Pre-parsed URL of https://discord.com/oauth2/authorize.
+	discordDefaultLoginURL = &url.URL{
+		Scheme: "https",
+		Host:   "discord.com",
+		Path:   "/oauth2/authorize",
+	}
+
+	// Default Redeem URL for Discord.
+	// Pre-parsed URL of https://discord.com/api/oauth2/token.
+	discordDefaultRedeemURL = &url.URL{
+		Scheme: "https",
+		Host:   "discord.com",
+		Path:   "/api/oauth2/token",
+	}
+
+	// Default Profile URL for Discord.
+	// The guild members of the user are read below this URL.
+	// Pre-parsed URL of https://discord.com/api/users/@me.
+	discordDefaultProfileURL = &url.URL{
+		Scheme: "https",
+		Host:   "discord.com",
+		Path:   "/api/users/@me",
+	}
+)
+
+// NewDiscordProvider initiates a new DiscordProvider
+func NewDiscordProvider(p *ProviderData, opts options.DiscordOptions) *DiscordProvider {
+	p.setProviderDefaults(providerDefaults{
+		name:        discordProviderName,
+		loginURL:    discordDefaultLoginURL,
+		redeemURL:   discordDefaultRedeemURL,
+		profileURL:  discordDefaultProfileURL,
+		validateURL: discordDefaultProfileURL,
+		scope:       discordDefaultScope,
+	})
+
+	provider := &DiscordProvider{ProviderData: p}
+	provider.setGuildsAndRoles(opts.Guilds, opts.Roles)
+	return provider
+}
+
+// setGuildsAndRoles configures the allowed guilds and roles. Roles without a
+// guild belong to the only configured guild.
+func (p *DiscordProvider) setGuildsAndRoles(guilds, roles []string) {
+	p.Guilds = guilds
+	p.Roles = make([]string, 0, len(roles))
+	for _, role := range roles {
+		if !strings.Contains(role, guildRoleSeparator) && len(guilds) == 1 {
+			role = guilds[0] + guildRoleSeparator + role
+		}
+		p.Roles = append(p.Roles, role)
+	}
+}
+
+// Redeem exchanges the OAuth2 authentication token for an access token
+func (p *DiscordProvider) Redeem(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
+	if code == "" {
+		return nil, ErrMissingCode
+	}
+
+	params := url.Values{}
+	params.Add("redirect_uri", redirectURL)
+	params.Add("code", code)
+	params.Add("grant_type", "authorization_code")
+	if codeVerifier != "" {
+		params.Add("code_verifier", codeVerifier)
+	}
+
+	ss := &sessions.SessionState{}
+	if err := p.redeemToken(ctx, params, ss); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+// RefreshSession uses the RefreshToken to fetch a new access token, then
+// reads the guild roles of the user again. The existing groups are kept if
+// they can't be read.
+func (p *DiscordProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	if s == nil || s.RefreshToken == "" {
+		return false, nil
+	}
+
+	params := url.Values{}
+	params.Add("refresh_token", s.RefreshToken)
+	params.Add("grant_type", "refresh_token")
+	if err := p.redeemToken(ctx, params, s); err != nil {
+		return false, fmt.Errorf("unable to redeem refresh token: %v", err)
+	}
+
+	groups, err := p.getGuildRoles(ctx, s.AccessToken)
+	if err != nil {
+		logger.Errorf("Warning: could not refresh the guild roles of %s: %v", s.User, err)
+		return true, nil
+	}
+	s.Groups = groups
+	return true, nil
+}
+
+// redeemToken requests a token with the given grant and stores it in the
+// session. Discord rotates the refresh token on every refresh.
+func (p *DiscordProvider) redeemToken(ctx context.Context, params url.Values, s *sessions.SessionState) error {
+	clientSecret, err := p.GetClientSecret()
+	if err != nil {
+		return err
+	}
+	params.Add("client_id", p.ClientID)
+	params.Add("client_secret", clientSecret)
+
+	var jsonResponse struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	err = requests.New(p.RedeemURL.String()).
+		WithContext(p.ClientContext(ctx)).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		Do().
+		UnmarshalInto(&jsonResponse)
+	if err != nil {
+		return err
+	}
+	if jsonResponse.AccessToken == "" {
+		return errors.New("no access token found in the token response")
+	}
+
+	s.AccessToken = jsonResponse.AccessToken
+	if jsonResponse.RefreshToken != "" {
+		s.RefreshToken = jsonResponse.RefreshToken
+	}
+	s.CreatedAtNow()
+	s.ExpiresIn(time.Duration(jsonResponse.ExpiresIn) * time.Second)
+	return nil
+}
+
+// EnrichSession sets the user and email from the Discord user, and the
+// configured guilds the user is a member of as groups, along with their roles
+// in these guilds formatted as guild:role
+func (p *DiscordProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
+	// https://discord.com/developers/docs/resources/user#get-current-user
+	var user struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Verified bool   `json:"verified"`
+	}
+	err := requests.New(p.ProfileURL.String()).
+		WithContext(p.ClientContext(ctx)).
+		WithHeaders(makeOIDCHeader(s.AccessToken)).
+		Do().
+		UnmarshalInto(&user)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user: %v", err)
+	}
+
+	if user.Email == "" {
+		return errors.New("the user has no email, the email scope is required")
+	}
+	if !p.AllowUnverifiedEmail && !user.Verified {
+		return errors.New("user email is not verified")
+	}
+	// Usernames can be changed, while the ID of the user is stable
+	s.User = user.ID
+	s.Email = user.Email
+	s.PreferredUsername = user.Username
+
+	s.Groups, err = p.getGuildRoles(ctx, s.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve guild members: %v", err)
+	}
+	return nil
+}
+
+// RevalidateSession reads the guild roles of the user again, which are
+// checked against the allowed guilds and roles by Authorize
+func (p *DiscordProvider) RevalidateSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	groups, err := p.getGuildRoles(ctx, s.AccessToken)
+	if err != nil {
+		return revalidationResult(s.User, fmt.Errorf("failed to retrieve guild members: %w", err))
+	}
+	s.Groups = groups
+	return true, nil
+}
+
+// getGuildRoles returns the configured guilds the user is a member of and the
+// roles of the user in them
+func (p *DiscordProvider) getGuildRoles(ctx context.Context, accessToken string) ([]string, error) {
+	var groups []string
+	for _, guild := range p.Guilds {
+		roles, err := p.getGuildMemberRoles(ctx, guild, accessToken)
+		if errors.Is(err, errDiscordNotGuildMember) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, guild)
+		for _, role := range roles {
+			groups = append(groups, guild+guildRoleSeparator+role)
+		}
+	}
+	return groups, nil
+}
+
+func (p *DiscordProvider) getGuildMemberRoles(ctx context.Context, guild, accessToken string) ([]string, error) {
+	// https://discord.com/developers/docs/resources/user#get-current-user-guild-member
+	endpoint := *p.ProfileURL
+	endpoint.Path = path.Join(endpoint.Path, "guilds", guild, "member")
+
+	var member struct {
+		Roles []string `json:"roles"`
+	}
+	result := requests.New(endpoint.String()).
+		WithContext(p.ClientContext(ctx)).
+		WithHeaders(makeOIDCHeader(accessToken)).
+		Do()
+	if result.StatusCode() == http.StatusNotFound {
+		// Discord doesn't find the guild for users who are not a member
+		return nil, errDiscordNotGuildMember
+	}
+	if err := result.UnmarshalInto(&member); err != nil {
+		return nil, err
+	}
+	return member.Roles, nil
+}
+
+// Authorize checks that the user is a member of one of the allowed guilds and
+// has one of the allowed roles, before the allowed groups and claim
+// expressions of the provider
+func (p *DiscordProvider) Authorize(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	if len(p.Guilds) > 0 && !hasAnyGroup(s, p.Guilds) {
+		logger.Printf("Missing Discord guild: %v", p.Guilds)
+		return false, nil
+	}
+	if len(p.Roles) > 0 && !hasAnyGroup(s, p.Roles) {
+		logger.Printf("Missing Discord guild role: %v", p.Roles)
+		return false, nil
+	}
+
+	return p.ProviderData.Authorize(ctx, s)
+}
+
+// hasAnyGroup returns whether the session has any of the given groups
+func hasAnyGroup(s *sessions.SessionState, groups []string) bool {
+	for _, group := range s.Groups {
+		for _, allowed := range groups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateSession validates the AccessToken
+func (p *DiscordProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
+	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+}
@@ -0,0 +1,315 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	discordUserPayload       = `{"id": "80351110224678912", "username": "nelly", "global_name": "Nelly", "email": "nelly@example.com", "verified": true}`
+	discordUnverifiedPayload = `{"id": "80351110224678912", "username": "nelly", "email": "nelly@example.com", "verified": false}`
+)
+
+func testDiscordProvider(hostname string, opts options.DiscordOptions) *DiscordProvider {
+	p := NewDiscordProvider(
+		&ProviderData{
+			ProviderName: "",
+			LoginURL:     &url.URL{},
+			RedeemURL:    &url.URL{Path: "/api/oauth2/token"},
+			ProfileURL:   &url.URL{Path: "/api/users/@me"},
+			ValidateURL:  &url.URL{Path: "/api/users/@me"},
+			Scope:        ""},
+		opts)
+	if hostname != "" {
+		updateURL(p.Data().RedeemURL, hostname)
+		updateURL(p.Data().ProfileURL, hostname)
+		updateURL(p.Data().ValidateURL, hostname)
+	}
+	return p
+}
+
+// testDiscordBackend serves the Discord user and the guild members given by
+// guild ID to requests with the authorized access token
+func testDiscordBackend(user string, members map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/oauth2/token" {
+				if err := r.ParseForm(); err != nil || r.PostForm.Get("client_secret") != "secret" {
+					w.WriteHeader(401)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(200)
+				w.Write([]byte(`{"access_token": "` + authorizedAccessToken + `", "refresh_token": "rotated", "expires_in": 604800}`))
+				return
+			}
+
+			if !IsAuthorizedInHeader(r.Header) {
+				w.WriteHeader(401)
+				return
+			}
+
+			payload := ""
+			if r.URL.Path == "/api/users/@me" {
+				payload = user
+			}
+			for guild, member := range members {
+				if r.URL.Path == "/api/users/@me/guilds/"+guild+"/member" {
+					payload = member
+				}
+			}
+			if payload == "" {
+				w.WriteHeader(404)
+				w.Write([]byte(`{"message": "Unknown Guild", "code": 10004}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			w.Write([]byte(payload))
+		}))
+}
+
+func TestNewDiscordProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	// Test that defaults are set when calling for a new provider with nothing set
+	providerData := NewDiscordProvider(&ProviderData{}, options.DiscordOptions{}).Data()
+	g.Expect(providerData.ProviderName).To(Equal("Discord"))
+	g.Expect(providerData.LoginURL.String()).To(Equal("https://discord.com/oauth2/authorize"))
+	g.Expect(providerData.RedeemURL.String()).To(Equal("https://discord.com/api/oauth2/token"))
+	g.Expect(providerData.ProfileURL.String()).To(Equal("https://discord.com/api/users/@me"))
+	g.Expect(providerData.ValidateURL.String()).To(Equal("https://discord.com/api/users/@me"))
+	g.Expect(providerData.Scope).To(Equal("identify email guilds.members.read"))
+}
+
+func TestDiscordProviderRoles(t *testing.T) {
+	g := NewWithT(t)
+
+	p := NewDiscordProvider(&ProviderData{}, options.DiscordOptions{
+		Guilds: []string{"1001"},
+		Roles:  []string{"2001", "1001:2002"},
+	})
+	g.Expect(p.Roles).To(Equal([]string{"1001:2001", "1001:2002"}))
+
+	p = NewDiscordProvider(&ProviderData{}, options.DiscordOptions{
+		Guilds: []string{"1001", "1002"},
+		Roles:  []string{"1002:2001"},
+	})
+	g.Expect(p.Roles).To(Equal([]string{"1002:2001"}))
+}
+
+func TestDiscordProviderRedeem(t *testing.T) {
+	g := NewWithT(t)
+
+	b := testDiscordBackend(discordUserPayload, nil)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testDiscordProvider(bURL.Host, options.DiscordOptions{})
+	p.ClientID = "client"
+	p.ClientSecret = "secret"
+
+	session, err := p.Redeem(context.Background(), "https://example.com/oauth2/callback", "code", "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(session.AccessToken).To(Equal(authorizedAccessToken))
+	g.Expect(session.RefreshToken).To(Equal("rotated"))
+	g.Expect(*session.ExpiresOn).To(BeTemporally("~", time.Now().Add(7*24*time.Hour), time.Minute))
+
+	_, err = p.Redeem(context.Background(), "https://example.com/oauth2/callback", "", "")
+	g.Expect(err).To(MatchError(ErrMissingCode))
+}
+
+func TestDiscordProviderEnrichSession(t *testing.T) {
+	testCases := map[string]struct {
+		user            string
+		members         map[string]string
+		opts            options.DiscordOptions
+		allowUnverified bool
+		expectedError   string
+		expected        *sessions.SessionState
+	}{
+		"without guilds": {
+			user: discordUserPayload,
+			expected: &sessions.SessionState{
+				User:              "80351110224678912",
+				Email:             "nelly@example.com",
+				PreferredUsername: "nelly",
+			},
+		},
+		"with guild roles": {
+			user: discordUserPayload,
+			members: map[string]string{
+				"1001": `{"roles": ["2001", "2002"]}`,
+				"1003": `{"roles": []}`,
+			},
+			opts: options.DiscordOptions{Guilds: []string{"1001", "1002", "1003"}},
+			expected: &sessions.SessionState{
+				User:              "80351110224678912",
+				Email:             "nelly@example.com",
+				PreferredUsername: "nelly",
+				Groups:            []string{"1001", "1001:2001", "1001:2002", "1003"},
+			},
+		},
+		"with an unverified email": {
+			user:          discordUnverifiedPayload,
+			expectedError: "user email is not verified",
+		},
+		"with an unverified email allowed": {
+			user:            discordUnverifiedPayload,
+			allowUnverified: true,
+			expected: &sessions.SessionState{
+				User:              "80351110224678912",
+				Email:             "nelly@example.com",
+				PreferredUsername: "nelly",
+			},
+		},
+		"without an email": {
+			user:          `{"id": "80351110224678912", "username": "nelly"}`,
+			expectedError: "the user has no email, the email scope is required",
+		},
+		"with an invalid guild member": {
+			user:          discordUserPayload,
+			members:       map[string]string{"1001": `not json`},
+			opts:          options.DiscordOptions{Guilds: []string{"1001"}},
+			expectedError: "failed to retrieve guild members: error unmarshalling body: invalid character 'o' in literal null (expecting 'u')",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			b := testDiscordBackend(tc.user, tc.members)
+			defer b.Close()
+
+			bURL, _ := url.Parse(b.URL)
+			p := testDiscordProvider(bURL.Host, tc.opts)
+			p.AllowUnverifiedEmail = tc.allowUnverified
+
+			session := CreateAuthorizedSession()
+			err := p.EnrichSession(context.Background(), session)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(session.User).To(Equal(tc.expected.User))
+			g.Expect(session.Email).To(Equal(tc.expected.Email))
+			g.Expect(session.PreferredUsername).To(Equal(tc.expected.PreferredUsername))
+			g.Expect(session.Groups).To(Equal(tc.expected.Groups))
+		})
+	}
+}
+
+func TestDiscordProviderAuthorize(t *testing.T) {
+	testCases := map[string]struct {
+		opts          options.DiscordOptions
+		allowedGroups []string
+		groups        []string
+		expected      bool
+	}{
+		"without restrictions": {
+			expected: true,
+		},
+		"member of an allowed guild": {
+			opts:     options.DiscordOptions{Guilds: []string{"1001", "1002"}},
+			groups:   []string{"1002"},
+			expected: true,
+		},
+		"not a member of an allowed guild": {
+			opts:     options.DiscordOptions{Guilds: []string{"1001"}},
+			expected: false,
+		},
+		"with an allowed role": {
+			opts:     options.DiscordOptions{Guilds: []string{"1001"}, Roles: []string{"2001"}},
+			groups:   []string{"1001", "1001:2001"},
+			expected: true,
+		},
+		"without an allowed role": {
+			opts:     options.DiscordOptions{Guilds: []string{"1001"}, Roles: []string{"2001"}},
+			groups:   []string{"1001", "1001:2002"},
+			expected: false,
+		},
+		"with an allowed role but not an allowed group": {
+			opts:          options.DiscordOptions{Guilds: []string{"1001"}, Roles: []string{"2001"}},
+			allowedGroups: []string{"1001:2003"},
+			groups:        []string{"1001", "1001:2001"},
+			expected:      false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			p := NewDiscordProvider(&ProviderData{}, tc.opts)
+			p.setAllowedGroups(tc.allowedGroups)
+
+			authorized, err := p.Authorize(context.Background(), &sessions.SessionState{Groups: tc.groups})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(authorized).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestDiscordProviderRefreshSession(t *testing.T) {
+	g := NewWithT(t)
+
+	b := testDiscordBackend(discordUserPayload, map[string]string{
+		"1001": `{"roles": ["2002"]}`,
+	})
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testDiscordProvider(bURL.Host, options.DiscordOptions{Guilds: []string{"1001"}})
+	p.ClientID = "client"
+	p.ClientSecret = "secret"
+
+	session := &sessions.SessionState{
+		AccessToken:  "expired",
+		RefreshToken: "refresh",
+		User:         "nelly",
+		Groups:       []string{"1001", "1001:2001"},
+	}
+	refreshed, err := p.RefreshSession(context.Background(), session)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refreshed).To(BeTrue())
+	g.Expect(session.AccessToken).To(Equal(authorizedAccessToken))
+	g.Expect(session.RefreshToken).To(Equal("rotated"))
+	g.Expect(session.User).To(Equal("nelly"))
+	g.Expect(session.Groups).To(Equal([]string{"1001", "1001:2002"}))
+
+	refreshed, err = p.RefreshSession(context.Background(), &sessions.SessionState{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refreshed).To(BeFalse())
+}
+
+func TestDiscordProviderRevalidateSession(t *testing.T) {
+	g := NewWithT(t)
+
+	b := testDiscordBackend(discordUserPayload, nil)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testDiscordProvider(bURL.Host, options.DiscordOptions{Guilds: []string{"1001"}})
+
+	// The user has left the guild
+	session := CreateAuthorizedSession()
+	session.Groups = []string{"1001"}
+	revalidated, err := p.RevalidateSession(context.Background(), session)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(revalidated).To(BeTrue())
+	g.Expect(session.Groups).To(BeEmpty())
+
+	authorized, err := p.Authorize(context.Background(), session)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(authorized).To(BeFalse())
+}
@@ -0,0 +1,258 @@
+package providers
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	// dpopHeader is the request header that carries the DPoP proof
+	dpopHeader = "DPoP"
+
+	// dpopScheme is the authorization scheme DPoP-bound access tokens are
+	// presented with
+	dpopScheme = "DPoP"
+
+	// dpopNonceHeader is the response header of the nonce the authorization
+	// server expects in the next DPoP proof
+	dpopNonceHeader = "DPoP-Nonce"
+
+	// dpopProofType is the typ header of DPoP proofs
+	dpopProofType = "dpop+jwt"
+)
+
+// dpopProver signs the DPoP proofs (RFC 9449) of the requests to the token
+// endpoint and to resources with the configured key pair
+type dpopProver struct {
+	key           crypto.Signer
+	signingMethod jwt.SigningMethod
+	jwk           *jose.JSONWebKey
+
+	// nonces are the latest nonces by origin, as the authorization server and
+	// each resource server issue their own
+	nonceMutex sync.Mutex
+	nonces     map[string]string
+}
+
+// newDPoPProver loads the DPoP key from the key file, or generates it when the
+// file does not exist yet. It returns nil when DPoP is not configured.
+func newDPoPProver(opts *options.DPoPOptions) (*dpopProver, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	if opts.KeyFile == "" {
+		return nil, errors.New("a key file is required")
+	}
+
+	keyPEM, err := os.ReadFile(opts.KeyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		keyPEM, err = generateDPoPKey(opts.KeyFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, signingMethod, err := parseClientAssertionKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &dpopProver{
+		key:           key,
+		signingMethod: signingMethod,
+		jwk:           &jose.JSONWebKey{Key: key.Public()},
+		nonces:        make(map[string]string),
+	}, nil
+}
+
+// generateDPoPKey generates an EC P-256 key and writes it PEM encoded to the
+// path, so the tokens bound to it stay usable across restarts
+func generateDPoPKey(path string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate key: %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode key: %v", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("could not write key: %v", err)
+	}
+	logger.Printf("Generated a new DPoP key in %s", path)
+	return keyPEM, nil
+}
+
+// proof signs a DPoP proof for a request with the method to the URL. Proofs
+// for requests to resources are bound to the presented access token.
+func (d *dpopProver) proof(method string, target *url.URL, accessToken string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	htu := url.URL{Scheme: target.Scheme, Host: target.Host, Path: target.Path}
+	claims := jwt.MapClaims{
+		"jti": base64.RawURLEncoding.EncodeToString(jti),
+		"htm": method,
+		"htu": htu.String(),
+		"iat": time.Now().Unix(),
+	}
+	if nonce := d.getNonce(target); nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if accessToken != "" {
+		ath := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(ath[:])
+	}
+
+	token := jwt.NewWithClaims(d.signingMethod, claims)
+	token.Header["typ"] = dpopProofType
+	token.Header["jwk"] = d.jwk
+	return token.SignedString(d.key)
+}
+
+func (d *dpopProver) getNonce(target *url.URL) string {
+	d.nonceMutex.Lock()
+	defer d.nonceMutex.Unlock()
+	return d.nonces[dpopNonceOrigin(target)]
+}
+
+// updateNonce remembers the nonce sent by the server of the URL and reports
+// whether it changed
+func (d *dpopProver) updateNonce(target *url.URL, nonce string) bool {
+	if nonce == "" {
+		return false
+	}
+	origin := dpopNonceOrigin(target)
+	d.nonceMutex.Lock()
+	defer d.nonceMutex.Unlock()
+	changed := d.nonces[origin] != nonce
+	d.nonces[origin] = nonce
+	return changed
+}
+
+func dpopNonceOrigin(target *url.URL) string {
+	return strings.ToLower(target.Scheme + "://" + target.Host)
+}
+
+// dpopTransport adds a DPoP proof to the requests to the token endpoint, and
+// presents the access tokens of requests to resources, such as the userinfo
+// endpoint, with the DPoP scheme and a proof
+type dpopTransport struct {
+	next     http.RoundTripper
+	prover   *dpopProver
+	tokenURL func() *url.URL
+}
+
+// RoundTrip implements http.RoundTripper. Requests that are neither to the
+// token endpoint nor carry a bearer access token are sent unchanged. When the
+// server rejects a proof because it requires a new nonce, the request is sent
+// once more with a proof that carries the nonce.
+func (t *dpopTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The token endpoint rejects a missing nonce with a 400, resources with
+	// a 401 (RFC 9449 sections 8 and 9)
+	nonceStatus := http.StatusBadRequest
+	accessToken := ""
+	if !t.isTokenRequest(req) {
+		var ok bool
+		accessToken, ok = bearerToken(req)
+		if !ok {
+			return t.next.RoundTrip(req)
+		}
+		nonceStatus = http.StatusUnauthorized
+	}
+
+	resp, err := t.roundTripWithProof(req, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if !t.prover.updateNonce(req.URL, resp.Header.Get(dpopNonceHeader)) || resp.StatusCode != nonceStatus {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return t.roundTripWithProof(retry, accessToken)
+}
+
+func (t *dpopTransport) roundTripWithProof(req *http.Request, accessToken string) (*http.Response, error) {
+	proof, err := t.prover.proof(req.Method, req.URL, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign DPoP proof: %v", err)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(dpopHeader, proof)
+	if accessToken != "" {
+		req.Header.Set("Authorization", dpopScheme+" "+accessToken)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// bearerToken returns the access token of a request with the Bearer scheme
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, tokenTypeBearer) || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func (t *dpopTransport) isTokenRequest(req *http.Request) bool {
+	tokenURL := t.tokenURL()
+	return tokenURL != nil && req.Method == http.MethodPost &&
+		strings.EqualFold(req.URL.Scheme, tokenURL.Scheme) &&
+		strings.EqualFold(req.URL.Host, tokenURL.Host) &&
+		req.URL.Path == tokenURL.Path
+}
+
+// dpopClient wraps the HTTP client so that the requests to the token endpoint
+// and the requests with the access token carry a DPoP proof
+func (p *ProviderData) dpopClient(client *http.Client) *http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	wrapped := *client
+	wrapped.Transport = &dpopTransport{
+		next:     next,
+		prover:   p.dpop,
+		tokenURL: func() *url.URL { return p.RedeemURL },
+	}
+	return &wrapped
+}
@@ -0,0 +1,194 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseTestDPoPProof verifies a DPoP proof with its embedded key
+func parseTestDPoPProof(t *testing.T, proof string) (jwt.MapClaims, map[string]interface{}) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(proof, claims, func(token *jwt.Token) (interface{}, error) {
+		jwk, err := json.Marshal(token.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		var key jose.JSONWebKey
+		if err := key.UnmarshalJSON(jwk); err != nil {
+			return nil, err
+		}
+		return key.Key, nil
+	})
+	require.NoError(t, err)
+	return claims, token.Header
+}
+
+func TestNewDPoPProver(t *testing.T) {
+	prover, err := newDPoPProver(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, prover)
+
+	_, err = newDPoPProver(&options.DPoPOptions{})
+	assert.Error(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "dpop.pem")
+	generated, err := newDPoPProver(&options.DPoPOptions{KeyFile: keyFile})
+	require.NoError(t, err)
+	assert.Equal(t, jwt.SigningMethodES256, generated.signingMethod)
+
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The persisted key is loaded on the next start
+	loaded, err := newDPoPProver(&options.DPoPOptions{KeyFile: keyFile})
+	require.NoError(t, err)
+	assert.Equal(t, generated.jwk.Key, loaded.jwk.Key)
+
+	invalidKeyFile := filepath.Join(t.TempDir(), "invalid.pem")
+	require.NoError(t, os.WriteFile(invalidKeyFile, []byte("not a key"), 0600))
+	_, err = newDPoPProver(&options.DPoPOptions{KeyFile: invalidKeyFile})
+	assert.Error(t, err)
+}
+
+func TestOIDCProviderDPoP(t *testing.T) {
+	idToken, _ := newSignedTestIDToken(defaultIDToken)
+	body, _ := json.Marshal(redeemTokenResponse{
+		AccessToken:  accessToken,
+		ExpiresIn:    10,
+		TokenType:    "DPoP",
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+	})
+
+	var proofs []string
+	requireNonce := ""
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		proofs = append(proofs, r.Header.Get("DPoP"))
+		rw.Header().Set("Content-Type", "application/json")
+		if requireNonce != "" {
+			claims, _ := parseTestDPoPProof(t, r.Header.Get("DPoP"))
+			if claims["nonce"] != requireNonce {
+				rw.Header().Set("DPoP-Nonce", requireNonce)
+				rw.WriteHeader(http.StatusBadRequest)
+				_, _ = rw.Write([]byte(`{"error":"use_dpop_nonce"}`))
+				return
+			}
+		}
+		_, _ = rw.Write(body)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	provider := newOIDCProvider(serverURL, false)
+	var err error
+	provider.dpop, err = newDPoPProver(&options.DPoPOptions{KeyFile: filepath.Join(t.TempDir(), "dpop.pem")})
+	require.NoError(t, err)
+
+	t.Run("redeem sends a proof", func(t *testing.T) {
+		proofs = nil
+		_, err := provider.Redeem(context.Background(), "https://app.example.com/callback", "code1234", "")
+		require.NoError(t, err)
+
+		require.Len(t, proofs, 1)
+		claims, header := parseTestDPoPProof(t, proofs[0])
+		assert.Equal(t, "dpop+jwt", header["typ"])
+		assert.Equal(t, "POST", claims["htm"])
+		assert.Equal(t, server.URL+"/login/oauth/access_token", claims["htu"])
+		assert.NotEmpty(t, claims["jti"])
+		assert.NotEmpty(t, claims["iat"])
+		assert.NotContains(t, claims, "nonce")
+	})
+
+	t.Run("refresh retries with the nonce", func(t *testing.T) {
+		proofs = nil
+		requireNonce = "server-nonce"
+		defer func() { requireNonce = "" }()
+
+		session := &sessions.SessionState{RefreshToken: refreshToken}
+		refreshed, err := provider.RefreshSession(context.Background(), session)
+		require.NoError(t, err)
+		assert.True(t, refreshed)
+		assert.Equal(t, accessToken, session.AccessToken)
+
+		require.Len(t, proofs, 2)
+		first, _ := parseTestDPoPProof(t, proofs[0])
+		second, _ := parseTestDPoPProof(t, proofs[1])
+		assert.NotContains(t, first, "nonce")
+		assert.Equal(t, "server-nonce", second["nonce"])
+		assert.NotEqual(t, first["jti"], second["jti"])
+	})
+
+	t.Run("requests with an access token present it with the DPoP scheme", func(t *testing.T) {
+		var authorizations []string
+		resourceProofs := []string{}
+		resourceServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+			resourceProofs = append(resourceProofs, r.Header.Get("DPoP"))
+			claims, _ := parseTestDPoPProof(t, r.Header.Get("DPoP"))
+			if claims["nonce"] != "resource-nonce" {
+				rw.Header().Set("DPoP-Nonce", "resource-nonce")
+				rw.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = rw.Write([]byte(`{"email":"janed@me.com"}`))
+		}))
+		defer resourceServer.Close()
+
+		req, _ := http.NewRequest(http.MethodGet, resourceServer.URL+"/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		resp, err := provider.dpopClient(http.DefaultClient).Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.Equal(t, []string{"DPoP " + accessToken, "DPoP " + accessToken}, authorizations)
+		require.Len(t, resourceProofs, 2)
+		first, _ := parseTestDPoPProof(t, resourceProofs[0])
+		second, _ := parseTestDPoPProof(t, resourceProofs[1])
+		assert.Equal(t, "GET", second["htm"])
+		assert.Equal(t, resourceServer.URL+"/userinfo", second["htu"])
+		ath := sha256.Sum256([]byte(accessToken))
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(ath[:]), second["ath"])
+		assert.NotContains(t, first, "nonce")
+		assert.Equal(t, "resource-nonce", second["nonce"])
+
+		// The nonce of the resource server is not sent to the token endpoint
+		proofs = nil
+		_, err = provider.Redeem(context.Background(), "https://app.example.com/callback", "code1234", "")
+		require.NoError(t, err)
+		require.Len(t, proofs, 1)
+		claims, _ := parseTestDPoPProof(t, proofs[0])
+		assert.NotContains(t, claims, "ath")
+		assert.NotEqual(t, "resource-nonce", claims["nonce"])
+	})
+
+	t.Run("other requests don't send a proof", func(t *testing.T) {
+		proofs = nil
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/profile", nil)
+		client := provider.dpopClient(http.DefaultClient)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Len(t, proofs, 1)
+		assert.Empty(t, proofs[0])
+	})
+}
@@ -0,0 +1,166 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/util"
+)
+
+// GenericOAuth2Provider represents a plain OAuth2 Identity Provider that
+// exposes the user's profile as JSON rather than in an ID Token
+type GenericOAuth2Provider struct {
+	*ProviderData
+
+	EmailPath             string
+	UserPath              string
+	PreferredUsernamePath string
+	GroupsPath            string
+	GroupNameField        string
+}
+
+var _ Provider = (*GenericOAuth2Provider)(nil)
+
+const (
+	genericOAuth2ProviderName = "OAuth2"
+
+	genericOAuth2DefaultUserPath              = "sub"
+	genericOAuth2DefaultPreferredUsernamePath = "preferred_username"
+	genericOAuth2DefaultGroupNameField        = "name"
+)
+
+// NewGenericOAuth2Provider initiates a new GenericOAuth2Provider
+func NewGenericOAuth2Provider(p *ProviderData, opts options.GenericOAuth2Options) *GenericOAuth2Provider {
+	p.setProviderDefaults(providerDefaults{
+		name: genericOAuth2ProviderName,
+	})
+	p.getAuthorizationHeaderFunc = makeOIDCHeader
+	if p.ValidateURL.String() == "" {
+		// Without a dedicated validation endpoint, a successful profile
+		// request proves that the access token is still valid
+		p.ValidateURL = p.ProfileURL
+	}
+
+	provider := &GenericOAuth2Provider{
+		ProviderData:          p,
+		EmailPath:             opts.EmailPath,
+		UserPath:              opts.UserPath,
+		PreferredUsernamePath: opts.PreferredUsernamePath,
+		GroupsPath:            opts.GroupsPath,
+		GroupNameField:        opts.GroupNameField,
+	}
+
+	if provider.EmailPath == "" {
+		provider.EmailPath = options.OIDCEmailClaim
+	}
+	if provider.UserPath == "" {
+		provider.UserPath = genericOAuth2DefaultUserPath
+	}
+	if provider.PreferredUsernamePath == "" {
+		provider.PreferredUsernamePath = genericOAuth2DefaultPreferredUsernamePath
+	}
+	if provider.GroupsPath == "" {
+		provider.GroupsPath = options.OIDCGroupsClaim
+	}
+	if provider.GroupNameField == "" {
+		provider.GroupNameField = genericOAuth2DefaultGroupNameField
+	}
+
+	return provider
+}
+
+// EnrichSession uses the profile URL to populate the session's email, user,
+// preferred username and groups.
+func (p *GenericOAuth2Provider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
+	if p.ProfileURL == nil || p.ProfileURL.String() == "" {
+		return errors.New("profile URL is required for the generic OAuth2 provider")
+	}
+	if s.AccessToken == "" {
+		return errors.New("missing access token")
+	}
+
+	extractor := util.NewProfileClaimExtractor(p.ClientContext(ctx), p.ProfileURL, p.getAuthorizationHeader(s.AccessToken))
+
+	for _, c := range []struct {
+		path string
+		dst  *string
+	}{
+		{p.EmailPath, &s.Email},
+		{p.UserPath, &s.User},
+		{p.PreferredUsernamePath, &s.PreferredUsername},
+	} {
+		if _, err := extractor.GetClaimInto(c.path, c.dst); err != nil {
+			return err
+		}
+	}
+
+	if s.Email == "" {
+		return fmt.Errorf("unable to extract email from profile URL using path %q", p.EmailPath)
+	}
+	if s.User == "" {
+		s.User = s.Email
+	}
+
+	groups, exists, err := extractor.GetClaim(p.GroupsPath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		s.Groups, err = p.extractGroups(groups)
+		if err != nil {
+			return fmt.Errorf("unable to extract groups from profile URL using path %q: %v", p.GroupsPath, err)
+		}
+	}
+
+	if p.AdditionalClaims != nil {
+		p.extractAdditionalClaims(extractor, s)
+	}
+
+	return nil
+}
+
+// extractGroups converts the groups found in the profile into a list of group
+// names. Groups may be an array of strings, a comma separated string or an
+// array of objects holding the group name in GroupNameField.
+func (p *GenericOAuth2Provider) extractGroups(value interface{}) ([]string, error) {
+	groups := []string{}
+
+	switch v := value.(type) {
+	case string:
+		for _, group := range strings.Split(v, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				groups = append(groups, group)
+			}
+		}
+	case []interface{}:
+		for _, entry := range v {
+			switch e := entry.(type) {
+			case string:
+				if e != "" {
+					groups = append(groups, e)
+				}
+			case map[string]interface{}:
+				name, ok := e[p.GroupNameField].(string)
+				if !ok {
+					return nil, fmt.Errorf("group object has no string field %q", p.GroupNameField)
+				}
+				groups = append(groups, name)
+			default:
+				return nil, fmt.Errorf("unsupported group entry type %T", entry)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported groups type %T", value)
+	}
+
+	return groups, nil
+}
+
+// ValidateSession validates the AccessToken
+func (p *GenericOAuth2Provider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
+	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+}
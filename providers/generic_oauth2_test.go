@@ -0,0 +1,181 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/gomega"
+)
+
+func testGenericOAuth2Provider(hostname string, opts options.GenericOAuth2Options) *GenericOAuth2Provider {
+	p := NewGenericOAuth2Provider(
+		&ProviderData{
+			ProviderName: "",
+			LoginURL:     &url.URL{},
+			RedeemURL:    &url.URL{},
+			ProfileURL:   &url.URL{Path: "/api/me"},
+			ValidateURL:  &url.URL{},
+			Scope:        ""},
+		opts)
+	if hostname != "" {
+		updateURL(p.Data().LoginURL, hostname)
+		updateURL(p.Data().RedeemURL, hostname)
+		updateURL(p.Data().ProfileURL, hostname)
+	}
+	return p
+}
+
+func testGenericOAuth2Backend(payload string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/me" {
+				w.WriteHeader(404)
+			} else if !IsAuthorizedInHeader(r.Header) {
+				w.WriteHeader(403)
+			} else {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(200)
+				w.Write([]byte(payload))
+			}
+		}))
+}
+
+func TestNewGenericOAuth2Provider(t *testing.T) {
+	g := NewWithT(t)
+
+	p := NewGenericOAuth2Provider(&ProviderData{
+		ProfileURL:  &url.URL{Scheme: "https", Host: "example.com", Path: "/api/me"},
+		ValidateURL: &url.URL{},
+	}, options.GenericOAuth2Options{})
+	g.Expect(p.Data().ProviderName).To(Equal("OAuth2"))
+	g.Expect(p.Data().ValidateURL.String()).To(Equal("https://example.com/api/me"))
+	g.Expect(p.EmailPath).To(Equal("email"))
+	g.Expect(p.UserPath).To(Equal("sub"))
+	g.Expect(p.PreferredUsernamePath).To(Equal("preferred_username"))
+	g.Expect(p.GroupsPath).To(Equal("groups"))
+	g.Expect(p.GroupNameField).To(Equal("name"))
+}
+
+func TestGenericOAuth2ProviderEnrichSession(t *testing.T) {
+	testCases := map[string]struct {
+		payload       string
+		opts          options.GenericOAuth2Options
+		expectedError string
+		expected      *sessions.SessionState
+	}{
+		"default paths": {
+			payload: `{"sub": "1234", "email": "user@example.com", "preferred_username": "user", "groups": ["admins", "devs"]}`,
+			expected: &sessions.SessionState{
+				User:              "1234",
+				Email:             "user@example.com",
+				PreferredUsername: "user",
+				Groups:            []string{"admins", "devs"},
+			},
+		},
+		"nested paths": {
+			payload: `{"data": {"id": 1234, "mail": "user@example.com", "login": "user", "teams": ["admins"]}}`,
+			opts: options.GenericOAuth2Options{
+				EmailPath:             "data.mail",
+				UserPath:              "data.id",
+				PreferredUsernamePath: "data.login",
+				GroupsPath:            "data.teams",
+			},
+			expected: &sessions.SessionState{
+				User:              "1234",
+				Email:             "user@example.com",
+				PreferredUsername: "user",
+				Groups:            []string{"admins"},
+			},
+		},
+		"comma separated groups": {
+			payload: `{"email": "user@example.com", "groups": "admins, devs,,ops"}`,
+			expected: &sessions.SessionState{
+				User:   "user@example.com",
+				Email:  "user@example.com",
+				Groups: []string{"admins", "devs", "ops"},
+			},
+		},
+		"groups as objects": {
+			payload: `{"email": "user@example.com", "memberships": [{"id": 1, "slug": "admins"}, {"id": 2, "slug": "devs"}]}`,
+			opts: options.GenericOAuth2Options{
+				GroupsPath:     "memberships",
+				GroupNameField: "slug",
+			},
+			expected: &sessions.SessionState{
+				User:   "user@example.com",
+				Email:  "user@example.com",
+				Groups: []string{"admins", "devs"},
+			},
+		},
+		"group objects without the name field": {
+			payload:       `{"email": "user@example.com", "groups": [{"id": 1}]}`,
+			expectedError: `unable to extract groups from profile URL using path "groups": group object has no string field "name"`,
+		},
+		"missing email": {
+			payload:       `{"sub": "1234"}`,
+			expectedError: `unable to extract email from profile URL using path "email"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			b := testGenericOAuth2Backend(tc.payload)
+			defer b.Close()
+
+			bURL, _ := url.Parse(b.URL)
+			p := testGenericOAuth2Provider(bURL.Host, tc.opts)
+
+			session := CreateAuthorizedSession()
+			err := p.EnrichSession(context.Background(), session)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			tc.expected.AccessToken = session.AccessToken
+			g.Expect(session).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestGenericOAuth2ProviderEnrichSessionUsesProviderHTTPClient(t *testing.T) {
+	g := NewWithT(t)
+
+	b := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sub": "1234", "email": "user@example.com"}`))
+	}))
+	defer b.Close()
+	bURL, _ := url.Parse(b.URL)
+
+	p := testGenericOAuth2Provider("", options.GenericOAuth2Options{})
+	p.ProfileURL = &url.URL{Scheme: bURL.Scheme, Host: bURL.Host, Path: "/api/me"}
+	// Only the HTTP client of the provider trusts the certificate of the server
+	p.httpClient = b.Client()
+
+	session := CreateAuthorizedSession()
+	g.Expect(p.EnrichSession(context.Background(), session)).To(Succeed())
+	g.Expect(session.User).To(Equal("1234"))
+}
+
+func TestGenericOAuth2ProviderValidateSession(t *testing.T) {
+	g := NewWithT(t)
+
+	b := testGenericOAuth2Backend(`{"email": "user@example.com"}`)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testGenericOAuth2Provider(bURL.Host, options.GenericOAuth2Options{})
+	updateURL(p.Data().ValidateURL, bURL.Host)
+
+	g.Expect(p.ValidateSession(context.Background(), CreateAuthorizedSession())).To(BeTrue())
+	g.Expect(p.ValidateSession(context.Background(), &sessions.SessionState{AccessToken: "invalid"})).To(BeFalse())
+}
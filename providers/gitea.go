@@ -0,0 +1,336 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+)
+
+const (
+	giteaProviderName = "Gitea"
+	giteaDefaultScope = "openid email profile"
+	giteaAPIPath      = "/api/v1"
+	giteaPageLimit    = 50
+	giteaMaxPages     = 100
+)
+
+// giteaAPIPathPattern matches the base path of the Gitea API, also when
+// Gitea is served from a sub path
+var giteaAPIPathPattern = regexp.MustCompile(`^.*?/api/v\d+`)
+
+// GiteaProvider represents a Gitea or Forgejo based Identity Provider.
+// The login uses the OIDC endpoints of the instance, while organizations,
+// teams and repositories are read from the Gitea API.
+type GiteaProvider struct {
+	*OIDCProvider
+
+	apiURL *url.URL
+	repo   string
+	// Expose this for unit testing
+	oidcRefreshFunc func(context.Context, *sessions.SessionState) (bool, error)
+}
+
+var _ Provider = (*GiteaProvider)(nil)
+
+// NewGiteaProvider initiates a new GiteaProvider
+func NewGiteaProvider(p *ProviderData, opts options.Provider) (*GiteaProvider, error) {
+	validateURL, err := giteaValidateURL(opts.OIDCConfig.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse issuer URL: %v", err)
+	}
+
+	p.setProviderDefaults(providerDefaults{
+		name:        giteaProviderName,
+		validateURL: validateURL,
+		scope:       giteaDefaultScope,
+	})
+	if p.ValidateURL.Host == "" {
+		return nil, errors.New("could not determine the Gitea API URL: an issuer URL or validate URL is required")
+	}
+
+	oidcProvider := NewOIDCProvider(p, opts.OIDCConfig)
+
+	provider := &GiteaProvider{
+		OIDCProvider:    oidcProvider,
+		apiURL:          giteaAPIURL(p.ValidateURL),
+		repo:            opts.GiteaConfig.Repo,
+		oidcRefreshFunc: oidcProvider.RefreshSession,
+	}
+	provider.setAllowedOrgAndTeams(opts.GiteaConfig.Org, opts.GiteaConfig.Teams)
+
+	return provider, nil
+}
+
+// giteaValidateURL returns the authenticated user endpoint of the Gitea API
+// of the instance issuing the ID tokens
+func giteaValidateURL(issuerURL string) (*url.URL, error) {
+	if issuerURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(issuerURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join("/", u.Path, giteaAPIPath, "/user")
+	return u, nil
+}
+
+// giteaAPIURL returns the base URL of the Gitea API from the validate URL
+func giteaAPIURL(validateURL *url.URL) *url.URL {
+	apiURL := *validateURL
+	if match := giteaAPIPathPattern.FindString(apiURL.Path); match != "" {
+		apiURL.Path = match
+	}
+	apiURL.RawQuery = ""
+	return &apiURL
+}
+
+// setAllowedOrgAndTeams adds the allowed organization or teams to the
+// AllowedGroups list, which Authorize checks against the groups of the user.
+// Teams within the organization take precedence over the whole organization.
+func (p *GiteaProvider) setAllowedOrgAndTeams(org string, teams []string) {
+	var allowed []string
+	switch {
+	case len(teams) > 0:
+		for _, team := range teams {
+			if org != "" && !strings.Contains(team, orgTeamSeparator) {
+				team = org + orgTeamSeparator + team
+			}
+			allowed = append(allowed, team)
+		}
+	case org != "":
+		allowed = []string{org}
+	default:
+		return
+	}
+
+	if p.AllowedGroups == nil {
+		p.AllowedGroups = make(map[string]struct{}, len(allowed))
+	}
+	for _, group := range allowed {
+		p.AllowedGroups[group] = struct{}{}
+	}
+}
+
+func (p *GiteaProvider) makeAPIEndpoint(endpoint string, params url.Values) string {
+	u := *p.apiURL
+	u.Path = path.Join(u.Path, endpoint)
+	u.RawQuery = params.Encode()
+	return u.String()
+}
+
+// EnrichSession sets the username, organizations and teams of the user from
+// the Gitea API and checks the access to the required repository
+func (p *GiteaProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
+	if err := p.getUser(ctx, s); err != nil {
+		return fmt.Errorf("failed to retrieve user: %v", err)
+	}
+
+	groups, err := p.getOrgsAndTeams(ctx, s.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve organizations and teams: %v", err)
+	}
+	s.Groups = groups
+
+	if p.repo != "" {
+		hasAccess, err := p.hasRepoAccess(ctx, s.AccessToken)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve repository: %v", err)
+		}
+		if !hasAccess {
+			return errors.New("user doesn't have repository access")
+		}
+	}
+
+	return p.OIDCProvider.EnrichSession(ctx, s)
+}
+
+// RefreshSession refreshes the session with the OIDCProvider implementation,
+// then reads the organizations and teams of the user again, as the ID token
+// doesn't carry them. The existing groups are kept if they can't be read.
+func (p *GiteaProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	username := s.User
+	groups := s.Groups
+	// This will overwrite s.Groups with the new IDToken's `groups` claims
+	// and s.User with the `sub` claim.
+	refreshed, err := p.oidcRefreshFunc(ctx, s)
+	if !refreshed || err != nil {
+		return refreshed, err
+	}
+
+	s.User = username
+	s.Groups = groups
+	freshGroups, err := p.getOrgsAndTeams(ctx, s.AccessToken)
+	if err != nil {
+		logger.Errorf("Warning: could not refresh the organizations and teams of %s: %v", s.User, err)
+		return true, nil
+	}
+	s.Groups = freshGroups
+	return true, nil
+}
+
+// RevalidateSession reads the organizations and teams of the user again,
+// which are checked against the allowed organization and teams by Authorize,
+// and re-checks the access to the required repository
+func (p *GiteaProvider) RevalidateSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	groups, err := p.getOrgsAndTeams(ctx, s.AccessToken)
+	if err != nil {
+		return revalidationResult(s.User, fmt.Errorf("failed to retrieve organizations and teams: %w", err))
+	}
+
+	if p.repo != "" {
+		hasAccess, err := p.hasRepoAccess(ctx, s.AccessToken)
+		if err != nil {
+			return revalidationResult(s.User, fmt.Errorf("failed to retrieve repository: %w", err))
+		}
+		if !hasAccess {
+			logger.Printf("Gitea user %s no longer has access to repository %s", s.User, p.repo)
+			return false, nil
+		}
+	}
+
+	s.Groups = groups
+	return true, nil
+}
+
+// getUser sets the Gitea username of the user, as the subject of the ID
+// token is the numeric user ID
+func (p *GiteaProvider) getUser(ctx context.Context, s *sessions.SessionState) error {
+	// https://docs.gitea.com/api/1.24/#tag/user/operation/userGetCurrent
+	var user struct {
+		Login string `json:"login"`
+	}
+
+	err := requests.New(p.makeAPIEndpoint("/user", nil)).
+		WithContext(p.ClientContext(ctx)).
+		WithHeaders(makeOIDCHeader(s.AccessToken)).
+		Do().
+		UnmarshalInto(&user)
+	if err != nil {
+		return err
+	}
+
+	if user.Login != "" {
+		s.User = user.Login
+	}
+	return nil
+}
+
+type giteaOrganization struct {
+	// Name is the login name of the organization,
+	// older versions of Gitea only set the username
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+func (o *giteaOrganization) login() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return o.Username
+}
+
+type giteaTeam struct {
+	Name         string             `json:"name"`
+	Organization *giteaOrganization `json:"organization"`
+}
+
+// getOrgsAndTeams returns the organizations of the user as `org` and their
+// teams as `org:team`
+func (p *GiteaProvider) getOrgsAndTeams(ctx context.Context, accessToken string) ([]string, error) {
+	// https://docs.gitea.com/api/1.24/#tag/organization/operation/orgListCurrentUserOrgs
+	orgs, err := getGiteaPages[giteaOrganization](ctx, p, "/user/orgs", accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// https://docs.gitea.com/api/1.24/#tag/user/operation/userListTeams
+	teams, err := getGiteaPages[giteaTeam](ctx, p, "/user/teams", accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(orgs)+len(teams))
+	for _, org := range orgs {
+		groups = append(groups, org.login())
+	}
+	for _, team := range teams {
+		if team.Organization == nil {
+			continue
+		}
+		groups = append(groups, team.Organization.login()+orgTeamSeparator+team.Name)
+	}
+	return groups, nil
+}
+
+// getGiteaPages reads all pages of a paginated list endpoint of the Gitea API.
+// The page size may be capped by the server, so pages are read until a page
+// is empty or shorter than the first one, up to giteaMaxPages pages.
+func getGiteaPages[T any](ctx context.Context, p *GiteaProvider, endpoint, accessToken string) ([]T, error) {
+	var all []T
+	pageSize := 0
+	for page := 1; page <= giteaMaxPages; page++ {
+		params := url.Values{
+			"page":  {strconv.Itoa(page)},
+			"limit": {strconv.Itoa(giteaPageLimit)},
+		}
+
+		var items []T
+		err := requests.New(p.makeAPIEndpoint(endpoint, params)).
+			WithContext(p.ClientContext(ctx)).
+			WithHeaders(makeOIDCHeader(accessToken)).
+			Do().
+			UnmarshalInto(&items)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, items...)
+		if page == 1 {
+			pageSize = len(items)
+		}
+		if len(items) == 0 || len(items) < pageSize {
+			return all, nil
+		}
+	}
+	return nil, fmt.Errorf("%s has more than %d pages", endpoint, giteaMaxPages)
+}
+
+// hasRepoAccess checks whether the user can push to the required repository,
+// or can read it if it is private, as everyone can read public repositories
+func (p *GiteaProvider) hasRepoAccess(ctx context.Context, accessToken string) (bool, error) {
+	// https://docs.gitea.com/api/1.24/#tag/repository/operation/repoGet
+	var repo struct {
+		Permissions struct {
+			Pull bool `json:"pull"`
+			Push bool `json:"push"`
+		} `json:"permissions"`
+		Private bool `json:"private"`
+	}
+
+	result := requests.New(p.makeAPIEndpoint("/repos/"+p.repo, nil)).
+		WithContext(p.ClientContext(ctx)).
+		WithHeaders(makeOIDCHeader(accessToken)).
+		Do()
+	if result.StatusCode() == http.StatusNotFound {
+		// Private repositories are hidden from users without access
+		return false, nil
+	}
+	if err := result.UnmarshalInto(&repo); err != nil {
+		return false, err
+	}
+
+	return repo.Permissions.Push || (repo.Private && repo.Permissions.Pull), nil
+}
@@ -2,16 +2,22 @@ package providers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
 )
 
-func testGiteaProvider(hostname string, opts options.GitHubOptions) *GitHubProvider {
+func testGitHubProviderForGitea(hostname string, opts options.GitHubOptions) *GitHubProvider {
 	p := NewGitHubProvider(
 		&ProviderData{
 			ProviderName: "Gitea",
@@ -74,7 +80,7 @@ func TestGiteaProvider_ValidateSessionWithBaseUrl(t *testing.T) {
 	defer b.Close()
 
 	bURL, _ := url.Parse(b.URL)
-	p := testGiteaProvider(bURL.Host, options.GitHubOptions{})
+	p := testGitHubProviderForGitea(bURL.Host, options.GitHubOptions{})
 
 	session := CreateAuthorizedSession()
 
@@ -89,10 +95,310 @@ func TestGiteaProvider_ValidateSessionWithUserEmails(t *testing.T) {
 	defer b.Close()
 
 	bURL, _ := url.Parse(b.URL)
-	p := testGiteaProvider(bURL.Host, options.GitHubOptions{})
+	p := testGitHubProviderForGitea(bURL.Host, options.GitHubOptions{})
 
 	session := CreateAuthorizedSession()
 
 	valid := p.ValidateSession(context.Background(), session)
 	assert.True(t, valid)
 }
+
+// testGiteaAPIBackend serves the given payloads by request URI, for requests
+// authorized with the gitea_access_token
+func testGiteaAPIBackend(payloads map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer gitea_access_token" {
+				w.WriteHeader(401)
+				return
+			}
+			payload, ok := payloads[r.URL.RequestURI()]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			w.WriteHeader(200)
+			w.Write([]byte(payload))
+		}))
+}
+
+var _ = Describe("Gitea Provider Tests", func() {
+	const (
+		userPath      = "/api/v1/user"
+		orgsPage1     = "/api/v1/user/orgs?limit=50&page=1"
+		orgsPage2     = "/api/v1/user/orgs?limit=50&page=2"
+		teamsPage1    = "/api/v1/user/teams?limit=50&page=1"
+		teamsPage2    = "/api/v1/user/teams?limit=50&page=2"
+		repoPath      = "/api/v1/repos/org1/repo"
+		pushAccess    = `{"private": false, "permissions": {"pull": true, "push": true}}`
+		pullAccess    = `{"private": false, "permissions": {"pull": true, "push": false}}`
+		privateAccess = `{"private": true, "permissions": {"pull": true, "push": false}}`
+	)
+
+	var payloads map[string]string
+	var b *httptest.Server
+
+	newProvider := func(config options.GiteaOptions) *GiteaProvider {
+		p, err := NewGiteaProvider(&ProviderData{
+			LoginURL:    &url.URL{},
+			RedeemURL:   &url.URL{},
+			ProfileURL:  &url.URL{},
+			ValidateURL: &url.URL{},
+		}, options.Provider{
+			OIDCConfig:  options.OIDCOptions{IssuerURL: b.URL + "/"},
+			GiteaConfig: config,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return p
+	}
+
+	newSession := func() *sessions.SessionState {
+		return &sessions.SessionState{
+			AccessToken: "gitea_access_token",
+			Email:       "jdoe@example.com",
+			User:        "42",
+		}
+	}
+
+	BeforeEach(func() {
+		payloads = map[string]string{
+			userPath:   `{"id": 42, "login": "jdoe", "email": "jdoe@example.com"}`,
+			orgsPage1:  `[{"name": "org1"}, {"username": "org2"}]`,
+			orgsPage2:  `[{"name": "org3"}]`,
+			teamsPage1: `[{"name": "developers", "organization": {"name": "org1"}}]`,
+			teamsPage2: `[]`,
+		}
+		b = testGiteaAPIBackend(payloads)
+	})
+
+	AfterEach(func() {
+		b.Close()
+	})
+
+	Context("New Provider Init", func() {
+		It("uses the API of the issuer", func() {
+			p, err := NewGiteaProvider(&ProviderData{}, options.Provider{
+				OIDCConfig: options.OIDCOptions{IssuerURL: "https://example.com/gitea/"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(p.Data().ProviderName).To(Equal("Gitea"))
+			Expect(p.Data().Scope).To(Equal("openid email profile"))
+			Expect(p.Data().ValidateURL.String()).To(Equal("https://example.com/gitea/api/v1/user"))
+			Expect(p.apiURL.String()).To(Equal("https://example.com/gitea/api/v1"))
+		})
+
+		It("uses the API of the validate URL", func() {
+			validateURL, err := url.Parse("https://gitea.example.com/api/v1/user/emails")
+			Expect(err).ToNot(HaveOccurred())
+
+			p, err := NewGiteaProvider(&ProviderData{ValidateURL: validateURL}, options.Provider{
+				OIDCConfig: options.OIDCOptions{IssuerURL: "https://example.com/"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(p.Data().ValidateURL.String()).To(Equal("https://gitea.example.com/api/v1/user/emails"))
+			Expect(p.apiURL.String()).To(Equal("https://gitea.example.com/api/v1"))
+		})
+
+		It("fails without an issuer URL or validate URL", func() {
+			_, err := NewGiteaProvider(&ProviderData{}, options.Provider{})
+			Expect(err).To(MatchError("could not determine the Gitea API URL: an issuer URL or validate URL is required"))
+		})
+	})
+
+	Context("when restricting to organizations and teams", func() {
+		type allowedGroupsTableInput struct {
+			config         options.GiteaOptions
+			expectedGroups map[string]struct{}
+		}
+
+		DescribeTable("sets the allowed groups",
+			func(in allowedGroupsTableInput) {
+				p := newProvider(in.config)
+				if len(in.expectedGroups) == 0 {
+					Expect(p.AllowedGroups).To(BeEmpty())
+					return
+				}
+				Expect(p.AllowedGroups).To(Equal(in.expectedGroups))
+			},
+			Entry("without restrictions", allowedGroupsTableInput{
+				config: options.GiteaOptions{},
+			}),
+			Entry("with an organization", allowedGroupsTableInput{
+				config:         options.GiteaOptions{Org: "org1"},
+				expectedGroups: map[string]struct{}{"org1": {}},
+			}),
+			Entry("with teams of the organization", allowedGroupsTableInput{
+				config: options.GiteaOptions{Org: "org1", Teams: []string{"developers", "org2:admins"}},
+				expectedGroups: map[string]struct{}{
+					"org1:developers": {},
+					"org2:admins":     {},
+				},
+			}),
+			Entry("with teams without an organization", allowedGroupsTableInput{
+				config:         options.GiteaOptions{Teams: []string{"org2:admins"}},
+				expectedGroups: map[string]struct{}{"org2:admins": {}},
+			}),
+		)
+	})
+
+	Context("when enriching the session", func() {
+		It("sets the username, organizations and teams", func() {
+			p := newProvider(options.GiteaOptions{Org: "org1", Teams: []string{"developers"}})
+
+			session := newSession()
+			Expect(p.EnrichSession(context.Background(), session)).To(Succeed())
+			Expect(session.User).To(Equal("jdoe"))
+			Expect(session.Groups).To(Equal([]string{"org1", "org2", "org3", "org1:developers"}))
+
+			authorized, err := p.Authorize(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorized).To(BeTrue())
+		})
+
+		It("stops reading pages after the maximum number of pages", func() {
+			for page := 1; page <= giteaMaxPages+1; page++ {
+				payloads[fmt.Sprintf("/api/v1/user/orgs?limit=50&page=%d", page)] = fmt.Sprintf(`[{"name": "org%d"}]`, page)
+			}
+			p := newProvider(options.GiteaOptions{})
+
+			_, err := getGiteaPages[giteaOrganization](context.Background(), p, "/user/orgs", "gitea_access_token")
+			Expect(err).To(MatchError("/user/orgs has more than 100 pages"))
+		})
+
+		It("is not authorized without the required team", func() {
+			p := newProvider(options.GiteaOptions{Org: "org1", Teams: []string{"admins"}})
+
+			session := newSession()
+			Expect(p.EnrichSession(context.Background(), session)).To(Succeed())
+
+			authorized, err := p.Authorize(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorized).To(BeFalse())
+		})
+
+		It("fails when the organizations can't be read", func() {
+			delete(payloads, orgsPage1)
+			p := newProvider(options.GiteaOptions{})
+
+			err := p.EnrichSession(context.Background(), newSession())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("failed to retrieve organizations and teams:"))
+		})
+
+		type repoTableInput struct {
+			repoPayload *string
+			expectedErr error
+		}
+
+		DescribeTable("with a required repository",
+			func(in repoTableInput) {
+				if in.repoPayload != nil {
+					payloads[repoPath] = *in.repoPayload
+				}
+				p := newProvider(options.GiteaOptions{Repo: "org1/repo"})
+
+				err := p.EnrichSession(context.Background(), newSession())
+				if in.expectedErr != nil {
+					Expect(err).To(MatchError(in.expectedErr))
+				} else {
+					Expect(err).ToNot(HaveOccurred())
+				}
+			},
+			Entry("with push access to a public repository", repoTableInput{
+				repoPayload: ptr.To(pushAccess),
+			}),
+			Entry("with read access to a private repository", repoTableInput{
+				repoPayload: ptr.To(privateAccess),
+			}),
+			Entry("with read access to a public repository", repoTableInput{
+				repoPayload: ptr.To(pullAccess),
+				expectedErr: errors.New("user doesn't have repository access"),
+			}),
+			Entry("when the repository is hidden from the user", repoTableInput{
+				expectedErr: errors.New("user doesn't have repository access"),
+			}),
+		)
+	})
+
+	Context("when refreshing", func() {
+		It("keeps the username and reads the groups again", func() {
+			p := newProvider(options.GiteaOptions{})
+			p.oidcRefreshFunc = func(_ context.Context, s *sessions.SessionState) (bool, error) {
+				s.User = "42"
+				s.Groups = nil
+				return true, nil
+			}
+
+			session := newSession()
+			session.User = "jdoe"
+			session.Groups = []string{"old-org"}
+
+			refreshed, err := p.RefreshSession(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(refreshed).To(BeTrue())
+			Expect(session.User).To(Equal("jdoe"))
+			Expect(session.Groups).To(Equal([]string{"org1", "org2", "org3", "org1:developers"}))
+		})
+
+		It("keeps the existing groups when they can't be read", func() {
+			delete(payloads, teamsPage1)
+			p := newProvider(options.GiteaOptions{})
+			p.oidcRefreshFunc = func(_ context.Context, s *sessions.SessionState) (bool, error) {
+				s.Groups = nil
+				return true, nil
+			}
+
+			session := newSession()
+			session.Groups = []string{"org1", "org1:developers"}
+
+			refreshed, err := p.RefreshSession(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(refreshed).To(BeTrue())
+			Expect(session.Groups).To(Equal([]string{"org1", "org1:developers"}))
+		})
+
+		It("leaves the session when the OIDC refresh errors", func() {
+			p := newProvider(options.GiteaOptions{})
+			p.oidcRefreshFunc = func(_ context.Context, _ *sessions.SessionState) (bool, error) {
+				return false, errors.New("failure")
+			}
+
+			session := newSession()
+			session.Groups = []string{"org1"}
+
+			refreshed, err := p.RefreshSession(context.Background(), session)
+			Expect(err).To(MatchError("failure"))
+			Expect(refreshed).To(BeFalse())
+			Expect(session.Groups).To(Equal([]string{"org1"}))
+		})
+	})
+
+	Context("when revalidating", func() {
+		It("replaces the groups of the session", func() {
+			p := newProvider(options.GiteaOptions{Org: "org1"})
+
+			session := newSession()
+			session.Groups = []string{"old-org"}
+
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorized).To(BeTrue())
+			Expect(session.Groups).To(Equal([]string{"org1", "org2", "org3", "org1:developers"}))
+		})
+
+		It("is not authorized without access to the repository", func() {
+			payloads[repoPath] = pullAccess
+			p := newProvider(options.GiteaOptions{Repo: "org1/repo"})
+
+			session := newSession()
+			session.Groups = []string{"org1"}
+
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorized).To(BeFalse())
+			Expect(session.Groups).To(Equal([]string{"org1"}))
+		})
+	})
+})
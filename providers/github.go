@@ -152,6 +152,50 @@ func (p *GitHubProvider) ValidateSession(ctx context.Context, s *sessions.Sessio
 	return validateToken(ctx, p, s.AccessToken, makeGitHubHeader(s.AccessToken))
 }
 
+// RevalidateSession reads the organizations and teams of the user again and
+// re-runs the configured restrictions, so that users removed from them lose
+// access before their session expires
+func (p *GitHubProvider) RevalidateSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	revalidated := &sessions.SessionState{AccessToken: s.AccessToken, User: s.User, Email: s.Email}
+	if err := p.getOrgAndTeam(ctx, revalidated); err != nil {
+		return revalidationResult(s.User, err)
+	}
+
+	if err := p.checkRestrictions(ctx, revalidated); err != nil {
+		var requestErr *gitHubRequestError
+		if errors.As(err, &requestErr) {
+			return revalidationResult(s.User, err)
+		}
+		logger.Printf("GitHub restrictions no longer met for %s: %v", s.User, err)
+		return false, nil
+	}
+
+	// The collaborator check of the login is made with the configured token,
+	// GitHub answers with a 404 once the user is no longer a collaborator
+	if !p.isVerifiedUser(s.User) && p.Org == "" && p.Repo != "" && p.Token != "" {
+		if _, err := p.isCollaborator(ctx, s.User, p.Token); err != nil {
+			return revalidationResult(s.User, err)
+		}
+	}
+
+	s.Groups = revalidated.Groups
+	return true, nil
+}
+
+// gitHubRequestError is returned by the restriction checks when the GitHub API
+// request made for the check fails, as opposed to a restriction not being met
+type gitHubRequestError struct {
+	err error
+}
+
+func (e *gitHubRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *gitHubRequestError) Unwrap() error {
+	return e.err
+}
+
 func (p *GitHubProvider) hasOrg(s *sessions.SessionState) error {
 	// https://developer.github.com/v3/orgs/#list-your-organizations
 	var orgs []string
@@ -269,13 +313,13 @@ func (p *GitHubProvider) hasRepoAccess(ctx context.Context, accessToken string)
 
 	var repo repository
 	err := requests.New(endpoint.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithHeaders(makeGitHubHeader(accessToken)).
 		Do().
 		UnmarshalInto(&repo)
 
 	if err != nil {
-		return err
+		return &gitHubRequestError{fmt.Errorf("unable to read repository %s: %w", p.Repo, err)}
 	}
 
 	// Every user can implicitly pull from a public repo, so only grant access
@@ -298,12 +342,12 @@ func (p *GitHubProvider) hasUser(ctx context.Context, accessToken string) (bool,
 	endpoint := p.makeGitHubAPIEndpoint("/user", nil)
 
 	err := requests.New(endpoint.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithHeaders(makeGitHubHeader(accessToken)).
 		Do().
 		UnmarshalInto(&user)
 	if err != nil {
-		return false, err
+		return false, &gitHubRequestError{fmt.Errorf("unable to read user: %w", err)}
 	}
 
 	if p.isVerifiedUser(user.Login) {
@@ -317,7 +361,7 @@ func (p *GitHubProvider) isCollaborator(ctx context.Context, username, accessTok
 
 	endpoint := p.makeGitHubAPIEndpoint("/repos/"+p.Repo+"/collaborators/"+username, nil)
 	result := requests.New(endpoint.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithHeaders(makeGitHubHeader(accessToken)).
 		Do()
 	if result.Error() != nil {
@@ -325,8 +369,8 @@ func (p *GitHubProvider) isCollaborator(ctx context.Context, username, accessTok
 	}
 
 	if result.StatusCode() != 204 {
-		return false, fmt.Errorf("got %d from %q %s",
-			result.StatusCode(), endpoint.String(), result.Body())
+		return false, fmt.Errorf("unable to check collaborator %s of %s: %w", username, p.Repo,
+			&requests.UnexpectedStatusError{StatusCode: result.StatusCode(), Body: result.Body()})
 	}
 
 	logger.Printf("got %d from %q %s", result.StatusCode(), endpoint.String(), result.Body())
@@ -346,7 +390,7 @@ func (p *GitHubProvider) getEmail(ctx context.Context, s *sessions.SessionState)
 	endpoint := p.makeGitHubAPIEndpoint("/user/emails", nil)
 
 	err := requests.New(endpoint.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithHeaders(makeGitHubHeader(s.AccessToken)).
 		Do().
 		UnmarshalInto(&emails)
@@ -376,7 +420,7 @@ func (p *GitHubProvider) getUser(ctx context.Context, s *sessions.SessionState)
 	endpoint := p.makeGitHubAPIEndpoint("/user", nil)
 
 	err := requests.New(endpoint.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithHeaders(makeGitHubHeader(s.AccessToken)).
 		Do().
 		UnmarshalInto(&user)
@@ -481,7 +525,7 @@ func (p *GitHubProvider) getOrgs(ctx context.Context, s *sessions.SessionState)
 
 		var orgs []Organization
 		err := requests.New(endpoint.String()).
-			WithContext(ctx).
+			WithContext(p.ClientContext(ctx)).
 			WithHeaders(makeGitHubHeader(s.AccessToken)).
 			Do().
 			UnmarshalInto(&orgs)
@@ -533,7 +577,7 @@ func (p *GitHubProvider) getTeams(ctx context.Context, s *sessions.SessionState)
 
 		var teams []Team
 		err := requests.New(endpoint.String()).
-			WithContext(ctx).
+			WithContext(p.ClientContext(ctx)).
 			WithHeaders(makeGitHubHeader(s.AccessToken)).
 			Do().
 			UnmarshalInto(&teams)
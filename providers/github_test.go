@@ -297,6 +297,168 @@ func TestGitHubProvider_checkRestrictionsTeam(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestGitHubProvider_RevalidateSession(t *testing.T) {
+	b := testGitHubBackend(map[string][]string{
+		"/user/orgs": {
+			`[ { "login": "test-org-1" } ]`,
+			`[ ]`,
+		},
+		"/user/teams": {
+			`[ { "name":"test-team-1", "slug":"test-team-1", "organization": { "login": "test-org-1" } } ]`,
+			`[ ]`,
+		},
+	})
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+
+	p := testGitHubProvider(bURL.Host,
+		options.GitHubOptions{
+			Org:  "test-org-1",
+			Team: "test-team-1",
+		},
+	)
+	session := CreateAuthorizedSession()
+	session.Groups = []string{"stale-org"}
+	authorized, err := p.RevalidateSession(context.Background(), session)
+	assert.NoError(t, err)
+	assert.True(t, authorized)
+	assert.Contains(t, session.Groups, "test-org-1:test-team-1")
+	assert.NotContains(t, session.Groups, "stale-org")
+
+	// The user has been removed from the required team
+	p = testGitHubProvider(bURL.Host,
+		options.GitHubOptions{
+			Org:  "test-org-1",
+			Team: "test-team-2",
+		},
+	)
+	session = CreateAuthorizedSession()
+	session.Groups = []string{"test-org-1:test-team-2"}
+	authorized, err = p.RevalidateSession(context.Background(), session)
+	assert.NoError(t, err)
+	assert.False(t, authorized)
+	assert.Equal(t, []string{"test-org-1:test-team-2"}, session.Groups)
+}
+
+func TestGitHubProvider_RevalidateSessionWithRevokedToken(t *testing.T) {
+	for name, tc := range map[string]struct {
+		status      int
+		expectError bool
+	}{
+		"revoked token":      {status: http.StatusUnauthorized, expectError: false},
+		"forbidden token":    {status: http.StatusForbidden, expectError: false},
+		"rate limited":       {status: http.StatusTooManyRequests, expectError: true},
+		"GitHub unavailable": {status: http.StatusServiceUnavailable, expectError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.status)
+			}))
+			defer b.Close()
+
+			bURL, _ := url.Parse(b.URL)
+			p := testGitHubProvider(bURL.Host, options.GitHubOptions{Org: "test-org-1"})
+
+			session := CreateAuthorizedSession()
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			assert.False(t, authorized)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGitHubProvider_RevalidateSessionRestrictionRequests(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts        options.GitHubOptions
+		path        string
+		status      int
+		body        string
+		expectAuthz bool
+		expectError bool
+	}{
+		"repository access": {
+			opts:        options.GitHubOptions{Repo: "oauth2-proxy/oauth2-proxy"},
+			path:        "/repos/oauth2-proxy/oauth2-proxy",
+			status:      http.StatusOK,
+			body:        `{"permissions": {"pull": true, "push": true}, "private": false}`,
+			expectAuthz: true,
+		},
+		"repository access removed": {
+			opts:   options.GitHubOptions{Repo: "oauth2-proxy/oauth2-proxy"},
+			path:   "/repos/oauth2-proxy/oauth2-proxy",
+			status: http.StatusOK,
+			body:   `{"permissions": {"pull": true, "push": false}, "private": false}`,
+		},
+		"repository no longer visible": {
+			opts:   options.GitHubOptions{Repo: "oauth2-proxy/oauth2-proxy"},
+			path:   "/repos/oauth2-proxy/oauth2-proxy",
+			status: http.StatusNotFound,
+		},
+		"repository unavailable": {
+			opts:        options.GitHubOptions{Repo: "oauth2-proxy/oauth2-proxy"},
+			path:        "/repos/oauth2-proxy/oauth2-proxy",
+			status:      http.StatusServiceUnavailable,
+			expectError: true,
+		},
+		"user unavailable": {
+			opts:        options.GitHubOptions{Users: []string{"mbland"}},
+			path:        "/user",
+			status:      http.StatusServiceUnavailable,
+			expectError: true,
+		},
+		"collaborator": {
+			opts:        options.GitHubOptions{Repo: "oauth2-proxy/oauth2-proxy", Token: "token"},
+			path:        "/repos/oauth2-proxy/oauth2-proxy/collaborators/mbland",
+			status:      http.StatusNoContent,
+			expectAuthz: true,
+		},
+		"collaborator removed": {
+			opts:   options.GitHubOptions{Repo: "oauth2-proxy/oauth2-proxy", Token: "token"},
+			path:   "/repos/oauth2-proxy/oauth2-proxy/collaborators/mbland",
+			status: http.StatusNotFound,
+		},
+		"collaborators unavailable": {
+			opts:        options.GitHubOptions{Repo: "oauth2-proxy/oauth2-proxy", Token: "token"},
+			path:        "/repos/oauth2-proxy/oauth2-proxy/collaborators/mbland",
+			status:      http.StatusServiceUnavailable,
+			expectError: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/user/orgs", "/user/teams":
+					w.Write([]byte(`[]`))
+				case tc.path:
+					w.WriteHeader(tc.status)
+					w.Write([]byte(tc.body))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer b.Close()
+
+			bURL, _ := url.Parse(b.URL)
+			p := testGitHubProvider(bURL.Host, tc.opts)
+
+			session := CreateAuthorizedSession()
+			session.User = "mbland"
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			assert.Equal(t, tc.expectAuthz, authorized)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestGitHubProvider_getEmailWithWriteAccessToPublicRepo(t *testing.T) {
 	b := testGitHubBackend(map[string][]string{
 		"/repo/oauth2-proxy/oauth2-proxy": {`{"permissions": {"pull": true, "push": true}, "private": false}`},
@@ -2,7 +2,9 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -166,12 +168,12 @@ func (p *GitLabProvider) getUserinfo(ctx context.Context, s *sessions.SessionSta
 
 	var userinfo gitlabUserinfo
 	err := requests.New(userinfoURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		SetHeader("Authorization", tokenTypeBearer+" "+s.AccessToken).
 		Do().
 		UnmarshalInto(&userinfo)
 	if err != nil {
-		return nil, fmt.Errorf("error getting user info: %v", err)
+		return nil, fmt.Errorf("error getting user info: %w", err)
 	}
 
 	return &userinfo, nil
@@ -183,40 +185,56 @@ func (p *GitLabProvider) getUserinfo(ctx context.Context, s *sessions.SessionSta
 func (p *GitLabProvider) addProjectsToSession(ctx context.Context, s *sessions.SessionState) {
 	// Iterate over projects, check if oauth2-proxy can get project information on behalf of the user
 	for _, project := range p.allowedProjects {
-		projectInfo, err := p.getProjectInfo(ctx, s, project.Name)
-		if err != nil {
+		if err := p.addProjectToSession(ctx, s, project); err != nil {
 			logger.Errorf("Warning: project info request failed: %v", err)
-			continue
 		}
+	}
+}
 
-		if projectInfo.Archived {
-			logger.Errorf("Warning: project %s is archived", project.Name)
-			continue
-		}
+// addProjectToSession adds the project to the session state groups list when
+// the user meets its access requirements. Projects the user can't see, that
+// are archived or that the user lacks the access level for are left out;
+// an error is only returned when the project info request fails.
+func (p *GitLabProvider) addProjectToSession(ctx context.Context, s *sessions.SessionState, project *gitlabProject) error {
+	projectInfo, err := p.getProjectInfo(ctx, s, project.Name)
+	var statusErr *requests.UnexpectedStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		// GitLab hides the projects the user has no access to
+		logger.Errorf("Warning: user %q has no access to %s", s.Email, project.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-		perms := projectInfo.Permissions.ProjectAccess
-		if perms == nil {
-			// use group project access as fallback
-			perms = projectInfo.Permissions.GroupAccess
-			// group project access is not set for this user then we give up
-			if perms == nil {
-				logger.Errorf("Warning: user %q has no project level access to %s",
-					s.Email, project.Name)
-				continue
-			}
-		}
+	if projectInfo.Archived {
+		logger.Errorf("Warning: project %s is archived", project.Name)
+		return nil
+	}
 
-		if perms.AccessLevel < project.AccessLevel {
-			logger.Errorf(
-				"Warning: user %q does not have the minimum required access level for project %q",
-				s.Email,
-				project.Name,
-			)
-			continue
+	perms := projectInfo.Permissions.ProjectAccess
+	if perms == nil {
+		// use group project access as fallback
+		perms = projectInfo.Permissions.GroupAccess
+		// group project access is not set for this user then we give up
+		if perms == nil {
+			logger.Errorf("Warning: user %q has no project level access to %s",
+				s.Email, project.Name)
+			return nil
 		}
+	}
 
-		s.Groups = append(s.Groups, formatProject(project))
+	if perms.AccessLevel < project.AccessLevel {
+		logger.Errorf(
+			"Warning: user %q does not have the minimum required access level for project %q",
+			s.Email,
+			project.Name,
+		)
+		return nil
 	}
+
+	s.Groups = append(s.Groups, formatProject(project))
+	return nil
 }
 
 type gitlabPermissionAccess struct {
@@ -245,12 +263,12 @@ func (p *GitLabProvider) getProjectInfo(ctx context.Context, s *sessions.Session
 	}
 
 	err := requests.New(fmt.Sprintf("%s%s", endpointURL.String(), url.QueryEscape(project))).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		SetHeader("Authorization", tokenTypeBearer+" "+s.AccessToken).
 		Do().
 		UnmarshalInto(&projectInfo)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get project info: %v", err)
+		return nil, fmt.Errorf("failed to get project info: %w", err)
 	}
 
 	return &projectInfo, nil
@@ -276,6 +294,25 @@ func (p *GitLabProvider) RefreshSession(ctx context.Context, s *sessions.Session
 	return refreshed, err
 }
 
+// RevalidateSession reads the groups and projects of the user again, which
+// are checked against the allowed groups and projects by Authorize
+func (p *GitLabProvider) RevalidateSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	userinfo, err := p.getUserinfo(ctx, s)
+	if err != nil {
+		return revalidationResult(s.User, fmt.Errorf("failed to retrieve user info: %w", err))
+	}
+
+	revalidated := &sessions.SessionState{AccessToken: s.AccessToken, Email: s.Email, Groups: userinfo.Groups}
+	for _, project := range p.allowedProjects {
+		if err := p.addProjectToSession(ctx, revalidated, project); err != nil {
+			return revalidationResult(s.User, err)
+		}
+	}
+
+	s.Groups = revalidated.Groups
+	return true, nil
+}
+
 func getSessionProjects(s *sessions.SessionState) []string {
 	var projects []string
 	for _, group := range s.Groups {
@@ -68,7 +68,7 @@ func testGitLabBackend() *httptest.Server {
 			"path_with_namespace": "no_access_group/no_access_project",
 			"permissions": {
 				"project_access": null,
-				"group_access": null,
+				"group_access": null
 			}
 		}
 	`
@@ -145,6 +145,8 @@ func testGitLabBackend() *httptest.Server {
 				}
 			case "/api/v4/projects/my_group/my_bad_project":
 				w.WriteHeader(403)
+			case "/api/v4/projects/my_group/my_unavailable_project":
+				w.WriteHeader(503)
 			default:
 				w.WriteHeader(404)
 			}
@@ -387,4 +389,114 @@ var _ = Describe("Gitlab Provider Tests", func() {
 				To(ContainElements([]string{"foo", "bar", "project:thing", "project:sample"}))
 		})
 	})
+
+	Context("when revalidating", func() {
+		It("replaces the groups and projects of the session", func() {
+			bURL, err := url.Parse(b.URL)
+			Expect(err).To(BeNil())
+
+			p, err := testGitLabProvider(bURL.Host, "", options.Provider{
+				GitLabConfig: options.GitLabOptions{
+					Projects: []string{"my_group/my_project"},
+				},
+			})
+			Expect(err).To(BeNil())
+
+			session := &sessions.SessionState{AccessToken: "gitlab_access_token"}
+			session.Groups = []string{"stale", "project:stale/project"}
+
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorized).To(BeTrue())
+			Expect(session.Groups).To(Equal([]string{"foo", "bar", "project:my_group/my_project"}))
+		})
+		It("drops the projects the user no longer has access to", func() {
+			bURL, err := url.Parse(b.URL)
+			Expect(err).To(BeNil())
+
+			p, err := testGitLabProvider(bURL.Host, "", options.Provider{
+				GitLabConfig: options.GitLabOptions{
+					Projects: []string{
+						"my_group/my_project=40",
+						"no_access_group/no_access_project",
+						"my_group/my_archived_project",
+						"my_group/my_deleted_project",
+						"my_profile/my_personal_project",
+					},
+				},
+			})
+			Expect(err).To(BeNil())
+
+			session := &sessions.SessionState{AccessToken: "gitlab_access_token"}
+
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorized).To(BeTrue())
+			Expect(session.Groups).To(Equal([]string{"foo", "bar", "project:my_profile/my_personal_project"}))
+		})
+		It("returns an error when a project can't be read", func() {
+			bURL, err := url.Parse(b.URL)
+			Expect(err).To(BeNil())
+
+			p, err := testGitLabProvider(bURL.Host, "", options.Provider{
+				GitLabConfig: options.GitLabOptions{
+					Projects: []string{"my_group/my_project", "my_group/my_unavailable_project"},
+				},
+			})
+			Expect(err).To(BeNil())
+
+			session := &sessions.SessionState{AccessToken: "gitlab_access_token"}
+			session.Groups = []string{"foo", "bar", "project:my_group/my_unavailable_project"}
+
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			Expect(err).To(HaveOccurred())
+			Expect(authorized).To(BeFalse())
+			Expect(session.Groups).To(Equal([]string{"foo", "bar", "project:my_group/my_unavailable_project"}))
+		})
+		It("is no longer authorized when the provider rejects a project request", func() {
+			bURL, err := url.Parse(b.URL)
+			Expect(err).To(BeNil())
+
+			p, err := testGitLabProvider(bURL.Host, "", options.Provider{
+				GitLabConfig: options.GitLabOptions{
+					Projects: []string{"my_group/my_bad_project"},
+				},
+			})
+			Expect(err).To(BeNil())
+
+			session := &sessions.SessionState{AccessToken: "gitlab_access_token"}
+
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorized).To(BeFalse())
+		})
+		It("is no longer authorized when the token is revoked", func() {
+			session := &sessions.SessionState{AccessToken: "revoked_access_token"}
+			session.Groups = []string{"foo", "bar"}
+
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorized).To(BeFalse())
+			Expect(session.Groups).To(Equal([]string{"foo", "bar"}))
+		})
+		It("returns an error when GitLab is unavailable", func() {
+			unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer unavailable.Close()
+			bURL, err := url.Parse(unavailable.URL)
+			Expect(err).To(BeNil())
+
+			p, err := testGitLabProvider(bURL.Host, "", options.Provider{})
+			Expect(err).To(BeNil())
+
+			session := &sessions.SessionState{AccessToken: "gitlab_access_token"}
+			session.Groups = []string{"foo", "bar"}
+
+			authorized, err := p.RevalidateSession(context.Background(), session)
+			Expect(err).To(HaveOccurred())
+			Expect(authorized).To(BeFalse())
+			Expect(session.Groups).To(Equal([]string{"foo", "bar"}))
+		})
+	})
 })
@@ -42,6 +42,15 @@ type GoogleProvider struct {
 	// Since it is called on every request.
 	groupValidator func(*sessions.SessionState) bool
 
+	// transitiveGroups includes the groups the user is a nested member of
+	transitiveGroups bool
+	// groupMembershipCache holds the groups that users and groups are direct
+	// members of, shared by all sessions
+	groupMembershipCache *ttlCache[[]string]
+	// groupMemberCache holds whether users are members of the allowed groups
+	// without transitive groups, shared by all sessions
+	groupMemberCache *ttlCache[bool]
+
 	setPreferredUsername func(s *sessions.SessionState) error
 }
 
@@ -107,6 +116,10 @@ func NewGoogleProvider(p *ProviderData, opts options.GoogleOptions) (*GoogleProv
 		setPreferredUsername: func(_ *sessions.SessionState) error {
 			return nil
 		},
+
+		transitiveGroups:     ptr.Deref(opts.TransitiveGroups, options.DefaultGoogleTransitiveGroups),
+		groupMembershipCache: newTTLCache[[]string](ptr.Deref(opts.GroupMembershipCacheTTL, options.DefaultGoogleGroupMembershipCacheTTL)),
+		groupMemberCache:     newTTLCache[bool](ptr.Deref(opts.GroupMembershipCacheTTL, options.DefaultGoogleGroupMembershipCacheTTL)),
 	}
 
 	if ptr.Deref(opts.UseOrganizationID, options.DefaultGoogleUseOrganizationID) || opts.ServiceAccountJSON != "" || ptr.Deref(opts.UseApplicationDefaultCredentials, options.DefaultUseApplicationDefaultCredentials) {
@@ -120,7 +133,7 @@ func NewGoogleProvider(p *ProviderData, opts options.GoogleOptions) (*GoogleProv
 				possibleScopesList[index] = scope + " " + userScope
 			}
 
-			adminService = getAdminService(opts)
+			adminService = getAdminService(provider.ClientContext(context.Background()), opts)
 
 			provider.setPreferredUsername = func(s *sessions.SessionState) error {
 				userName, err := getUserInfo(adminService, s.Email)
@@ -134,7 +147,7 @@ func NewGoogleProvider(p *ProviderData, opts options.GoogleOptions) (*GoogleProv
 
 		if opts.ServiceAccountJSON != "" || ptr.Deref(opts.UseApplicationDefaultCredentials, options.DefaultUseApplicationDefaultCredentials) {
 			if adminService == nil {
-				adminService = getAdminService(opts)
+				adminService = getAdminService(provider.ClientContext(context.Background()), opts)
 			}
 			provider.configureGroups(opts, adminService)
 		}
@@ -158,6 +171,10 @@ func (p *GoogleProvider) configureGroups(opts options.GoogleOptions, adminServic
 	// Backwards compatibility with `--google-group` option
 	if len(opts.Groups) > 0 {
 		p.setAllowedGroups(opts.Groups)
+		if p.transitiveGroups {
+			p.groupValidator = p.setTransitiveGroupRestriction(opts.Groups, adminService)
+			return
+		}
 		p.groupValidator = p.setGroupRestriction(opts.Groups, adminService)
 		return
 	}
@@ -218,7 +235,7 @@ func (p *GoogleProvider) Redeem(ctx context.Context, redirectURL, code, codeVeri
 	}
 
 	err = requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithMethod("POST").
 		WithBody(bytes.NewBufferString(params.Encode())).
 		SetHeader("Content-Type", "application/x-www-form-urlencoded").
@@ -268,7 +285,7 @@ func (p *GoogleProvider) setGroupRestriction(groups []string, adminService *admi
 		// This is used by `Authorize` on every request
 		s.Groups = make([]string, 0, len(groups))
 		for _, group := range groups {
-			if userInGroup(adminService, group, s.Email) {
+			if p.isGroupMember(adminService, group, s.Email) {
 				s.Groups = append(s.Groups, group)
 			}
 		}
@@ -276,13 +293,39 @@ func (p *GoogleProvider) setGroupRestriction(groups []string, adminService *admi
 	}
 }
 
+// setTransitiveGroupRestriction configures the GoogleProvider to restrict
+// access to the specified group(s), including nested memberships.
+func (p *GoogleProvider) setTransitiveGroupRestriction(groups []string, adminService *admin.Service) func(*sessions.SessionState) bool {
+	return func(s *sessions.SessionState) bool {
+		// Reset our saved Groups in case membership changed
+		// This is used by `Authorize` on every request
+		s.Groups = make([]string, 0, len(groups))
+
+		userGroups, err := p.getGroupMemberships(adminService, s.Email)
+		if err != nil {
+			logger.Errorf("Failed to get user groups for %s: %v", s.Email, err)
+			return false
+		}
+
+		for _, group := range groups {
+			for _, userGroup := range userGroups {
+				if strings.EqualFold(group, userGroup) {
+					s.Groups = append(s.Groups, group)
+					break
+				}
+			}
+		}
+		return len(s.Groups) > 0
+	}
+}
+
 // populateAllGroups configures the GoogleProvider to allow access with all
 // groups and populate session with all groups of the user when no specific
 // groups are configured.
 func (p *GoogleProvider) populateAllGroups(adminService *admin.Service) func(s *sessions.SessionState) bool {
 	return func(s *sessions.SessionState) bool {
 		// Get all groups of the user
-		groups, err := getUserGroups(adminService, s.Email)
+		groups, err := p.getGroupMemberships(adminService, s.Email)
 		if err != nil {
 			logger.Errorf("Failed to get user groups for %s: %v", s.Email, err)
 			s.Groups = []string{}
@@ -338,9 +381,9 @@ func getOauth2TokenSource(ctx context.Context, opts options.GoogleOptions, scope
 // getAdminService retrieves an oauth token for the admin api of Google
 // AdminEmail has to be an administrative email on the domain that is
 // checked. CredentialsFile is the path to a json file containing a Google service
-// account credentials.
-func getAdminService(opts options.GoogleOptions) *admin.Service {
-	ctx := context.Background()
+// account credentials. The requests are made with the HTTP client of the
+// context.
+func getAdminService(ctx context.Context, opts options.GoogleOptions) *admin.Service {
 	var client *http.Client
 
 	for _, scope := range possibleScopesList {
@@ -433,7 +476,7 @@ func getUserInfo(service *admin.Service, email string) (string, error) {
 	return "", fmt.Errorf("failed to get organization id for %s", email)
 }
 
-// getUserGroups retrieves all groups that a user is a member of using the Google Admin Directory API
+// getUserGroups retrieves all groups that a user or group is a direct member of using the Google Admin Directory API
 func getUserGroups(service *admin.Service, email string) ([]string, error) {
 	var allGroups []string
 	var pageToken string
@@ -465,12 +508,80 @@ func getUserGroups(service *admin.Service, email string) ([]string, error) {
 	return allGroups, nil
 }
 
-func userInGroup(service *admin.Service, group string, email string) bool {
+// getGroupMemberships returns the groups that the user is a member of,
+// including the nested memberships when transitive groups are enabled
+func (p *GoogleProvider) getGroupMemberships(service *admin.Service, email string) ([]string, error) {
+	if !p.transitiveGroups {
+		return p.getDirectGroups(service, email)
+	}
+
+	// Walk up the group hierarchy breadth first, as the groups of a group
+	// are listed the same way as the groups of a user
+	var groups []string
+	seen := map[string]bool{}
+	memberKeys := []string{email}
+	for len(memberKeys) > 0 {
+		direct, err := p.getDirectGroups(service, memberKeys[0])
+		if err != nil {
+			return nil, err
+		}
+		memberKeys = memberKeys[1:]
+
+		for _, group := range direct {
+			key := strings.ToLower(group)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			groups = append(groups, group)
+			memberKeys = append(memberKeys, group)
+		}
+	}
+	return groups, nil
+}
+
+// getDirectGroups returns the groups that the user or group is a direct
+// member of, from the shared membership cache when possible
+func (p *GoogleProvider) getDirectGroups(service *admin.Service, memberKey string) ([]string, error) {
+	key := strings.ToLower(memberKey)
+	groups, ok := p.groupMembershipCache.get(key)
+	if !ok {
+		var err error
+		groups, err = getUserGroups(service, memberKey)
+		if err != nil {
+			return nil, err
+		}
+		p.groupMembershipCache.set(key, groups, nil)
+	}
+	// Copy the cached groups, as sessions may change their groups
+	return append([]string{}, groups...), nil
+}
+
+// isGroupMember checks whether the user is a member of the group, from the
+// shared membership cache when possible. Failed checks are not cached.
+func (p *GoogleProvider) isGroupMember(service *admin.Service, group string, email string) bool {
+	key := strings.ToLower(group) + " " + strings.ToLower(email)
+	if isMember, ok := p.groupMemberCache.get(key); ok {
+		return isMember
+	}
+
+	isMember, err := userInGroup(service, group, email)
+	if err != nil {
+		logger.Errorf("error checking group membership: %v", err)
+		return false
+	}
+	p.groupMemberCache.set(key, isMember, nil)
+	return isMember
+}
+
+// userInGroup checks whether the user is a member of the group. An error is
+// returned when the membership could not be determined.
+func userInGroup(service *admin.Service, group string, email string) (bool, error) {
 	// Use the HasMember API to checking for the user's presence in each group or nested subgroups
 	req := service.Members.HasMember(group, email)
 	r, err := req.Do()
 	if err == nil {
-		return r.IsMember
+		return r.IsMember, nil
 	}
 
 	gerr, ok := err.(*googleapi.Error)
@@ -484,20 +595,21 @@ func userInGroup(service *admin.Service, group string, email string) bool {
 		// from the HasMember API. In that case, attempt to query the member object directly from the group.
 		req := service.Members.Get(group, email)
 		r, err := req.Do()
-		if err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == 404 {
 			logger.Errorf("error using get API to check member %s of google group %s: user not in the group", email, group)
-			return false
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("error using get API to check member %s of google group %s: %v", email, group, err)
 		}
 
 		// If the non-domain user is found within the group, still verify that they are "ACTIVE".
 		// Do not count the user as belonging to a group if they have another status ("ARCHIVED", "SUSPENDED", or "UNKNOWN").
-		if r.Status == "ACTIVE" {
-			return true
-		}
+		return r.Status == "ACTIVE", nil
 	default:
-		logger.Errorf("error checking group membership: %v", err)
+		return false, err
 	}
-	return false
+	return false, nil
 }
 
 // RefreshSession uses the RefreshToken to fetch new Access and ID Tokens
@@ -542,7 +654,7 @@ func (p *GoogleProvider) redeemRefreshToken(ctx context.Context, s *sessions.Ses
 	}
 
 	err = requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithMethod("POST").
 		WithBody(bytes.NewBufferString(params.Encode())).
 		SetHeader("Content-Type", "application/x-www-form-urlencoded").
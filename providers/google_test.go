@@ -9,9 +9,11 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
 	admin "google.golang.org/api/admin/directory/v1"
@@ -277,17 +279,60 @@ func TestGoogleProvider_userInGroup(t *testing.T) {
 
 	service.BasePath = ts.URL
 
-	result := userInGroup(service, "group@example.com", "member-in-domain@example.com")
+	result, err := userInGroup(service, "group@example.com", "member-in-domain@example.com")
+	assert.NoError(t, err)
 	assert.True(t, result)
 
-	result = userInGroup(service, "group@example.com", "member-out-of-domain@otherexample.com")
+	result, err = userInGroup(service, "group@example.com", "member-out-of-domain@otherexample.com")
+	assert.NoError(t, err)
 	assert.True(t, result)
 
-	result = userInGroup(service, "group@example.com", "non-member-in-domain@example.com")
+	result, err = userInGroup(service, "group@example.com", "non-member-in-domain@example.com")
+	assert.NoError(t, err)
 	assert.False(t, result)
 
-	result = userInGroup(service, "group@example.com", "non-member-out-of-domain@otherexample.com")
+	result, err = userInGroup(service, "group@example.com", "non-member-out-of-domain@otherexample.com")
+	assert.NoError(t, err)
 	assert.False(t, result)
+
+	_, err = userInGroup(service, "group@example.com", "unavailable@example.com")
+	assert.Error(t, err)
+}
+
+func TestGoogleProviderGroupRestrictionCachesMembership(t *testing.T) {
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/admin/directory/v1/groups/group@example.com/hasMember/member@example.com":
+			fmt.Fprintln(w, `{"isMember":true}`)
+		case "/admin/directory/v1/groups/other@example.com/hasMember/member@example.com":
+			fmt.Fprintln(w, `{"isMember":false}`)
+		default:
+			http.Error(w, `{"error":{"code":503,"message":"unavailable"}}`, http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	service, err := admin.NewService(context.Background(), option.WithHTTPClient(ts.Client()))
+	assert.NoError(t, err)
+	service.BasePath = ts.URL
+
+	p := &GoogleProvider{groupMemberCache: newTTLCache[bool](time.Minute)}
+	validator := p.setGroupRestriction([]string{"group@example.com", "other@example.com", "failing@example.com"}, service)
+
+	for i := 0; i < 2; i++ {
+		s := &sessions.SessionState{Email: "member@example.com"}
+		assert.True(t, validator(s))
+		assert.Equal(t, []string{"group@example.com"}, s.Groups)
+	}
+
+	// Only the checks that failed are repeated
+	assert.Equal(t, map[string]int{
+		"/admin/directory/v1/groups/group@example.com/hasMember/member@example.com":   1,
+		"/admin/directory/v1/groups/other@example.com/hasMember/member@example.com":   1,
+		"/admin/directory/v1/groups/failing@example.com/hasMember/member@example.com": 2,
+	}, requests)
 }
 
 func TestGoogleProvider_getUserGroups(t *testing.T) {
@@ -405,3 +450,82 @@ func TestGoogleProvider_getUserInfo(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "test.user", info)
 }
+
+func TestGoogleProviderTransitiveGroups(t *testing.T) {
+	// eng-backend@ is nested in eng@, which is nested in all@ and, in a
+	// cycle, in eng-backend@
+	memberships := map[string][]string{
+		"test@example.com":        {"eng-backend@example.com"},
+		"eng-backend@example.com": {"eng@example.com"},
+		"eng@example.com":         {"all@example.com", "eng-backend@example.com"},
+		"all@example.com":         {},
+	}
+
+	requestCount := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userKey := r.URL.Query().Get("userKey")
+		groups, ok := memberships[userKey]
+		if r.URL.Path != "/admin/directory/v1/groups" || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		requestCount[userKey]++
+
+		response := &admin.Groups{Kind: "admin#directory#groups"}
+		for _, group := range groups {
+			response.Groups = append(response.Groups, &admin.Group{Kind: "admin#directory#group", Email: group})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer ts.Close()
+
+	adminService, err := admin.NewService(context.Background(), option.WithHTTPClient(&http.Client{}), option.WithEndpoint(ts.URL))
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		groups         []string
+		expectedAuthZ  bool
+		expectedGroups []string
+	}{
+		"Nested member of an allowed group": {
+			groups:         []string{"eng@example.com", "sales@example.com"},
+			expectedAuthZ:  true,
+			expectedGroups: []string{"eng@example.com"},
+		},
+		"Not a member of an allowed group": {
+			groups:         []string{"sales@example.com"},
+			expectedAuthZ:  false,
+			expectedGroups: []string{},
+		},
+		"All groups without allowed groups": {
+			expectedAuthZ:  true,
+			expectedGroups: []string{"eng-backend@example.com", "eng@example.com", "all@example.com"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			clear(requestCount)
+
+			p, err := NewGoogleProvider(&ProviderData{}, options.GoogleOptions{
+				TransitiveGroups: ptr.To(true),
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			p.configureGroups(options.GoogleOptions{Groups: tc.groups}, adminService)
+
+			for i := 0; i < 2; i++ {
+				session := &sessions.SessionState{Email: "test@example.com"}
+				g.Expect(p.groupValidator(session)).To(Equal(tc.expectedAuthZ))
+				g.Expect(session.Groups).To(Equal(tc.expectedGroups))
+			}
+
+			// The second validation is served from the membership cache
+			g.Expect(requestCount).To(Equal(map[string]int{
+				"test@example.com":        1,
+				"eng-backend@example.com": 1,
+				"eng@example.com":         1,
+				"all@example.com":         1,
+			}))
+		})
+	}
+}
@@ -62,7 +62,7 @@ func validateToken(ctx context.Context, p Provider, accessToken string, header h
 	}
 
 	result := requests.New(endpoint).
-		WithContext(ctx).
+		WithContext(p.Data().ClientContext(ctx)).
 		WithHeaders(header).
 		Do()
 	if result.Error() != nil {
@@ -74,7 +74,7 @@ func (p *KeycloakProvider) EnrichSession(ctx context.Context, s *sessions.Sessio
 	}
 
 	json, err := requests.New(profileURL).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		SetHeader("Authorization", tokenTypeBearer+" "+s.AccessToken).
 		Do().
 		UnmarshalSimpleJSON()
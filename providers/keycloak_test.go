@@ -10,6 +10,7 @@ import (
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -203,7 +204,7 @@ var _ = Describe("Keycloak Provider Tests", func() {
 				backendHandler: func(w http.ResponseWriter, _ *http.Request) {
 					w.WriteHeader(500)
 				},
-				expectedError:  errors.New(`unexpected status "500": `),
+				expectedError:  &requests.UnexpectedStatusError{StatusCode: 500, Body: []byte{}},
 				expectedEmail:  "",
 				expectedGroups: nil,
 			}),
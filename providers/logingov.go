@@ -150,10 +150,13 @@ type loginGovCustomClaims struct {
 }
 
 // checkNonce checks the nonce in the id_token
-func checkNonce(idToken string, p *LoginGovProvider) (err error) {
+func checkNonce(ctx context.Context, idToken string, p *LoginGovProvider) (err error) {
 	token, err := jwt.ParseWithClaims(idToken, &loginGovCustomClaims{}, func(_ *jwt.Token) (interface{}, error) {
 		var pubkeys jose.JSONWebKeySet
-		rerr := requests.New(p.PubJWKURL.String()).Do().UnmarshalInto(&pubkeys)
+		rerr := requests.New(p.PubJWKURL.String()).
+			WithContext(p.ClientContext(ctx)).
+			Do().
+			UnmarshalInto(&pubkeys)
 		if rerr != nil {
 			return nil, rerr
 		}
@@ -236,7 +239,7 @@ func (p *LoginGovProvider) Redeem(ctx context.Context, _, code, codeVerifier str
 		ExpiresIn   int64  `json:"expires_in"`
 	}
 	err = requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithMethod("POST").
 		WithBody(bytes.NewBufferString(params.Encode())).
 		SetHeader("Content-Type", "application/x-www-form-urlencoded").
@@ -247,14 +250,14 @@ func (p *LoginGovProvider) Redeem(ctx context.Context, _, code, codeVerifier str
 	}
 
 	// check nonce here
-	err = checkNonce(jsonResponse.IDToken, p)
+	err = checkNonce(ctx, jsonResponse.IDToken, p)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get the email address
 	var email string
-	email, err = emailFromUserInfo(ctx, jsonResponse.AccessToken, p.ProfileURL.String())
+	email, err = emailFromUserInfo(p.ClientContext(ctx), jsonResponse.AccessToken, p.ProfileURL.String())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,499 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
+)
+
+const (
+	mockProviderName = "Mock"
+	mockDefaultScope = "openid email profile"
+
+	// mockIssuerURL is the issuer of the ID tokens of the mock provider. The
+	// provider reaches its token and JWKS endpoints below it in process, so
+	// it is never resolved.
+	mockIssuerURL = "http://mock.oauth2-proxy.localhost"
+
+	// mockPathPrefix is the path below the proxy prefix the endpoints of the
+	// mock provider are served at
+	mockPathPrefix = "/mock"
+
+	mockAuthorizePath = "/authorize"
+	mockTokenPath     = "/token"
+	mockJWKSPath      = "/jwks"
+
+	mockCodeLifetime         = time.Minute
+	mockTokenLifetime        = time.Hour
+	mockRefreshTokenLifetime = 7 * 24 * time.Hour
+)
+
+// MockIdentityProvider is implemented by providers that serve the endpoints
+// of their identity provider within the proxy
+type MockIdentityProvider interface {
+	Provider
+	http.Handler
+}
+
+// MockProvider is a development identity provider that logs anyone in as any
+// of the configured users. It serves its own authorize, token and JWKS
+// endpoints and signs its ID tokens with an ephemeral key, so that logins go
+// through the same code paths as with the OIDCProvider.
+type MockProvider struct {
+	*OIDCProvider
+
+	users        []options.MockUser
+	clientSecret string
+	key          *rsa.PrivateKey
+	keyID        string
+	handler      http.Handler
+
+	codes         *ttlCache[mockGrant]
+	refreshTokens *ttlCache[mockGrant]
+}
+
+var _ MockIdentityProvider = (*MockProvider)(nil)
+
+// mockGrant is the authorization request of the user a code or refresh token
+// was issued for
+type mockGrant struct {
+	email               string
+	clientID            string
+	redirectURI         string
+	nonce               string
+	codeChallenge       string
+	codeChallengeMethod string
+}
+
+// NewMockProvider initiates a new MockProvider
+func NewMockProvider(p *ProviderData, opts options.Provider) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate the mock signing key: %v", err)
+	}
+	keyID, err := mockRandomString()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate the mock key ID: %v", err)
+	}
+
+	// The token endpoint verifies the client secret the provider was
+	// configured with
+	clientSecret, err := p.GetClientSecret()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the mock client secret: %v", err)
+	}
+
+	issuerURL, _ := url.Parse(mockIssuerURL)
+	p.setProviderDefaults(providerDefaults{
+		name:      mockProviderName,
+		loginURL:  &url.URL{Path: mockAuthorizePath},
+		redeemURL: issuerURL.JoinPath(mockTokenPath),
+		scope:     mockDefaultScope,
+	})
+
+	provider := &MockProvider{
+		users:         opts.MockConfig.Users,
+		clientSecret:  clientSecret,
+		key:           key,
+		keyID:         keyID,
+		codes:         newTTLCache[mockGrant](mockCodeLifetime),
+		refreshTokens: newTTLCache[mockGrant](mockRefreshTokenLifetime),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(mockAuthorizePath, provider.authorize)
+	mux.HandleFunc(mockTokenPath, provider.token)
+	mux.HandleFunc(mockJWKSPath, provider.jwks)
+	provider.handler = mux
+
+	// The token and JWKS requests of the provider are served in process
+	p.httpClient = &http.Client{Transport: mockTransport{handler: mux}}
+
+	audienceClaims := opts.OIDCConfig.AudienceClaims
+	if len(audienceClaims) == 0 {
+		audienceClaims = options.OIDCAudienceClaims
+	}
+	keySet := oidc.NewRemoteKeySet(p.ClientContext(context.Background()), issuerURL.JoinPath(mockJWKSPath).String())
+	p.Verifier = internaloidc.NewVerifier(oidc.NewVerifier(mockIssuerURL, keySet, &oidc.Config{
+		ClientID:          p.ClientID,
+		SkipClientIDCheck: true,
+	}), internaloidc.IDTokenVerificationOptions{
+		AudienceClaims: audienceClaims,
+		ClientID:       p.ClientID,
+		ExtraAudiences: opts.OIDCConfig.ExtraAudiences,
+	})
+
+	provider.OIDCProvider = NewOIDCProvider(p, opts.OIDCConfig)
+
+	logger.Printf("WARNING: the mock provider %q logs anyone in as any of its users without a password, it must only be used for development and testing", p.ProviderID)
+	return provider, nil
+}
+
+// GetLoginURL makes the login URL for the authorize endpoint, which is served
+// by the proxy next to the callback the user is redirected back to
+func (p *MockProvider) GetLoginURL(redirectURI, state, nonce string, extraParams url.Values) string {
+	if p.ProviderID != "" {
+		extraParams.Set("provider", p.ProviderID)
+	}
+	loginURL, err := url.Parse(p.OIDCProvider.GetLoginURL(redirectURI, state, nonce, extraParams))
+	if err != nil || loginURL.Host != "" {
+		return loginURL.String()
+	}
+
+	callbackURL, err := url.Parse(redirectURI)
+	if err != nil {
+		return loginURL.String()
+	}
+	loginURL.Scheme = callbackURL.Scheme
+	loginURL.Host = callbackURL.Host
+	loginURL.Path = path.Join(path.Dir(callbackURL.Path), mockPathPrefix, loginURL.Path)
+	return loginURL.String()
+}
+
+// ServeHTTP serves the authorize, token and JWKS endpoints of the provider
+func (p *MockProvider) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	p.handler.ServeHTTP(rw, req)
+}
+
+var mockLoginTemplate = template.Must(template.New("mock").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Mock Login</title>
+</head>
+<body>
+<h1>Mock Login</h1>
+<p>Pick the user to sign in as. This provider is for development and testing only.</p>
+<form method="post">
+{{- range . }}
+<p><button type="submit" name="email" value="{{ .Email }}">{{ .Email }}</button>{{ if .Groups }} {{ join .Groups ", " }}{{ end }}</p>
+{{- end }}
+</form>
+</body>
+</html>
+`))
+
+// authorize shows the user picker, and redirects back with a code for the
+// picked user
+func (p *MockProvider) authorize(rw http.ResponseWriter, req *http.Request) {
+	params := req.URL.Query()
+	switch {
+	case params.Get("client_id") != p.ClientID:
+		http.Error(rw, "unknown client_id", http.StatusBadRequest)
+		return
+	case params.Get("response_type") != "code":
+		http.Error(rw, "unsupported response_type", http.StatusBadRequest)
+		return
+	case params.Get("redirect_uri") == "":
+		http.Error(rw, "missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := mockLoginTemplate.Execute(rw, p.users); err != nil {
+			logger.Errorf("Error rendering the mock login page: %v", err)
+		}
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, ok := p.findUser(req.PostForm.Get("email"))
+	if !ok {
+		http.Error(rw, "unknown user", http.StatusBadRequest)
+		return
+	}
+	redirectURL, err := url.Parse(params.Get("redirect_uri"))
+	if err != nil {
+		http.Error(rw, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code, err := mockRandomString()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.codes.set(code, mockGrant{
+		email:               user.Email,
+		clientID:            p.ClientID,
+		redirectURI:         redirectURL.String(),
+		nonce:               params.Get("nonce"),
+		codeChallenge:       params.Get("code_challenge"),
+		codeChallengeMethod: params.Get("code_challenge_method"),
+	}, nil)
+
+	query := redirectURL.Query()
+	query.Set("code", code)
+	query.Set("state", params.Get("state"))
+	redirectURL.RawQuery = query.Encode()
+	http.Redirect(rw, req, redirectURL.String(), http.StatusFound)
+}
+
+// token redeems codes and refresh tokens issued by the provider
+func (p *MockProvider) token(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		writeMockTokenError(rw, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	clientID, clientSecret, ok := req.BasicAuth()
+	if ok {
+		// The client credentials are form encoded in the basic auth header
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = req.PostForm.Get("client_id")
+		clientSecret = req.PostForm.Get("client_secret")
+	}
+	if !p.isClient(clientID, clientSecret) {
+		writeMockTokenError(rw, http.StatusUnauthorized, "invalid_client", "unknown client or invalid client secret")
+		return
+	}
+
+	var grant mockGrant
+	switch req.PostForm.Get("grant_type") {
+	case "authorization_code":
+		// The code is only used up once the request is verified, as the
+		// oauth2 client retries the exchange when it fails
+		code := req.PostForm.Get("code")
+		grant, ok = p.codes.get(code)
+		if !ok || grant.redirectURI != req.PostForm.Get("redirect_uri") {
+			writeMockTokenError(rw, http.StatusBadRequest, "invalid_grant", "invalid code or redirect_uri")
+			return
+		}
+		if !grant.verifyCodeChallenge(req.PostForm.Get("code_verifier")) {
+			writeMockTokenError(rw, http.StatusBadRequest, "invalid_grant", "invalid code_verifier")
+			return
+		}
+		if _, ok = p.codes.take(code); !ok {
+			writeMockTokenError(rw, http.StatusBadRequest, "invalid_grant", "invalid code or redirect_uri")
+			return
+		}
+	case "refresh_token":
+		grant, ok = p.refreshTokens.take(req.PostForm.Get("refresh_token"))
+		if !ok {
+			writeMockTokenError(rw, http.StatusBadRequest, "invalid_grant", "invalid refresh_token")
+			return
+		}
+		// The nonce is only set in the ID token issued for the code
+		grant.nonce = ""
+	default:
+		writeMockTokenError(rw, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+	if grant.clientID != clientID {
+		writeMockTokenError(rw, http.StatusBadRequest, "invalid_grant", "the grant was issued to another client")
+		return
+	}
+
+	user, ok := p.findUser(grant.email)
+	if !ok {
+		writeMockTokenError(rw, http.StatusBadRequest, "invalid_grant", "the user no longer exists")
+		return
+	}
+
+	response, err := p.issueTokens(user, grant)
+	if err != nil {
+		writeMockTokenError(rw, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	rw.Header().Set("Cache-Control", "no-store")
+	writeMockJSON(rw, http.StatusOK, response)
+}
+
+// issueTokens signs an ID token for the user and issues a new access token
+// and refresh token
+func (p *MockProvider) issueTokens(user options.MockUser, grant mockGrant) (map[string]interface{}, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for name, value := range user.Claims {
+		claims[name] = value
+	}
+	claims["iss"] = mockIssuerURL
+	claims["sub"] = user.Email
+	claims["aud"] = grant.clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(mockTokenLifetime).Unix()
+	claims["email"] = user.Email
+	claims["email_verified"] = true
+	if len(user.Groups) > 0 {
+		claims["groups"] = user.Groups
+	}
+	if grant.nonce != "" {
+		claims["nonce"] = grant.nonce
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign the ID token: %v", err)
+	}
+
+	accessToken, err := mockRandomString()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := mockRandomString()
+	if err != nil {
+		return nil, err
+	}
+	p.refreshTokens.set(refreshToken, grant, nil)
+
+	return map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int64(mockTokenLifetime / time.Second),
+		"refresh_token": refreshToken,
+		"id_token":      idToken,
+	}, nil
+}
+
+// jwks serves the public key the ID tokens are signed with
+func (p *MockProvider) jwks(rw http.ResponseWriter, _ *http.Request) {
+	writeMockJSON(rw, http.StatusOK, jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{
+			Key:       &p.key.PublicKey,
+			KeyID:     p.keyID,
+			Algorithm: string(jose.RS256),
+			Use:       "sig",
+		}},
+	})
+}
+
+func (p *MockProvider) findUser(email string) (options.MockUser, bool) {
+	for _, user := range p.users {
+		if email != "" && user.Email == email {
+			return user, true
+		}
+	}
+	return options.MockUser{}, false
+}
+
+func (p *MockProvider) isClient(clientID, clientSecret string) bool {
+	return clientID == p.ClientID && subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.clientSecret)) == 1
+}
+
+// verifyCodeChallenge checks the PKCE code verifier against the code
+// challenge of the authorization request, if there was one
+func (g mockGrant) verifyCodeChallenge(codeVerifier string) bool {
+	switch g.codeChallengeMethod {
+	case "":
+		return g.codeChallenge == "" || codeVerifier == g.codeChallenge
+	case CodeChallengeMethodPlain:
+		return codeVerifier == g.codeChallenge
+	case CodeChallengeMethodS256:
+		hash := sha256.Sum256([]byte(codeVerifier))
+		return base64.RawURLEncoding.EncodeToString(hash[:]) == g.codeChallenge
+	default:
+		return false
+	}
+}
+
+func mockRandomString() (string, error) {
+	b, err := encryption.Nonce(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func writeMockTokenError(rw http.ResponseWriter, code int, errorCode, description string) {
+	writeMockJSON(rw, code, map[string]string{
+		"error":             errorCode,
+		"error_description": description,
+	})
+}
+
+func writeMockJSON(rw http.ResponseWriter, code int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		logger.Errorf("Error encoding mock provider response: %v", err)
+	}
+}
+
+// mockTransport serves the requests of the provider to its own endpoints in
+// process rather than over the network
+type mockTransport struct {
+	handler http.Handler
+}
+
+func (t mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The handler parses the form of the request, which the caller owns
+	req = req.Clone(req.Context())
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+
+	rw := &mockResponseWriter{header: make(http.Header)}
+	t.handler.ServeHTTP(rw, req)
+	if rw.code == 0 {
+		rw.code = http.StatusOK
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rw.code, http.StatusText(rw.code)),
+		StatusCode:    rw.code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rw.header,
+		Body:          io.NopCloser(&rw.body),
+		ContentLength: int64(rw.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// mockResponseWriter buffers the response of the in process handler
+type mockResponseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *mockResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *mockResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *mockResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
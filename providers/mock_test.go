@@ -0,0 +1,248 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+	. "github.com/onsi/gomega"
+)
+
+const mockRedirectURI = "https://proxy.example.com/oauth2/callback"
+
+func testMockProvider(t *testing.T) *MockProvider {
+	opts := options.Provider{
+		ID:           "mock",
+		Type:         options.MockProvider,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		MockConfig: options.MockOptions{
+			InsecureDevelopmentMode: true,
+			Users: []options.MockUser{
+				{
+					Email:  "jane@example.com",
+					Groups: []string{"admins", "developers"},
+					Claims: map[string]interface{}{"preferred_username": "jane", "department": "engineering"},
+				},
+				{Email: "john@example.com"},
+			},
+		},
+		OIDCConfig: options.OIDCOptions{
+			InsecureSkipNonce: ptr.To(false),
+		},
+	}
+	opts.EnsureDefaults()
+
+	p, err := NewMockProvider(&ProviderData{
+		ProviderID:   opts.ID,
+		ClientID:     opts.ClientID,
+		ClientSecret: opts.ClientSecret,
+		EmailClaim:   opts.OIDCConfig.EmailClaim,
+		GroupsClaim:  opts.OIDCConfig.GroupsClaim,
+	}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// mockAuthorize picks the user on the authorize endpoint and returns the
+// code of the redirect back to the callback
+func mockAuthorize(t *testing.T, p *MockProvider, email string, params url.Values) string {
+	g := NewWithT(t)
+
+	loginURL, err := url.Parse(p.GetLoginURL(mockRedirectURI, "state", "nonce", params))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	req := httptest.NewRequest(http.MethodPost, mockAuthorizePath+"?"+loginURL.RawQuery, strings.NewReader(url.Values{"email": {email}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+	p.ServeHTTP(rw, req)
+	g.Expect(rw.Code).To(Equal(http.StatusFound), rw.Body.String())
+
+	callbackURL, err := url.Parse(rw.Header().Get("Location"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(callbackURL.Query().Get("state")).To(Equal("state"))
+	return callbackURL.Query().Get("code")
+}
+
+func TestNewMockProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	// Test that defaults are set when calling for a new provider with nothing set
+	providerData := testMockProvider(t).Data()
+	g.Expect(providerData.ProviderName).To(Equal("Mock"))
+	g.Expect(providerData.LoginURL.String()).To(Equal("/authorize"))
+	g.Expect(providerData.RedeemURL.String()).To(Equal("http://mock.oauth2-proxy.localhost/token"))
+	g.Expect(providerData.Scope).To(Equal("openid email profile"))
+}
+
+func TestMockProviderGetLoginURL(t *testing.T) {
+	g := NewWithT(t)
+
+	p := testMockProvider(t)
+	loginURL, err := url.Parse(p.GetLoginURL(mockRedirectURI, "state", "nonce", url.Values{}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(loginURL.Scheme).To(Equal("https"))
+	g.Expect(loginURL.Host).To(Equal("proxy.example.com"))
+	g.Expect(loginURL.Path).To(Equal("/oauth2/mock/authorize"))
+	g.Expect(loginURL.Query().Get("provider")).To(Equal("mock"))
+	g.Expect(loginURL.Query().Get("redirect_uri")).To(Equal(mockRedirectURI))
+	g.Expect(loginURL.Query().Get("nonce")).To(Equal("nonce"))
+
+	// Relative redirect URLs make relative login URLs
+	loginURL, err = url.Parse(p.GetLoginURL("/auth/callback", "state", "nonce", url.Values{}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(loginURL.Host).To(BeEmpty())
+	g.Expect(loginURL.Path).To(Equal("/auth/mock/authorize"))
+}
+
+func TestMockProviderAuthorize(t *testing.T) {
+	testCases := map[string]struct {
+		method       string
+		query        url.Values
+		email        string
+		expectedCode int
+		expectedBody string
+	}{
+		"showing the users": {
+			method:       http.MethodGet,
+			query:        url.Values{"client_id": {"client"}, "response_type": {"code"}, "redirect_uri": {mockRedirectURI}},
+			expectedCode: http.StatusOK,
+			expectedBody: `<button type="submit" name="email" value="jane@example.com">jane@example.com</button> admins, developers`,
+		},
+		"with an unknown client": {
+			method:       http.MethodGet,
+			query:        url.Values{"client_id": {"other"}, "response_type": {"code"}, "redirect_uri": {mockRedirectURI}},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "unknown client_id",
+		},
+		"with an unsupported response type": {
+			method:       http.MethodGet,
+			query:        url.Values{"client_id": {"client"}, "response_type": {"token"}, "redirect_uri": {mockRedirectURI}},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "unsupported response_type",
+		},
+		"without a redirect URI": {
+			method:       http.MethodGet,
+			query:        url.Values{"client_id": {"client"}, "response_type": {"code"}},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "missing redirect_uri",
+		},
+		"picking an unknown user": {
+			method:       http.MethodPost,
+			query:        url.Values{"client_id": {"client"}, "response_type": {"code"}, "redirect_uri": {mockRedirectURI}},
+			email:        "mallory@example.com",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "unknown user",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			p := testMockProvider(t)
+			req := httptest.NewRequest(tc.method, mockAuthorizePath+"?"+tc.query.Encode(), strings.NewReader(url.Values{"email": {tc.email}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rw := httptest.NewRecorder()
+			p.ServeHTTP(rw, req)
+			g.Expect(rw.Code).To(Equal(tc.expectedCode))
+			g.Expect(rw.Body.String()).To(ContainSubstring(tc.expectedBody))
+		})
+	}
+}
+
+func TestMockProviderRedeem(t *testing.T) {
+	g := NewWithT(t)
+
+	p := testMockProvider(t)
+	code := mockAuthorize(t, p, "jane@example.com", url.Values{})
+
+	session, err := p.Redeem(context.Background(), mockRedirectURI, code, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(session.Email).To(Equal("jane@example.com"))
+	g.Expect(session.User).To(Equal("jane@example.com"))
+	g.Expect(session.Groups).To(Equal([]string{"admins", "developers"}))
+	g.Expect(session.PreferredUsername).To(Equal("jane"))
+	g.Expect(session.AccessToken).ToNot(BeEmpty())
+	g.Expect(session.RefreshToken).ToNot(BeEmpty())
+	g.Expect(p.EnrichSession(context.Background(), session)).To(Succeed())
+
+	// The ID token is verified with the keys from the JWKS endpoint
+	idToken, err := p.Verifier.Verify(context.Background(), session.IDToken)
+	g.Expect(err).ToNot(HaveOccurred())
+	var claims struct {
+		Department string `json:"department"`
+		Nonce      string `json:"nonce"`
+	}
+	g.Expect(idToken.Claims(&claims)).To(Succeed())
+	g.Expect(claims.Department).To(Equal("engineering"))
+	g.Expect(claims.Nonce).To(Equal("nonce"))
+
+	// Codes can only be redeemed once
+	_, err = p.Redeem(context.Background(), mockRedirectURI, code, "")
+	g.Expect(err).To(MatchError(ContainSubstring("invalid code or redirect_uri")))
+
+	code = mockAuthorize(t, p, "john@example.com", url.Values{})
+	_, err = p.Redeem(context.Background(), "https://other.example.com/oauth2/callback", code, "")
+	g.Expect(err).To(MatchError(ContainSubstring("invalid code or redirect_uri")))
+
+	code = mockAuthorize(t, p, "john@example.com", url.Values{})
+	p.ClientSecret = "wrong"
+	_, err = p.Redeem(context.Background(), mockRedirectURI, code, "")
+	g.Expect(err).To(MatchError(ContainSubstring("invalid_client")))
+}
+
+func TestMockProviderRedeemWithPKCE(t *testing.T) {
+	g := NewWithT(t)
+
+	p := testMockProvider(t)
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	hash := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(hash[:])},
+		"code_challenge_method": {CodeChallengeMethodS256},
+	}
+
+	code := mockAuthorize(t, p, "jane@example.com", params)
+	_, err := p.Redeem(context.Background(), mockRedirectURI, code, "wrong")
+	g.Expect(err).To(MatchError(ContainSubstring("invalid code_verifier")))
+
+	code = mockAuthorize(t, p, "jane@example.com", params)
+	session, err := p.Redeem(context.Background(), mockRedirectURI, code, verifier)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(session.Email).To(Equal("jane@example.com"))
+}
+
+func TestMockProviderRefreshSession(t *testing.T) {
+	g := NewWithT(t)
+
+	p := testMockProvider(t)
+	code := mockAuthorize(t, p, "jane@example.com", url.Values{})
+	session, err := p.Redeem(context.Background(), mockRedirectURI, code, "")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	refreshToken := session.RefreshToken
+	accessToken := session.AccessToken
+	refreshed, err := p.RefreshSession(context.Background(), session)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refreshed).To(BeTrue())
+	g.Expect(session.AccessToken).ToNot(Equal(accessToken))
+	g.Expect(session.RefreshToken).ToNot(Equal(refreshToken))
+	g.Expect(session.Email).To(Equal("jane@example.com"))
+	g.Expect(session.Groups).To(Equal([]string{"admins", "developers"}))
+	g.Expect(p.ValidateSession(context.Background(), session)).To(BeTrue())
+
+	// Refresh tokens are rotated
+	_, err = p.RefreshSession(context.Background(), &sessions.SessionState{RefreshToken: refreshToken})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid refresh_token")))
+}
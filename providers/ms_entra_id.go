@@ -5,12 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"regexp"
 	"time"
 
-	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
@@ -18,7 +19,6 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
 	"github.com/spf13/cast"
-	"golang.org/x/oauth2"
 )
 
 // MicrosoftEntraIDProvider represents provider for Azure Entra Authentication V2 endpoint
@@ -27,11 +27,20 @@ type MicrosoftEntraIDProvider struct {
 	multiTenantAllowedTenants []string
 	federatedTokenAuth        bool
 
+	groupNameAttribute options.MicrosoftEntraIDGroupNameAttribute
+	groupNameCache     *ttlCache[string]
+	appRolesAsGroups   bool
+	appRolePrefix      string
+
 	microsoftGraphURL *url.URL
 }
 
 const (
 	microsoftEntraIDProviderName = "Microsoft Entra ID"
+
+	// microsoftGraphGetByIDsBatchSize is the maximum number of IDs accepted
+	// by a single directoryObjects/getByIds request
+	microsoftGraphGetByIDsBatchSize = 1000
 )
 
 var (
@@ -40,6 +49,8 @@ var (
 		Host:   "graph.microsoft.com",
 		Path:   "/v1.0/me",
 	}
+
+	entraObjectIDRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 )
 
 // NewMicrosoftEntraIDProvider initiates a new MicrosoftEntraIDProvider
@@ -53,17 +64,28 @@ func NewMicrosoftEntraIDProvider(p *ProviderData, opts options.Provider) *Micros
 
 		multiTenantAllowedTenants: opts.MicrosoftEntraIDConfig.AllowedTenants,
 		federatedTokenAuth:        ptr.Deref(opts.MicrosoftEntraIDConfig.FederatedTokenAuth, options.DefaultMicrosoftEntraIDUseFederatedToken),
+		groupNameAttribute:        opts.MicrosoftEntraIDConfig.GroupNameAttribute,
+		groupNameCache:            newTTLCache[string](ptr.Deref(opts.MicrosoftEntraIDConfig.GroupNameCacheTTL, options.DefaultMicrosoftEntraIDGroupNameCacheTTL)),
+		appRolesAsGroups:          ptr.Deref(opts.MicrosoftEntraIDConfig.AppRolesAsGroups, options.DefaultMicrosoftEntraIDAppRolesAsGroups),
+		appRolePrefix:             opts.MicrosoftEntraIDConfig.AppRolePrefix,
 		microsoftGraphURL:         microsoftGraphURL,
 	}
 }
 
-// EnrichSession checks for group overage after calling generic EnrichSession
+// EnrichSession checks for group overage after calling generic EnrichSession,
+// then resolves group names and adds the app roles when configured
 func (p *MicrosoftEntraIDProvider) EnrichSession(ctx context.Context, session *sessions.SessionState) error {
 	if err := p.OIDCProvider.EnrichSession(ctx, session); err != nil {
 		return fmt.Errorf("unable to enrich session: %v", err)
 	}
 
-	hasGroupOverage, err := p.checkGroupOverage(session)
+	return p.enrichGroups(ctx, session)
+}
+
+// enrichGroups completes the groups of the session from the ID token, which
+// only holds the group object IDs, and up to 200 of them
+func (p *MicrosoftEntraIDProvider) enrichGroups(ctx context.Context, session *sessions.SessionState) error {
+	hasGroupOverage, err := p.checkGroupOverage(ctx, session)
 	if err != nil {
 		return fmt.Errorf("unable to check token: %v", err)
 	}
@@ -75,12 +97,22 @@ func (p *MicrosoftEntraIDProvider) EnrichSession(ctx context.Context, session *s
 		}
 	}
 
+	if p.groupNameAttribute != "" {
+		p.resolveGroupNames(ctx, session)
+	}
+
+	if p.appRolesAsGroups {
+		if err := p.addAppRolesToSession(ctx, session); err != nil {
+			return fmt.Errorf("unable to enrich session: %v", err)
+		}
+	}
+
 	return nil
 }
 
 // ValidateSession checks for allowed tenants (e.g. for multi-tenant apps) and passes through to generic ValidateSession
 func (p *MicrosoftEntraIDProvider) ValidateSession(ctx context.Context, session *sessions.SessionState) bool {
-	tenant, err := p.getTenantFromToken(session)
+	tenant, err := p.getTenantFromToken(ctx, session)
 	if err != nil {
 		logger.Errorf("unable to retrieve entra tenant from token: %v", err)
 		return false
@@ -144,7 +176,8 @@ func (p *MicrosoftEntraIDProvider) RefreshSession(ctx context.Context, s *sessio
 	}
 
 	var err error
-	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
+	idToken := s.IDToken
+	ctx = p.ClientContext(ctx)
 	if p.federatedTokenAuth {
 		err = p.redeemRefreshTokenWithFederatedToken(ctx, s)
 	} else {
@@ -155,6 +188,13 @@ func (p *MicrosoftEntraIDProvider) RefreshSession(ctx context.Context, s *sessio
 		return false, fmt.Errorf("unable to redeem refresh token: %v", err)
 	}
 
+	// The groups are reset from the new ID token, if one was returned
+	if s.IDToken != idToken {
+		if err := p.enrichGroups(ctx, s); err != nil {
+			return false, err
+		}
+	}
+
 	return true, nil
 }
 
@@ -204,8 +244,8 @@ func (p *MicrosoftEntraIDProvider) redeemRefreshTokenWithFederatedToken(ctx cont
 }
 
 // checkGroupOverage checks ID token's group membership claims for the group overage
-func (p *MicrosoftEntraIDProvider) checkGroupOverage(session *sessions.SessionState) (bool, error) {
-	extractor, err := p.getClaimExtractor(session.IDToken, session.AccessToken)
+func (p *MicrosoftEntraIDProvider) checkGroupOverage(ctx context.Context, session *sessions.SessionState) (bool, error) {
+	extractor, err := p.getClaimExtractor(ctx, session.IDToken, session.AccessToken)
 	if err != nil {
 		return false, fmt.Errorf("unable to get claim extractor: %v", err)
 	}
@@ -238,7 +278,7 @@ func (p *MicrosoftEntraIDProvider) addGraphGroupsToSession(ctx context.Context,
 		}
 
 		response, err := requests.New(nextLink).
-			WithContext(ctx).
+			WithContext(p.ClientContext(ctx)).
 			WithHeaders(groupsHeaders).
 			Do().
 			UnmarshalSimpleJSON()
@@ -266,8 +306,117 @@ func (p *MicrosoftEntraIDProvider) addGraphGroupsToSession(ctx context.Context,
 	return nil
 }
 
-func (p *MicrosoftEntraIDProvider) getTenantFromToken(session *sessions.SessionState) (string, error) {
-	extractor, err := p.getClaimExtractor(session.IDToken, session.AccessToken)
+// resolveGroupNames replaces the group object IDs of the session with the
+// configured group attribute. Groups missing from the cache are looked up
+// through Microsoft Graph; groups that can't be resolved keep their ID.
+func (p *MicrosoftEntraIDProvider) resolveGroupNames(ctx context.Context, s *sessions.SessionState) {
+	names := make(map[string]string, len(s.Groups))
+	var unresolved []string
+	for _, group := range s.Groups {
+		if !entraObjectIDRegexp.MatchString(group) {
+			continue
+		}
+		if name, ok := p.groupNameCache.get(group); ok {
+			names[group] = name
+			continue
+		}
+		unresolved = append(unresolved, group)
+	}
+
+	for len(unresolved) > 0 {
+		batch := unresolved[:min(len(unresolved), microsoftGraphGetByIDsBatchSize)]
+		unresolved = unresolved[len(batch):]
+
+		resolved, err := p.getGraphGroupNames(ctx, s.AccessToken, batch)
+		if err != nil {
+			logger.Errorf("invalid response from microsoft graph, group IDs are not resolved to names: %v", err)
+			break
+		}
+		for _, id := range batch {
+			name := resolved[id]
+			if name == "" {
+				name = id
+			}
+			p.groupNameCache.set(id, name, nil)
+			names[id] = name
+		}
+	}
+
+	groups := make([]string, 0, len(s.Groups))
+	for _, group := range s.Groups {
+		if name, ok := names[group]; ok {
+			group = name
+		}
+		groups = append(groups, group)
+	}
+	s.Groups = util.RemoveDuplicateStr(groups)
+}
+
+// getGraphGroupNames returns the configured attribute of the groups by ID.
+// Groups that don't exist, or that the user may not read, are left out.
+func (p *MicrosoftEntraIDProvider) getGraphGroupNames(ctx context.Context, accessToken string, ids []string) (map[string]string, error) {
+	body, err := json.Marshal(map[string][]string{
+		"ids":   ids,
+		"types": {"group"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	// https://learn.microsoft.com/en-us/graph/api/directoryobject-getbyids?view=graph-rest-1.0
+	getByIDsURL := *p.microsoftGraphURL
+	getByIDsURL.Path = path.Join(path.Dir(getByIDsURL.Path), "directoryObjects/getByIds")
+
+	var response struct {
+		Value []map[string]interface{} `json:"value"`
+	}
+	err = requests.New(getByIDsURL.String()).
+		WithContext(p.ClientContext(ctx)).
+		WithMethod(http.MethodPost).
+		WithHeaders(makeOIDCHeader(accessToken)).
+		SetHeader("Content-Type", "application/json").
+		WithBody(bytes.NewReader(body)).
+		Do().
+		UnmarshalInto(&response)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(response.Value))
+	for _, object := range response.Value {
+		id, _ := object["id"].(string)
+		name, _ := object[string(p.groupNameAttribute)].(string)
+		names[id] = name
+	}
+	return names, nil
+}
+
+// addAppRolesToSession adds the app roles of the ID token to the groups, with
+// the configured prefix
+func (p *MicrosoftEntraIDProvider) addAppRolesToSession(ctx context.Context, s *sessions.SessionState) error {
+	if s.IDToken == "" {
+		return nil
+	}
+
+	extractor, err := p.getClaimExtractor(ctx, s.IDToken, "")
+	if err != nil {
+		return fmt.Errorf("unable to get claim extractor: %v", err)
+	}
+
+	var roles []string
+	if _, err := extractor.GetClaimInto("roles", &roles); err != nil {
+		return fmt.Errorf("unable to get app roles: %v", err)
+	}
+
+	for _, role := range roles {
+		s.Groups = append(s.Groups, p.appRolePrefix+role)
+	}
+	s.Groups = util.RemoveDuplicateStr(s.Groups)
+	return nil
+}
+
+func (p *MicrosoftEntraIDProvider) getTenantFromToken(ctx context.Context, session *sessions.SessionState) (string, error) {
+	extractor, err := p.getClaimExtractor(ctx, session.IDToken, session.AccessToken)
 	if err != nil {
 		return "", fmt.Errorf("unable to get claim extractor: %v", err)
 	}
@@ -298,26 +447,3 @@ func (p *MicrosoftEntraIDProvider) checkTenantMatchesTenantList(tenant string, a
 	}
 	return false
 }
-
-func (p *MicrosoftEntraIDProvider) fetchToken(ctx context.Context, params url.Values) (*oauth2.Token, error) {
-	resp := requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
-		WithMethod("POST").
-		WithBody(bytes.NewBufferString(params.Encode())).
-		SetHeader("Content-Type", "application/x-www-form-urlencoded").
-		Do()
-
-	var token *oauth2.Token
-	var rawResponse interface{}
-
-	body := resp.Body()
-	if err := json.Unmarshal(body, &rawResponse); err != nil {
-		return nil, fmt.Errorf("unable to unmarshal raw response body: %w", err)
-	}
-
-	if err := json.Unmarshal(body, &token); err != nil {
-		return nil, fmt.Errorf("unable to unmarshal token response body: %w", err)
-	}
-
-	return token.WithExtra(rawResponse), nil
-}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -129,6 +130,208 @@ func TestAzureEntraOIDCProviderValidateSessionAllowedTenants(t *testing.T) {
 	assert.True(t, valid)
 }
 
+func TestAzureEntraOIDCProviderEnrichSessionGroupNames(t *testing.T) {
+	testCases := []struct {
+		name           string
+		attribute      options.MicrosoftEntraIDGroupNameAttribute
+		expectedGroups []string
+	}{
+		{
+			name:      "DisplayName",
+			attribute: options.MicrosoftEntraIDGroupDisplayName,
+			expectedGroups: []string{
+				"Engineering",
+				"Cloud Only",
+				"0b4e2f6a-7c1d-4f3e-9a5b-2c8d1e6f4a7b",
+				"not-an-object-id",
+			},
+		},
+		{
+			name:      "OnPremisesSamAccountName",
+			attribute: options.MicrosoftEntraIDGroupSamAccountName,
+			expectedGroups: []string{
+				"CORP-ENG",
+				"916f0604-8a3b-4a69-bda9-06db11a8f0cd",
+				"0b4e2f6a-7c1d-4f3e-9a5b-2c8d1e6f4a7b",
+				"not-an-object-id",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mockedGraph, requestCount := mockGraphGetByIDsAPI()
+			defer mockedGraph.Close()
+
+			provider := NewMicrosoftEntraIDProvider(&ProviderData{},
+				options.Provider{
+					OIDCConfig: options.OIDCOptions{
+						IssuerURL: "https://login.microsoftonline.com/18014347-dd57-41a1-8191-7a1f734ea457/v2.0",
+					},
+					MicrosoftEntraIDConfig: options.MicrosoftEntraIDOptions{
+						GroupNameAttribute: tc.attribute,
+					},
+				},
+			)
+			mockedGraphURL, _ := url.Parse(mockedGraph.URL)
+			provider.microsoftGraphURL = &url.URL{Scheme: "http", Host: mockedGraphURL.Host, Path: "/v1.0/me"}
+
+			for i := 0; i < 2; i++ {
+				session := CreateAuthorizedSession()
+				session.IDToken = newSignedTestEntraIDToken(t, nil)
+				session.Email = "mock@example.com"
+				session.Groups = []string{
+					"85d7d600-7804-4d92-8d43-9c33c21c130c",
+					"916f0604-8a3b-4a69-bda9-06db11a8f0cd",
+					"0b4e2f6a-7c1d-4f3e-9a5b-2c8d1e6f4a7b",
+					"not-an-object-id",
+				}
+
+				g.Expect(provider.EnrichSession(context.Background(), session)).To(Succeed())
+				g.Expect(session.Groups).To(Equal(tc.expectedGroups))
+			}
+
+			// The second session is resolved from the cache
+			g.Expect(*requestCount).To(Equal(1))
+		})
+	}
+}
+
+func TestAzureEntraOIDCProviderEnrichSessionGroupNamesUsesProviderClient(t *testing.T) {
+	g := NewWithT(t)
+
+	handler, requestCount := mockGraphGetByIDsHandler()
+	mockedGraph := httptest.NewTLSServer(handler)
+	defer mockedGraph.Close()
+
+	provider := NewMicrosoftEntraIDProvider(&ProviderData{},
+		options.Provider{
+			OIDCConfig: options.OIDCOptions{
+				IssuerURL: "https://login.microsoftonline.com/18014347-dd57-41a1-8191-7a1f734ea457/v2.0",
+			},
+			MicrosoftEntraIDConfig: options.MicrosoftEntraIDOptions{
+				GroupNameAttribute: options.MicrosoftEntraIDGroupDisplayName,
+			},
+		},
+	)
+	// The Graph API is only trusted through the client of the provider
+	provider.httpClient = mockedGraph.Client()
+	mockedGraphURL, _ := url.Parse(mockedGraph.URL)
+	provider.microsoftGraphURL = &url.URL{Scheme: "https", Host: mockedGraphURL.Host, Path: "/v1.0/me"}
+
+	session := CreateAuthorizedSession()
+	session.IDToken = newSignedTestEntraIDToken(t, nil)
+	session.Email = "mock@example.com"
+	session.Groups = []string{"85d7d600-7804-4d92-8d43-9c33c21c130c"}
+
+	g.Expect(provider.EnrichSession(context.Background(), session)).To(Succeed())
+	g.Expect(session.Groups).To(Equal([]string{"Engineering"}))
+	g.Expect(*requestCount).To(Equal(1))
+}
+
+func TestAzureEntraOIDCProviderEnrichSessionAppRoles(t *testing.T) {
+	g := NewWithT(t)
+
+	provider := NewMicrosoftEntraIDProvider(&ProviderData{},
+		options.Provider{
+			OIDCConfig: options.OIDCOptions{
+				IssuerURL: "https://login.microsoftonline.com/18014347-dd57-41a1-8191-7a1f734ea457/v2.0",
+			},
+			MicrosoftEntraIDConfig: options.MicrosoftEntraIDOptions{
+				AppRolesAsGroups: ptr.To(true),
+				AppRolePrefix:    "role:",
+			},
+		},
+	)
+
+	session := CreateAuthorizedSession()
+	session.IDToken = newSignedTestEntraIDToken(t, []string{"Admin", "Reader"})
+	session.Email = "mock@example.com"
+	session.Groups = []string{"85d7d600-7804-4d92-8d43-9c33c21c130c"}
+
+	g.Expect(provider.EnrichSession(context.Background(), session)).To(Succeed())
+	g.Expect(session.Groups).To(Equal([]string{
+		"85d7d600-7804-4d92-8d43-9c33c21c130c",
+		"role:Admin",
+		"role:Reader",
+	}))
+}
+
+func newSignedTestEntraIDToken(t *testing.T, roles []string) string {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	claims := &struct {
+		jwt.RegisteredClaims
+		Roles []string `json:"roles,omitempty"`
+	}{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer: "https://login.microsoftonline.com/18014347-dd57-41a1-8191-7a1f734ea457/v2.0",
+		},
+		Roles: roles,
+	}
+
+	signedJWT, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	assert.NoError(t, err)
+	return signedJWT
+}
+
+// mockGraphGetByIDsAPI serves the directoryObjects/getByIds endpoint and counts
+// the requests made to it
+func mockGraphGetByIDsAPI() (*httptest.Server, *int) {
+	handler, requestCount := mockGraphGetByIDsHandler()
+	return httptest.NewServer(handler), requestCount
+}
+
+func mockGraphGetByIDsHandler() (http.Handler, *int) {
+	groups := map[string]map[string]interface{}{
+		"85d7d600-7804-4d92-8d43-9c33c21c130c": {
+			"displayName":              "Engineering",
+			"onPremisesSamAccountName": "CORP-ENG",
+		},
+		"916f0604-8a3b-4a69-bda9-06db11a8f0cd": {
+			"displayName":              "Cloud Only",
+			"onPremisesSamAccountName": nil,
+		},
+	}
+
+	requestCount := 0
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1.0/directoryObjects/getByIds" || r.Method != http.MethodPost ||
+				r.Header.Get("Authorization") != "Bearer "+authorizedAccessToken {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			requestCount++
+
+			var body struct {
+				IDs   []string `json:"ids"`
+				Types []string `json:"types"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Types) != 1 || body.Types[0] != "group" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			value := []map[string]interface{}{}
+			for _, id := range body.IDs {
+				if group, ok := groups[id]; ok {
+					object := map[string]interface{}{
+						"@odata.type": "#microsoft.graph.group",
+						"id":          id,
+					}
+					for k, v := range group {
+						object[k] = v
+					}
+					value = append(value, object)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": value})
+		},
+	), &requestCount
+}
+
 func mockGraphAPI(noGroupMemberPermissions bool) *httptest.Server {
 	groupsPath := "/v1.0/me/transitiveMemberOf"
 
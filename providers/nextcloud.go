@@ -44,7 +44,7 @@ func (p *NextcloudProvider) EnrichSession(ctx context.Context, s *sessions.Sessi
 	}
 
 	json, err := requests.New(profileURL).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		SetHeader("Authorization", tokenTypeBearer+" "+s.AccessToken).
 		Do().
 		UnmarshalSimpleJSON()
@@ -7,11 +7,9 @@ import (
 	"net/url"
 	"time"
 
-	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
-	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
 	"golang.org/x/oauth2"
 )
@@ -73,6 +71,10 @@ func (p *OIDCProvider) GetLoginURL(redirectURI, state, nonce string, extraParams
 
 // Redeem exchanges the OAuth2 authentication token for an ID token
 func (p *OIDCProvider) Redeem(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
+	if !p.usesClientSecretAuthentication() {
+		return p.redeemWithClientAuthentication(ctx, redirectURL, code, codeVerifier)
+	}
+
 	clientSecret, err := p.GetClientSecret()
 	if err != nil {
 		return nil, err
@@ -92,7 +94,7 @@ func (p *OIDCProvider) Redeem(ctx context.Context, redirectURL, code, codeVerifi
 		RedirectURL: redirectURL,
 	}
 
-	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
+	ctx = p.ClientContext(ctx)
 	token, err := c.Exchange(ctx, code, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("token exchange failed: %v", err)
@@ -101,6 +103,41 @@ func (p *OIDCProvider) Redeem(ctx context.Context, redirectURL, code, codeVerifi
 	return p.createSession(ctx, token, false)
 }
 
+// redeemWithClientAuthentication exchanges the code at the token endpoint,
+// authenticating with the configured method instead of the client secret
+func (p *OIDCProvider) redeemWithClientAuthentication(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
+	params := url.Values{}
+	params.Add("code", code)
+	params.Add("grant_type", "authorization_code")
+	params.Add("redirect_uri", redirectURL)
+	if codeVerifier != "" {
+		params.Add("code_verifier", codeVerifier)
+	}
+	if err := p.setClientAuthentication(params); err != nil {
+		return nil, err
+	}
+
+	ctx = p.ClientContext(ctx)
+	token, err := p.fetchToken(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %v", err)
+	}
+
+	return p.createSession(ctx, token, false)
+}
+
+// RedeemDeviceCode polls the token endpoint with the device code and creates
+// the session from the ID token once the user has completed the login
+func (p *OIDCProvider) RedeemDeviceCode(ctx context.Context, deviceCode string) (*sessions.SessionState, error) {
+	ctx = p.ClientContext(ctx)
+	token, err := p.fetchDeviceToken(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.createSession(ctx, token, false)
+}
+
 // EnrichSession is called after Redeem to allow providers to enrich session fields
 // such as User, Email, Groups with provider specific API calls.
 func (p *OIDCProvider) EnrichSession(_ context.Context, s *sessions.SessionState) error {
@@ -113,7 +150,7 @@ func (p *OIDCProvider) EnrichSession(_ context.Context, s *sessions.SessionState
 
 // ValidateSession checks that the session's id_token or access_token (when a ValidateURL is configured) is still valid
 func (p *OIDCProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
-	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
+	ctx = p.ClientContext(ctx)
 
 	// https://openid.net/specs/openid-connect-core-1_0.html#RefreshTokenResponse
 	// The ID Token is optional in the Refresh Token Response
@@ -135,7 +172,7 @@ func (p *OIDCProvider) ValidateSession(ctx context.Context, s *sessions.SessionS
 		return true
 	}
 
-	if err := p.checkNonce(s); err != nil {
+	if err := p.checkNonce(ctx, s); err != nil {
 		logger.Errorf("nonce verification failed: %v", err)
 		return false
 	}
@@ -149,7 +186,7 @@ func (p *OIDCProvider) RefreshSession(ctx context.Context, s *sessions.SessionSt
 		return false, nil
 	}
 
-	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
+	ctx = p.ClientContext(ctx)
 	err := p.redeemRefreshToken(ctx, s)
 	if err != nil {
 		return false, fmt.Errorf("unable to redeem refresh token: %v", err)
@@ -162,23 +199,7 @@ func (p *OIDCProvider) RefreshSession(ctx context.Context, s *sessions.SessionSt
 // Access Token and (optionally) the ID Token.
 // https://openid.net/specs/openid-connect-core-1_0.html#RefreshTokenResponse
 func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.SessionState) error {
-	clientSecret, err := p.GetClientSecret()
-	if err != nil {
-		return err
-	}
-
-	c := oauth2.Config{
-		ClientID:     p.ClientID,
-		ClientSecret: clientSecret,
-		Endpoint: oauth2.Endpoint{
-			TokenURL: p.RedeemURL.String(),
-		},
-	}
-	t := &oauth2.Token{
-		RefreshToken: s.RefreshToken,
-		Expiry:       time.Now().Add(-time.Hour),
-	}
-	token, err := c.TokenSource(ctx, t).Token()
+	token, err := p.fetchRefreshedToken(ctx, s.RefreshToken)
 	if err != nil {
 		return fmt.Errorf("failed to get token: %v", err)
 	}
@@ -207,15 +228,47 @@ func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sessi
 	return nil
 }
 
+// fetchRefreshedToken redeems the refresh token at the token endpoint with
+// the configured client authentication method
+func (p *OIDCProvider) fetchRefreshedToken(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	if !p.usesClientSecretAuthentication() {
+		params := url.Values{}
+		params.Add("grant_type", "refresh_token")
+		params.Add("refresh_token", refreshToken)
+		if err := p.setClientAuthentication(params); err != nil {
+			return nil, err
+		}
+		return p.fetchToken(ctx, params)
+	}
+
+	clientSecret, err := p.GetClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	c := oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: clientSecret,
+		Endpoint: oauth2.Endpoint{
+			TokenURL: p.RedeemURL.String(),
+		},
+	}
+	t := &oauth2.Token{
+		RefreshToken: refreshToken,
+		Expiry:       time.Now().Add(-time.Hour),
+	}
+	return c.TokenSource(ctx, t).Token()
+}
+
 // CreateSessionFromToken converts Bearer IDTokens into sessions
 func (p *OIDCProvider) CreateSessionFromToken(ctx context.Context, token string) (*sessions.SessionState, error) {
-	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
+	ctx = p.ClientContext(ctx)
 	idToken, err := p.Verifier.Verify(ctx, token)
 	if err != nil {
 		return nil, err
 	}
 
-	ss, err := p.buildSessionFromClaims(token, "")
+	ss, err := p.buildSessionFromClaims(ctx, token, "")
 	if err != nil {
 		return nil, err
 	}
@@ -252,7 +305,7 @@ func (p *OIDCProvider) createSession(ctx context.Context, token *oauth2.Token, r
 	}
 
 	rawIDToken := getIDToken(token)
-	ss, err := p.buildSessionFromClaims(rawIDToken, token.AccessToken)
+	ss, err := p.buildSessionFromClaims(ctx, rawIDToken, token.AccessToken)
 	if err != nil {
 		return nil, err
 	}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
@@ -27,16 +28,38 @@ const (
 // ProviderData contains information required to configure all implementations
 // of OAuth2 providers
 type ProviderData struct {
+	// ProviderID is the unique ID of the provider as configured in the
+	// providers list. It is recorded on sessions so that they can be
+	// refreshed and validated against the provider that created them.
+	ProviderID        string
 	ProviderName      string
 	LoginURL          *url.URL
 	RedeemURL         *url.URL
 	ProfileURL        *url.URL
 	ProtectedResource *url.URL
 	ValidateURL       *url.URL
-	ClientID          string
-	ClientSecret      string
-	ClientSecretFile  string
-	Scope             string
+	// EndSessionURL is the end_session_endpoint users are redirected to on
+	// sign out. It is only set when RP-initiated logout is enabled.
+	EndSessionURL *url.URL
+	// DeviceAuthorizationURL is the device authorization endpoint. It is only
+	// set when the device authorization grant is enabled.
+	DeviceAuthorizationURL *url.URL
+	// PushedAuthorizationRequestURL is the pushed authorization request
+	// endpoint (RFC 9126) the authorization request parameters are posted to
+	PushedAuthorizationRequestURL *url.URL
+	// RequirePushedAuthorizationRequests fails the login when the pushed
+	// authorization request can't be made
+	RequirePushedAuthorizationRequests bool
+	// IntrospectionURL is the token introspection endpoint (RFC 7662). It is
+	// only set when token introspection is enabled.
+	IntrospectionURL *url.URL
+	// RevalidateInterval is how often the authorization of sessions is
+	// re-validated with RevalidateSession, zero when disabled
+	RevalidateInterval time.Duration
+	ClientID           string
+	ClientSecret       string
+	ClientSecretFile   string
+	Scope              string
 	// The response mode requested from the provider or empty for default ("query")
 	AuthRequestResponseMode string
 	// The picked CodeChallenge Method or empty if none.
@@ -72,6 +95,30 @@ type ProviderData struct {
 	AllowedGroups map[string]struct{}
 
 	getAuthorizationHeaderFunc func(string) http.Header
+	// getClientSecretFunc creates the client secret for providers that
+	// don't use a static secret, nil to use ClientSecret or ClientSecretFile
+	getClientSecretFunc func() (string, error)
+	// clientAuthenticationMethod is the configured method to authenticate
+	// the client to the token endpoint, empty for the client secret
+	clientAuthenticationMethod options.ClientAuthenticationMethod
+	// clientAssertion is set when the client authenticates to the token
+	// endpoint with a signed JWT instead of the client secret
+	clientAssertion *clientAssertion
+	// httpClient verifies the provider against its configured CA files and
+	// presents the configured client certificate on the requests to the
+	// provider, nil when neither is configured
+	httpClient *http.Client
+	// dpop signs the DPoP proofs of the token and resource requests, nil
+	// when the tokens are not bound to a DPoP key
+	dpop *dpopProver
+	// introspectionCache holds the recent token introspection results
+	introspectionCache *introspectionCache
+	// extraAudiences are the audiences, besides the client ID, of the tokens
+	// accepted through token introspection
+	extraAudiences []string
+	// claimExpressions compute session fields and authorize sessions, nil
+	// when no claim expressions are configured
+	claimExpressions           *util.ClaimExpressions
 	loginURLParameterDefaults  url.Values
 	loginURLParameterOverrides map[string]*regexp.Regexp
 
@@ -82,6 +129,9 @@ type ProviderData struct {
 func (p *ProviderData) Data() *ProviderData { return p }
 
 func (p *ProviderData) GetClientSecret() (clientSecret string, err error) {
+	if p.getClientSecretFunc != nil {
+		return p.getClientSecretFunc()
+	}
 	if p.ClientSecret != "" || p.ClientSecretFile == "" {
 		return p.ClientSecret, nil
 	}
@@ -255,14 +305,14 @@ func (p *ProviderData) verifyIDToken(ctx context.Context, token *oauth2.Token) (
 
 // buildSessionFromClaims uses IDToken claims to populate a fresh SessionState
 // with non-Token related fields.
-func (p *ProviderData) buildSessionFromClaims(rawIDToken, accessToken string) (*sessions.SessionState, error) {
+func (p *ProviderData) buildSessionFromClaims(ctx context.Context, rawIDToken, accessToken string) (*sessions.SessionState, error) {
 	ss := &sessions.SessionState{}
 
 	if rawIDToken == "" {
 		return ss, nil
 	}
 
-	extractor, err := p.getClaimExtractor(rawIDToken, accessToken)
+	extractor, err := p.getClaimExtractor(ctx, rawIDToken, accessToken)
 	if err != nil {
 		return nil, err
 	}
@@ -283,6 +333,25 @@ func (p *ProviderData) buildSessionFromClaims(rawIDToken, accessToken string) (*
 		}
 	}
 
+	// The session and subject IDs that back-channel logout tokens refer to
+	// are those of the ID token, so they are never looked up from the profile
+	// URL
+	tokenExtractor, err := util.NewClaimExtractor(context.TODO(), rawIDToken, &url.URL{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range []struct {
+		claim string
+		dst   *string
+	}{
+		{"sid", &ss.SID},
+		{"sub", &ss.Subject},
+	} {
+		if _, err := tokenExtractor.GetClaimInto(c.claim, c.dst); err != nil {
+			return nil, err
+		}
+	}
+
 	if p.AdditionalClaims != nil {
 		p.extractAdditionalClaims(extractor, ss)
 	}
@@ -306,13 +375,13 @@ func (p *ProviderData) buildSessionFromClaims(rawIDToken, accessToken string) (*
 	return ss, nil
 }
 
-func (p *ProviderData) getClaimExtractor(rawIDToken, accessToken string) (util.ClaimExtractor, error) {
+func (p *ProviderData) getClaimExtractor(ctx context.Context, rawIDToken, accessToken string) (util.ClaimExtractor, error) {
 	profileURL := p.ProfileURL
 	if p.SkipClaimsFromProfileURL {
 		profileURL = &url.URL{}
 	}
 
-	extractor, err := util.NewClaimExtractor(context.TODO(), rawIDToken, profileURL, p.getAuthorizationHeader(accessToken))
+	extractor, err := util.NewClaimExtractor(p.ClientContext(ctx), rawIDToken, profileURL, p.getAuthorizationHeader(accessToken))
 	if err != nil {
 		return nil, fmt.Errorf("could not initialise claim extractor: %v", err)
 	}
@@ -337,8 +406,8 @@ func (p *ProviderData) extractAdditionalClaims(extractor util.ClaimExtractor, ss
 }
 
 // checkNonce compares the session's nonce with the IDToken's nonce claim
-func (p *ProviderData) checkNonce(s *sessions.SessionState) error {
-	extractor, err := p.getClaimExtractor(s.IDToken, "")
+func (p *ProviderData) checkNonce(ctx context.Context, s *sessions.SessionState) error {
+	extractor, err := p.getClaimExtractor(ctx, s.IDToken, "")
 	if err != nil {
 		return fmt.Errorf("id_token claims extraction failed: %v", err)
 	}
@@ -246,6 +246,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "groups",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "123456789",
 				Email:             "janed@me.com",
 				Groups:            []string{"test:a", "test:b"},
@@ -266,6 +267,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "groups",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "123456789",
 				Email:             "unverified@email.com",
 				Groups:            []string{"test:a", "test:b"},
@@ -279,8 +281,9 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "groups",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
-				User:  "123456789",
-				Email: "complex@claims.com",
+				Subject: "123456789",
+				User:    "123456789",
+				Email:   "complex@claims.com",
 				Groups: []string{
 					"{\"groupId\":\"Admin Group Id\",\"roles\":[\"Admin\"]}",
 					"12345",
@@ -296,6 +299,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			EmailClaim:      "email",
 			GroupsClaim:     "groups",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "+4798765432",
 				Email:             "janed@me.com",
 				Groups:            []string{"test:a", "test:b"},
@@ -309,6 +313,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			EmailClaim:      "email",
 			GroupsClaim:     "groups",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "[\"test:c\",\"test:d\"]",
 				Email:             "janed@me.com",
 				Groups:            []string{"test:a", "test:b"},
@@ -322,6 +327,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "groups",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "123456789",
 				Email:             "+4025205729",
 				Groups:            []string{"test:a", "test:b"},
@@ -335,6 +341,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "groups",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "123456789",
 				Email:             "[\"test:c\",\"test:d\"]",
 				Groups:            []string{"test:a", "test:b"},
@@ -348,6 +355,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "groups",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "123456789",
 				Email:             "",
 				Groups:            []string{"test:a", "test:b"},
@@ -361,6 +369,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "roles",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "123456789",
 				Email:             "janed@me.com",
 				Groups:            []string{"test:c", "test:d"},
@@ -374,6 +383,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "alskdjfsalkdjf",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "123456789",
 				Email:             "janed@me.com",
 				Groups:            nil,
@@ -387,6 +397,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "groups",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "123456789",
 				Email:             "janed@me.com",
 				Groups:            []string{"1", "2", "3"},
@@ -400,6 +411,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			GroupsClaim:     "email",
 			UserClaim:       "sub",
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				User:              "123456789",
 				Email:             "janed@me.com",
 				Groups:            []string{"janed@me.com"},
@@ -410,18 +422,19 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			IDToken:                minimalIDToken,
 			SetProfileURL:          true,
 			ExpectProfileURLCalled: true,
-			ExpectedSession:        &sessions.SessionState{},
+			ExpectedSession:        &sessions.SessionState{Subject: "123456789"},
 		},
 		"Skip claims request to ProfileURL": {
 			IDToken:                  minimalIDToken,
 			SetProfileURL:            true,
 			SkipClaimsFromProfileURL: true,
-			ExpectedSession:          &sessions.SessionState{},
+			ExpectedSession:          &sessions.SessionState{Subject: "123456789"},
 		},
 		"Additional claims": {
 			IDToken:          defaultIDToken,
 			AdditionalClaims: []string{"phone_number", "picture"},
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				PreferredUsername: "Jane Dobbs",
 				AdditionalClaims: map[string]interface{}{
 					"phone_number": "+4798765432",
@@ -433,6 +446,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			IDToken:          defaultIDToken,
 			AdditionalClaims: []string{"phone_number", "picture1"},
 			ExpectedSession: &sessions.SessionState{
+				Subject:           "123456789",
 				PreferredUsername: "Jane Dobbs",
 				AdditionalClaims: map[string]interface{}{
 					"phone_number": "+4798765432",
@@ -480,7 +494,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			rawIDToken, err := newSignedTestIDToken(tc.IDToken)
 			g.Expect(err).ToNot(HaveOccurred())
 
-			ss, err := provider.buildSessionFromClaims(rawIDToken, "testtoken")
+			ss, err := provider.buildSessionFromClaims(context.Background(), rawIDToken, "testtoken")
 			if err != nil {
 				g.Expect(err).To(Equal(tc.ExpectedError))
 			}
@@ -543,7 +557,7 @@ func TestProviderData_checkNonce(t *testing.T) {
 				), verificationOptions),
 			}
 
-			if err := provider.checkNonce(tc.Session); err != nil {
+			if err := provider.checkNonce(context.Background(), tc.Session); err != nil {
 				g.Expect(err).To(Equal(tc.ExpectedError))
 			} else {
 				g.Expect(err).ToNot(HaveOccurred())
@@ -5,10 +5,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 )
 
@@ -45,6 +47,25 @@ func (p *ProviderData) GetLoginURL(redirectURI, state, _ string, extraParams url
 	return loginURL.String()
 }
 
+// GetLogoutURL returns the end session URL with the RP-initiated logout
+// parameters, or an empty string if RP-initiated logout is not enabled.
+func (p *ProviderData) GetLogoutURL(redirectURI, state, idTokenHint string) string {
+	if p.EndSessionURL == nil || p.EndSessionURL.String() == "" {
+		return ""
+	}
+
+	a := *p.EndSessionURL
+	params, _ := url.ParseQuery(a.RawQuery)
+	params.Set("client_id", p.ClientID)
+	params.Set("post_logout_redirect_uri", redirectURI)
+	params.Set("state", state)
+	if idTokenHint != "" {
+		params.Set("id_token_hint", idTokenHint)
+	}
+	a.RawQuery = params.Encode()
+	return a.String()
+}
+
 // Redeem provides a default implementation of the OAuth2 token redemption process
 // The codeVerifier is set if a code_verifier parameter should be sent for PKCE
 func (p *ProviderData) Redeem(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
@@ -70,7 +91,7 @@ func (p *ProviderData) Redeem(ctx context.Context, redirectURL, code, codeVerifi
 	}
 
 	result := requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithMethod("POST").
 		WithBody(bytes.NewBufferString(params.Encode())).
 		SetHeader("Content-Type", "application/x-www-form-urlencoded").
@@ -122,6 +143,10 @@ func (p *ProviderData) EnrichSession(_ context.Context, _ *sessions.SessionState
 // Authorize performs global authorization on an authenticated session.
 // This is not used for fine-grained per route authorization rules.
 func (p *ProviderData) Authorize(_ context.Context, s *sessions.SessionState) (bool, error) {
+	if authorized, err := p.claimExpressions.Authorize(s); err != nil || !authorized {
+		return false, err
+	}
+
 	if len(p.AllowedGroups) == 0 {
 		return true, nil
 	}
@@ -135,6 +160,21 @@ func (p *ProviderData) Authorize(_ context.Context, s *sessions.SessionState) (b
 	return false, nil
 }
 
+// ApplyClaimExpressions sets the session fields computed by the configured
+// claim expressions. It is applied to every session the provider creates or
+// refreshes, after the provider specific claim handling.
+func (p *ProviderData) ApplyClaimExpressions(s *sessions.SessionState) error {
+	return p.claimExpressions.MapSession(s)
+}
+
+// RevertClaimExpressions resets the session fields computed by the claim
+// expressions to the values the provider set. It is applied before a session
+// is refreshed or re-validated, so that the provider works with its own values
+// and ApplyClaimExpressions doesn't map already mapped values again.
+func (p *ProviderData) RevertClaimExpressions(s *sessions.SessionState) {
+	p.claimExpressions.RestoreSession(s)
+}
+
 // ValidateSession validates the AccessToken
 func (p *ProviderData) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
 	return validateToken(ctx, p, s.AccessToken, nil)
@@ -145,6 +185,30 @@ func (p *ProviderData) RefreshSession(_ context.Context, _ *sessions.SessionStat
 	return false, ErrNotImplemented
 }
 
+// RevalidateSession re-runs the membership checks of the provider on an
+// existing session with its stored access token, and updates its groups.
+// It returns false when the user no longer meets the provider restrictions
+// or the provider rejects the access token; the allowed groups are checked by
+// Authorize afterwards. An error means the provider couldn't be reached.
+func (p *ProviderData) RevalidateSession(_ context.Context, _ *sessions.SessionState) (bool, error) {
+	return false, ErrNotImplemented
+}
+
+// revalidationResult returns the result of a re-validation that failed with
+// the error. Responses of the provider API that reject the request, such as
+// a 401 or 403 for a revoked access token, mean the session is no longer
+// authorized. Other errors, such as network errors, 5xx responses and rate
+// limiting, are returned so that the session is re-validated again later.
+func revalidationResult(user string, err error) (bool, error) {
+	var statusErr *requests.UnexpectedStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+		statusErr.StatusCode != http.StatusTooManyRequests {
+		logger.Printf("Provider rejected the re-validation of %s: %v", user, err)
+		return false, nil
+	}
+	return false, err
+}
+
 // CreateSessionFromToken converts Bearer IDTokens into sessions
 func (p *ProviderData) CreateSessionFromToken(ctx context.Context, token string) (*sessions.SessionState, error) {
 	if p.Verifier != nil {
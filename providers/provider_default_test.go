@@ -6,7 +6,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/util"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
 )
@@ -121,6 +123,93 @@ func TestProviderDataAuthorize(t *testing.T) {
 	}
 }
 
+func TestProviderDataAuthorizeWithClaimExpressions(t *testing.T) {
+	testCases := []struct {
+		name          string
+		idToken       idTokenClaims
+		allowedGroups []string
+		expectedAuthZ bool
+	}{
+		{
+			name:          "ExpressionAllows",
+			idToken:       defaultIDToken,
+			expectedAuthZ: true,
+		},
+		{
+			name:          "ExpressionDenies",
+			idToken:       unverifiedIDToken,
+			expectedAuthZ: false,
+		},
+		{
+			name:          "ExpressionAllowsUserInAllowedGroup",
+			idToken:       defaultIDToken,
+			allowedGroups: []string{"test:a"},
+			expectedAuthZ: true,
+		},
+		{
+			name:          "ExpressionAllowsUserNotInAllowedGroup",
+			idToken:       defaultIDToken,
+			allowedGroups: []string{"test:z"},
+			expectedAuthZ: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			expressions, err := util.NewClaimExpressions(&options.ClaimExpressions{
+				Authorize: `claims.email_verified && "test:c" in claims.roles`,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			idToken, err := newSignedTestIDToken(tc.idToken)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			session := &sessions.SessionState{
+				IDToken: idToken,
+				Groups:  []string{"test:a", "test:b"},
+			}
+			p := &ProviderData{claimExpressions: expressions}
+			p.setAllowedGroups(tc.allowedGroups)
+
+			authorized, err := p.Authorize(context.Background(), session)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(authorized).To(Equal(tc.expectedAuthZ))
+		})
+	}
+}
+
+func TestProviderDataApplyClaimExpressions(t *testing.T) {
+	g := NewWithT(t)
+
+	expressions, err := util.NewClaimExpressions(&options.ClaimExpressions{
+		Groups:            `claims.roles.map(r, "role:" + r)`,
+		PreferredUsername: `claims.preferred_username.lowerAscii()`,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	idToken, err := newSignedTestIDToken(defaultIDToken)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	session := &sessions.SessionState{
+		IDToken: idToken,
+		Email:   "janed@me.com",
+		Groups:  []string{"test:a", "test:b"},
+	}
+	p := &ProviderData{claimExpressions: expressions}
+
+	g.Expect(p.ApplyClaimExpressions(session)).To(Succeed())
+	g.Expect(session.Email).To(Equal("janed@me.com"))
+	g.Expect(session.Groups).To(Equal([]string{"role:test:c", "role:test:d"}))
+	g.Expect(session.PreferredUsername).To(Equal("jane dobbs"))
+
+	// Without claim expressions the session is left untouched
+	p = &ProviderData{}
+	g.Expect(p.ApplyClaimExpressions(session)).To(Succeed())
+	g.Expect(session.Groups).To(Equal([]string{"role:test:c", "role:test:d"}))
+}
+
 func TestResponseModeConfigured(t *testing.T) {
 	p := &ProviderData{
 		LoginURL: &url.URL{
@@ -147,3 +236,35 @@ func TestResponseModeNotConfigured(t *testing.T) {
 	result := p.GetLoginURL("https://my.test.app/oauth", "", "", url.Values{})
 	assert.NotContains(t, result, "response_mode")
 }
+
+func TestGetLogoutURL(t *testing.T) {
+	p := &ProviderData{
+		ClientID: "client",
+		EndSessionURL: &url.URL{
+			Scheme:   "https",
+			Host:     "my.test.idp",
+			Path:     "/logout",
+			RawQuery: "ui_locales=en",
+		},
+	}
+
+	result, err := url.Parse(p.GetLogoutURL("https://my.test.app/oauth2/sign_out/callback", "state", "id-token"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/logout", result.Path)
+	assert.Equal(t, url.Values{
+		"client_id":                {"client"},
+		"id_token_hint":            {"id-token"},
+		"post_logout_redirect_uri": {"https://my.test.app/oauth2/sign_out/callback"},
+		"state":                    {"state"},
+		"ui_locales":               {"en"},
+	}, result.Query())
+
+	result, err = url.Parse(p.GetLogoutURL("https://my.test.app/oauth2/sign_out/callback", "state", ""))
+	assert.NoError(t, err)
+	assert.NotContains(t, result.Query(), "id_token_hint")
+}
+
+func TestGetLogoutURLNotConfigured(t *testing.T) {
+	p := &ProviderData{}
+	assert.Equal(t, "", p.GetLogoutURL("https://my.test.app/oauth2/sign_out/callback", "state", "id-token"))
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
 )
@@ -22,6 +24,7 @@ const (
 type Provider interface {
 	Data() *ProviderData
 	GetLoginURL(redirectURI, finalRedirect, nonce string, extraParams url.Values) string
+	GetLogoutURL(redirectURI, state, idTokenHint string) string
 	Redeem(ctx context.Context, redirectURI, code, codeVerifier string) (*sessions.SessionState, error)
 	// Deprecated: Migrate to EnrichSession
 	GetEmailAddress(ctx context.Context, s *sessions.SessionState) (string, error)
@@ -29,7 +32,20 @@ type Provider interface {
 	Authorize(ctx context.Context, s *sessions.SessionState) (bool, error)
 	ValidateSession(ctx context.Context, s *sessions.SessionState) bool
 	RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error)
+	RevalidateSession(ctx context.Context, s *sessions.SessionState) (bool, error)
 	CreateSessionFromToken(ctx context.Context, token string) (*sessions.SessionState, error)
+	StartDeviceAuthorization(ctx context.Context) (*DeviceAuthorization, error)
+	RedeemDeviceCode(ctx context.Context, deviceCode string) (*sessions.SessionState, error)
+}
+
+// CallbackSessionEnricher is implemented by providers that post details of the
+// user to the callback along with the code, which are not available from the
+// tokens or endpoints of the provider
+type CallbackSessionEnricher interface {
+	Provider
+	// EnrichSessionFromCallback sets session fields from the form of the
+	// callback request after the code is redeemed
+	EnrichSessionFromCallback(s *sessions.SessionState, form url.Values) error
 }
 
 func NewProvider(providerConfig options.Provider) (Provider, error) {
@@ -40,6 +56,8 @@ func NewProvider(providerConfig options.Provider) (Provider, error) {
 	switch providerConfig.Type {
 	case options.ADFSProvider:
 		return NewADFSProvider(providerData, providerConfig), nil
+	case options.AppleProvider:
+		return NewAppleProvider(providerData, providerConfig)
 	case options.AzureProvider:
 		return NewAzureProvider(providerData, providerConfig.AzureConfig), nil
 	case options.MicrosoftEntraIDProvider:
@@ -50,8 +68,14 @@ func NewProvider(providerConfig options.Provider) (Provider, error) {
 		return NewCIDAASProvider(providerData, providerConfig), nil
 	case options.DigitalOceanProvider:
 		return NewDigitalOceanProvider(providerData), nil
+	case options.DiscordProvider:
+		return NewDiscordProvider(providerData, providerConfig.DiscordConfig), nil
 	case options.FacebookProvider:
 		return NewFacebookProvider(providerData), nil
+	case options.GenericOAuth2Provider:
+		return NewGenericOAuth2Provider(providerData, providerConfig.GenericOAuth2Config), nil
+	case options.GiteaProvider:
+		return NewGiteaProvider(providerData, providerConfig)
 	case options.GitHubProvider:
 		return NewGitHubProvider(providerData, providerConfig.GitHubConfig), nil
 	case options.GitLabProvider:
@@ -66,10 +90,14 @@ func NewProvider(providerConfig options.Provider) (Provider, error) {
 		return NewLinkedInProvider(providerData), nil
 	case options.LoginGovProvider:
 		return NewLoginGovProvider(providerData, providerConfig.LoginGovConfig)
+	case options.MockProvider:
+		return NewMockProvider(providerData, providerConfig)
 	case options.NextCloudProvider:
 		return NewNextcloudProvider(providerData), nil
 	case options.OIDCProvider:
 		return NewOIDCProvider(providerData, providerConfig.OIDCConfig), nil
+	case options.SAMLProvider:
+		return NewSAMLProvider(providerData, providerConfig.SAMLConfig)
 	case options.SourceHutProvider:
 		return NewSourceHutProvider(providerData), nil
 	default:
@@ -79,6 +107,7 @@ func NewProvider(providerConfig options.Provider) (Provider, error) {
 
 func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData, error) {
 	p := &ProviderData{
+		ProviderID:              providerConfig.ID,
 		Scope:                   providerConfig.Scope,
 		ClientID:                providerConfig.ClientID,
 		ClientSecret:            providerConfig.ClientSecret,
@@ -91,9 +120,21 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 	if err != nil {
 		return nil, err
 	}
+	if providerConfig.Type == options.AppleProvider && providerConfig.OIDCConfig.IssuerURL == "" {
+		providerConfig.OIDCConfig.IssuerURL = appleDefaultIssuerURL
+	}
+
+	p.httpClient, err = newProviderHTTPClient(providerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not create HTTP client: %v", err)
+	}
+	p.dpop, err = newDPoPProver(providerConfig.DPoP)
+	if err != nil {
+		return nil, fmt.Errorf("could not load DPoP key: %v", err)
+	}
 
 	if needsVerifier {
-		pv, err := internaloidc.NewProviderVerifier(context.TODO(), internaloidc.ProviderVerifierOptions{
+		pv, err := internaloidc.NewProviderVerifier(p.ClientContext(context.TODO()), internaloidc.ProviderVerifierOptions{
 			AudienceClaims:         providerConfig.OIDCConfig.AudienceClaims,
 			ClientID:               providerConfig.ClientID,
 			ExtraAudiences:         providerConfig.OIDCConfig.ExtraAudiences,
@@ -111,11 +152,19 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 		if pv.DiscoveryEnabled() {
 			// Use the discovered values rather than any specified values
 			endpoints := pv.Provider().Endpoints()
+			if providerConfig.ClientCertificate != nil {
+				// Prefer the mutual TLS endpoint aliases when presenting a client certificate
+				endpoints = pv.Provider().MTLSEndpoints()
+			}
 			pkce := pv.Provider().PKCE()
 			providerConfig.LoginURL = endpoints.AuthURL
 			providerConfig.RedeemURL = endpoints.TokenURL
 			providerConfig.ProfileURL = endpoints.UserInfoURL
 			providerConfig.OIDCConfig.JwksURL = endpoints.JWKsURL
+			providerConfig.OIDCConfig.EndSessionURL = endpoints.EndSessionURL
+			providerConfig.DeviceAuthorizationURL = endpoints.DeviceAuthURL
+			providerConfig.PushedAuthorizationRequestURL = endpoints.PARURL
+			providerConfig.IntrospectionURL = endpoints.IntrospectionURL
 			p.SupportedCodeChallengeMethods = pkce.CodeChallengeAlgs
 		}
 	}
@@ -137,6 +186,51 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 			errs = append(errs, fmt.Errorf("could not parse %s URL: %v", name, err))
 		}
 	}
+	p.clientAuthenticationMethod = providerConfig.ClientAuthentication.Method
+	p.clientAssertion, err = newClientAssertion(providerConfig.ClientAuthentication)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not configure client authentication: %v", err))
+	}
+
+	if ptr.Deref(providerConfig.OIDCConfig.RPInitiatedLogout, options.DefaultRPInitiatedLogout) {
+		var err error
+		p.EndSessionURL, err = url.Parse(providerConfig.OIDCConfig.EndSessionURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse end session URL: %v", err))
+		}
+	}
+	if ptr.Deref(providerConfig.DeviceAuthorizationGrant, options.DefaultDeviceAuthorizationGrant) {
+		var err error
+		p.DeviceAuthorizationURL, err = url.Parse(providerConfig.DeviceAuthorizationURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse device authorization URL: %v", err))
+		}
+	}
+	if providerConfig.PushedAuthorizationRequestURL != "" {
+		var err error
+		p.PushedAuthorizationRequestURL, err = url.Parse(providerConfig.PushedAuthorizationRequestURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse pushed authorization request URL: %v", err))
+		}
+	}
+	if ptr.Deref(providerConfig.TokenIntrospection, options.DefaultTokenIntrospection) {
+		var err error
+		p.IntrospectionURL, err = url.Parse(providerConfig.IntrospectionURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse introspection URL: %v", err))
+		}
+		p.introspectionCache = newIntrospectionCache(ptr.Deref(providerConfig.IntrospectionCacheTTL, options.DefaultIntrospectionCacheTTL))
+		p.extraAudiences = providerConfig.OIDCConfig.ExtraAudiences
+	}
+	p.RequirePushedAuthorizationRequests = ptr.Deref(providerConfig.RequirePushedAuthorizationRequests, options.DefaultRequirePushedAuthorizationRequests)
+	if p.RequirePushedAuthorizationRequests && p.PushedAuthorizationRequestURL == nil {
+		errs = append(errs, errors.New("pushed authorization requests are required, but the provider has no pushed authorization request endpoint"))
+	}
+	p.RevalidateInterval = ptr.Deref(providerConfig.RevalidateInterval, options.DefaultRevalidateInterval)
+	p.claimExpressions, err = util.NewClaimExpressions(providerConfig.ClaimExpressions)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not compile claim expressions: %v", err))
+	}
 	// handle LoginURLParameters
 	errs = append(errs, p.compileLoginParams(providerConfig.LoginURLParameters)...)
 
@@ -144,6 +238,16 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 		return nil, k8serrors.NewAggregate(errs)
 	}
 
+	if p.EndSessionURL != nil && p.EndSessionURL.String() == "" {
+		logger.Printf("Warning: RP-initiated logout is enabled for provider %q, but it has no end session endpoint", providerConfig.ID)
+	}
+	if p.DeviceAuthorizationURL != nil && p.DeviceAuthorizationURL.String() == "" {
+		logger.Printf("Warning: the device authorization grant is enabled for provider %q, but it has no device authorization endpoint", providerConfig.ID)
+	}
+	if p.IntrospectionURL != nil && p.IntrospectionURL.String() == "" {
+		logger.Printf("Warning: token introspection is enabled for provider %q, but it has no introspection endpoint", providerConfig.ID)
+	}
+
 	// Make the OIDC options available to all providers that support it
 	p.AllowUnverifiedEmail = ptr.Deref(providerConfig.OIDCConfig.InsecureAllowUnverifiedEmail, options.DefaultInsecureAllowUnverifiedEmail)
 	p.EmailClaim = providerConfig.OIDCConfig.EmailClaim
@@ -188,12 +292,12 @@ func parseCodeChallengeMethod(providerConfig options.Provider) string {
 
 func providerRequiresOIDCProviderVerifier(providerType options.ProviderType) (bool, error) {
 	switch providerType {
-	case options.BitbucketProvider, options.DigitalOceanProvider, options.FacebookProvider, options.GitHubProvider,
+	case options.BitbucketProvider, options.DigitalOceanProvider, options.DiscordProvider, options.FacebookProvider, options.GenericOAuth2Provider, options.GitHubProvider,
 		options.GoogleProvider, options.KeycloakProvider, options.LinkedInProvider, options.LoginGovProvider,
-		options.NextCloudProvider, options.SourceHutProvider:
+		options.MockProvider, options.NextCloudProvider, options.SAMLProvider, options.SourceHutProvider:
 		return false, nil
-	case options.OIDCProvider, options.ADFSProvider, options.AzureProvider, options.CidaasProvider,
-		options.GitLabProvider, options.KeycloakOIDCProvider, options.MicrosoftEntraIDProvider:
+	case options.OIDCProvider, options.ADFSProvider, options.AppleProvider, options.AzureProvider, options.CidaasProvider,
+		options.GiteaProvider, options.GitLabProvider, options.KeycloakOIDCProvider, options.MicrosoftEntraIDProvider:
 		return true, nil
 	default:
 		return false, fmt.Errorf("unknown provider type: %s", providerType)
@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+)
+
+// pushedAuthorizationResponse is the response of the pushed authorization
+// request endpoint (RFC 9126)
+type pushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
+
+// PushAuthorizationRequest posts the parameters of the login URL to the pushed
+// authorization request endpoint (RFC 9126) together with the client
+// authentication. It returns the login URL with only the client_id and the
+// request_uri issued by the provider.
+func (p *ProviderData) PushAuthorizationRequest(ctx context.Context, loginURL string) (string, error) {
+	if p.PushedAuthorizationRequestURL == nil {
+		return "", errors.New("provider has no pushed authorization request endpoint")
+	}
+
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		return "", err
+	}
+	params := u.Query()
+	if err := p.setClientAuthentication(params); err != nil {
+		return "", err
+	}
+
+	resp := requests.New(p.PushedAuthorizationRequestURL.String()).
+		WithContext(p.ClientContext(ctx)).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetHeader("Accept", "application/json").
+		Do()
+	if err := resp.Error(); err != nil {
+		return "", err
+	}
+	// RFC 9126 specifies 201 Created, but some providers respond with 200 OK
+	if resp.StatusCode() != http.StatusCreated && resp.StatusCode() != http.StatusOK {
+		return "", tokenErrorFromResponse(resp, "pushed authorization request endpoint")
+	}
+
+	var pushed pushedAuthorizationResponse
+	if err := json.Unmarshal(resp.Body(), &pushed); err != nil {
+		return "", err
+	}
+	if pushed.RequestURI == "" {
+		return "", errors.New("pushed authorization response is missing the request_uri")
+	}
+
+	u.RawQuery = url.Values{
+		"client_id":   []string{p.ClientID},
+		"request_uri": []string{pushed.RequestURI},
+	}.Encode()
+	return u.String(), nil
+}
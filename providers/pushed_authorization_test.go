@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushAuthorizationRequest(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		rw.Header().Set("Content-Type", "application/json")
+		if form.Get("state") == "rejected" {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"error":"invalid_request","error_description":"invalid redirect_uri"}`))
+			return
+		}
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{"request_uri":"urn:ietf:params:oauth:request_uri:abc","expires_in":60}`))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	provider := newOIDCProvider(serverURL, false)
+	extraParams := url.Values{}
+	extraParams.Add("code_challenge", "challenge")
+	extraParams.Add("code_challenge_method", "S256")
+	loginURL := provider.GetLoginURL("https://proxy.example.com/oauth2/callback", "state", "nonce", extraParams)
+
+	t.Run("without an endpoint", func(t *testing.T) {
+		_, err := provider.PushAuthorizationRequest(context.Background(), loginURL)
+		assert.EqualError(t, err, "provider has no pushed authorization request endpoint")
+	})
+
+	provider.PushedAuthorizationRequestURL, _ = url.Parse(server.URL + "/par")
+
+	t.Run("pushes the authorization parameters", func(t *testing.T) {
+		pushedURL, err := provider.PushAuthorizationRequest(context.Background(), loginURL)
+		require.NoError(t, err)
+
+		u, err := url.Parse(pushedURL)
+		require.NoError(t, err)
+		assert.Equal(t, "/login/oauth/authorize", u.Path)
+		assert.Equal(t, url.Values{
+			"client_id":   []string{oidcClientID},
+			"request_uri": []string{"urn:ietf:params:oauth:request_uri:abc"},
+		}, u.Query())
+
+		assert.Equal(t, oidcClientID, form.Get("client_id"))
+		assert.Equal(t, oidcSecret, form.Get("client_secret"))
+		assert.Equal(t, "https://proxy.example.com/oauth2/callback", form.Get("redirect_uri"))
+		assert.Equal(t, "state", form.Get("state"))
+		assert.Equal(t, "nonce", form.Get("nonce"))
+		assert.Equal(t, "challenge", form.Get("code_challenge"))
+		assert.Equal(t, "S256", form.Get("code_challenge_method"))
+	})
+
+	t.Run("with an error response", func(t *testing.T) {
+		rejectedURL := provider.GetLoginURL("https://proxy.example.com/oauth2/callback", "rejected", "nonce", url.Values{})
+		_, err := provider.PushAuthorizationRequest(context.Background(), rejectedURL)
+		assert.Equal(t, &TokenError{Code: "invalid_request", Description: "invalid redirect_uri"}, err)
+	})
+}
@@ -0,0 +1,422 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/crewjam/saml"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// SAMLServiceProvider is implemented by providers that act as a SAML service
+// provider. The identity provider posts its response to the assertion
+// consumer service (ACS), which redeems it like an OAuth2 code: the ACS URL
+// takes the place of the redirect URI and the SAMLResponse the place of the
+// code.
+type SAMLServiceProvider interface {
+	Provider
+	// Metadata returns the service provider metadata for the given ACS URL
+	Metadata(acsURL string) ([]byte, error)
+	// MakeAuthnRequest builds the authentication request for the identity
+	// provider. It returns the URL to redirect to when the HTTP-Redirect
+	// binding is used, or a self-submitting HTML form when the HTTP-POST
+	// binding is used.
+	MakeAuthnRequest(acsURL, state string, extraParams url.Values) (string, []byte, error)
+}
+
+// SAMLProvider represents a SAML 2.0 Identity Provider
+type SAMLProvider struct {
+	*ProviderData
+
+	Binding         string
+	EmailAttribute  string
+	UserAttribute   string
+	GroupsAttribute string
+
+	serviceProvider saml.ServiceProvider
+}
+
+var _ SAMLServiceProvider = (*SAMLProvider)(nil)
+
+const (
+	samlProviderName = "SAML"
+
+	// SAMLBindingRedirect sends authentication requests with the HTTP-Redirect binding
+	SAMLBindingRedirect = "redirect"
+	// SAMLBindingPost sends authentication requests with the HTTP-POST binding
+	SAMLBindingPost = "post"
+
+	samlDefaultEmailAttribute  = "email"
+	samlDefaultGroupsAttribute = "groups"
+)
+
+// NewSAMLProvider initiates a new SAMLProvider
+func NewSAMLProvider(p *ProviderData, opts options.SAMLOptions) (*SAMLProvider, error) {
+	p.setProviderDefaults(providerDefaults{
+		name: samlProviderName,
+	})
+	// The PKCE code verifier stored in the CSRF cookie is used to derive the
+	// AuthnRequest ID, which binds the SAML response to the browser that
+	// started the login.
+	p.CodeChallengeMethod = CodeChallengeMethodS256
+
+	metadata, err := loadSAMLIDPMetadata(p.ClientContext(context.Background()), opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not load SAML identity provider metadata: %v", err)
+	}
+
+	provider := &SAMLProvider{
+		ProviderData:    p,
+		Binding:         opts.Binding,
+		EmailAttribute:  opts.EmailAttribute,
+		UserAttribute:   opts.UserAttribute,
+		GroupsAttribute: opts.GroupsAttribute,
+		serviceProvider: saml.ServiceProvider{
+			EntityID:          p.ClientID,
+			IDPMetadata:       metadata,
+			AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		},
+	}
+
+	if provider.Binding == "" {
+		provider.Binding = SAMLBindingRedirect
+	}
+	if provider.EmailAttribute == "" {
+		provider.EmailAttribute = samlDefaultEmailAttribute
+	}
+	if provider.GroupsAttribute == "" {
+		provider.GroupsAttribute = samlDefaultGroupsAttribute
+	}
+
+	if opts.SigningKey != nil {
+		if err := provider.configureSigning(opts.SigningKey, opts.SigningCert); err != nil {
+			return nil, err
+		}
+	}
+
+	if provider.serviceProvider.GetSSOBindingLocation(provider.samlBinding()) == "" {
+		return nil, fmt.Errorf("SAML identity provider does not support the %s binding", provider.Binding)
+	}
+
+	return provider, nil
+}
+
+// configureSigning loads the key and certificate used to sign authentication
+// requests
+func (p *SAMLProvider) configureSigning(keySource, certSource *options.SecretSource) error {
+	keyData, err := util.GetSecretValue(keySource)
+	if err != nil {
+		return fmt.Errorf("could not load SAML signing key: %v", err)
+	}
+
+	p.serviceProvider.Key, p.serviceProvider.SignatureMethod, err = parseSAMLSigningKey(keyData)
+	if err != nil {
+		return fmt.Errorf("could not parse SAML signing key: %v", err)
+	}
+
+	if certSource == nil {
+		return errors.New("a SAML signing certificate is required with the signing key")
+	}
+	certData, err := util.GetSecretValue(certSource)
+	if err != nil {
+		return fmt.Errorf("could not load SAML signing certificate: %v", err)
+	}
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return errors.New("could not parse SAML signing certificate: no PEM block found")
+	}
+	p.serviceProvider.Certificate, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("could not parse SAML signing certificate: %v", err)
+	}
+
+	return nil
+}
+
+// parseSAMLSigningKey parses a PEM encoded RSA or ECDSA private key and
+// returns it with the matching signature method
+func parseSAMLSigningKey(data []byte) (crypto.Signer, string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, "", errors.New("no PEM block found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, dsig.RSASHA256SignatureMethod, nil
+	case *ecdsa.PrivateKey:
+		return k, dsig.ECDSASHA256SignatureMethod, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// loadSAMLIDPMetadata reads the identity provider metadata from the metadata
+// URL, with the HTTP client of the context, or file
+func loadSAMLIDPMetadata(ctx context.Context, opts options.SAMLOptions) (*saml.EntityDescriptor, error) {
+	var data []byte
+	switch {
+	case opts.IDPMetadataURL != "":
+		result := requests.New(opts.IDPMetadataURL).
+			WithContext(ctx).
+			Do()
+		if result.Error() != nil {
+			return nil, result.Error()
+		}
+		if result.StatusCode() != 200 {
+			return nil, fmt.Errorf("unexpected status %d fetching %s", result.StatusCode(), opts.IDPMetadataURL)
+		}
+		data = result.Body()
+	case opts.IDPMetadataFile != "":
+		var err error
+		data, err = os.ReadFile(opts.IDPMetadataFile)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("one of idpMetadataURL or idpMetadataFile must be set")
+	}
+
+	entity := &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(data, entity); err == nil {
+		return entity, nil
+	}
+
+	// Metadata may also be published as a list of entities
+	entities := &saml.EntitiesDescriptor{}
+	if err := xml.Unmarshal(data, entities); err != nil {
+		return nil, err
+	}
+	for i, e := range entities.EntityDescriptors {
+		if len(e.IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+	return nil, errors.New("no entity found with an IDPSSODescriptor")
+}
+
+// samlBinding returns the SAML binding URN of the configured binding
+func (p *SAMLProvider) samlBinding() string {
+	if p.Binding == SAMLBindingPost {
+		return saml.HTTPPostBinding
+	}
+	return saml.HTTPRedirectBinding
+}
+
+// serviceProviderFor returns a copy of the service provider using the given
+// ACS URL, as the ACS URL depends on the host of the request when no redirect
+// URL is configured.
+func (p *SAMLProvider) serviceProviderFor(acsURL string) (*saml.ServiceProvider, error) {
+	u, err := url.Parse(acsURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse ACS URL: %v", err)
+	}
+	sp := p.serviceProvider
+	sp.AcsURL = *u
+	return &sp, nil
+}
+
+// samlRequestID derives the AuthnRequest ID from the PKCE code challenge
+func samlRequestID(codeChallenge string) string {
+	return "id-" + codeChallenge
+}
+
+// Metadata returns the service provider metadata for the given ACS URL
+func (p *SAMLProvider) Metadata(acsURL string) ([]byte, error) {
+	sp, err := p.serviceProviderFor(acsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := sp.Metadata()
+	for i := range metadata.SPSSODescriptors {
+		// Only the HTTP-POST binding is supported for responses
+		metadata.SPSSODescriptors[i].AssertionConsumerServices = []saml.IndexedEndpoint{{
+			Binding:  saml.HTTPPostBinding,
+			Location: acsURL,
+			Index:    1,
+		}}
+	}
+
+	return xml.MarshalIndent(metadata, "", "  ")
+}
+
+// MakeAuthnRequest builds the authentication request for the identity provider
+func (p *SAMLProvider) MakeAuthnRequest(acsURL, state string, extraParams url.Values) (string, []byte, error) {
+	codeChallenge := extraParams.Get("code_challenge")
+	if codeChallenge == "" {
+		return "", nil, errors.New("missing code challenge for the SAML request ID")
+	}
+
+	sp, err := p.serviceProviderFor(acsURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	binding := p.samlBinding()
+	// The request is built as a redirect request so that it is not signed
+	// before its ID has been set.
+	req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(binding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", nil, err
+	}
+	req.ID = samlRequestID(codeChallenge)
+
+	if binding == saml.HTTPPostBinding {
+		if sp.SignatureMethod != "" {
+			if err := sp.SignAuthnRequest(req); err != nil {
+				return "", nil, err
+			}
+		}
+		return "", req.Post(state), nil
+	}
+
+	loginURL, err := req.Redirect(url.QueryEscape(state), sp)
+	if err != nil {
+		return "", nil, err
+	}
+	return loginURL.String(), nil, nil
+}
+
+// GetLoginURL returns the HTTP-Redirect binding URL of the authentication
+// request. The ACS URL is passed as the redirect URI.
+func (p *SAMLProvider) GetLoginURL(redirectURI, state, _ string, extraParams url.Values) string {
+	loginURL, _, err := p.MakeAuthnRequest(redirectURI, state, extraParams)
+	if err != nil {
+		logger.Errorf("Error creating SAML authentication request: %v", err)
+	}
+	return loginURL
+}
+
+// Redeem validates the base64 encoded SAMLResponse posted to the ACS URL and
+// creates a session from its assertion
+func (p *SAMLProvider) Redeem(_ context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
+	if code == "" {
+		return nil, ErrMissingCode
+	}
+
+	response, err := base64.StdEncoding.DecodeString(code)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode SAML response: %v", err)
+	}
+
+	codeChallenge, err := encryption.GenerateCodeChallenge(CodeChallengeMethodS256, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	sp, err := p.serviceProviderFor(redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, err := sp.ParseXMLResponse(response, []string{samlRequestID(codeChallenge)}, sp.AcsURL)
+	if err != nil {
+		var invalidResponse *saml.InvalidResponseError
+		if errors.As(err, &invalidResponse) {
+			err = invalidResponse.PrivateErr
+		}
+		return nil, fmt.Errorf("invalid SAML response: %v", err)
+	}
+
+	return p.sessionFromAssertion(assertion)
+}
+
+// sessionFromAssertion maps the assertion's NameID and attributes to a session
+func (p *SAMLProvider) sessionFromAssertion(assertion *saml.Assertion) (*sessions.SessionState, error) {
+	var nameID, nameIDFormat string
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		nameID = assertion.Subject.NameID.Value
+		nameIDFormat = assertion.Subject.NameID.Format
+	}
+
+	s := &sessions.SessionState{
+		Email:  firstSAMLAttributeValue(assertion, p.EmailAttribute),
+		User:   nameID,
+		Groups: samlAttributeValues(assertion, p.GroupsAttribute),
+	}
+	if s.Email == "" && nameIDFormat == string(saml.EmailAddressNameIDFormat) {
+		s.Email = nameID
+	}
+	if p.UserAttribute != "" {
+		s.User = firstSAMLAttributeValue(assertion, p.UserAttribute)
+	}
+	if s.User == "" {
+		s.User = s.Email
+	}
+	if s.Email == "" {
+		return nil, fmt.Errorf("SAML assertion has no %q attribute", p.EmailAttribute)
+	}
+
+	s.CreatedAtNow()
+	for _, statement := range assertion.AuthnStatements {
+		if statement.SessionNotOnOrAfter != nil {
+			s.SetExpiresOn(*statement.SessionNotOnOrAfter)
+			break
+		}
+	}
+
+	return s, nil
+}
+
+// samlAttributeValues returns the values of the attributes matching name by
+// their Name or FriendlyName
+func samlAttributeValues(assertion *saml.Assertion, name string) []string {
+	values := []string{}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if attr.Name != name && attr.FriendlyName != name {
+				continue
+			}
+			for _, value := range attr.Values {
+				if value.Value != "" {
+					values = append(values, value.Value)
+				}
+			}
+		}
+	}
+	return values
+}
+
+func firstSAMLAttributeValue(assertion *saml.Assertion, name string) string {
+	if values := samlAttributeValues(assertion, name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// ValidateSession checks that the session has not expired, as there is no
+// token to validate with the identity provider
+func (p *SAMLProvider) ValidateSession(_ context.Context, s *sessions.SessionState) bool {
+	return !s.IsExpired()
+}
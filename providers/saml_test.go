@@ -0,0 +1,270 @@
+package providers
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crewjam/saml"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/providers/samltest"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	samlTestEntityID     = "https://proxy.example.com/oauth2/saml/metadata"
+	samlTestACSURL       = "https://proxy.example.com/oauth2/saml/acs"
+	samlTestCodeVerifier = "code-verifier-code-verifier-code-verifier-code-verifier"
+)
+
+func writeTestSAMLMetadata(t *testing.T, idp *saml.IdentityProvider) string {
+	metadata, err := xml.Marshal(idp.Metadata())
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	path := filepath.Join(t.TempDir(), "idp-metadata.xml")
+	NewWithT(t).Expect(os.WriteFile(path, metadata, 0600)).To(Succeed())
+	return path
+}
+
+func newTestSAMLProvider(t *testing.T, idp *saml.IdentityProvider, opts options.SAMLOptions) *SAMLProvider {
+	opts.IDPMetadataFile = writeTestSAMLMetadata(t, idp)
+	p, err := NewSAMLProvider(&ProviderData{ClientID: samlTestEntityID}, opts)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+	return p
+}
+
+func testSAMLCodeChallenge(t *testing.T) string {
+	codeChallenge, err := encryption.GenerateCodeChallenge(CodeChallengeMethodS256, samlTestCodeVerifier)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+	return codeChallenge
+}
+
+// makeTestSAMLResponse returns a base64 encoded SAML response from the
+// identity provider for the given session
+func makeTestSAMLResponse(t *testing.T, idp *saml.IdentityProvider, p *SAMLProvider, requestID string, session *saml.Session) string {
+	g := NewWithT(t)
+
+	rawMetadata, err := p.Metadata(samlTestACSURL)
+	g.Expect(err).ToNot(HaveOccurred())
+	spMetadata := &saml.EntityDescriptor{}
+	g.Expect(xml.Unmarshal(rawMetadata, spMetadata)).To(Succeed())
+
+	req := &saml.IdpAuthnRequest{
+		IDP:                     idp,
+		HTTPRequest:             httptest.NewRequest(http.MethodPost, samlTestACSURL, nil),
+		Request:                 saml.AuthnRequest{ID: requestID, IssueInstant: saml.TimeNow()},
+		ServiceProviderMetadata: spMetadata,
+		SPSSODescriptor:         &spMetadata.SPSSODescriptors[0],
+		ACSEndpoint:             &spMetadata.SPSSODescriptors[0].AssertionConsumerServices[0],
+		Now:                     saml.TimeNow(),
+	}
+	g.Expect(saml.DefaultAssertionMaker{}.MakeAssertion(req, session)).To(Succeed())
+
+	form, err := req.PostBinding()
+	g.Expect(err).ToNot(HaveOccurred())
+	return form.SAMLResponse
+}
+
+func TestNewSAMLProvider(t *testing.T) {
+	g := NewWithT(t)
+	idp := samltest.NewIdentityProvider(t)
+
+	metadata, err := xml.Marshal(idp.Metadata())
+	g.Expect(err).ToNot(HaveOccurred())
+	metadataServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write(metadata)
+	}))
+	defer metadataServer.Close()
+
+	// Only the HTTP client of the provider trusts the certificate of the server
+	p, err := NewSAMLProvider(&ProviderData{ClientID: samlTestEntityID, httpClient: metadataServer.Client()}, options.SAMLOptions{
+		IDPMetadataURL: metadataServer.URL,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.Data().ProviderName).To(Equal("SAML"))
+	g.Expect(p.Data().CodeChallengeMethod).To(Equal(CodeChallengeMethodS256))
+	g.Expect(p.Binding).To(Equal(SAMLBindingRedirect))
+	g.Expect(p.EmailAttribute).To(Equal("email"))
+	g.Expect(p.UserAttribute).To(BeEmpty())
+	g.Expect(p.GroupsAttribute).To(Equal("groups"))
+	g.Expect(p.serviceProvider.IDPMetadata.EntityID).To(Equal("https://idp.example.com/metadata"))
+}
+
+func TestNewSAMLProviderErrors(t *testing.T) {
+	_, err := NewSAMLProvider(&ProviderData{ClientID: samlTestEntityID}, options.SAMLOptions{})
+	NewWithT(t).Expect(err).To(MatchError("could not load SAML identity provider metadata: one of idpMetadataURL or idpMetadataFile must be set"))
+
+	_, err = NewSAMLProvider(&ProviderData{ClientID: samlTestEntityID}, options.SAMLOptions{
+		IDPMetadataFile: writeTestSAMLMetadata(t, samltest.NewIdentityProvider(t)),
+		SigningKey:      &options.SecretSource{Value: []byte("not a key")},
+	})
+	NewWithT(t).Expect(err).To(MatchError("could not parse SAML signing key: no PEM block found"))
+}
+
+func TestSAMLProviderMetadata(t *testing.T) {
+	g := NewWithT(t)
+	p := newTestSAMLProvider(t, samltest.NewIdentityProvider(t), options.SAMLOptions{})
+
+	rawMetadata, err := p.Metadata(samlTestACSURL)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	metadata := &saml.EntityDescriptor{}
+	g.Expect(xml.Unmarshal(rawMetadata, metadata)).To(Succeed())
+	g.Expect(metadata.EntityID).To(Equal(samlTestEntityID))
+	g.Expect(metadata.SPSSODescriptors).To(HaveLen(1))
+	g.Expect(metadata.SPSSODescriptors[0].AssertionConsumerServices).To(Equal([]saml.IndexedEndpoint{{
+		Binding:  saml.HTTPPostBinding,
+		Location: samlTestACSURL,
+		Index:    1,
+	}}))
+}
+
+func TestSAMLProviderMakeAuthnRequest(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	extraParams := url.Values{"code_challenge": []string{testSAMLCodeChallenge(t)}}
+	expectedID := "id-" + testSAMLCodeChallenge(t)
+
+	t.Run("with the redirect binding", func(t *testing.T) {
+		g := NewWithT(t)
+		p := newTestSAMLProvider(t, idp, options.SAMLOptions{})
+
+		loginURL, form, err := p.MakeAuthnRequest(samlTestACSURL, "nonce:/app?a=b", extraParams)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(form).To(BeNil())
+		g.Expect(p.GetLoginURL(samlTestACSURL, "nonce:/app?a=b", "", extraParams)).To(HavePrefix("https://idp.example.com/sso?"))
+
+		u, err := url.Parse(loginURL)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(u.Host).To(Equal("idp.example.com"))
+		g.Expect(u.Query().Get("RelayState")).To(Equal("nonce:/app?a=b"))
+
+		compressed, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+		g.Expect(err).ToNot(HaveOccurred())
+		rawRequest, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		request := &saml.AuthnRequest{}
+		g.Expect(xml.Unmarshal(rawRequest, request)).To(Succeed())
+		g.Expect(request.ID).To(Equal(expectedID))
+		g.Expect(request.AssertionConsumerServiceURL).To(Equal(samlTestACSURL))
+		g.Expect(request.Issuer.Value).To(Equal(samlTestEntityID))
+	})
+
+	t.Run("with the post binding and a signing key", func(t *testing.T) {
+		g := NewWithT(t)
+
+		key, cert := samltest.NewKeyPair(t)
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		p := newTestSAMLProvider(t, idp, options.SAMLOptions{
+			Binding:     SAMLBindingPost,
+			SigningKey:  &options.SecretSource{Value: keyPEM},
+			SigningCert: &options.SecretSource{Value: certPEM},
+		})
+
+		loginURL, form, err := p.MakeAuthnRequest(samlTestACSURL, "nonce:/", extraParams)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(loginURL).To(BeEmpty())
+		g.Expect(string(form)).To(ContainSubstring(`action="https://idp.example.com/sso"`))
+		g.Expect(string(form)).To(ContainSubstring(`name="RelayState" value="nonce:/"`))
+		g.Expect(string(form)).To(ContainSubstring(`name="SAMLRequest"`))
+	})
+
+	t.Run("without a code challenge", func(t *testing.T) {
+		p := newTestSAMLProvider(t, idp, options.SAMLOptions{})
+		_, _, err := p.MakeAuthnRequest(samlTestACSURL, "nonce:/", url.Values{})
+		NewWithT(t).Expect(err).To(MatchError("missing code challenge for the SAML request ID"))
+	})
+}
+
+func TestSAMLProviderRedeem(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	requestID := "id-" + testSAMLCodeChallenge(t)
+
+	t.Run("maps the assertion attributes", func(t *testing.T) {
+		g := NewWithT(t)
+		p := newTestSAMLProvider(t, idp, options.SAMLOptions{
+			EmailAttribute:  "mail",
+			UserAttribute:   "uid",
+			GroupsAttribute: "eduPersonAffiliation",
+		})
+
+		response := makeTestSAMLResponse(t, idp, p, requestID, &saml.Session{
+			NameID:    "transient-id",
+			UserName:  "jdoe",
+			UserEmail: "jdoe@example.com",
+			Groups:    []string{"admins", "devs"},
+		})
+
+		s, err := p.Redeem(context.Background(), samlTestACSURL, response, samlTestCodeVerifier)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(s.Email).To(Equal("jdoe@example.com"))
+		g.Expect(s.User).To(Equal("jdoe"))
+		g.Expect(s.Groups).To(Equal([]string{"admins", "devs"}))
+		g.Expect(s.CreatedAt).ToNot(BeNil())
+		g.Expect(p.ValidateSession(context.Background(), s)).To(BeTrue())
+	})
+
+	t.Run("uses an email NameID", func(t *testing.T) {
+		g := NewWithT(t)
+		p := newTestSAMLProvider(t, idp, options.SAMLOptions{})
+
+		response := makeTestSAMLResponse(t, idp, p, requestID, &saml.Session{
+			NameID:       "jdoe@example.com",
+			NameIDFormat: string(saml.EmailAddressNameIDFormat),
+			CustomAttributes: []saml.Attribute{{
+				Name:   "groups",
+				Values: []saml.AttributeValue{{Value: "admins"}},
+			}},
+		})
+
+		s, err := p.Redeem(context.Background(), samlTestACSURL, response, samlTestCodeVerifier)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(s.Email).To(Equal("jdoe@example.com"))
+		g.Expect(s.User).To(Equal("jdoe@example.com"))
+		g.Expect(s.Groups).To(Equal([]string{"admins"}))
+	})
+
+	t.Run("without an email", func(t *testing.T) {
+		p := newTestSAMLProvider(t, idp, options.SAMLOptions{})
+		response := makeTestSAMLResponse(t, idp, p, requestID, &saml.Session{NameID: "transient-id"})
+
+		_, err := p.Redeem(context.Background(), samlTestACSURL, response, samlTestCodeVerifier)
+		NewWithT(t).Expect(err).To(MatchError(`SAML assertion has no "email" attribute`))
+	})
+
+	t.Run("with a different code verifier", func(t *testing.T) {
+		p := newTestSAMLProvider(t, idp, options.SAMLOptions{})
+		response := makeTestSAMLResponse(t, idp, p, requestID, &saml.Session{UserEmail: "jdoe@example.com"})
+
+		_, err := p.Redeem(context.Background(), samlTestACSURL, response, "another-code-verifier")
+		NewWithT(t).Expect(err).To(MatchError(ContainSubstring("`InResponseTo` does not match any of the possible request IDs")))
+	})
+
+	t.Run("signed by an unknown identity provider", func(t *testing.T) {
+		p := newTestSAMLProvider(t, idp, options.SAMLOptions{})
+		otherIDP := samltest.NewIdentityProvider(t)
+		response := makeTestSAMLResponse(t, otherIDP, p, requestID, &saml.Session{UserEmail: "jdoe@example.com"})
+
+		_, err := p.Redeem(context.Background(), samlTestACSURL, response, samlTestCodeVerifier)
+		NewWithT(t).Expect(err).To(MatchError(HavePrefix("invalid SAML response:")))
+	})
+
+	t.Run("without a response", func(t *testing.T) {
+		p := newTestSAMLProvider(t, idp, options.SAMLOptions{})
+		_, err := p.Redeem(context.Background(), samlTestACSURL, "", samlTestCodeVerifier)
+		NewWithT(t).Expect(err).To(Equal(ErrMissingCode))
+	})
+}
@@ -0,0 +1,58 @@
+// Package samltest provides a self-signed SAML identity provider for tests
+// of the SAML provider and the proxy's SAML endpoints.
+package samltest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+)
+
+// NewKeyPair generates an RSA key and a self-signed certificate for it.
+func NewKeyPair(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "saml.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse certificate: %v", err)
+	}
+
+	return key, cert
+}
+
+// NewIdentityProvider returns an identity provider on idp.example.com that
+// signs its assertions with a freshly generated key pair.
+func NewIdentityProvider(t *testing.T) *saml.IdentityProvider {
+	t.Helper()
+
+	key, cert := NewKeyPair(t)
+	return &saml.IdentityProvider{
+		Key:         key,
+		Signer:      key,
+		Certificate: cert,
+		MetadataURL: url.URL{Scheme: "https", Host: "idp.example.com", Path: "/metadata"},
+		SSOURL:      url.URL{Scheme: "https", Host: "idp.example.com", Path: "/sso"},
+	}
+}
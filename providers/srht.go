@@ -74,7 +74,7 @@ func NewSourceHutProvider(p *ProviderData) *SourceHutProvider {
 // email and username.
 func (p *SourceHutProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
 	json, err := requests.New(p.ProfileURL.String()).
-		WithContext(ctx).
+		WithContext(p.ClientContext(ctx)).
 		WithMethod("POST").
 		SetHeader("Content-Type", "application/json").
 		SetHeader("Authorization", "Bearer "+s.AccessToken).
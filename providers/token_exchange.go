@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// tokenExchangeGrantType is the grant type of the token exchange (RFC 8693)
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// accessTokenType identifies an access token in a token exchange
+	accessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// ExchangeToken exchanges the access token of a session at the token endpoint
+// for an access token issued for the audience and resource (RFC 8693)
+func (p *ProviderData) ExchangeToken(ctx context.Context, subjectToken, audience, resource string, scopes []string) (*oauth2.Token, error) {
+	if subjectToken == "" {
+		return nil, errors.New("missing subject token")
+	}
+
+	params := url.Values{}
+	params.Add("grant_type", tokenExchangeGrantType)
+	params.Add("subject_token", subjectToken)
+	params.Add("subject_token_type", accessTokenType)
+	params.Add("requested_token_type", accessTokenType)
+	if audience != "" {
+		params.Add("audience", audience)
+	}
+	if resource != "" {
+		params.Add("resource", resource)
+	}
+	if len(scopes) > 0 {
+		params.Add("scope", strings.Join(scopes, " "))
+	}
+	if err := p.setClientAuthentication(params); err != nil {
+		return nil, err
+	}
+
+	token, err := p.fetchToken(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("token exchange response is missing the access_token")
+	}
+	return token, nil
+}
@@ -0,0 +1,58 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExchangeToken(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		rw.Header().Set("Content-Type", "application/json")
+		if form.Get("audience") == "forbidden" {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"error":"invalid_target"}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"access_token":"exchanged","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":300}`))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	provider := newOIDCProvider(serverURL, true)
+
+	t.Run("exchanges the subject token", func(t *testing.T) {
+		token, err := provider.ExchangeToken(context.Background(), "subject", "backend", "https://backend.example.com", []string{"read", "write"})
+		require.NoError(t, err)
+		assert.Equal(t, "exchanged", token.AccessToken)
+		assert.False(t, token.Expiry.IsZero())
+
+		assert.Equal(t, tokenExchangeGrantType, form.Get("grant_type"))
+		assert.Equal(t, "subject", form.Get("subject_token"))
+		assert.Equal(t, accessTokenType, form.Get("subject_token_type"))
+		assert.Equal(t, accessTokenType, form.Get("requested_token_type"))
+		assert.Equal(t, "backend", form.Get("audience"))
+		assert.Equal(t, "https://backend.example.com", form.Get("resource"))
+		assert.Equal(t, "read write", form.Get("scope"))
+		assert.Equal(t, oidcClientID, form.Get("client_id"))
+		assert.Equal(t, oidcSecret, form.Get("client_secret"))
+	})
+
+	t.Run("with an error response", func(t *testing.T) {
+		_, err := provider.ExchangeToken(context.Background(), "subject", "forbidden", "", nil)
+		assert.Equal(t, &TokenError{Code: "invalid_target"}, err)
+	})
+
+	t.Run("without a subject token", func(t *testing.T) {
+		_, err := provider.ExchangeToken(context.Background(), "", "backend", "", nil)
+		assert.EqualError(t, err, "missing subject token")
+	})
+}
@@ -0,0 +1,267 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util/ptr"
+)
+
+var (
+	// ErrTokenIntrospectionNotEnabled is returned when the provider has no
+	// introspection endpoint
+	ErrTokenIntrospectionNotEnabled = errors.New("token introspection is not enabled for this provider")
+
+	// ErrInactiveToken is returned when the provider reports the token as
+	// not active
+	ErrInactiveToken = errors.New("token is not active")
+
+	// ErrTokenAudienceMismatch is returned when the token was issued to
+	// another client
+	ErrTokenAudienceMismatch = errors.New("token was not issued to this client")
+)
+
+// introspectionResponse holds the fields of the token introspection response
+// (RFC 7662) that are not mapped through the claim extractor
+type introspectionResponse struct {
+	Active       bool                   `json:"active"`
+	Scope        string                 `json:"scope"`
+	ExpiresAt    int64                  `json:"exp"`
+	IssuedAt     int64                  `json:"iat"`
+	ClientID     string                 `json:"client_id"`
+	Audience     jwt.ClaimStrings       `json:"aud"`
+	Confirmation map[string]interface{} `json:"cnf"`
+}
+
+// IntrospectToken creates a session for an opaque access token from the
+// response of the token introspection endpoint (RFC 7662). Active and
+// inactive results are cached for up to the introspection cache TTL.
+func (p *ProviderData) IntrospectToken(ctx context.Context, token string) (*sessions.SessionState, error) {
+	if p.IntrospectionURL == nil || p.IntrospectionURL.String() == "" {
+		return nil, ErrTokenIntrospectionNotEnabled
+	}
+	if token == "" {
+		return nil, errors.New("missing token")
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(hash[:])
+	if session, ok := p.introspectionCache.get(key); ok {
+		if session == nil {
+			return nil, ErrInactiveToken
+		}
+		return session, nil
+	}
+
+	session, err := p.introspectToken(ctx, token)
+	switch {
+	case err == nil:
+		p.introspectionCache.set(key, session, session.ExpiresOn)
+	case errors.Is(err, ErrInactiveToken):
+		p.introspectionCache.set(key, nil, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return copySession(session), nil
+}
+
+func (p *ProviderData) introspectToken(ctx context.Context, token string) (*sessions.SessionState, error) {
+	params := url.Values{}
+	params.Add("token", token)
+	params.Add("token_type_hint", "access_token")
+	if err := p.setClientAuthentication(params); err != nil {
+		return nil, err
+	}
+
+	resp := requests.New(p.IntrospectionURL.String()).
+		WithContext(p.ClientContext(ctx)).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetHeader("Accept", "application/json").
+		Do()
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, tokenErrorFromResponse(resp, "introspection endpoint")
+	}
+
+	var introspection introspectionResponse
+	if err := json.Unmarshal(resp.Body(), &introspection); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !introspection.Active || (introspection.ExpiresAt != 0 && now.Unix() >= introspection.ExpiresAt) {
+		return nil, ErrInactiveToken
+	}
+	if !p.isIntrospectionAudience(introspection) {
+		return nil, ErrTokenAudienceMismatch
+	}
+	if len(introspection.Confirmation) > 0 {
+		// The binding can't be checked without the token claims
+		return nil, errors.New("sender-constrained tokens are not supported with token introspection")
+	}
+
+	session, err := p.buildSessionFromIntrospection(resp.Body())
+	if err != nil {
+		return nil, err
+	}
+	session.AccessToken = token
+	session.CreatedAt = &now
+	if introspection.IssuedAt != 0 {
+		session.CreatedAt = ptr.To(time.Unix(introspection.IssuedAt, 0))
+	}
+	if introspection.ExpiresAt != 0 {
+		session.ExpiresOn = ptr.To(time.Unix(introspection.ExpiresAt, 0))
+	}
+	if introspection.Scope != "" {
+		if session.AdditionalClaims == nil {
+			session.AdditionalClaims = make(map[string]interface{})
+		}
+		session.AdditionalClaims["scope"] = introspection.Scope
+	}
+	return session, nil
+}
+
+// isIntrospectionAudience returns whether the token was issued to this client,
+// either by its client_id or by its aud. Any active token the provider issued
+// would be accepted otherwise, including tokens of other clients.
+func (p *ProviderData) isIntrospectionAudience(introspection introspectionResponse) bool {
+	audiences := append([]string{p.ClientID}, p.extraAudiences...)
+	isAudience := func(aud string) bool {
+		return aud != "" && slices.Contains(audiences, aud)
+	}
+	return isAudience(introspection.ClientID) || slices.ContainsFunc(introspection.Audience, isAudience)
+}
+
+// buildSessionFromIntrospection maps the claims of the introspection response
+// with the configured user, email and groups claims
+func (p *ProviderData) buildSessionFromIntrospection(body []byte) (*sessions.SessionState, error) {
+	extractor, err := util.NewJSONClaimExtractor(body)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := &sessions.SessionState{}
+	for _, c := range []struct {
+		claim    string
+		fallback string
+		dst      interface{}
+	}{
+		{p.UserClaim, oidcUserClaim, &ss.User},
+		{p.EmailClaim, options.OIDCEmailClaim, &ss.Email},
+		{p.GroupsClaim, options.OIDCGroupsClaim, &ss.Groups},
+		{"username", "", &ss.PreferredUsername},
+	} {
+		claim := c.claim
+		if claim == "" {
+			claim = c.fallback
+		}
+		if _, err := extractor.GetClaimInto(claim, c.dst); err != nil {
+			return nil, err
+		}
+	}
+	if ss.User == "" {
+		return nil, errors.New("introspection response has no subject")
+	}
+
+	if p.AdditionalClaims != nil {
+		p.extractAdditionalClaims(extractor, ss)
+	}
+	return ss, nil
+}
+
+// copySession returns a deep copy of the cached session, so that the request
+// handling can't change the cached session
+func copySession(s *sessions.SessionState) *sessions.SessionState {
+	c := *s
+	if s.CreatedAt != nil {
+		createdAt := *s.CreatedAt
+		c.CreatedAt = &createdAt
+	}
+	if s.ExpiresOn != nil {
+		expiresOn := *s.ExpiresOn
+		c.ExpiresOn = &expiresOn
+	}
+	if s.ValidatedAt != nil {
+		validatedAt := *s.ValidatedAt
+		c.ValidatedAt = &validatedAt
+	}
+	c.Nonce = slices.Clone(s.Nonce)
+	c.Groups = slices.Clone(s.Groups)
+	if s.AdditionalClaims != nil {
+		c.AdditionalClaims = copyClaimValue(s.AdditionalClaims).(map[string]interface{})
+	}
+	if s.ProviderValues != nil {
+		providerValues := *s.ProviderValues
+		providerValues.Groups = slices.Clone(s.ProviderValues.Groups)
+		c.ProviderValues = &providerValues
+	}
+	return &c
+}
+
+// copyClaimValue returns a deep copy of a claim value decoded from JSON
+func copyClaimValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			c[key] = copyClaimValue(item)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, item := range v {
+			c[i] = copyClaimValue(item)
+		}
+		return c
+	case []string:
+		return slices.Clone(v)
+	default:
+		return v
+	}
+}
+
+// introspectionCache holds introspection results by token hash. A nil session
+// records an inactive token.
+type introspectionCache struct {
+	cache *ttlCache[*sessions.SessionState]
+}
+
+func newIntrospectionCache(ttl time.Duration) *introspectionCache {
+	return &introspectionCache{cache: newTTLCache[*sessions.SessionState](ttl)}
+}
+
+func (c *introspectionCache) get(key string) (*sessions.SessionState, bool) {
+	if c == nil {
+		return nil, false
+	}
+	session, ok := c.cache.get(key)
+	if !ok || session == nil {
+		return nil, ok
+	}
+	return copySession(session), true
+}
+
+// set caches the result for the TTL, but never beyond the expiry of the token
+func (c *introspectionCache) set(key string, session *sessions.SessionState, tokenExpiry *time.Time) {
+	if c == nil {
+		return
+	}
+	c.cache.set(key, session, tokenExpiry)
+}
@@ -0,0 +1,202 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospectToken(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+
+	var form url.Values
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		rw.Header().Set("Content-Type", "application/json")
+		switch form.Get("token") {
+		case "active":
+			_, _ = rw.Write([]byte(`{"active":true,"client_id":"` + oidcClientID + `","sub":"user-id","username":"jdoe","email":"jdoe@example.com",` +
+				`"groups":["admins","users"],"scope":"read write","iat":1700000000,"exp":` + strconv.FormatInt(exp, 10) + `}`))
+		case "audience":
+			_, _ = rw.Write([]byte(`{"active":true,"client_id":"other-client","aud":["other-api","extra-api"],"sub":"user-id"}`))
+		case "other-client":
+			_, _ = rw.Write([]byte(`{"active":true,"client_id":"other-client","aud":"other-api","sub":"user-id"}`))
+		case "no-audience":
+			_, _ = rw.Write([]byte(`{"active":true,"sub":"user-id"}`))
+		case "bound":
+			_, _ = rw.Write([]byte(`{"active":true,"aud":"` + oidcClientID + `","sub":"user-id","cnf":{"jkt":"thumbprint"}}`))
+		case "expired":
+			_, _ = rw.Write([]byte(`{"active":true,"sub":"user-id","exp":1700000000}`))
+		case "error":
+			rw.WriteHeader(http.StatusUnauthorized)
+			_, _ = rw.Write([]byte(`{"error":"invalid_client"}`))
+		default:
+			_, _ = rw.Write([]byte(`{"active":false}`))
+		}
+	}))
+	defer server.Close()
+
+	newProvider := func(cacheTTL time.Duration) *OIDCProvider {
+		serverURL, _ := url.Parse(server.URL)
+		provider := newOIDCProvider(serverURL, true)
+		provider.IntrospectionURL, _ = url.Parse(server.URL + "/introspect")
+		provider.introspectionCache = newIntrospectionCache(cacheTTL)
+		return provider
+	}
+
+	t.Run("builds a session for an active token", func(t *testing.T) {
+		provider := newProvider(0)
+		session, err := provider.IntrospectToken(context.Background(), "active")
+		require.NoError(t, err)
+
+		assert.Equal(t, "active", session.AccessToken)
+		assert.Equal(t, "user-id", session.User)
+		assert.Equal(t, "jdoe", session.PreferredUsername)
+		assert.Equal(t, "jdoe@example.com", session.Email)
+		assert.Equal(t, []string{"admins", "users"}, session.Groups)
+		assert.Equal(t, "read write", session.AdditionalClaims["scope"])
+		assert.Equal(t, time.Unix(1700000000, 0), *session.CreatedAt)
+		assert.Equal(t, time.Unix(exp, 0), *session.ExpiresOn)
+
+		assert.Equal(t, "active", form.Get("token"))
+		assert.Equal(t, "access_token", form.Get("token_type_hint"))
+		assert.Equal(t, oidcClientID, form.Get("client_id"))
+		assert.Equal(t, oidcSecret, form.Get("client_secret"))
+	})
+
+	t.Run("rejects inactive and expired tokens", func(t *testing.T) {
+		provider := newProvider(0)
+		_, err := provider.IntrospectToken(context.Background(), "inactive")
+		assert.Equal(t, ErrInactiveToken, err)
+
+		_, err = provider.IntrospectToken(context.Background(), "expired")
+		assert.Equal(t, ErrInactiveToken, err)
+	})
+
+	t.Run("accepts tokens for an extra audience", func(t *testing.T) {
+		provider := newProvider(0)
+		provider.extraAudiences = []string{"extra-api"}
+		session, err := provider.IntrospectToken(context.Background(), "audience")
+		require.NoError(t, err)
+		assert.Equal(t, "user-id", session.User)
+	})
+
+	t.Run("rejects tokens issued to other clients", func(t *testing.T) {
+		provider := newProvider(0)
+		provider.extraAudiences = []string{"extra-api"}
+		_, err := provider.IntrospectToken(context.Background(), "other-client")
+		assert.Equal(t, ErrTokenAudienceMismatch, err)
+
+		_, err = provider.IntrospectToken(context.Background(), "no-audience")
+		assert.Equal(t, ErrTokenAudienceMismatch, err)
+	})
+
+	t.Run("rejects sender-constrained tokens", func(t *testing.T) {
+		provider := newProvider(0)
+		_, err := provider.IntrospectToken(context.Background(), "bound")
+		assert.EqualError(t, err, "sender-constrained tokens are not supported with token introspection")
+	})
+
+	t.Run("with an error response", func(t *testing.T) {
+		provider := newProvider(0)
+		_, err := provider.IntrospectToken(context.Background(), "error")
+		assert.Equal(t, &TokenError{Code: "invalid_client"}, err)
+	})
+
+	t.Run("caches active and inactive results", func(t *testing.T) {
+		provider := newProvider(time.Minute)
+		requests = 0
+
+		for i := 0; i < 2; i++ {
+			session, err := provider.IntrospectToken(context.Background(), "active")
+			require.NoError(t, err)
+			assert.Equal(t, "user-id", session.User)
+
+			_, err = provider.IntrospectToken(context.Background(), "inactive")
+			assert.Equal(t, ErrInactiveToken, err)
+
+			_, err = provider.IntrospectToken(context.Background(), "error")
+			assert.Error(t, err)
+		}
+		// Only errors are not cached
+		assert.Equal(t, 4, requests)
+
+		// The cached session can't be changed through the returned session
+		session, err := provider.IntrospectToken(context.Background(), "active")
+		require.NoError(t, err)
+		session.User = "changed"
+		session, err = provider.IntrospectToken(context.Background(), "active")
+		require.NoError(t, err)
+		assert.Equal(t, "user-id", session.User)
+	})
+
+	t.Run("without an introspection endpoint", func(t *testing.T) {
+		provider := newProvider(0)
+		provider.IntrospectionURL = nil
+		_, err := provider.IntrospectToken(context.Background(), "active")
+		assert.Equal(t, ErrTokenIntrospectionNotEnabled, err)
+	})
+}
+
+func TestIntrospectionCache(t *testing.T) {
+	cache := newIntrospectionCache(time.Minute)
+
+	// Entries never outlive the token
+	expired := time.Now().Add(-time.Second)
+	cache.set("expired", nil, &expired)
+	_, ok := cache.get("expired")
+	assert.False(t, ok)
+
+	cache.set("inactive", nil, nil)
+	session, ok := cache.get("inactive")
+	assert.True(t, ok)
+	assert.Nil(t, session)
+
+	// A disabled cache stores nothing
+	disabled := newIntrospectionCache(0)
+	disabled.set("inactive", nil, nil)
+	_, ok = disabled.get("inactive")
+	assert.False(t, ok)
+}
+
+func TestIntrospectionCacheReturnsCopies(t *testing.T) {
+	cache := newIntrospectionCache(time.Minute)
+	cache.set("active", &sessions.SessionState{
+		User:   "jdoe",
+		Groups: []string{"admins"},
+		AdditionalClaims: map[string]interface{}{
+			"roles": []interface{}{"reader"},
+			"org":   map[string]interface{}{"name": "example"},
+		},
+		ProviderValues: &sessions.ProviderValues{Groups: []string{"admins"}},
+	}, nil)
+
+	session, ok := cache.get("active")
+	require.True(t, ok)
+	session.Groups[0] = "changed"
+	session.Groups = append(session.Groups, "added")
+	session.AdditionalClaims["roles"].([]interface{})[0] = "changed"
+	session.AdditionalClaims["org"].(map[string]interface{})["name"] = "changed"
+	session.AdditionalClaims["added"] = "added"
+	session.ProviderValues.Groups[0] = "changed"
+
+	cached, ok := cache.get("active")
+	require.True(t, ok)
+	assert.Equal(t, []string{"admins"}, cached.Groups)
+	assert.Equal(t, map[string]interface{}{
+		"roles": []interface{}{"reader"},
+		"org":   map[string]interface{}{"name": "example"},
+	}, cached.AdditionalClaims)
+	assert.Equal(t, []string{"admins"}, cached.ProviderValues.Groups)
+}
@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"sync"
+	"time"
+)
+
+// maxTTLCacheEntries bounds the memory used by a ttlCache, as the keys are
+// often derived from user input, such as presented tokens or user IDs
+const maxTTLCacheEntries = 10000
+
+// ttlCache is a size bounded cache whose entries expire after a fixed TTL.
+// It is safe for concurrent use and is shared by all requests to a provider.
+// A nil cache, or one with a TTL of zero or less, caches nothing.
+type ttlCache[V any] struct {
+	ttl time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]ttlCacheEntry[V]
+}
+
+type ttlCacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:     ttl,
+		entries: make(map[string]ttlCacheEntry[V]),
+	}
+}
+
+// get returns the cached value for the key, if it has not expired
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// set caches the value for the TTL, but never beyond notAfter when it is set.
+// When the cache is full and no entry has expired, the value is not cached.
+func (c *ttlCache[V]) set(key string, value V, notAfter *time.Time) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	expires := now.Add(c.ttl)
+	if notAfter != nil && notAfter.Before(expires) {
+		expires = *notAfter
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= maxTTLCacheEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxTTLCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = ttlCacheEntry[V]{value: value, expires: expires}
+}
+
+// take returns the cached value for the key, if it has not expired, and
+// removes it so that it is only returned once
+func (c *ttlCache[V]) take(key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	delete(c.entries, key)
+	if time.Now().After(entry.expires) {
+		return zero, false
+	}
+	return entry.value, true
+}
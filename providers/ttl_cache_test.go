@@ -0,0 +1,78 @@
+package providers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestTTLCache(t *testing.T) {
+	g := NewWithT(t)
+
+	cache := newTTLCache[string](time.Minute)
+	cache.set("key", "value", nil)
+	value, ok := cache.get("key")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(value).To(Equal("value"))
+
+	_, ok = cache.get("missing")
+	g.Expect(ok).To(BeFalse())
+
+	// Entries never outlive notAfter
+	expired := time.Now().Add(-time.Second)
+	cache.set("expired", "value", &expired)
+	_, ok = cache.get("expired")
+	g.Expect(ok).To(BeFalse())
+
+	// A cache without a TTL caches nothing
+	disabled := newTTLCache[string](0)
+	disabled.set("key", "value", nil)
+	_, ok = disabled.get("key")
+	g.Expect(ok).To(BeFalse())
+
+	var nilCache *ttlCache[string]
+	nilCache.set("key", "value", nil)
+	_, ok = nilCache.get("key")
+	g.Expect(ok).To(BeFalse())
+	_, ok = nilCache.take("key")
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestTTLCacheTake(t *testing.T) {
+	g := NewWithT(t)
+
+	cache := newTTLCache[string](time.Minute)
+	cache.set("key", "value", nil)
+	value, ok := cache.take("key")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(value).To(Equal("value"))
+
+	// Taken entries are only returned once
+	_, ok = cache.take("key")
+	g.Expect(ok).To(BeFalse())
+	_, ok = cache.get("key")
+	g.Expect(ok).To(BeFalse())
+
+	expired := time.Now().Add(-time.Second)
+	cache.set("expired", "value", &expired)
+	_, ok = cache.take("expired")
+	g.Expect(ok).To(BeFalse())
+	g.Expect(cache.entries).ToNot(HaveKey("expired"))
+}
+
+func TestTTLCacheMaxEntries(t *testing.T) {
+	g := NewWithT(t)
+
+	cache := newTTLCache[int](time.Minute)
+	for i := 0; i < maxTTLCacheEntries+1; i++ {
+		cache.set(string(rune(i)), i, nil)
+	}
+	g.Expect(cache.entries).To(HaveLen(maxTTLCacheEntries))
+
+	// Existing entries can still be updated when the cache is full
+	cache.set(string(rune(0)), -1, nil)
+	value, ok := cache.get(string(rune(0)))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(value).To(Equal(-1))
+}